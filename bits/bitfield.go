@@ -49,6 +49,25 @@ func (b *Bitfield) And(other Bitfield) {
 
 }
 
+func (b *Bitfield) Or(other *Bitfield) {
+	for i := range other {
+		b[i] |= other[i]
+	}
+}
+
+// clears bits that are set in other
+func (b *Bitfield) AndNot(other *Bitfield) {
+	for i := range other {
+		b[i] &^= other[i]
+	}
+}
+
+func (b *Bitfield) Not() {
+	for i := range b {
+		b[i] = ^b[i]
+	}
+}
+
 func (b *Bitfield) FromSorted(bits []uint16) {
 	arr := b[:] // removes bounds checks in indexing
 	if len(bits) == 0 {
@@ -160,3 +179,24 @@ func MergeAND(a Bitfield, b Bitfield) (out Bitfield) {
 	}
 	return
 }
+
+func MergeANDNOT(a Bitfield, b Bitfield) (out Bitfield) {
+	for i := range a {
+		out[i] = a[i] &^ b[i]
+	}
+	return
+}
+
+// clears all bits starting from given one
+func (b *Bitfield) ClearFrom(bit int) {
+	if bit >= len(b)*64 {
+		return
+	}
+
+	word := bit >> 6
+	b[word] &= (uint64(1) << (bit & 63)) - 1
+
+	for i := word + 1; i < len(b); i++ {
+		b[i] = 0
+	}
+}
@@ -15,3 +15,15 @@ func MapBytesToArray[T any](data []byte, count int) []T {
 	hdr := unsafe.Slice((*T)(unsafe.Pointer(&data[0])), count)
 	return hdr
 }
+
+// raw bytes of the array, no copy is made
+func ArrayToBytes[T any](data []T) []byte {
+	if len(data) == 0 {
+		return nil
+	}
+
+	var zero T
+	size := int(unsafe.Sizeof(zero))
+
+	return unsafe.Slice((*byte)(unsafe.Pointer(&data[0])), len(data)*size)
+}
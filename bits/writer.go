@@ -102,6 +102,12 @@ func (this *BitWriter) PutInt32(v int32) {
 	this.pos += 4
 }
 
+func (this *BitWriter) PutUint32(v uint32) {
+	this.tryGrow(4)
+	this.order.PutUint32(this.data[this.pos:], v)
+	this.pos += 4
+}
+
 func (this *BitWriter) PutUint64(v uint64) {
 	this.tryGrow(8)
 	this.order.PutUint64(this.data[this.pos:], v)
@@ -125,10 +131,13 @@ func (this *BitWriter) PutUint16(v uint16) {
 	this.pos += 2
 }
 
-func (this *BitWriter) WriteByte(u uint8) {
+// satisfies io.ByteWriter
+func (this *BitWriter) WriteByte(u byte) error {
 	this.tryGrow(1)
 	this.data[this.pos] = u
 	this.pos++
+
+	return nil
 }
 
 func (this *BitWriter) PutFloat64(f float64) {
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/dot5enko/simple-column-db/manager/meta"
+)
+
+// offline check of storage directory, database must not be running
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// exit code is 1 if storage has issues or can't be checked, 2 on bad flags
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+
+	flags := flag.NewFlagSet("fsck", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	storagePath := flags.String("storage", "./storage", "path to storage directory")
+	repair := flags.Bool("repair", false, "rebuild column slabs lists from slab headers")
+
+	if parseErr := flags.Parse(args); parseErr != nil {
+		return 2
+	}
+
+	report, checkErr := meta.CheckStorage(*storagePath, *repair)
+	if report != nil {
+		report.Print(stdout)
+	}
+
+	if checkErr != nil {
+		fmt.Fprintf(stderr, "storage check failed : %s\n", checkErr.Error())
+		return 1
+	}
+
+	if len(report.Issues) > 0 {
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dot5enko/simple-column-db/manager"
+	"github.com/dot5enko/simple-column-db/schema"
+)
+
+func TestRunExitCodes(t *testing.T) {
+
+	dir := t.TempDir()
+
+	m, newErr := manager.New(manager.ManagerConfig{PathToStorage: dir})
+	if newErr != nil {
+		t.Fatal(newErr)
+	}
+
+	createErr := m.CreateSchemaIfNotExists(schema.Schema{Name: "checked", Columns: []schema.SchemaColumn{
+		{Name: "x", Type: schema.Uint64FieldType},
+	}})
+	if createErr != nil {
+		t.Fatal(createErr)
+	}
+
+	binData := []byte{}
+	for _, x := range []uint64{1, 2, 3} {
+		binData = binary.LittleEndian.AppendUint64(binData, x)
+	}
+
+	if ingestErr := m.Ingest("checked", manager.IngestBufferFromBinary(binData, []string{"x"})); ingestErr != nil {
+		t.Fatal(ingestErr)
+	}
+
+	slabUid := m.Meta.GetSchema("checked").Columns[0].Slabs[0]
+
+	if closeErr := m.Close(context.Background()); closeErr != nil {
+		t.Fatal(closeErr)
+	}
+
+	var stdout, stderr bytes.Buffer
+
+	if code := run([]string{"-storage", dir}, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0 of clean storage, got %d : %s%s", code, stdout.String(), stderr.String())
+	}
+
+	if !strings.Contains(stdout.String(), "checked 1 schemas, 1 slabs : 0 issues") {
+		t.Errorf("unexpected report : %s", stdout.String())
+	}
+
+	if removeErr := os.Remove(filepath.Join(dir, "checked", slabUid.String()+".slab")); removeErr != nil {
+		t.Fatal(removeErr)
+	}
+
+	stdout.Reset()
+
+	if code := run([]string{"-storage", dir, "-repair"}, &stdout, &stderr); code != 1 {
+		t.Errorf("expected exit code 1 of missing slab, got %d", code)
+	}
+
+	if !strings.Contains(stdout.String(), slabUid.String()+" : slab file is missing") {
+		t.Errorf("expected missing slab in report : %s", stdout.String())
+	}
+
+	if code := run([]string{"-unknown"}, &stdout, &stderr); code != 2 {
+		t.Errorf("expected exit code 2 of unknown flag, got %d", code)
+	}
+
+	if code := run([]string{"-storage", filepath.Join(dir, "missing")}, &stdout, &stderr); code != 1 {
+		t.Errorf("expected exit code 1 of missing storage, got %d", code)
+	}
+}
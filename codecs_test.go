@@ -0,0 +1,97 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/dot5enko/simple-column-db/compression"
+	"github.com/dot5enko/simple-column-db/schema"
+)
+
+func TestCodecsRoundTrip(t *testing.T) {
+
+	size := 4096
+
+	timestamps := make([]uint64, size)
+	monitors := make([]uint64, size)
+	signed := make([]int64, size)
+	values := make([]float32, size)
+	doubles := make([]float64, size)
+
+	for i := range size {
+		timestamps[i] = 1_700_000_000 + uint64(i)*10 + uint64(rand.Intn(2))
+		monitors[i] = uint64(rand.Intn(8))
+		signed[i] = int64(rand.Intn(1000)) - 500
+		values[i] = 0.5 + float32(i%16)*0.25
+		doubles[i] = 20 + float64(i%100)*0.5
+	}
+
+	values[10] = float32(math.NaN())
+	out := make([]byte, size*8)
+
+	check := func(name string, codec schema.ColumnCodec, input any, decoded any, equal func() bool) {
+		clear(out)
+
+		encodedSize, encodeErr := compression.EncodeBlock(codec, input, out)
+		if encodeErr != nil {
+			t.Fatalf("%s: unable to encode: %s", name, encodeErr.Error())
+		}
+
+		decodeErr := compression.DecodeBlock(codec, out[:encodedSize], decoded, size)
+		if decodeErr != nil {
+			t.Fatalf("%s: unable to decode: %s", name, decodeErr.Error())
+		}
+
+		if !equal() {
+			t.Errorf("%s: decoded values differ", name)
+		}
+	}
+
+	decodedU64 := make([]uint64, size)
+	decodedI64 := make([]int64, size)
+	decodedF32 := make([]float32, size)
+	decodedF64 := make([]float64, size)
+
+	check("delta-of-delta", schema.CodecDeltaOfDelta, timestamps, decodedU64, func() bool { return equalSlices(timestamps, decodedU64) })
+	check("delta", schema.CodecDelta, timestamps, decodedU64, func() bool { return equalSlices(timestamps, decodedU64) })
+	check("frame-of-reference", schema.CodecFrameOfReference, monitors, decodedU64, func() bool { return equalSlices(monitors, decodedU64) })
+	check("signed frame-of-reference", schema.CodecFrameOfReference, signed, decodedI64, func() bool { return equalSlices(signed, decodedI64) })
+
+	// wraps around on differences
+	signed[20] = math.MinInt64
+	check("signed delta", schema.CodecDelta, signed, decodedI64, func() bool { return equalSlices(signed, decodedI64) })
+	check("xor float32", schema.CodecXor, values, decodedF32, func() bool {
+		for i := range values {
+			if math.Float32bits(values[i]) != math.Float32bits(decodedF32[i]) {
+				return false
+			}
+		}
+		return true
+	})
+	check("xor float64", schema.CodecXor, doubles, decodedF64, func() bool { return equalSlices(doubles, decodedF64) })
+}
+
+func TestCodecOverflow(t *testing.T) {
+
+	input := make([]uint64, 1024)
+	for i := range input {
+		input[i] = rand.Uint64()
+	}
+
+	out := make([]byte, len(input)*8)
+
+	_, encodeErr := compression.EncodeBlock(schema.CodecDelta, input, out)
+	if encodeErr != compression.ErrCodecOverflow {
+		t.Errorf("expected overflow on random values, got %v", encodeErr)
+	}
+}
+
+func equalSlices[T comparable](a, b []T) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
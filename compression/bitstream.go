@@ -0,0 +1,68 @@
+package compression
+
+// msb first bit writer over a fixed size zeroed buffer.
+// sets overflow instead of growing the buffer
+type bitWriter struct {
+	buf      []byte
+	pos      int
+	overflow bool
+}
+
+func (w *bitWriter) writeBits(v uint64, n int) {
+
+	if w.pos+n > len(w.buf)*8 {
+		w.overflow = true
+		return
+	}
+
+	for n > 0 {
+		free := 8 - w.pos&7
+		take := min(free, n)
+
+		chunk := byte(v>>(n-take)) & byte(1<<take-1)
+		w.buf[w.pos>>3] |= chunk << (free - take)
+
+		w.pos += take
+		n -= take
+	}
+}
+
+func (w *bitWriter) writeBit(bit bool) {
+	if bit {
+		w.writeBits(1, 1)
+	} else {
+		w.writeBits(0, 1)
+	}
+}
+
+// bytes used by written bits
+func (w *bitWriter) size() int {
+	return (w.pos + 7) >> 3
+}
+
+type bitReader struct {
+	buf []byte
+	pos int
+}
+
+func (r *bitReader) readBits(n int) (uint64, bool) {
+
+	if r.pos+n > len(r.buf)*8 {
+		return 0, false
+	}
+
+	var v uint64
+
+	for n > 0 {
+		avail := 8 - r.pos&7
+		take := min(avail, n)
+
+		chunk := (r.buf[r.pos>>3] >> (avail - take)) & byte(1<<take-1)
+		v = v<<take | uint64(chunk)
+
+		r.pos += take
+		n -= take
+	}
+
+	return v, true
+}
@@ -0,0 +1,435 @@
+package compression
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"unsafe"
+
+	"github.com/dot5enko/simple-column-db/ops"
+	"github.com/dot5enko/simple-column-db/schema"
+)
+
+// encoded values don't fit into the output buffer,
+// block should be stored as is
+var ErrCodecOverflow = errors.New("encoded block doesn't fit into output buffer")
+
+var errCodecTruncated = errors.New("encoded block is truncated")
+
+type Integers interface {
+	ops.UnsignedInts | ops.SignedInts
+}
+
+// encodes values into out, out is expected to be zeroed.
+// returns encoded size
+func EncodeBlock(codec schema.ColumnCodec, values any, out []byte) (int, error) {
+	switch typed := values.(type) {
+	case []uint8:
+		return encodeIntegersBlock(codec, typed, out)
+	case []uint16:
+		return encodeIntegersBlock(codec, typed, out)
+	case []uint32:
+		return encodeIntegersBlock(codec, typed, out)
+	case []uint64:
+		return encodeIntegersBlock(codec, typed, out)
+	case []int8:
+		return encodeIntegersBlock(codec, typed, out)
+	case []int16:
+		return encodeIntegersBlock(codec, typed, out)
+	case []int32:
+		return encodeIntegersBlock(codec, typed, out)
+	case []int64:
+		return encodeIntegersBlock(codec, typed, out)
+	case []float32:
+		return encodeFloatsBlock(codec, typed, out)
+	case []float64:
+		return encodeFloatsBlock(codec, typed, out)
+	default:
+		return 0, fmt.Errorf("unsupported type %T while encoding block", values)
+	}
+}
+
+// decodes count values of encoded block into out
+func DecodeBlock(codec schema.ColumnCodec, data []byte, out any, count int) error {
+	switch typed := out.(type) {
+	case []uint8:
+		return decodeIntegersBlock(codec, data, typed[:count])
+	case []uint16:
+		return decodeIntegersBlock(codec, data, typed[:count])
+	case []uint32:
+		return decodeIntegersBlock(codec, data, typed[:count])
+	case []uint64:
+		return decodeIntegersBlock(codec, data, typed[:count])
+	case []int8:
+		return decodeIntegersBlock(codec, data, typed[:count])
+	case []int16:
+		return decodeIntegersBlock(codec, data, typed[:count])
+	case []int32:
+		return decodeIntegersBlock(codec, data, typed[:count])
+	case []int64:
+		return decodeIntegersBlock(codec, data, typed[:count])
+	case []float32:
+		return decodeFloatsBlock(codec, data, typed[:count])
+	case []float64:
+		return decodeFloatsBlock(codec, data, typed[:count])
+	default:
+		return fmt.Errorf("unsupported type %T while decoding block", out)
+	}
+}
+
+func encodeIntegersBlock[T Integers](codec schema.ColumnCodec, values []T, out []byte) (int, error) {
+	switch codec {
+	case schema.CodecDelta:
+		return EncodeDelta(values, out)
+	case schema.CodecDeltaOfDelta:
+		return EncodeDeltaOfDelta(values, out)
+	case schema.CodecFrameOfReference:
+		return EncodeFrameOfReference(values, out)
+	default:
+		return 0, fmt.Errorf("codec %s is not supported for integers", codec.String())
+	}
+}
+
+func decodeIntegersBlock[T Integers](codec schema.ColumnCodec, data []byte, out []T) error {
+	switch codec {
+	case schema.CodecDelta:
+		return DecodeDelta(data, out)
+	case schema.CodecDeltaOfDelta:
+		return DecodeDeltaOfDelta(data, out)
+	case schema.CodecFrameOfReference:
+		return DecodeFrameOfReference(data, out)
+	default:
+		return fmt.Errorf("codec %s is not supported for integers", codec.String())
+	}
+}
+
+func encodeFloatsBlock[T ops.Floats](codec schema.ColumnCodec, values []T, out []byte) (int, error) {
+	switch codec {
+	case schema.CodecXor:
+		return EncodeXor(values, out)
+	default:
+		return 0, fmt.Errorf("codec %s is not supported for floats", codec.String())
+	}
+}
+
+func decodeFloatsBlock[T ops.Floats](codec schema.ColumnCodec, data []byte, out []T) error {
+	switch codec {
+	case schema.CodecXor:
+		return DecodeXor(data, out)
+	default:
+		return fmt.Errorf("codec %s is not supported for floats", codec.String())
+	}
+}
+
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
+
+func unzigzag(v uint64) int64 {
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+// varints writer, signed values are widened to 64 bits
+// so differences wrap around the same way for all integer types
+type varintWriter struct {
+	buf []byte
+	pos int
+}
+
+func (w *varintWriter) put(v uint64) bool {
+
+	if w.pos+binary.MaxVarintLen64 <= len(w.buf) {
+		w.pos += binary.PutUvarint(w.buf[w.pos:], v)
+		return true
+	}
+
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	if w.pos+n > len(w.buf) {
+		return false
+	}
+
+	w.pos += copy(w.buf[w.pos:], tmp[:n])
+	return true
+}
+
+type varintReader struct {
+	buf []byte
+	pos int
+}
+
+func (r *varintReader) next() (uint64, bool) {
+	v, n := binary.Uvarint(r.buf[r.pos:])
+	if n <= 0 {
+		return 0, false
+	}
+	r.pos += n
+	return v, true
+}
+
+// first value followed by zigzag varints of consecutive differences
+func EncodeDelta[T Integers](values []T, out []byte) (int, error) {
+
+	w := varintWriter{buf: out}
+	var prev uint64
+
+	for _, v := range values {
+		cur := uint64(v)
+		if !w.put(zigzag(int64(cur - prev))) {
+			return 0, ErrCodecOverflow
+		}
+		prev = cur
+	}
+
+	return w.pos, nil
+}
+
+func DecodeDelta[T Integers](data []byte, out []T) error {
+
+	r := varintReader{buf: data}
+	var prev uint64
+
+	for i := range out {
+		delta, ok := r.next()
+		if !ok {
+			return errCodecTruncated
+		}
+		prev += uint64(unzigzag(delta))
+		out[i] = T(prev)
+	}
+
+	return nil
+}
+
+// first value and first delta followed by zigzag varints of delta changes,
+// regular timestamps take a single byte per value
+func EncodeDeltaOfDelta[T Integers](values []T, out []byte) (int, error) {
+
+	w := varintWriter{buf: out}
+	var prev, prevDelta uint64
+
+	for _, v := range values {
+		cur := uint64(v)
+		delta := cur - prev
+
+		if !w.put(zigzag(int64(delta - prevDelta))) {
+			return 0, ErrCodecOverflow
+		}
+
+		prev, prevDelta = cur, delta
+	}
+
+	return w.pos, nil
+}
+
+func DecodeDeltaOfDelta[T Integers](data []byte, out []T) error {
+
+	r := varintReader{buf: data}
+	var prev, prevDelta uint64
+
+	for i := range out {
+		deltaOfDelta, ok := r.next()
+		if !ok {
+			return errCodecTruncated
+		}
+
+		prevDelta += uint64(unzigzag(deltaOfDelta))
+		prev += prevDelta
+		out[i] = T(prev)
+	}
+
+	return nil
+}
+
+// block minimum (8 bytes), bit width (1 byte), then offsets from minimum packed with that width
+func EncodeFrameOfReference[T Integers](values []T, out []byte) (int, error) {
+
+	if len(out) < 9 {
+		return 0, ErrCodecOverflow
+	}
+
+	if len(values) == 0 {
+		return 9, nil
+	}
+
+	minVal, maxVal := values[0], values[0]
+	for _, v := range values[1:] {
+		minVal = min(minVal, v)
+		maxVal = max(maxVal, v)
+	}
+
+	base := uint64(minVal)
+	width := bits.Len64(uint64(maxVal) - base)
+
+	binary.LittleEndian.PutUint64(out, base)
+	out[8] = byte(width)
+
+	w := bitWriter{buf: out[9:]}
+	for _, v := range values {
+		w.writeBits(uint64(v)-base, width)
+	}
+
+	if w.overflow {
+		return 0, ErrCodecOverflow
+	}
+
+	return 9 + w.size(), nil
+}
+
+func DecodeFrameOfReference[T Integers](data []byte, out []T) error {
+
+	if len(data) < 9 {
+		return errCodecTruncated
+	}
+
+	base := binary.LittleEndian.Uint64(data)
+	width := int(data[8])
+
+	r := bitReader{buf: data[9:]}
+	for i := range out {
+		offset, ok := r.readBits(width)
+		if !ok {
+			return errCodecTruncated
+		}
+		out[i] = T(base + offset)
+	}
+
+	return nil
+}
+
+func floatWidth[T ops.Floats]() int {
+	var zero T
+	return int(unsafe.Sizeof(zero)) * 8
+}
+
+func floatToBits[T ops.Floats](v T, width int) uint64 {
+	if width == 32 {
+		return uint64(math.Float32bits(float32(v)))
+	}
+	return math.Float64bits(float64(v))
+}
+
+func floatFromBits[T ops.Floats](v uint64, width int) T {
+	if width == 32 {
+		return T(math.Float32frombits(uint32(v)))
+	}
+	return T(math.Float64frombits(v))
+}
+
+// gorilla encoding: first value as is, then xor with previous value.
+// zero xor takes a single bit, otherwise meaningful bits are stored
+// within previous leading/trailing zeros window or with a new window
+func EncodeXor[T ops.Floats](values []T, out []byte) (int, error) {
+
+	width := floatWidth[T]()
+	w := bitWriter{buf: out}
+
+	if len(values) == 0 {
+		return 0, nil
+	}
+
+	prev := floatToBits(values[0], width)
+	w.writeBits(prev, width)
+
+	prevLeading, prevTrailing := -1, 0
+
+	for _, v := range values[1:] {
+
+		cur := floatToBits(v, width)
+		xor := cur ^ prev
+		prev = cur
+
+		if xor == 0 {
+			w.writeBit(false)
+			continue
+		}
+
+		w.writeBit(true)
+
+		leading := min(bits.LeadingZeros64(xor)-(64-width), 63)
+		trailing := bits.TrailingZeros64(xor)
+
+		if prevLeading >= 0 && leading >= prevLeading && trailing >= prevTrailing {
+			w.writeBit(false)
+			w.writeBits(xor>>prevTrailing, width-prevLeading-prevTrailing)
+		} else {
+			meaningful := width - leading - trailing
+
+			w.writeBit(true)
+			w.writeBits(uint64(leading), 6)
+			w.writeBits(uint64(meaningful-1), 6)
+			w.writeBits(xor>>trailing, meaningful)
+
+			prevLeading, prevTrailing = leading, trailing
+		}
+
+		if w.overflow {
+			return 0, ErrCodecOverflow
+		}
+	}
+
+	if w.overflow {
+		return 0, ErrCodecOverflow
+	}
+
+	return w.size(), nil
+}
+
+func DecodeXor[T ops.Floats](data []byte, out []T) error {
+
+	if len(out) == 0 {
+		return nil
+	}
+
+	width := floatWidth[T]()
+	r := bitReader{buf: data}
+
+	prev, ok := r.readBits(width)
+	if !ok {
+		return errCodecTruncated
+	}
+
+	out[0] = floatFromBits[T](prev, width)
+
+	leading, trailing := 0, 0
+
+	for i := 1; i < len(out); i++ {
+
+		changed, ok := r.readBits(1)
+		if !ok {
+			return errCodecTruncated
+		}
+
+		if changed == 1 {
+
+			newWindow, ok := r.readBits(1)
+			if !ok {
+				return errCodecTruncated
+			}
+
+			if newWindow == 1 {
+				leadingRaw, leadingOk := r.readBits(6)
+				meaningfulRaw, meaningfulOk := r.readBits(6)
+				if !leadingOk || !meaningfulOk {
+					return errCodecTruncated
+				}
+
+				leading = int(leadingRaw)
+				trailing = width - leading - int(meaningfulRaw) - 1
+			}
+
+			xor, ok := r.readBits(width - leading - trailing)
+			if !ok {
+				return errCodecTruncated
+			}
+
+			prev ^= xor << trailing
+		}
+
+		out[i] = floatFromBits[T](prev, width)
+	}
+
+	return nil
+}
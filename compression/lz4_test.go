@@ -0,0 +1,51 @@
+package compression
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"testing"
+)
+
+func TestLz4RoundTrip(t *testing.T) {
+
+	// raw block with zeroed tail, as finalized slabs are stored
+	src := make([]byte, 64*1024)
+	for i := range 4096 {
+		binary.LittleEndian.PutUint64(src[i*8:], 1_700_000_000+uint64(i)*10+uint64(rand.Intn(3)))
+	}
+
+	compressed := make([]byte, len(src))
+
+	compressedSize, compressErr := CompressLz4(src, compressed)
+	if compressErr != nil {
+		t.Fatalf("unable to compress : %s", compressErr.Error())
+	}
+
+	if compressedSize == 0 || compressedSize >= len(src) {
+		t.Fatalf("expected data to shrink, compressed size %d of %d", compressedSize, len(src))
+	}
+
+	decompressed := make([]byte, len(src))
+
+	decompressedSize, decompressErr := DecompressLz4(compressed[:compressedSize], decompressed)
+	if decompressErr != nil {
+		t.Fatalf("unable to decompress : %s", decompressErr.Error())
+	}
+
+	if decompressedSize != len(src) || !bytes.Equal(src, decompressed) {
+		t.Errorf("decompressed data differs, %d bytes of %d", decompressedSize, len(src))
+	}
+}
+
+// zero size means data doesn't shrink, it's stored uncompressed then
+func TestLz4IncompressibleData(t *testing.T) {
+
+	src := make([]byte, 16*1024)
+	rand.Read(src)
+
+	compressedSize, compressErr := CompressLz4(src, make([]byte, len(src)))
+	if compressErr == nil && compressedSize > 0 && compressedSize < len(src) {
+		t.Errorf("random data is compressed to %d of %d bytes", compressedSize, len(src))
+	}
+}
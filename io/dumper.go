@@ -31,9 +31,15 @@ func DumpNumbersArrayBlockAny(writer io.Writer, arr any) (int, error) {
 
 	case []uint8:
 		_, err := writer.Write(v)
-		return 0, err
+		return len(v), err
 	case []int8:
 		return DumpNumbersArrayBlock[int8](writer, v)
+	case []int16:
+		return DumpNumbersArrayBlock[int16](writer, v)
+	case []int32:
+		return DumpNumbersArrayBlock[int32](writer, v)
+	case []int64:
+		return DumpNumbersArrayBlock[int64](writer, v)
 	case []uint16:
 		return DumpNumbersArrayBlock[uint16](writer, v)
 	case []uint32:
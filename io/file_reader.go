@@ -75,8 +75,8 @@ func (f *FileReader) WriteAt(in []byte, off, length int) (err error) {
 	}
 
 	var writtenBytes int
-	writtenBytes, err = f.file.WriteAt(in, int64(off))
-	if writtenBytes != len(in) {
+	writtenBytes, err = f.file.WriteAt(in[:length], int64(off))
+	if writtenBytes != length {
 		err = errors.New("written bytes mismatch")
 		return err
 	}
@@ -45,18 +45,19 @@ func (i *IndiceUnmerged) Merges() int {
 	return i.merges
 }
 
-// func (i *IndiceUnmerged) WithOtherBitset(other bits.Bitfield) {
+func (i *IndiceUnmerged) WithOtherBitset(other *bits.Bitfield) {
 
-// 	if !i.initialized {
-// 		i.initialized = true
+	i.merges += 1
 
-// 		i.ResultBitset = other
-// 		return
-// 	}
+	if !i.initialized {
+		i.initialized = true
 
-// 	i.ResultBitset = bits.MergeAND(i.ResultBitset, other)
+		i.ResultBitset = *other
+		return
+	}
 
-// }
+	i.ResultBitset.And(*other)
+}
 
 func (i *IndiceUnmerged) With(input []uint16, isEmpty, isFull bool) {
 
@@ -2,7 +2,7 @@ package main
 
 import (
 	"context"
-	"encoding/binary"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
@@ -105,11 +105,15 @@ func main() {
 		}()
 	}
 
-	m := manager.New(manager.ManagerConfig{
+	m, managerErr := manager.New(manager.ManagerConfig{
 		PathToStorage: "./storage",
 		CacheMaxBytes: 0,
 	})
 
+	if managerErr != nil {
+		panic(managerErr)
+	}
+
 	testSchemaName := "health_cheks_"
 	//+ uuid.NewString()[:5]
 
@@ -238,6 +242,11 @@ func main() {
 
 	}
 
+	closeErr := m.Close(context.Background())
+	if closeErr != nil {
+		panic(closeErr)
+	}
+
 	cancelWorkers()
 
 }
@@ -247,8 +256,14 @@ func ingest_data_into_simple_metric_value(m *manager.Manager, testSchemaName str
 	fields := []string{"created_at", "value", "monitor_id"}
 	testRows := dataSize
 
-	binWriter := bits.NewEncodeBuffer([]byte{}, binary.LittleEndian)
-	binWriter.EnableGrowing()
+	buffer, bufferErr := manager.NewIngestBuffer(m.Meta.GetSchema(testSchemaName), fields)
+	if bufferErr != nil {
+		panic(bufferErr)
+	}
+
+	createdAt := make([]uint64, testRows)
+	values := make([]float32, testRows)
+	monitorIds := make([]uint64, testRows)
 
 	frameStart := time.Hour * 24 * 30 * 12 * 5
 	startTime := time.Now().Add(-frameStart).Unix()
@@ -258,17 +273,23 @@ func ingest_data_into_simple_metric_value(m *manager.Manager, testSchemaName str
 		monitorId := rand.Int63n(int64(monitors))
 
 		timeOffset := uint64(i * 60)
-		timeVal := uint64(startTime) + timeOffset
-		randVal := 0.5 + rand.Float32()*(0.8-0.5)
+		createdAt[i] = uint64(startTime) + timeOffset
+		values[i] = 0.5 + rand.Float32()*(0.8-0.5)
+		monitorIds[i] = uint64(monitorId)
 
-		binWriter.PutUint64(timeVal)
-		binWriter.PutFloat32(randVal)
-		binWriter.PutUint64(uint64(monitorId))
+	}
 
+	appendErr := errors.Join(
+		manager.AppendColumn(buffer, "created_at", createdAt),
+		manager.AppendColumn(buffer, "value", values),
+		manager.AppendColumn(buffer, "monitor_id", monitorIds),
+	)
+	if appendErr != nil {
+		panic(appendErr)
 	}
 
 	before := time.Now()
-	ingestErr := m.Ingest(testSchemaName, manager.IngestBufferFromBinary(binWriter.Bytes(), fields))
+	ingestErr := m.Ingest(testSchemaName, buffer)
 	after := time.Since(before)
 
 	log.Printf("ingested %d rows in %.2f ms", testRows, after.Seconds()*1000)
@@ -1,9 +1,14 @@
 package cache
 
+import "sync"
+
 type FixedSizeBufferPool struct {
 	buffers [][]byte
 	free    chan uint16
 
+	// one pair is taken at a time, otherwise callers could each hold one buffer and wait for the second forever
+	pairLock sync.Mutex
+
 	arena   []byte
 	bufSize int
 }
@@ -39,3 +44,15 @@ func (p *FixedSizeBufferPool) Get() ([]byte, uint16) {
 func (p *FixedSizeBufferPool) Return(id uint16) {
 	p.free <- id
 }
+
+// two buffers for a caller that needs both at the same time,
+// callers taking a single buffer never wait while holding it, so the second one is freed eventually
+func (p *FixedSizeBufferPool) GetPair() (first []byte, firstId uint16, second []byte, secondId uint16) {
+	p.pairLock.Lock()
+	defer p.pairLock.Unlock()
+
+	first, firstId = p.Get()
+	second, secondId = p.Get()
+
+	return
+}
@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// callers taking pairs next to callers taking single buffers from a pool
+// that fits only two pairs finish instead of holding one buffer each
+func TestBufferPoolPairsDontDeadlock(t *testing.T) {
+
+	pool := NewFixedSizeBufferPool(4, 16)
+
+	waiter := sync.WaitGroup{}
+
+	for worker := range 8 {
+		waiter.Add(1)
+		go func() {
+			defer waiter.Done()
+
+			for range 1000 {
+				if worker%2 == 0 {
+					_, firstId, _, secondId := pool.GetPair()
+					pool.Return(firstId)
+					pool.Return(secondId)
+				} else {
+					_, id := pool.Get()
+					pool.Return(id)
+				}
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		waiter.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("buffer pool deadlocked")
+	}
+
+	if len(pool.free) != 4 {
+		t.Errorf("expected 4 free buffers, got %d", len(pool.free))
+	}
+}
+
+func TestBufferPoolPairIsDistinct(t *testing.T) {
+
+	pool := NewFixedSizeBufferPool(2, 16)
+
+	first, firstId, second, secondId := pool.GetPair()
+	if firstId == secondId {
+		t.Fatalf("same buffer %d returned twice", firstId)
+	}
+
+	first[0] = 1
+	if second[0] != 0 {
+		t.Errorf("buffers of a pair overlap")
+	}
+}
@@ -1,8 +1,6 @@
 package cache
 
 import (
-	"time"
-
 	"github.com/dot5enko/simple-column-db/schema"
 )
 
@@ -20,12 +18,9 @@ func (item *SlabCacheItem) Reset() {
 	item.Header = nil
 
 	if item.RtStats != nil {
-		item.RtStats.Reads = 0
-		item.RtStats.Created = time.Now()
+		item.RtStats.Reset()
 	} else {
-		item.RtStats = &CacheStats{
-			Created: time.Now(),
-		}
+		item.RtStats = NewCacheStats()
 	}
 }
 
@@ -39,11 +34,8 @@ type SlabDataCacheItem struct {
 func (item *SlabDataCacheItem) Reset() {
 
 	if item.RtStats != nil {
-		item.RtStats.Reads = 0
-		item.RtStats.Created = time.Now()
+		item.RtStats.Reset()
 	} else {
-		item.RtStats = &CacheStats{
-			Created: time.Now(),
-		}
+		item.RtStats = NewCacheStats()
 	}
 }
@@ -1,10 +1,40 @@
 package cache
 
-import "time"
+import (
+	"sync/atomic"
+	"time"
+)
 
+// stats are shared by cached items of a slab and updated by concurrent readers
 type CacheStats struct {
 	CacheEntryId uint16
 
-	Reads   int
+	Reads   atomic.Int64
 	Created time.Time
+
+	// unix nanoseconds of the last read
+	lastRead atomic.Int64
+}
+
+func NewCacheStats() *CacheStats {
+	return &CacheStats{Created: time.Now()}
+}
+
+func (s *CacheStats) Touch() {
+	s.Reads.Add(1)
+	s.lastRead.Store(time.Now().UnixNano())
+}
+
+// time of the last read, creation time if there were no reads
+func (s *CacheStats) LastUsed() time.Time {
+	if s.Reads.Load() == 0 {
+		return s.Created
+	}
+	return time.Unix(0, s.lastRead.Load())
+}
+
+func (s *CacheStats) Reset() {
+	s.Reads.Store(0)
+	s.lastRead.Store(0)
+	s.Created = time.Now()
 }
@@ -0,0 +1,115 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var ErrManagerClosed = errors.New("manager is closed")
+
+// registers a query or an ingest, rejected once Close is called
+func (m *Manager) beginOperation() error {
+
+	m.lifecycleLock.Lock()
+	defer m.lifecycleLock.Unlock()
+
+	if m.closed {
+		return ErrManagerClosed
+	}
+
+	m.operations.Add(1)
+
+	return nil
+}
+
+func (m *Manager) endOperation() {
+	m.operations.Done()
+}
+
+// stops accepting queries and ingests, waits for the ones in flight and for workers,
+// syncs active slabs and schemas to disk and releases the log and caches.
+//
+// when ctx is done before queries, ingests or workers finish, ctx error is returned
+// and nothing is flushed, Close may be called again to finish the shutdown
+func (m *Manager) Close(ctx context.Context) error {
+
+	m.closeLock.Lock()
+	defer m.closeLock.Unlock()
+
+	m.walsLock.Lock()
+	walsClosed := m.wals == nil
+	m.walsLock.Unlock()
+
+	if walsClosed {
+		return ErrManagerClosed
+	}
+
+	m.lifecycleLock.Lock()
+	m.closed = true
+	workers := m.workers
+	m.lifecycleLock.Unlock()
+
+	waitErr := waitGroupContext(&m.operations, ctx)
+	if waitErr != nil {
+		return waitErr
+	}
+
+	// nothing enqueues chunks anymore, workers take what is left and stop
+	if !m.queueClosed {
+		close(m.chunksQueue)
+		m.queueClosed = true
+	}
+
+	for _, it := range workers {
+		waitErr = waitGroupContext(it, ctx)
+		if waitErr != nil {
+			return waitErr
+		}
+	}
+
+	for _, schemaObject := range m.Meta.Schemas() {
+
+		for _, column := range schemaObject.Columns {
+			syncErr := m.Slabs.SyncSlabFiles(*schemaObject, column.ActiveSlab)
+			if syncErr != nil {
+				return fmt.Errorf("unable to flush `%s` : %s", schemaObject.Name, syncErr.Error())
+			}
+		}
+
+		storeErr := m.Meta.StoreSchemeToDisk(*schemaObject)
+		if storeErr != nil {
+			return fmt.Errorf("unable to flush schema `%s` : %s", schemaObject.Name, storeErr.Error())
+		}
+	}
+
+	// logs are truncated by applied ingests,
+	// batch that failed to apply is kept for replay on the next start
+	closeErr := m.closeWals()
+	if closeErr != nil {
+		return closeErr
+	}
+
+	m.Slabs.DropCaches()
+
+	return nil
+}
+
+// goroutine waiting for wg outlives a done ctx until wg is done,
+// so every timed out Close leaves one behind per group it waited for
+func waitGroupContext(wg *sync.WaitGroup, ctx context.Context) error {
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
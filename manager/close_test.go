@@ -0,0 +1,110 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dot5enko/simple-column-db/manager/query"
+)
+
+func TestClosedManagerRejectsOperations(t *testing.T) {
+
+	m := openTestManager(t, t.TempDir(), testSchema("checks"))
+
+	if ingestErr := m.Ingest("checks", testRows(1000, 1)); ingestErr != nil {
+		t.Fatal(ingestErr)
+	}
+
+	closeTestManager(t, m)
+
+	_, queryErr := m.Query("checks", query.Query{Select: []query.Selector{{Arguments: []any{"count"}}}}, context.Background())
+	if !errors.Is(queryErr, ErrManagerClosed) {
+		t.Errorf("expected query to fail with ErrManagerClosed, got %v", queryErr)
+	}
+
+	if ingestErr := m.Ingest("checks", IngestBufferFromBinary(nil, []string{"x", "y"})); !errors.Is(ingestErr, ErrManagerClosed) {
+		t.Errorf("expected ingest to fail with ErrManagerClosed, got %v", ingestErr)
+	}
+
+	if createErr := m.CreateSchemaIfNotExists(testSchema("other")); !errors.Is(createErr, ErrManagerClosed) {
+		t.Errorf("expected schema creation to fail with ErrManagerClosed, got %v", createErr)
+	}
+
+	if closeErr := m.Close(context.Background()); !errors.Is(closeErr, ErrManagerClosed) {
+		t.Errorf("expected second close to fail with ErrManagerClosed, got %v", closeErr)
+	}
+
+	returnsInTime(t, "workers started after close", m.StartWorkers(2, context.Background()).Wait)
+}
+
+// workers started while closing are either waited for by close or not started at all
+func TestCloseWhileStartingWorkers(t *testing.T) {
+
+	m := openTestManager(t, t.TempDir(), testSchema("checks"))
+
+	started := make(chan *sync.WaitGroup)
+	go func() {
+		started <- m.StartWorkers(2, context.Background())
+	}()
+
+	closeTestManager(t, m)
+
+	workers := <-started
+	returnsInTime(t, "workers started while closing", workers.Wait)
+}
+
+// close waits for operations in flight, giving up when ctx is done
+func TestCloseWaitsForOperations(t *testing.T) {
+
+	dir := t.TempDir()
+	m := openTestManager(t, dir, testSchema("checks"))
+
+	if ingestErr := m.Ingest("checks", testRows(1000, 1)); ingestErr != nil {
+		t.Fatal(ingestErr)
+	}
+
+	// an operation that doesn't end until it's told to
+	if operationErr := m.beginOperation(); operationErr != nil {
+		t.Fatal(operationErr)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if closeErr := m.Close(ctx); !errors.Is(closeErr, context.DeadlineExceeded) {
+		t.Fatalf("expected close to give up with deadline exceeded, got %v", closeErr)
+	}
+
+	// manager being closed doesn't take new operations
+	if ingestErr := m.Ingest("checks", testRows(1000, 1)); !errors.Is(ingestErr, ErrManagerClosed) {
+		t.Errorf("expected ingest to fail with ErrManagerClosed, got %v", ingestErr)
+	}
+
+	closed := sync.WaitGroup{}
+	closed.Add(1)
+
+	var closeErr error
+	go func() {
+		defer closed.Done()
+		closeErr = m.Close(context.Background())
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	m.endOperation()
+
+	returnsInTime(t, "close", closed.Wait)
+
+	if closeErr != nil {
+		t.Fatalf("unable to close : %s", closeErr.Error())
+	}
+
+	m = openTestManager(t, dir)
+	defer closeTestManager(t, m)
+
+	if count, sum := testCountSum(t, m, "checks"); count != 1000 || sum != 1000 {
+		t.Errorf("expected 1000 rows with sum 1000 after close, got %d rows with sum %f", count, sum)
+	}
+}
@@ -5,5 +5,12 @@ import (
 )
 
 func (sm *Manager) CreateSchemaIfNotExists(schemaConfig schema.Schema) error {
+
+	operationErr := sm.beginOperation()
+	if operationErr != nil {
+		return operationErr
+	}
+	defer sm.endOperation()
+
 	return sm.Slabs.CreateSchema(schemaConfig)
 }
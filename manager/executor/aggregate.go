@@ -0,0 +1,154 @@
+package executor
+
+import (
+	"fmt"
+
+	executortypes "github.com/dot5enko/simple-column-db/manager/executor/executor_types"
+	"github.com/dot5enko/simple-column-db/manager/executor/kernels"
+	"github.com/dot5enko/simple-column-db/manager/meta"
+	"github.com/dot5enko/simple-column-db/manager/query"
+	"github.com/dot5enko/simple-column-db/schema"
+)
+
+func NewAggregateStates(size int) []query.AggregateState {
+	states := make([]query.AggregateState, size)
+	for i := range states {
+		states[i] = query.NewAggregateState()
+	}
+	return states
+}
+
+// loads blocks of columns used by selectors into thread cache
+// returns amount of blocks in the chunk
+//
+// when there are no columns to load, only block headers of the first column are read,
+// so the amount of rows in each block is known
+func loadSelectedColumnBlocks(
+	cache *executortypes.ChunkExecutorThreadCache,
+	sm *meta.SlabManager,
+	plan *query.QueryPlan,
+	blockChunk *query.BlockChunk,
+) (int, error) {
+
+	cache.EnsureColumns(len(plan.Schema.Columns))
+
+	columns := plan.SelectColumns
+	headersOnly := false
+
+	if len(columns) == 0 {
+		columns = []int{0}
+		headersOnly = true
+	}
+
+	blocksInChunk := 0
+
+	for _, columnIdx := range columns {
+
+		relIdx := 0
+
+		iterErr := forEachSegmentBlock(sm, &plan.Schema, blockChunk.ChunkSegmentsByFieldIndexMap[columnIdx], func(slabInfo *schema.DiskSlabHeader, blockIdx int, blockHeader *schema.DiskHeader) error {
+
+			cache.BlockAbsIdx[relIdx] = slabInfo.SlabOffsetBlocks + uint64(blockIdx)
+
+			blockRT := &cache.Blocks[relIdx]
+			if blockRT.BlockHeader == nil {
+				blockRT.BlockHeader = blockHeader
+			}
+
+			// may be loaded by expression filters already
+			if !headersOnly && cache.ColumnBlocks[columnIdx][relIdx] == nil {
+				blockData, blockErr := sm.LoadBlockToRuntimeBlockData(plan.Schema, slabInfo, blockHeader.Uid)
+				if blockErr != nil {
+					return fmt.Errorf("unable to decode block : %w", blockErr)
+				}
+
+				cache.ColumnBlocks[columnIdx][relIdx] = blockData
+			}
+
+			relIdx++
+
+			return nil
+		})
+
+		if iterErr != nil {
+			return 0, fmt.Errorf("unable to load selected column `%s` : %w", plan.Schema.Columns[columnIdx].Name, iterErr)
+		}
+
+		blocksInChunk = relIdx
+	}
+
+	return blocksInChunk, nil
+}
+
+// calculates aggregates over selected rows of a single block
+func aggregateBlock(
+	cache *executortypes.ChunkExecutorThreadCache,
+	plan *query.QueryPlan,
+	relIdx int,
+	indices []uint16,
+	states []query.AggregateState,
+) error {
+
+	for aggIdx, aggregate := range plan.Aggregates {
+
+		state := &states[aggIdx]
+
+		// count of a column skips its nulls only
+		if aggregate.Function == query.AggCount && (aggregate.ColumnIdx < 0 || !aggregate.ColumnSchemaInfo.Nullable) {
+			state.Count += len(indices)
+			continue
+		}
+
+		blockData := cache.ColumnBlocks[aggregate.ColumnIdx][relIdx]
+		if blockData == nil {
+			return fmt.Errorf("block %d of column `%s` is not loaded", relIdx, aggregate.ColumnSchemaInfo.Name)
+		}
+
+		valuesIndices := nonNullIndices(blockData, indices, cache.NonNullIndicesCache[:])
+
+		if aggregate.Function == query.AggCount {
+			state.Count += len(valuesIndices)
+			continue
+		}
+
+		if len(valuesIndices) == 0 {
+			continue
+		}
+
+		typeKernels, typeErr := kernels.Of(blockData.Header.DataType)
+		if typeErr != nil {
+			return fmt.Errorf("unable to aggregate : %s", typeErr.Error())
+		}
+
+		directBlockArray, _ := blockData.DirectAccess()
+		sum, minVal, maxVal := typeKernels.Aggregate(directBlockArray, valuesIndices)
+
+		state.Merge(query.AggregateState{
+			Count: len(valuesIndices),
+			Sum:   sum,
+			Min:   minVal,
+			Max:   maxVal,
+		})
+	}
+
+	return nil
+}
+
+// selected rows having value in the column,
+// indices are returned as is when block has no nulls
+func nonNullIndices(blockData *schema.RuntimeBlockData, indices []uint16, out []uint16) []uint16 {
+
+	if !blockData.HasNulls() {
+		return indices
+	}
+
+	validity := blockData.ValidityView()
+
+	filled := 0
+	for _, idx := range indices {
+		out[filled] = idx
+		filled += int(validity.Get(int(idx)))
+	}
+
+	return out[:filled]
+}
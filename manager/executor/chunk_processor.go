@@ -8,6 +8,7 @@ import (
 	executortypes "github.com/dot5enko/simple-column-db/manager/executor/executor_types"
 	"github.com/dot5enko/simple-column-db/manager/meta"
 	"github.com/dot5enko/simple-column-db/manager/query"
+	"github.com/dot5enko/simple-column-db/schema"
 )
 
 type ChunkFilterProcessResult struct {
@@ -18,6 +19,15 @@ type ChunkFilterProcessResult struct {
 	TotalItems   int
 	WastedMerges int
 
+	// partial aggregates, in the order of plan aggregates
+	Aggregates []query.AggregateState
+
+	// partial group by aggregates, nil if query has no grouping
+	Groups *query.GroupTable
+
+	// matched rows of the chunk, nil if query has no column selectors
+	Projection *query.Projection
+
 	LockTook           time.Duration
 	PlanTook           time.Duration
 	PureLock           time.Duration
@@ -36,7 +46,7 @@ func preloadSlabHeaders(slabs *meta.SlabManager, plan *query.QueryPlan, blockChu
 		for _, segment := range blockSegments {
 			_, err := slabs.LoadSlabHeaderToCache(&schemaObject, segment.Slab)
 			if err != nil {
-				return fmt.Errorf("unable to load slab : %s", err.Error())
+				return fmt.Errorf("unable to load slab : %w", err)
 			}
 		}
 	}
@@ -49,9 +59,61 @@ func preloadSlabHeaders(slabs *meta.SlabManager, plan *query.QueryPlan, blockChu
 	return nil
 }
 
+// slabs of the chunk are kept in cache while it's executed
+func pinChunkSlabs(sm *meta.SlabManager, blockChunk *query.BlockChunk) func() {
+
+	for _, segments := range blockChunk.ChunkSegmentsByFieldIndexMap {
+		for _, segment := range segments {
+			sm.PinSlab(segment.Slab)
+		}
+	}
+
+	return func() {
+		for _, segments := range blockChunk.ChunkSegmentsByFieldIndexMap {
+			for _, segment := range segments {
+				sm.UnpinSlab(segment.Slab)
+			}
+		}
+	}
+}
+
+// rows visible to the query in each relative block of the chunk,
+// rows ingested after the query was planned are never scanned
+func fillCommittedBlockRows(cache *executortypes.ChunkExecutorThreadCache, plan *query.QueryPlan, blockChunk *query.BlockChunk) {
+
+	for _, segments := range blockChunk.ChunkSegmentsByFieldIndexMap {
+		if len(segments) == 0 {
+			continue
+		}
+
+		relIdx := 0
+		for _, segment := range segments {
+			for i := range segment.Size {
+				if relIdx >= query.ExecutorChunkSizeBlocks {
+					return
+				}
+
+				absRow := (segment.AbsBlock + uint64(i)) * schema.BlockRowsSize
+				if absRow < plan.Rows {
+					cache.BlockRows[relIdx] = int(min(plan.Rows-absRow, schema.BlockRowsSize))
+				}
+
+				relIdx++
+			}
+		}
+
+		// every column has the same block layout
+		return
+	}
+}
+
 func ExecutePlanForChunk(cache *executortypes.ChunkExecutorThreadCache, sm *meta.SlabManager, plan *query.QueryPlan, blockChunk *query.BlockChunk) (ChunkFilterProcessResult, error) {
 
 	cache.Reset()
+	fillCommittedBlockRows(cache, plan, blockChunk)
+
+	unpin := pinChunkSlabs(sm, blockChunk)
+	defer unpin()
 
 	// preload all slabs that are in the chunk
 	// preloadErr := preloadChunks(sm, plan, blockChunk)
@@ -66,63 +128,216 @@ func ExecutePlanForChunk(cache *executortypes.ChunkExecutorThreadCache, sm *meta
 	// than chunk process parallelization
 
 	result := ChunkFilterProcessResult{}
+	blocksInChunk := 0
 
-	for _, filtersGroup := range plan.FilterGroupedByFields {
+	mergerContexts := make([]BlockMergerContext, len(plan.FilterGroupedByFields))
+
+	// match all filter conditions against block headers first,
+	// so filter tree can skip blocks before any data is loaded
+	for groupIdx, filtersGroup := range plan.FilterGroupedByFields {
 
 		blockSegments := blockChunk.ChunkSegmentsByFieldIndexMap[filtersGroup.ColumnIdx]
 		filtersSize := len(filtersGroup.Conditions)
 
-		slabMergerContext := BlockMergerContext{
+		mergerContexts[groupIdx] = BlockMergerContext{
 			Schema:         plan.Schema,
 			AbsOffsetStart: blockChunk.GlobalBlockOffset,
 
 			// filters applied to single column
 			FilterColumn: filtersGroup.Conditions,
-
-			// todo use circular buffer per thread?
-			FilterColumnRuntimeCache: make([]query.RuntimeFilterCache, len(filtersGroup.Conditions)),
-
-			FilterSize: filtersSize,
+			FilterSize:   filtersSize,
 
 			Blocks:       cache.Blocks[:],
 			AbsBlockMaps: cache.AbsBlockMaps[:],
 
+			LeafHeaderResults:  &cache.LeafHeaderResults,
+			LeafMaps:           &cache.LeafMaps,
+			BlockFilterResults: cache.BlockFilterResults[:],
+
+			QueryPlan: plan,
+
 			CurrentBlockProcessingIdx: 0,
 		}
 
+		slabMergerContext := &mergerContexts[groupIdx]
+
 		// preprocess segments into blocks
-		blocksPreprocessErr := preprocessSegmentsIntoBlocksAndHeaderFilter(sm, &slabMergerContext, blockSegments)
+		blocksPreprocessErr := preprocessSegmentsIntoBlocksAndHeaderFilter(sm, slabMergerContext, blockSegments)
 		if blocksPreprocessErr != nil {
-			return ChunkFilterProcessResult{}, fmt.Errorf("unable to preprocess blocks from segments: %s", blocksPreprocessErr.Error())
+			return ChunkFilterProcessResult{}, fmt.Errorf("unable to preprocess blocks from segments: %w", blocksPreprocessErr)
 		}
 
-		singleColumnProcessResult, chunkProcessErr := processFiltersOnPreparedBlocks(&slabMergerContext, cache.IndicesResultCache[:])
-		if chunkProcessErr != nil {
-			return ChunkFilterProcessResult{}, fmt.Errorf("chunk processing failed : %s", chunkProcessErr.Error())
-		} else {
-			result.SkippedBlocksDueToHeaderFiltering += singleColumnProcessResult.skippedBlocksDueToHeaderFiltering
-			result.ProcessedBlocks += singleColumnProcessResult.processedBlocks
-			result.FullSkips += singleColumnProcessResult.fullSkips
+		// columns may have different amount of blocks available,
+		// only blocks present in every filtered column are considered
+		if groupIdx == 0 || slabMergerContext.CurrentBlockProcessingIdx < blocksInChunk {
+			blocksInChunk = slabMergerContext.CurrentBlockProcessingIdx
+		}
+	}
 
-			// slog.Info("single column processing done", "skipped", singleColumnProcessResult.skippedBlocksDueToHeaderFiltering, "processed", singleColumnProcessResult.processedBlocks, "total_processed", result.ProcessedBlocks, "block_offset", blockChunk.GlobalBlockOffset)
+	if len(plan.ExpressionFilters) > 0 {
+		expressionBlocks, expressionErr := prepareExpressionFilters(cache, sm, plan, blockChunk)
+		if expressionErr != nil {
+			return ChunkFilterProcessResult{}, fmt.Errorf("unable to prepare expression filters : %w", expressionErr)
+		}
 
+		if len(plan.FilterGroupedByFields) == 0 || expressionBlocks < blocksInChunk {
+			blocksInChunk = expressionBlocks
 		}
 	}
 
+	if plan.FilterTree != nil {
+
+		for idx := range blocksInChunk {
+			cache.BlockFilterResults[idx] = plan.FilterTree.EvalBounds(func(leafIdx int) schema.BoundsFilterMatchResult {
+				return cache.LeafHeaderResults[leafIdx][idx]
+			})
+		}
+
+		for groupIdx, filtersGroup := range plan.FilterGroupedByFields {
+
+			blockSegments := blockChunk.ChunkSegmentsByFieldIndexMap[filtersGroup.ColumnIdx]
+			slabMergerContext := &mergerContexts[groupIdx]
+			slabMergerContext.BlockFilterResults = cache.BlockFilterResults[:blocksInChunk]
+
+			singleColumnProcessResult, chunkProcessErr := processFiltersOnPreparedBlocks(sm, slabMergerContext, blockSegments, cache.IndicesResultCache[:])
+			if chunkProcessErr != nil {
+				return ChunkFilterProcessResult{}, fmt.Errorf("chunk processing failed : %w", chunkProcessErr)
+			} else {
+				result.SkippedBlocksDueToHeaderFiltering += singleColumnProcessResult.skippedBlocksDueToHeaderFiltering
+				result.ProcessedBlocks += singleColumnProcessResult.processedBlocks
+				result.FullSkips += singleColumnProcessResult.fullSkips
+			}
+		}
+
+		if len(plan.ExpressionFilters) > 0 {
+			expressionResult, expressionErr := processExpressionFilters(cache, sm, plan, blocksInChunk)
+			if expressionErr != nil {
+				return ChunkFilterProcessResult{}, fmt.Errorf("expression filters processing failed : %w", expressionErr)
+			}
+
+			result.SkippedBlocksDueToHeaderFiltering += expressionResult.skippedBlocksDueToHeaderFiltering
+			result.ProcessedBlocks += expressionResult.processedBlocks
+		}
+
+		cache.EnsureFilterNodes(plan.FilterNodes)
+
+		for idx := range blocksInChunk {
+
+			blockFilterMask := &cache.AbsBlockMaps[idx]
+
+			switch cache.BlockFilterResults[idx] {
+			case schema.NoIntersection:
+				blockFilterMask.SetFullSkip()
+			case schema.FullIntersection:
+				blockFilterMask.With(nil, false, true)
+			default:
+				treeBits := &cache.FilterNodeBitsets[plan.FilterTree.Id]
+
+				switch evalFilterTree(cache, plan.FilterTree, idx, treeBits) {
+				case schema.NoIntersection:
+					blockFilterMask.SetFullSkip()
+				case schema.FullIntersection:
+					blockFilterMask.With(nil, false, true)
+				default:
+					blockFilterMask.WithOtherBitset(treeBits)
+				}
+			}
+		}
+	}
+
+	// columns used by selectors may not be filtered at all
+	if len(plan.SelectColumns) > 0 || plan.FilterTree == nil {
+		loadedBlocks, loadErr := loadSelectedColumnBlocks(cache, sm, plan, blockChunk)
+		if loadErr != nil {
+			return ChunkFilterProcessResult{}, loadErr
+		}
+
+		if plan.FilterTree == nil {
+			blocksInChunk = loadedBlocks
+		}
+	}
+
+	result.Aggregates = NewAggregateStates(len(plan.Aggregates))
+
+	grouping := len(plan.GroupBy) > 0
+	if grouping {
+		cache.ResetGroups(len(plan.Aggregates))
+		result.Groups = cache.GroupTable
+	}
+
+	projecting := len(plan.Projections) > 0
+	if projecting {
+		result.Projection = &query.Projection{
+			RowIds: []uint64{},
+			Values: make([][]any, len(plan.Projections)),
+		}
+	}
+
+	var topRows *topRowsHeap
+	if len(plan.OrderBy) > 0 {
+		topRows = newTopRowsHeap(plan)
+	}
+
 	totalItems := 0
 	wastedMerges := 0
 
 	// filter merged blocks info
-	for idx := range query.ExecutorChunkSizeBlocks {
+	for idx := range blocksInChunk {
 
 		blockFilterMask := &cache.AbsBlockMaps[idx]
 
-		if blockFilterMask.Merges() == plan.FilterSize {
-			amount := blockFilterMask.ResultBitset.Count()
-			totalItems += amount
+		if plan.FilterTree == nil {
+			// no filters, select every row
+			blockFilterMask.With(nil, false, true)
+		} else if blockFilterMask.FullSkip() {
+			wastedMerges += leafMerges(cache, plan, idx)
+			continue
+		}
+
+		// full intersections set bits past the last committed row
+		blockFilterMask.ResultBitset.ClearFrom(cache.BlockRows[idx])
+
+		amount := blockFilterMask.ResultBitset.Count()
+		totalItems += amount
 
+		if amount == 0 {
+			wastedMerges += leafMerges(cache, plan, idx)
+		}
+
+		if amount == 0 || (len(plan.Aggregates) == 0 && !grouping && !projecting) {
+			continue
+		}
+
+		if topRows != nil {
+			orderBounds := cache.ColumnBlocks[plan.OrderBy[0].ColumnIdx][idx].HeaderBounds()
+			if topRows.blockCanBeSkipped(&orderBounds) {
+				continue
+			}
+		}
+
+		indicesSize := blockFilterMask.ResultBitset.ToIndices(cache.IndicesResultCache[:])
+		indices := cache.IndicesResultCache[:indicesSize]
+
+		var aggregateErr error
+		if topRows != nil {
+			aggregateErr = collectTopRows(cache, plan, idx, indices, topRows)
+		} else if projecting {
+			aggregateErr = projectBlock(cache, plan, idx, indices, result.Projection)
+		} else if grouping {
+			aggregateErr = groupAggregateBlock(cache, plan, idx, indices)
 		} else {
-			wastedMerges += blockFilterMask.Merges()
+			aggregateErr = aggregateBlock(cache, plan, idx, indices, result.Aggregates)
+		}
+
+		if aggregateErr != nil {
+			return ChunkFilterProcessResult{}, fmt.Errorf("unable to aggregate block : %w", aggregateErr)
+		}
+	}
+
+	if topRows != nil {
+		materializeErr := materializeTopRows(cache, plan, topRows, result.Projection)
+		if materializeErr != nil {
+			return ChunkFilterProcessResult{}, fmt.Errorf("unable to materialize ordered rows : %w", materializeErr)
 		}
 	}
 
@@ -134,3 +349,12 @@ func ExecutePlanForChunk(cache *executortypes.ChunkExecutorThreadCache, sm *meta
 
 	return result, nil
 }
+
+// filter kernel runs spent on a block
+func leafMerges(cache *executortypes.ChunkExecutorThreadCache, plan *query.QueryPlan, blockRelativeIdx int) int {
+	total := 0
+	for leafIdx := range plan.FilterSize {
+		total += cache.LeafMaps[leafIdx][blockRelativeIdx].Merges()
+	}
+	return total
+}
@@ -1,74 +1,130 @@
 package executor
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"runtime/debug"
 	"time"
 
 	executortypes "github.com/dot5enko/simple-column-db/manager/executor/executor_types"
 	"github.com/dot5enko/simple-column-db/manager/meta"
-	"github.com/fatih/color"
 )
 
-func ChunkSingleThreadProcessor(threadId int, slabManager *meta.SlabManager, tasksQueue <-chan *ChunkProcessingTask) {
+// processes chunks until tasks queue is closed or ctx is done
+func ChunkSingleThreadProcessor(threadId int, slabManager *meta.SlabManager, tasksQueue <-chan *ChunkProcessingTask, ctx context.Context) {
 
 	threadCache := &executortypes.ChunkExecutorThreadCache{}
 
 	slog.Info("worker started", "thread_id", threadId)
 	defer slog.Info("worker stopped", "thread_id", threadId)
 
-	for task := range tasksQueue {
+	for {
 
-		curStatus := task.Status
+		var task *ChunkProcessingTask
+		var ok bool
 
-		start := time.Now()
+		select {
+		case <-ctx.Done():
+			return
+		case task, ok = <-tasksQueue:
+			if !ok {
+				return
+			}
+		}
 
-		if curStatus.Err.Load() {
+		processChunkTask(threadCache, slabManager, task)
+	}
+}
 
-			if curStatus.ErrObject == nil {
-				panic("err object not set, but err flag is true")
-			} else {
-				color.Red("skipped because of error: %s", curStatus.ErrObject.Error())
+// fails chunks left in the queue when there is no worker to take them
+func FailQueuedChunks(tasksQueue <-chan *ChunkProcessingTask, err error) {
+
+	for {
+		select {
+		case task, ok := <-tasksQueue:
+			if !ok {
+				return
 			}
-			continue
+
+			task.Status.Fail(&ChunkError{Chunk: task.ChunkIdx, Err: err})
+			task.Status.ChunkDone()
+		default:
+			return
 		}
+	}
+}
 
-		taskRes, err := ExecutePlanForChunk(threadCache, slabManager, task.Plan, task.Bchunk)
-		if err != nil {
-			curStatus.Err.Store(true)
-			curStatus.ErrObject = fmt.Errorf("error while executing plan chunk: %s", err.Error())
-		} else {
+// executes a single chunk and merges its result into the task status.
+// errors and panics of the chunk fail the task, worker keeps running
+func processChunkTask(threadCache *executortypes.ChunkExecutorThreadCache, slabManager *meta.SlabManager, task *ChunkProcessingTask) {
 
-			processed := curStatus.ChunksProcessed.Add(1)
+	curStatus := task.Status
+	defer curStatus.ChunkDone()
 
-			processingTook := time.Since(start).Seconds() * 1000.0
+	// query is cancelled, timed out or failed on another chunk,
+	// it doesn't wait for the result anymore
+	if curStatus.Err.Load() || (task.Ctx != nil && task.Ctx.Err() != nil) {
+		return
+	}
 
-			if false {
-				slog.Info("chunk processing done ", "chunk_id", task.ChunkIdx, "took_ms", fmt.Sprintf("%.2f", processingTook))
-			}
+	defer func() {
+		rec := recover()
+		if rec != nil {
+			slog.Error("chunk processing panicked", "chunk_id", task.ChunkIdx, "err", fmt.Sprintf("%v", rec), "stack", string(debug.Stack()))
+			curStatus.Fail(&ChunkError{Chunk: task.ChunkIdx, Err: fmt.Errorf("panic : %v", rec)})
+		}
+	}()
 
-			func() {
+	start := time.Now()
 
-				timeB := time.Now()
+	taskRes, err := ExecutePlanForChunk(threadCache, slabManager, task.Plan, task.Bchunk)
+	if err != nil {
 
-				curStatus.Lock.Lock()
-				lockTook := time.Since(timeB)
-				defer curStatus.Lock.Unlock()
+		chunkErr := &ChunkError{Chunk: task.ChunkIdx, Err: err}
 
-				globalChunkResult := &curStatus.ChunkResult
+		var slabErr *slabError
+		if errors.As(err, &slabErr) {
+			chunkErr.Slab = slabErr.slab
+		}
 
-				globalChunkResult.LockTook += lockTook
-				globalChunkResult.TotalItems += taskRes.TotalItems
-				globalChunkResult.WastedMerges += taskRes.WastedMerges
-				globalChunkResult.SkippedBlocksDueToHeaderFiltering += taskRes.SkippedBlocksDueToHeaderFiltering
-				globalChunkResult.ProcessedBlocks += taskRes.ProcessedBlocks
-				globalChunkResult.FullSkips += taskRes.FullSkips
-			}()
+		curStatus.Fail(chunkErr)
+		return
+	}
 
-			if processed == int32(curStatus.ChunksTotal) {
-				curStatus.Waiter.Done()
-			}
+	processingTook := time.Since(start).Seconds() * 1000.0
 
-		}
+	if false {
+		slog.Info("chunk processing done ", "chunk_id", task.ChunkIdx, "took_ms", fmt.Sprintf("%.2f", processingTook))
+	}
+
+	timeB := time.Now()
+
+	curStatus.Lock.Lock()
+	lockTook := time.Since(timeB)
+	defer curStatus.Lock.Unlock()
+
+	globalChunkResult := &curStatus.ChunkResult
+
+	globalChunkResult.LockTook += lockTook
+	globalChunkResult.TotalItems += taskRes.TotalItems
+	globalChunkResult.WastedMerges += taskRes.WastedMerges
+	globalChunkResult.SkippedBlocksDueToHeaderFiltering += taskRes.SkippedBlocksDueToHeaderFiltering
+	globalChunkResult.ProcessedBlocks += taskRes.ProcessedBlocks
+	globalChunkResult.FullSkips += taskRes.FullSkips
+
+	for aggIdx := range taskRes.Aggregates {
+		globalChunkResult.Aggregates[aggIdx].Merge(taskRes.Aggregates[aggIdx])
+	}
+
+	// thread local table is reused by the next chunk
+	// so it has to be merged while processing thread waits
+	if taskRes.Groups != nil {
+		globalChunkResult.Groups.Merge(taskRes.Groups)
+	}
+
+	if taskRes.Projection != nil {
+		curStatus.Projections[task.ChunkIdx] = taskRes.Projection
 	}
 }
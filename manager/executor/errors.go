@@ -0,0 +1,50 @@
+package executor
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// failure of a single chunk, completes the query it belongs to
+type ChunkError struct {
+	Chunk int
+
+	// slab being processed when chunk failed, nil uuid if it's unknown
+	Slab uuid.UUID
+
+	Err error
+}
+
+func (e *ChunkError) Error() string {
+	if e.Slab == uuid.Nil {
+		return fmt.Sprintf("chunk %d failed : %s", e.Chunk, e.Err.Error())
+	}
+	return fmt.Sprintf("chunk %d failed on slab %s : %s", e.Chunk, e.Slab.String(), e.Err.Error())
+}
+
+func (e *ChunkError) Unwrap() error {
+	return e.Err
+}
+
+// carries uid of the slab an error happened on up to the chunk error,
+// message is left as is
+type slabError struct {
+	slab uuid.UUID
+	err  error
+}
+
+func (e *slabError) Error() string {
+	return e.err.Error()
+}
+
+func (e *slabError) Unwrap() error {
+	return e.err
+}
+
+func withSlab(slab uuid.UUID, err error) error {
+	if _, ok := err.(*slabError); ok {
+		return err
+	}
+	return &slabError{slab: slab, err: err}
+}
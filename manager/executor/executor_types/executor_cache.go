@@ -1,6 +1,7 @@
 package executortypes
 
 import (
+	"github.com/dot5enko/simple-column-db/bits"
 	"github.com/dot5enko/simple-column-db/lists"
 	"github.com/dot5enko/simple-column-db/manager/query"
 	"github.com/dot5enko/simple-column-db/schema"
@@ -10,6 +11,43 @@ type ChunkExecutorThreadCache struct {
 	AbsBlockMaps       [query.ExecutorChunkSizeBlocks]lists.IndiceUnmerged
 	Blocks             [query.ExecutorChunkSizeBlocks]BlockRuntimeInfo
 	IndicesResultCache [schema.BlockRowsSize]uint16
+
+	// selected rows having value in aggregated column
+	NonNullIndicesCache [schema.BlockRowsSize]uint16
+
+	// per filter leaf results, indexed by leaf and relative block
+	LeafHeaderResults [query.MaxFilterLeaves][query.ExecutorChunkSizeBlocks]schema.BoundsFilterMatchResult
+	LeafMaps          [query.MaxFilterLeaves][query.ExecutorChunkSizeBlocks]lists.IndiceUnmerged
+
+	// filter tree result of block headers
+	BlockFilterResults [query.ExecutorChunkSizeBlocks]schema.BoundsFilterMatchResult
+
+	// scratch bitsets for filter tree evaluation, indexed by node id
+	FilterNodeBitsets []bits.Bitfield
+
+	// blocks of columns used by selectors, indexed by schema column
+	ColumnBlocks [][query.ExecutorChunkSizeBlocks]*schema.RuntimeBlockData
+
+	// headers of columns used by expression filters, indexed by schema column
+	ColumnBlockHeaders [][query.ExecutorChunkSizeBlocks]*schema.DiskHeader
+	ColumnSlabHeaders  [][query.ExecutorChunkSizeBlocks]*schema.DiskSlabHeader
+
+	// per node buffers for expression evaluation
+	ExprScratch [][query.ExprBatchSize]float64
+	BlockAbsIdx [query.ExecutorChunkSizeBlocks]uint64
+
+	// rows of each block committed when the query was planned
+	BlockRows [query.ExecutorChunkSizeBlocks]int
+
+	// group by / order by buffers for a single block
+	KeysCache            [query.MaxGroupByColumns][schema.BlockRowsSize]uint64
+	AggregateValuesCache [][schema.BlockRowsSize]float64
+
+	// validity of aggregated columns in current block, nil when block has no nulls
+	AggregateValidity []*bits.Bitfield
+
+	// thread local hash aggregation table
+	GroupTable *query.GroupTable
 }
 
 func (c *ChunkExecutorThreadCache) Reset() {
@@ -21,5 +59,53 @@ func (c *ChunkExecutorThreadCache) Reset() {
 
 		bRef.BlockHeader = nil
 		bRef.Val = nil
+
+		c.BlockFilterResults[i] = schema.UnknownIntersection
+		c.BlockRows[i] = 0
+
+		for leafIdx := range query.MaxFilterLeaves {
+			c.LeafHeaderResults[leafIdx][i] = schema.UnknownIntersection
+			c.LeafMaps[leafIdx][i].Reset()
+		}
+	}
+
+	for i := range c.ColumnBlocks {
+		clear(c.ColumnBlocks[i][:])
+		clear(c.ColumnBlockHeaders[i][:])
+		clear(c.ColumnSlabHeaders[i][:])
+	}
+}
+
+func (c *ChunkExecutorThreadCache) EnsureColumns(columns int) {
+	if len(c.ColumnBlocks) < columns {
+		c.ColumnBlocks = make([][query.ExecutorChunkSizeBlocks]*schema.RuntimeBlockData, columns)
+		c.ColumnBlockHeaders = make([][query.ExecutorChunkSizeBlocks]*schema.DiskHeader, columns)
+		c.ColumnSlabHeaders = make([][query.ExecutorChunkSizeBlocks]*schema.DiskSlabHeader, columns)
+	}
+}
+
+func (c *ChunkExecutorThreadCache) EnsureExprNodes(nodes int) {
+	if len(c.ExprScratch) < nodes {
+		c.ExprScratch = make([][query.ExprBatchSize]float64, nodes)
+	}
+}
+
+func (c *ChunkExecutorThreadCache) EnsureFilterNodes(nodes int) {
+	if len(c.FilterNodeBitsets) < nodes {
+		c.FilterNodeBitsets = make([]bits.Bitfield, nodes)
+	}
+}
+
+func (c *ChunkExecutorThreadCache) ResetGroups(aggregates int) {
+
+	if len(c.AggregateValuesCache) < aggregates {
+		c.AggregateValuesCache = make([][schema.BlockRowsSize]float64, aggregates)
+		c.AggregateValidity = make([]*bits.Bitfield, aggregates)
+	}
+
+	if c.GroupTable == nil {
+		c.GroupTable = query.NewGroupTable(aggregates)
+	} else {
+		c.GroupTable.Reset(aggregates)
 	}
 }
@@ -0,0 +1,260 @@
+package executor
+
+import (
+	"fmt"
+	"slices"
+
+	executortypes "github.com/dot5enko/simple-column-db/manager/executor/executor_types"
+	"github.com/dot5enko/simple-column-db/manager/executor/filters"
+	"github.com/dot5enko/simple-column-db/manager/executor/kernels"
+	"github.com/dot5enko/simple-column-db/manager/meta"
+	"github.com/dot5enko/simple-column-db/manager/query"
+	"github.com/dot5enko/simple-column-db/ops"
+	"github.com/dot5enko/simple-column-db/schema"
+)
+
+// collects block headers of columns used by expression filters
+// and matches expressions against their bounds.
+// returns amount of blocks present in all of the columns
+func prepareExpressionFilters(
+	cache *executortypes.ChunkExecutorThreadCache,
+	sm *meta.SlabManager,
+	plan *query.QueryPlan,
+	blockChunk *query.BlockChunk,
+) (int, error) {
+
+	cache.EnsureColumns(len(plan.Schema.Columns))
+
+	columns := []int{}
+	for _, it := range plan.ExpressionFilters {
+		for _, columnIdx := range it.Columns {
+			if !slices.Contains(columns, columnIdx) {
+				columns = append(columns, columnIdx)
+			}
+		}
+	}
+
+	blocksInChunk := -1
+
+	for _, columnIdx := range columns {
+
+		relIdx := 0
+
+		iterErr := forEachSegmentBlock(sm, &plan.Schema, blockChunk.ChunkSegmentsByFieldIndexMap[columnIdx], func(slabInfo *schema.DiskSlabHeader, _ int, blockHeader *schema.DiskHeader) error {
+
+			cache.ColumnBlockHeaders[columnIdx][relIdx] = blockHeader
+			cache.ColumnSlabHeaders[columnIdx][relIdx] = slabInfo
+
+			blockRT := &cache.Blocks[relIdx]
+			if blockRT.BlockHeader == nil {
+				blockRT.BlockHeader = blockHeader
+			}
+
+			relIdx++
+
+			return nil
+		})
+
+		if iterErr != nil {
+			return 0, fmt.Errorf("unable to read headers of column `%s` : %w", plan.Schema.Columns[columnIdx].Name, iterErr)
+		}
+
+		if blocksInChunk == -1 || relIdx < blocksInChunk {
+			blocksInChunk = relIdx
+		}
+	}
+
+	// expressions on constants only
+	if blocksInChunk == -1 {
+		blocksInChunk = 0
+	}
+
+	for relIdx := range blocksInChunk {
+		for _, it := range plan.ExpressionFilters {
+
+			matchResult, matchErr := filters.ProcessDiffOnBlockHeaders(it, func(columnIdx int) *schema.DiskHeader {
+				return cache.ColumnBlockHeaders[columnIdx][relIdx]
+			})
+			if matchErr != nil {
+				return 0, fmt.Errorf("error filtering expression bounds : %s", matchErr.Error())
+			}
+
+			cache.LeafHeaderResults[it.LeafIdx][relIdx] = matchResult
+		}
+	}
+
+	return blocksInChunk, nil
+}
+
+// evaluates expression filters on blocks partially matched by the filter tree
+func processExpressionFilters(
+	cache *executortypes.ChunkExecutorThreadCache,
+	sm *meta.SlabManager,
+	plan *query.QueryPlan,
+	blocksInChunk int,
+) (result SingleColumnProcessingResult, err error) {
+
+	cache.EnsureExprNodes(plan.ExpressionNodes)
+
+	for relIdx := range blocksInChunk {
+
+		switch cache.BlockFilterResults[relIdx] {
+		case schema.NoIntersection, schema.FullIntersection:
+			continue
+		}
+
+		for _, it := range plan.ExpressionFilters {
+
+			headerMatchResult := cache.LeafHeaderResults[it.LeafIdx][relIdx]
+			if headerMatchResult == schema.FullIntersection || headerMatchResult == schema.NoIntersection {
+				result.skippedBlocksDueToHeaderFiltering += 1
+				continue
+			}
+
+			result.processedBlocks += 1
+
+			rows := cache.BlockRows[relIdx]
+
+			for _, columnIdx := range it.Columns {
+
+				blockData := cache.ColumnBlocks[columnIdx][relIdx]
+				if blockData == nil {
+					blockHeader := cache.ColumnBlockHeaders[columnIdx][relIdx]
+					slabInfo := cache.ColumnSlabHeaders[columnIdx][relIdx]
+
+					var blockErr error
+					blockData, blockErr = sm.LoadBlockToRuntimeBlockData(plan.Schema, slabInfo, blockHeader.Uid)
+					if blockErr != nil {
+						return SingleColumnProcessingResult{}, withSlab(slabInfo.Uid, fmt.Errorf("unable to decode block : %w", blockErr))
+					}
+
+					cache.ColumnBlocks[columnIdx][relIdx] = blockData
+				}
+
+				// columns of the active block may be written unevenly
+				_, arrayEndOffset := blockData.DirectAccess()
+				rows = min(rows, arrayEndOffset)
+			}
+
+			filled := 0
+
+			for offset := 0; offset < rows; offset += query.ExprBatchSize {
+
+				size := min(query.ExprBatchSize, rows-offset)
+				values := cache.ExprScratch[it.Diff.Id][:size]
+
+				evalErr := evalExprBatch(cache, it.Diff, relIdx, offset, values)
+				if evalErr != nil {
+					return SingleColumnProcessingResult{}, evalErr
+				}
+
+				found, compareErr := compareWithZero(it.Operand, values, cache.IndicesResultCache[filled:])
+				if compareErr != nil {
+					return SingleColumnProcessingResult{}, compareErr
+				}
+
+				for i := filled; i < filled+found; i++ {
+					cache.IndicesResultCache[i] += uint16(offset)
+				}
+
+				filled += found
+			}
+
+			leafMerger := &cache.LeafMaps[it.LeafIdx][relIdx]
+			leafMerger.With(cache.IndicesResultCache[:filled], false, false)
+
+			for _, columnIdx := range it.Columns {
+				filters.ExcludeNullRows(cache.ColumnBlocks[columnIdx][relIdx], leafMerger)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// evaluates expression over rows [offset, offset + len(out)) of a block
+func evalExprBatch(cache *executortypes.ChunkExecutorThreadCache, expr *query.ExprRT, relIdx int, offset int, out []float64) error {
+
+	switch expr.Type {
+	case query.ExprConst:
+		for i := range out {
+			out[i] = expr.Value
+		}
+		return nil
+
+	case query.ExprColumn:
+
+		blockData := cache.ColumnBlocks[expr.ColumnIdx][relIdx]
+		directBlockArray, _ := blockData.DirectAccess()
+
+		typeKernels, typeErr := kernels.Of(blockData.Header.DataType)
+		if typeErr != nil {
+			return fmt.Errorf("unsupported type in expression : %s", typeErr.Error())
+		}
+
+		typeKernels.ToFloat64(directBlockArray, offset, out)
+
+		if expr.Scale != 0 {
+			for i := range out {
+				out[i] *= expr.Scale
+			}
+		}
+
+		return nil
+	}
+
+	leftErr := evalExprBatch(cache, expr.Left, relIdx, offset, out)
+	if leftErr != nil {
+		return leftErr
+	}
+
+	right := cache.ExprScratch[expr.Right.Id][:len(out)]
+
+	rightErr := evalExprBatch(cache, expr.Right, relIdx, offset, right)
+	if rightErr != nil {
+		return rightErr
+	}
+
+	switch expr.Type {
+	case query.ExprAdd:
+		for i := range out {
+			out[i] += right[i]
+		}
+	case query.ExprSub:
+		for i := range out {
+			out[i] -= right[i]
+		}
+	case query.ExprMul:
+		for i := range out {
+			out[i] *= right[i]
+		}
+	case query.ExprDiv:
+		for i := range out {
+			out[i] /= right[i]
+		}
+	default:
+		return fmt.Errorf("unsupported expression %s", expr.Type.String())
+	}
+
+	return nil
+}
+
+func compareWithZero(operand query.CondOperand, values []float64, out []uint16) (int, error) {
+
+	switch operand {
+	case query.EQ:
+		return ops.CompareNumericValuesAreEqual(values, 0, out), nil
+	case query.NEQ:
+		return ops.CompareNumericValuesAreNotEqual(values, 0, out), nil
+	case query.GT:
+		return ops.CompareValuesAreBigger(values, 0, out), nil
+	case query.GTE:
+		return ops.CompareValuesAreBiggerOrEqual(values, 0, out), nil
+	case query.LT:
+		return ops.CompareValuesAreSmaller(values, 0, out), nil
+	case query.LTE:
+		return ops.CompareValuesAreSmallerOrEqual(values, 0, out), nil
+	default:
+		return 0, fmt.Errorf("unsupported operand %s on expressions", operand.String())
+	}
+}
@@ -3,12 +3,13 @@ package filters
 import (
 	"fmt"
 	"log"
+	"math"
+	"slices"
 
 	"github.com/dot5enko/simple-column-db/lists"
 	executortypes "github.com/dot5enko/simple-column-db/manager/executor/executor_types"
 	"github.com/dot5enko/simple-column-db/manager/query"
 	"github.com/dot5enko/simple-column-db/ops"
-	"github.com/dot5enko/simple-column-db/schema"
 	"github.com/fatih/color"
 )
 
@@ -39,29 +40,23 @@ func ProcessUnsignedFilterOnColumnWithType[T ops.UnsignedInts](
 
 		}
 
-		itemsFiltered = ops.CompareValuesAreInRangeUnsignedInts(inputArray, operandA, operandB, indicesCache)
+		var nonEmpty bool
+		operandA, operandB, nonEmpty = inclusiveIntRange(operandA, operandB, filter.Range)
+		if nonEmpty {
+			itemsFiltered = ops.CompareValuesAreInRangeUnsignedInts(inputArray, operandA, operandB, indicesCache)
+		}
 		// log.Printf(" end of input array offset : %v", arrayEndOffset)
 
 		if false && itemsFiltered > 0 {
 			log.Printf("filtered %v items from block by range %s. ", itemsFiltered, blockData.BlockHeader.Uid.String())
 			color.Red(" operands %v <-> %v. %s block range : [%e: max %e]", operandA, operandB, blockData.BlockHeader.Uid.String(), blockData.BlockHeader.Bounds.Min, blockData.BlockHeader.Bounds.Max)
 		}
-	case query.EQ:
-		operand := filter.Arguments[0].(T)
-
-		itemsFiltered = ops.CompareNumericValuesAreEqual(inputArray, operand, indicesCache)
-
-	case query.GT:
-		operand := filter.Arguments[0].(T)
-
-		itemsFiltered = ops.CompareValuesAreBigger(inputArray, operand, indicesCache)
-	case query.LT:
-		operand := filter.Arguments[0].(T)
-
-		itemsFiltered = ops.CompareValuesAreSmaller(inputArray, operand, indicesCache)
-
 	default:
-		return itemsFiltered, fmt.Errorf("unsupported operand type=%s while ProcessNumericFilterOnColumnWithType[%s]", filter.Operand.String(), blockData.BlockHeader.DataType.String())
+		var compareErr error
+		itemsFiltered, compareErr = processComparisonFilter(filter, inputArray, indicesCache)
+		if compareErr != nil {
+			return itemsFiltered, fmt.Errorf("%s while ProcessNumericFilterOnColumnWithType[%s]", compareErr.Error(), blockData.BlockHeader.DataType.String())
+		}
 	}
 
 	merger.With(indicesCache[:itemsFiltered], false, false)
@@ -71,7 +66,6 @@ func ProcessUnsignedFilterOnColumnWithType[T ops.UnsignedInts](
 }
 
 func ProcessSignedFilterOnColumnWithType[T ops.SignedInts](
-	slab *schema.DiskSlabHeader,
 	filter query.FilterCondition,
 	blockData *executortypes.BlockRuntimeInfo,
 	merger *lists.IndiceUnmerged,
@@ -83,8 +77,6 @@ func ProcessSignedFilterOnColumnWithType[T ops.SignedInts](
 	runtimeBlockInfo := blockData.Val
 	directBlockArray, arrayEndOffset := runtimeBlockInfo.DirectAccess()
 
-	// log.Printf("[slab %s] processing numeric filter on column %v, type = %s", slab.Uid.String(), filter.Field, blockData.BlockHeader.DataType.String())
-
 	arrayCasted := directBlockArray.([]T)
 	inputArray := arrayCasted[:arrayEndOffset]
 
@@ -100,29 +92,23 @@ func ProcessSignedFilterOnColumnWithType[T ops.SignedInts](
 
 		}
 
-		itemsFiltered = ops.CompareValuesAreInRangeSignedInts(inputArray, operandA, operandB, indicesCache)
+		var nonEmpty bool
+		operandA, operandB, nonEmpty = inclusiveIntRange(operandA, operandB, filter.Range)
+		if nonEmpty {
+			itemsFiltered = ops.CompareValuesAreInRangeSignedInts(inputArray, operandA, operandB, indicesCache)
+		}
 		// log.Printf(" end of input array offset : %v", arrayEndOffset)
 
 		if false && itemsFiltered > 0 {
 			log.Printf("filtered %v items from block by range %s. ", itemsFiltered, blockData.BlockHeader.Uid.String())
 			color.Red(" operands %v <-> %v. %s block range : [%e: max %e]", operandA, operandB, blockData.BlockHeader.Uid.String(), blockData.BlockHeader.Bounds.Min, blockData.BlockHeader.Bounds.Max)
 		}
-	case query.EQ:
-		operand := filter.Arguments[0].(T)
-
-		itemsFiltered = ops.CompareNumericValuesAreEqual(inputArray, operand, indicesCache)
-
-	case query.GT:
-		operand := filter.Arguments[0].(T)
-
-		itemsFiltered = ops.CompareValuesAreBigger(inputArray, operand, indicesCache)
-	case query.LT:
-		operand := filter.Arguments[0].(T)
-
-		itemsFiltered = ops.CompareValuesAreSmaller(inputArray, operand, indicesCache)
-
 	default:
-		return itemsFiltered, fmt.Errorf("unsupported operand type=%v while ProcessNumericFilterOnColumnWithType[%s]", filter.Operand, blockData.BlockHeader.DataType.String())
+		var compareErr error
+		itemsFiltered, compareErr = processComparisonFilter(filter, inputArray, indicesCache)
+		if compareErr != nil {
+			return itemsFiltered, fmt.Errorf("%s while ProcessNumericFilterOnColumnWithType[%s]", compareErr.Error(), blockData.BlockHeader.DataType.String())
+		}
 	}
 
 	merger.With(indicesCache[:itemsFiltered], false, false)
@@ -168,6 +154,7 @@ func ProcessFloatFilterOnColumnWithType[T ops.Floats](
 
 		}
 
+		operandA, operandB = inclusiveFloatRange(operandA, operandB, filter.Range)
 		itemsFiltered = ops.CompareValuesAreInRangeFloats(inputArray, operandA, operandB, indicesCache)
 		// log.Printf(" end of input array offset : %v", arrayEndOffset)
 
@@ -183,25 +170,96 @@ func ProcessFloatFilterOnColumnWithType[T ops.Floats](
 			color.Green("-- filtered : %#+v", valuesFiltered)
 		}
 
-	case query.EQ:
+	default:
+		var compareErr error
+		itemsFiltered, compareErr = processComparisonFilter(filter, inputArray, indicesCache)
+		if compareErr != nil {
+			return itemsFiltered, fmt.Errorf("%s while ProcessNumericFilterOnColumnWithType[%s]", compareErr.Error(), blockData.BlockHeader.DataType.String())
+		}
+	}
 
-		operand := filter.Arguments[0].(T)
-		itemsFiltered = ops.CompareNumericValuesAreEqual(inputArray, operand, indicesCache)
+	merger.With(indicesCache[:itemsFiltered], false, false)
 
-	case query.GT:
+	return itemsFiltered, nil
+}
 
-		operand := filter.Arguments[0].(T)
-		itemsFiltered = ops.CompareValuesAreBigger(inputArray, operand, indicesCache)
+// filters that are shared by all numeric types
+func processComparisonFilter[T ops.NumericTypes](filter query.FilterCondition, inputArray []T, indicesCache []uint16) (int, error) {
+
+	switch filter.Operand {
+	case query.EQ:
+		return ops.CompareNumericValuesAreEqual(inputArray, filter.Arguments[0].(T), indicesCache), nil
+	case query.NEQ:
+		return ops.CompareNumericValuesAreNotEqual(inputArray, filter.Arguments[0].(T), indicesCache), nil
+	case query.GT:
+		return ops.CompareValuesAreBigger(inputArray, filter.Arguments[0].(T), indicesCache), nil
+	case query.GTE:
+		return ops.CompareValuesAreBiggerOrEqual(inputArray, filter.Arguments[0].(T), indicesCache), nil
 	case query.LT:
+		return ops.CompareValuesAreSmaller(inputArray, filter.Arguments[0].(T), indicesCache), nil
+	case query.LTE:
+		return ops.CompareValuesAreSmallerOrEqual(inputArray, filter.Arguments[0].(T), indicesCache), nil
+	case query.IN:
+		return ops.CompareValuesAreInSet(inputArray, argumentsSet[T](filter), indicesCache), nil
+	case query.NOT_IN:
+		return ops.CompareValuesAreNotInSet(inputArray, argumentsSet[T](filter), indicesCache), nil
+	default:
+		return 0, fmt.Errorf("unsupported operand type=%s", filter.Operand.String())
+	}
+}
 
-		operand := filter.Arguments[0].(T)
-		itemsFiltered = ops.CompareValuesAreSmaller(inputArray, operand, indicesCache)
+// sorted distinct arguments of IN / NOT_IN
+func argumentsSet[T ops.NumericTypes](filter query.FilterCondition) []T {
 
-	default:
-		return itemsFiltered, fmt.Errorf("unsupported operand type=%v while ProcessNumericFilterOnColumnWithType[%s]", filter.Operand, blockData.BlockHeader.DataType.String())
+	set := make([]T, len(filter.Arguments))
+	for idx, arg := range filter.Arguments {
+		set[idx] = arg.(T)
 	}
 
-	merger.With(indicesCache[:itemsFiltered], false, false)
+	slices.Sort(set)
+	return slices.Compact(set)
+}
 
-	return itemsFiltered, nil
+// moves excluded endpoints of integer range inwards,
+// returns false if no value fits in the range
+func inclusiveIntRange[T ops.UnsignedInts | ops.SignedInts](from, to T, flags query.RangeFlags) (T, T, bool) {
+
+	if flags&query.RangeExcludeFrom != 0 {
+		if from == to {
+			return from, to, false
+		}
+		from++
+	}
+
+	if flags&query.RangeExcludeTo != 0 {
+		if from == to {
+			return from, to, false
+		}
+		to--
+	}
+
+	return from, to, true
+}
+
+// moves excluded endpoints of float range to the nearest representable values
+func inclusiveFloatRange[T ops.Floats](from, to T, flags query.RangeFlags) (T, T) {
+
+	if flags&query.RangeExcludeFrom != 0 {
+		from = nextFloat(from, math.Inf(1))
+	}
+
+	if flags&query.RangeExcludeTo != 0 {
+		to = nextFloat(to, math.Inf(-1))
+	}
+
+	return from, to
+}
+
+func nextFloat[T ops.Floats](v T, direction float64) T {
+	switch typed := any(v).(type) {
+	case float32:
+		return T(math.Nextafter32(typed, float32(direction)))
+	default:
+		return T(math.Nextafter(float64(v), direction))
+	}
 }
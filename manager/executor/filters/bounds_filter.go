@@ -8,6 +8,8 @@ import (
 	"github.com/dot5enko/simple-column-db/schema"
 )
 
+// matches filter against block bounds,
+// must agree with the kernels used on block data
 func ProcessFilterOnBounds[T ops.NumericTypes](
 	filter query.FilterCondition,
 	bounds *schema.BoundsFloat,
@@ -16,8 +18,8 @@ func ProcessFilterOnBounds[T ops.NumericTypes](
 	switch filter.Operand {
 	case query.RANGE:
 
-		operandFrom := float64(filter.Arguments[0].(T))
-		operandTo := float64(filter.Arguments[1].(T))
+		operandFrom := filter.Arguments[0].(T)
+		operandTo := filter.Arguments[1].(T)
 
 		if operandFrom > operandTo {
 			temp := operandTo
@@ -25,52 +27,183 @@ func ProcessFilterOnBounds[T ops.NumericTypes](
 			operandFrom = temp
 		}
 
-		matchResult = bounds.Intersects(schema.NewBoundsFromValues(operandFrom, operandTo))
-		return matchResult, nil
+		excludeFrom := filter.Range&query.RangeExcludeFrom != 0
+		excludeTo := filter.Range&query.RangeExcludeTo != 0
 
-	case query.EQ:
+		if operandFrom == operandTo && (excludeFrom || excludeTo) {
+			return schema.NoIntersection, nil
+		}
 
-		operand := float64(filter.Arguments[0].(T))
-		contains := bounds.Contains(operand)
+		lower := boundsCompare(bounds, operandFrom, excludeFrom, true)
+		upper := boundsCompare(bounds, operandTo, excludeTo, false)
 
-		if !contains {
+		if lower == schema.NoIntersection || upper == schema.NoIntersection {
 			return schema.NoIntersection, nil
-		} else if contains {
-			return schema.PartialIntersection, nil
 		}
 
-	case query.GT:
+		if lower == schema.FullIntersection && upper == schema.FullIntersection {
+			return schema.FullIntersection, nil
+		}
+
+		return schema.PartialIntersection, nil
+
+	case query.EQ:
 
 		operand := float64(filter.Arguments[0].(T))
 
-		if operand > bounds.Max {
+		if !bounds.Contains(operand) {
 			return schema.NoIntersection, nil
+		} else if singleValueBounds[T](bounds) {
+			return schema.FullIntersection, nil
 		}
 
-		if operand <= bounds.Min {
+		return schema.PartialIntersection, nil
+
+	case query.NEQ:
+
+		operand := float64(filter.Arguments[0].(T))
+
+		if !bounds.Contains(operand) {
 			return schema.FullIntersection, nil
+		} else if singleValueBounds[T](bounds) {
+			return schema.NoIntersection, nil
 		}
 
 		return schema.PartialIntersection, nil
 
+	case query.GT:
+		return boundsCompare(bounds, filter.Arguments[0].(T), true, true), nil
+	case query.GTE:
+		return boundsCompare(bounds, filter.Arguments[0].(T), false, true), nil
 	case query.LT:
+		return boundsCompare(bounds, filter.Arguments[0].(T), true, false), nil
+	case query.LTE:
+		return boundsCompare(bounds, filter.Arguments[0].(T), false, false), nil
 
-		operand := float64(filter.Arguments[0].(T))
+	case query.IN, query.NOT_IN:
 
-		if operand < bounds.Min {
-			return schema.NoIntersection, nil
+		contained := 0
+		for _, arg := range filter.Arguments {
+			if bounds.Contains(float64(arg.(T))) {
+				contained++
+			}
 		}
 
-		if operand >= bounds.Max {
-			return schema.FullIntersection, nil
+		matchResult = schema.PartialIntersection
+
+		if contained == 0 {
+			matchResult = schema.NoIntersection
+		} else if singleValueBounds[T](bounds) {
+			matchResult = schema.FullIntersection
 		}
 
-		return schema.PartialIntersection, nil
+		if filter.Operand == query.NOT_IN {
+			switch matchResult {
+			case schema.NoIntersection:
+				matchResult = schema.FullIntersection
+			case schema.FullIntersection:
+				matchResult = schema.NoIntersection
+			}
+		}
+
+		return matchResult, nil
 
 	default:
 		return schema.UnknownIntersection, fmt.Errorf("unsupported operand type=%v while ProcessFilterOnBounds", filter.Operand)
 	}
+}
+
+// matches comparison of expression sides difference against zero
+func ProcessDiffOnBounds(operand query.CondOperand, diff *schema.BoundsFloat) (schema.BoundsFilterMatchResult, error) {
+
+	filter := query.FilterCondition{
+		Operand:   operand,
+		Arguments: []any{float64(0)},
+	}
+
+	return ProcessFilterOnBounds[float64](filter, diff)
+}
+
+// matches expression filter against headers of its columns.
+// unknown when some header is missing or expression can't be bound
+func ProcessDiffOnBlockHeaders(it query.ExpressionFilterRT, columnHeader func(columnIdx int) *schema.DiskHeader) (schema.BoundsFilterMatchResult, error) {
+
+	hasNulls := false
+
+	for _, columnIdx := range it.Columns {
+		header := columnHeader(columnIdx)
+		if header == nil {
+			return schema.UnknownIntersection, nil
+		}
+
+		if header.NullCount > 0 {
+			if header.NullCount == header.Items {
+				return schema.NoIntersection, nil
+			}
+			hasNulls = true
+		}
+	}
+
+	diffBounds, bounded := it.Diff.EvalBounds(func(columnIdx int) (schema.BoundsFloat, bool) {
+		return columnHeader(columnIdx).Bounds, true
+	})
+
+	if !bounded {
+		return schema.UnknownIntersection, nil
+	}
+
+	matchResult, matchErr := ProcessDiffOnBounds(it.Operand, &diffBounds)
+	if matchResult == schema.FullIntersection && hasNulls {
+		matchResult = schema.PartialIntersection
+	}
+
+	return matchResult, matchErr
+}
+
+// matches a single sided comparison: value > operand (or >= when not strict) for lower bounds,
+// value < operand (or <=) otherwise.
+//
+// values equal to the operand only after rounding to float64 may be on either side of it,
+// so equality with a bound decides the match only when the operand is exact
+func boundsCompare[T ops.NumericTypes](bounds *schema.BoundsFloat, operand T, strict bool, lowerBound bool) schema.BoundsFilterMatchResult {
+
+	value := float64(operand)
+	exact := exactFloat[T](value)
+
+	if lowerBound {
+		if bounds.Max < value || (exact && strict && bounds.Max == value) {
+			return schema.NoIntersection
+		}
+
+		if bounds.Min > value || (exact && !strict && bounds.Min == value) {
+			return schema.FullIntersection
+		}
+	} else {
+		if bounds.Min > value || (exact && strict && bounds.Min == value) {
+			return schema.NoIntersection
+		}
+
+		if bounds.Max < value || (exact && !strict && bounds.Max == value) {
+			return schema.FullIntersection
+		}
+	}
+
+	return schema.PartialIntersection
+}
+
+// true when every value of the block is the same,
+// different integers past 2^53 by magnitude may share rounded bounds
+func singleValueBounds[T ops.NumericTypes](bounds *schema.BoundsFloat) bool {
+	return bounds.Min == bounds.Max && exactFloat[T](bounds.Min)
+}
+
+// true when value of T converted to float64 is exact, which holds for floats
+// and for integers below 2^53 by magnitude. integer converted to a float64 of smaller magnitude
+// than 2^53 was exact, as rounding doesn't cross a representable value
+func exactFloat[T ops.NumericTypes](value float64) bool {
 
-	return schema.UnknownIntersection, nil
+	var half T = 1
+	half /= 2
 
+	return half != 0 || (value > -(1<<53) && value < 1<<53)
 }
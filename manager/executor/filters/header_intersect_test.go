@@ -1,6 +1,7 @@
 package filters
 
 import (
+	"math"
 	"testing"
 
 	"github.com/dot5enko/simple-column-db/manager/query"
@@ -65,3 +66,203 @@ func TestHeaderPartialIntersectFilter(t *testing.T) {
 	}
 
 }
+
+func TestHeaderRangeExclusiveFilter(t *testing.T) {
+
+	bounds := schema.NewBoundsFromValues(10, 20)
+
+	filter := query.FilterCondition{
+		Field:     "value",
+		Operand:   query.RANGE,
+		Arguments: []any{uint64(20), uint64(30)},
+	}
+
+	matchResult, _ := ProcessFilterOnBounds[uint64](filter, &bounds)
+	if matchResult != schema.PartialIntersection {
+		t.Errorf("expected partial intersection for inclusive range, got %s", matchResult.String())
+	}
+
+	filter.Range = query.RangeExcludeFrom
+
+	matchResult, _ = ProcessFilterOnBounds[uint64](filter, &bounds)
+	if matchResult != schema.NoIntersection {
+		t.Errorf("expected no intersection for excluded lower bound, got %s", matchResult.String())
+	}
+
+	filter.Arguments = []any{uint64(10), uint64(21)}
+	filter.Range = query.RangeExcludeTo
+
+	matchResult, _ = ProcessFilterOnBounds[uint64](filter, &bounds)
+	if matchResult != schema.FullIntersection {
+		t.Errorf("expected full intersection, got %s", matchResult.String())
+	}
+}
+
+func TestHeaderNotInFilter(t *testing.T) {
+
+	bounds := schema.NewBoundsFromValues(5, 5)
+
+	filter := query.FilterCondition{
+		Field:     "monitor_id",
+		Operand:   query.NOT_IN,
+		Arguments: []any{uint64(1), uint64(5)},
+	}
+
+	matchResult, _ := ProcessFilterOnBounds[uint64](filter, &bounds)
+	if matchResult != schema.NoIntersection {
+		t.Errorf("expected no intersection, got %s", matchResult.String())
+	}
+
+	filter.Operand = query.IN
+
+	matchResult, _ = ProcessFilterOnBounds[uint64](filter, &bounds)
+	if matchResult != schema.FullIntersection {
+		t.Errorf("expected full intersection, got %s", matchResult.String())
+	}
+}
+
+func TestHeaderExpressionFilter(t *testing.T) {
+
+	columnBounds := []schema.BoundsFloat{
+		schema.NewBoundsFromValues(0.5, 0.8),
+		schema.NewBoundsFromValues(10, 20),
+	}
+
+	boundsOf := func(columnIdx int) (schema.BoundsFloat, bool) {
+		return columnBounds[columnIdx], true
+	}
+
+	// value * 100 - 45
+	diff := &query.ExprRT{
+		Type: query.ExprSub,
+		Left: &query.ExprRT{
+			Type:  query.ExprMul,
+			Left:  &query.ExprRT{Type: query.ExprColumn, ColumnIdx: 0},
+			Right: &query.ExprRT{Type: query.ExprConst, Value: 100},
+		},
+		Right: &query.ExprRT{Type: query.ExprConst, Value: 45},
+	}
+
+	diffBounds, bounded := diff.EvalBounds(boundsOf)
+	if !bounded {
+		t.Fatalf("expected bounded expression")
+	}
+
+	matchResult, _ := ProcessDiffOnBounds(query.GT, &diffBounds)
+	if matchResult != schema.FullIntersection {
+		t.Errorf("expected full intersection, got %s", matchResult.String())
+	}
+
+	// value / (threshold - 15) can't be bound
+	ratio := &query.ExprRT{
+		Type: query.ExprDiv,
+		Left: &query.ExprRT{Type: query.ExprColumn, ColumnIdx: 0},
+		Right: &query.ExprRT{
+			Type:  query.ExprSub,
+			Left:  &query.ExprRT{Type: query.ExprColumn, ColumnIdx: 1},
+			Right: &query.ExprRT{Type: query.ExprConst, Value: 15},
+		},
+	}
+
+	if _, bounded = ratio.EvalBounds(boundsOf); bounded {
+		t.Errorf("expected division by interval containing zero to be unbounded")
+	}
+}
+
+func TestHeaderNullCountFilter(t *testing.T) {
+
+	header := schema.DiskHeader{
+		Items:     100,
+		NullCount: 10,
+		Bounds:    schema.NewBoundsFromValues(10, 20),
+	}
+
+	cases := []struct {
+		filter   query.FilterCondition
+		expected schema.BoundsFilterMatchResult
+	}{
+		{query.FilterCondition{Operand: query.GTE, Arguments: []any{uint64(10)}}, schema.PartialIntersection},
+		{query.FilterCondition{Operand: query.GT, Arguments: []any{uint64(20)}}, schema.NoIntersection},
+		{query.FilterCondition{Operand: query.IS_NULL}, schema.PartialIntersection},
+		{query.FilterCondition{Operand: query.IS_NOT_NULL}, schema.PartialIntersection},
+	}
+
+	for _, it := range cases {
+		matchResult, _ := ProcessFilterOnBlockHeader[uint64](it.filter, &header)
+		if matchResult != it.expected {
+			t.Errorf("%s: expected %s, got %s", it.filter.Operand.String(), it.expected.String(), matchResult.String())
+		}
+	}
+
+	header.NullCount = 0
+
+	if matchResult, _ := ProcessFilterOnBlockHeader[uint64](query.FilterCondition{Operand: query.IS_NULL}, &header); matchResult != schema.NoIntersection {
+		t.Errorf("expected no intersection of IS_NULL without nulls, got %s", matchResult.String())
+	}
+
+	header.NullCount = header.Items
+	header.Bounds = schema.NewBounds()
+
+	if matchResult, _ := ProcessFilterOnBlockHeader[uint64](query.FilterCondition{Operand: query.NEQ, Arguments: []any{uint64(1)}}, &header); matchResult != schema.NoIntersection {
+		t.Errorf("expected no intersection of block without values, got %s", matchResult.String())
+	}
+
+	if matchResult, _ := ProcessFilterOnBlockHeader[uint64](query.FilterCondition{Operand: query.IS_NULL}, &header); matchResult != schema.FullIntersection {
+		t.Errorf("expected full intersection of IS_NULL on block without values, got %s", matchResult.String())
+	}
+}
+
+func TestHeaderSignedFilter(t *testing.T) {
+
+	header := schema.DiskHeader{
+		Items:  100,
+		Bounds: schema.NewBoundsFromValues(-20, -10),
+	}
+
+	matchResult, _ := ProcessFilterOnBlockHeader[int32](query.FilterCondition{Operand: query.LT, Arguments: []any{int32(-5)}}, &header)
+	if matchResult != schema.FullIntersection {
+		t.Errorf("expected full intersection, got %s", matchResult.String())
+	}
+
+	matchResult, _ = ProcessFilterOnBlockHeader[int32](query.FilterCondition{Operand: query.RANGE, Arguments: []any{int32(-30), int32(-20)}, Range: query.RangeExcludeTo}, &header)
+	if matchResult != schema.NoIntersection {
+		t.Errorf("expected no intersection, got %s", matchResult.String())
+	}
+}
+
+// int64 values next to math.MaxInt64 round to the same float64 bounds,
+// only bounds strictly apart from the operand decide the match
+func TestHeaderWideIntegerFilter(t *testing.T) {
+
+	// block of values [MaxInt64-1000, MaxInt64], both bounds round to 2^63
+	bounds := schema.NewBoundsFromValues(float64(int64(math.MaxInt64-1000)), float64(int64(math.MaxInt64)))
+
+	cases := []struct {
+		operand  query.CondOperand
+		argument int64
+		expected schema.BoundsFilterMatchResult
+	}{
+		{query.GT, math.MaxInt64 - 1, schema.PartialIntersection},
+		{query.GTE, math.MaxInt64 - 1000, schema.PartialIntersection},
+		{query.LT, math.MaxInt64 - 999, schema.PartialIntersection},
+		{query.LTE, math.MaxInt64, schema.PartialIntersection},
+		{query.EQ, math.MaxInt64 - 500, schema.PartialIntersection},
+		{query.NEQ, math.MaxInt64 - 500, schema.PartialIntersection},
+		{query.GT, 0, schema.FullIntersection},
+		{query.LT, 1 << 53, schema.NoIntersection},
+	}
+
+	for _, it := range cases {
+		filter := query.FilterCondition{Operand: it.operand, Arguments: []any{it.argument}}
+
+		matchResult, _ := ProcessFilterOnBounds[int64](filter, &bounds)
+		if matchResult != it.expected {
+			t.Errorf("%s %d : expected %s, got %s", it.operand.String(), it.argument, it.expected.String(), matchResult.String())
+		}
+	}
+
+	filter := query.FilterCondition{Operand: query.RANGE, Arguments: []any{int64(math.MaxInt64 - 1), int64(math.MaxInt64 - 1)}, Range: query.RangeInclusive}
+	if matchResult, _ := ProcessFilterOnBounds[int64](filter, &bounds); matchResult != schema.PartialIntersection {
+		t.Errorf("expected partial intersection of single value range, got %s", matchResult.String())
+	}
+}
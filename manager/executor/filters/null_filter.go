@@ -0,0 +1,92 @@
+package filters
+
+import (
+	"github.com/dot5enko/simple-column-db/bits"
+	"github.com/dot5enko/simple-column-db/lists"
+	executortypes "github.com/dot5enko/simple-column-db/manager/executor/executor_types"
+	"github.com/dot5enko/simple-column-db/manager/query"
+	"github.com/dot5enko/simple-column-db/ops"
+	"github.com/dot5enko/simple-column-db/schema"
+)
+
+// matches filter against block header.
+// rows without value match no condition on values,
+// bounds of the block cover only rows with values
+func ProcessFilterOnBlockHeader[T ops.NumericTypes](
+	filter query.FilterCondition,
+	header *schema.DiskHeader,
+) (schema.BoundsFilterMatchResult, error) {
+
+	if filter.IsNullCheck() {
+		return processNullCheckOnHeader(filter.Operand, header), nil
+	}
+
+	if header.NullCount > 0 && header.NullCount == header.Items {
+		return schema.NoIntersection, nil
+	}
+
+	matchResult, err := ProcessFilterOnBounds[T](filter, &header.Bounds)
+
+	return WithNullRows(matchResult, header), err
+}
+
+// block with nulls can't be matched fully by a condition on values
+func WithNullRows(matchResult schema.BoundsFilterMatchResult, header *schema.DiskHeader) schema.BoundsFilterMatchResult {
+	if matchResult == schema.FullIntersection && header.NullCount > 0 {
+		return schema.PartialIntersection
+	}
+	return matchResult
+}
+
+func processNullCheckOnHeader(operand query.CondOperand, header *schema.DiskHeader) schema.BoundsFilterMatchResult {
+
+	matchResult := schema.PartialIntersection
+
+	if header.NullCount == 0 {
+		matchResult = schema.NoIntersection
+	} else if header.NullCount == header.Items {
+		matchResult = schema.FullIntersection
+	}
+
+	if operand == query.IS_NOT_NULL {
+		switch matchResult {
+		case schema.NoIntersection:
+			matchResult = schema.FullIntersection
+		case schema.FullIntersection:
+			matchResult = schema.NoIntersection
+		}
+	}
+
+	return matchResult
+}
+
+// IS_NULL / IS_NOT_NULL on block data, result is taken from validity bitmap
+func ProcessNullFilterOnBlock(
+	filter query.FilterCondition,
+	blockData *executortypes.BlockRuntimeInfo,
+	merger *lists.IndiceUnmerged,
+) {
+
+	runtimeBlockInfo := blockData.Val
+	_, arrayEndOffset := runtimeBlockInfo.DirectAccess()
+
+	valid := bits.NewFullBitfield()
+	if validity := runtimeBlockInfo.ValidityView(); validity != nil {
+		valid = *validity
+	}
+
+	if filter.Operand == query.IS_NULL {
+		valid.Not()
+	}
+
+	valid.ClearFrom(arrayEndOffset)
+
+	merger.WithOtherBitset(&valid)
+}
+
+// drops rows without value from filter result of the block
+func ExcludeNullRows(blockData *schema.RuntimeBlockData, merger *lists.IndiceUnmerged) {
+	if blockData.HasNulls() {
+		merger.WithOtherBitset(blockData.ValidityView())
+	}
+}
@@ -0,0 +1,122 @@
+package executor
+
+import (
+	"fmt"
+
+	executortypes "github.com/dot5enko/simple-column-db/manager/executor/executor_types"
+	"github.com/dot5enko/simple-column-db/manager/executor/kernels"
+	"github.com/dot5enko/simple-column-db/manager/query"
+	"github.com/dot5enko/simple-column-db/schema"
+)
+
+// aggregates selected rows of a single block into thread local group table
+func groupAggregateBlock(
+	cache *executortypes.ChunkExecutorThreadCache,
+	plan *query.QueryPlan,
+	relIdx int,
+	indices []uint16,
+) error {
+
+	rows := len(indices)
+
+	for groupIdx := range plan.GroupBy {
+		groupBy := &plan.GroupBy[groupIdx]
+
+		blockData := cache.ColumnBlocks[groupBy.ColumnIdx][relIdx]
+		if blockData == nil {
+			return fmt.Errorf("block %d of group column `%s` is not loaded", relIdx, groupBy.ColumnSchemaInfo.Name)
+		}
+
+		keys := cache.KeysCache[groupIdx][:rows]
+
+		gatherErr := gatherGroupKeys(blockData, indices, groupBy.Bucket, keys)
+		if gatherErr != nil {
+			return gatherErr
+		}
+
+		if groupBy.HasTimeFunction() {
+			groupBy.TruncateTimeKeys(keys)
+		}
+	}
+
+	for aggIdx, aggregate := range plan.Aggregates {
+
+		cache.AggregateValidity[aggIdx] = nil
+
+		if aggregate.Function == query.AggCount && (aggregate.ColumnIdx < 0 || !aggregate.ColumnSchemaInfo.Nullable) {
+			continue
+		}
+
+		blockData := cache.ColumnBlocks[aggregate.ColumnIdx][relIdx]
+		if blockData == nil {
+			return fmt.Errorf("block %d of column `%s` is not loaded", relIdx, aggregate.ColumnSchemaInfo.Name)
+		}
+
+		if blockData.HasNulls() {
+			cache.AggregateValidity[aggIdx] = blockData.ValidityView()
+		}
+
+		if aggregate.Function == query.AggCount {
+			continue
+		}
+
+		gatherErr := gatherValues(blockData, indices, cache.AggregateValuesCache[aggIdx][:rows])
+		if gatherErr != nil {
+			return gatherErr
+		}
+	}
+
+	var key query.GroupKey
+
+	for row := range rows {
+
+		for groupIdx := range plan.GroupBy {
+			key[groupIdx] = cache.KeysCache[groupIdx][row]
+		}
+
+		states := cache.GroupTable.Get(key)
+
+		for aggIdx, aggregate := range plan.Aggregates {
+
+			// aggregates skip rows without value
+			validity := cache.AggregateValidity[aggIdx]
+			if validity != nil && validity.Get(int(indices[row])) == 0 {
+				continue
+			}
+
+			if aggregate.Function == query.AggCount {
+				states[aggIdx].Count++
+			} else {
+				states[aggIdx].Add(cache.AggregateValuesCache[aggIdx][row])
+			}
+		}
+	}
+
+	return nil
+}
+
+func gatherGroupKeys(blockData *schema.RuntimeBlockData, indices []uint16, bucket uint64, out []uint64) error {
+
+	typeKernels, typeErr := kernels.Of(blockData.Header.DataType)
+	if typeErr != nil {
+		return fmt.Errorf("unable to group : %s", typeErr.Error())
+	}
+
+	directBlockArray, _ := blockData.DirectAccess()
+	typeKernels.GatherKeys(directBlockArray, indices, bucket, out)
+
+	return nil
+}
+
+func gatherValues(blockData *schema.RuntimeBlockData, indices []uint16, out []float64) error {
+
+	typeKernels, typeErr := kernels.Of(blockData.Header.DataType)
+	if typeErr != nil {
+		return fmt.Errorf("unable to aggregate : %s", typeErr.Error())
+	}
+
+	directBlockArray, _ := blockData.DirectAccess()
+	typeKernels.GatherValues(directBlockArray, indices, out)
+
+	return nil
+}
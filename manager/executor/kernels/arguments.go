@@ -0,0 +1,233 @@
+package kernels
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/dot5enko/simple-column-db/manager/query"
+	"github.com/dot5enko/simple-column-db/ops"
+)
+
+// filter arguments may be given as any numeric Go type, they are converted to the column type.
+// arguments integer column can't hold are rounded inwards:
+// comparison bounds become the nearest column value matching the same rows,
+// EQ / IN values are dropped, so filter may turn into IN or NOT_IN without arguments
+func convertCondition[T ops.NumericTypes](filter query.FilterCondition) (query.FilterCondition, error) {
+
+	args := make([]roundedArgument[T], len(filter.Arguments))
+
+	for idx, arg := range filter.Arguments {
+		rounded, roundErr := roundArgument[T](arg)
+		if roundErr != nil {
+			return filter, roundErr
+		}
+		args[idx] = rounded
+	}
+
+	converted := filter
+	converted.Arguments = make([]any, 0, len(args))
+
+	switch filter.Operand {
+	case query.GT, query.GTE:
+		switch arg := args[0]; {
+		case arg.exact():
+			converted.Arguments = append(converted.Arguments, arg.up)
+		case arg.hasUp:
+			converted.Operand = query.GTE
+			converted.Arguments = append(converted.Arguments, arg.up)
+		default:
+			return matchNothing(filter), nil
+		}
+
+	case query.LT, query.LTE:
+		switch arg := args[0]; {
+		case arg.exact():
+			converted.Arguments = append(converted.Arguments, arg.down)
+		case arg.hasDown:
+			converted.Operand = query.LTE
+			converted.Arguments = append(converted.Arguments, arg.down)
+		default:
+			return matchNothing(filter), nil
+		}
+
+	case query.EQ, query.NEQ, query.IN, query.NOT_IN:
+		for _, arg := range args {
+			if arg.exact() {
+				converted.Arguments = append(converted.Arguments, arg.up)
+			}
+		}
+
+		if len(converted.Arguments) == 0 {
+			converted.Operand = query.IN
+			if filter.Operand == query.NEQ || filter.Operand == query.NOT_IN {
+				converted.Operand = query.NOT_IN
+			}
+		}
+
+	case query.RANGE:
+		// kernels match reversed endpoints as the range between them,
+		// flags stay at the lower and the upper end
+		lower, upper := args[0], args[1]
+		if order, comparable := query.CompareArguments(filter.Arguments[0], filter.Arguments[1]); comparable && order > 0 {
+			lower, upper = upper, lower
+		}
+
+		if !lower.hasUp || !upper.hasDown {
+			return matchNothing(filter), nil
+		}
+
+		if !lower.exact() {
+			converted.Range &^= query.RangeExcludeFrom
+		}
+		if !upper.exact() {
+			converted.Range &^= query.RangeExcludeTo
+		}
+
+		// no column value between rounded endpoints
+		if lower.up > upper.down {
+			return matchNothing(filter), nil
+		}
+
+		converted.Arguments = append(converted.Arguments, lower.up, upper.down)
+
+	default:
+		for _, arg := range args {
+			converted.Arguments = append(converted.Arguments, arg.up)
+		}
+	}
+
+	return converted, nil
+}
+
+func matchNothing(filter query.FilterCondition) query.FilterCondition {
+	filter.Operand = query.IN
+	filter.Arguments = nil
+	filter.Range = query.RangeInclusive
+	return filter
+}
+
+// column values nearest to argument, down <= argument <= up.
+// a side is missing when argument is out of column range on that side
+type roundedArgument[T ops.NumericTypes] struct {
+	down, up       T
+	hasDown, hasUp bool
+}
+
+func (r roundedArgument[T]) exact() bool {
+	return r.hasDown && r.hasUp && r.down == r.up
+}
+
+func roundArgument[T ops.NumericTypes](arg any) (roundedArgument[T], error) {
+
+	switch v := arg.(type) {
+	case T:
+		return roundedArgument[T]{down: v, up: v, hasDown: true, hasUp: true}, nil
+	case int:
+		return fromInt64[T](int64(v)), nil
+	case int8:
+		return fromInt64[T](int64(v)), nil
+	case int16:
+		return fromInt64[T](int64(v)), nil
+	case int32:
+		return fromInt64[T](int64(v)), nil
+	case int64:
+		return fromInt64[T](v), nil
+	case uint:
+		return fromUint64[T](uint64(v)), nil
+	case uint8:
+		return fromUint64[T](uint64(v)), nil
+	case uint16:
+		return fromUint64[T](uint64(v)), nil
+	case uint32:
+		return fromUint64[T](uint64(v)), nil
+	case uint64:
+		return fromUint64[T](v), nil
+	case float32:
+		return fromFloat64[T](float64(v))
+	case float64:
+		return fromFloat64[T](v)
+	default:
+		return roundedArgument[T]{}, fmt.Errorf("argument %v of type %T is not a number", arg, arg)
+	}
+}
+
+func isFloat[T ops.NumericTypes]() bool {
+	var half T = 1
+	half /= 2
+	return half != 0
+}
+
+// smallest and largest values of integer type
+func integerLimits[T ops.NumericTypes]() (minVal, maxVal T) {
+
+	maxVal = 1
+	for next := maxVal*2 + 1; next > maxVal; next = maxVal*2 + 1 {
+		maxVal = next
+	}
+
+	// signed
+	if minVal-1 < minVal {
+		minVal = -maxVal - 1
+	}
+
+	return minVal, maxVal
+}
+
+func fromInt64[T ops.NumericTypes](v int64) roundedArgument[T] {
+	converted := T(v)
+	if isFloat[T]() || (int64(converted) == v && (v < 0) == (converted < 0)) {
+		return roundedArgument[T]{down: converted, up: converted, hasDown: true, hasUp: true}
+	}
+
+	minVal, maxVal := integerLimits[T]()
+	if v < 0 {
+		return roundedArgument[T]{up: minVal, hasUp: true}
+	}
+	return roundedArgument[T]{down: maxVal, hasDown: true}
+}
+
+func fromUint64[T ops.NumericTypes](v uint64) roundedArgument[T] {
+	converted := T(v)
+	if isFloat[T]() || (uint64(converted) == v && converted >= 0) {
+		return roundedArgument[T]{down: converted, up: converted, hasDown: true, hasUp: true}
+	}
+
+	_, maxVal := integerLimits[T]()
+	return roundedArgument[T]{down: maxVal, hasDown: true}
+}
+
+// float columns take the nearest value
+func fromFloat64[T ops.NumericTypes](v float64) (roundedArgument[T], error) {
+
+	if isFloat[T]() {
+		converted := T(v)
+		return roundedArgument[T]{down: converted, up: converted, hasDown: true, hasUp: true}, nil
+	}
+
+	if math.IsNaN(v) {
+		return roundedArgument[T]{}, fmt.Errorf("argument NaN can't be compared with integer column")
+	}
+
+	minVal, maxVal := integerLimits[T]()
+
+	// both are powers of two, float64 of 64 bit max rounds up to the same value
+	lowest, beyondMax := float64(minVal), float64(maxVal)+1
+
+	var rounded roundedArgument[T]
+
+	switch down := math.Floor(v); {
+	case down >= beyondMax:
+		rounded.down, rounded.hasDown = maxVal, true
+	case down >= lowest:
+		rounded.down, rounded.hasDown = T(down), true
+	}
+
+	switch up := math.Ceil(v); {
+	case up < lowest:
+		rounded.up, rounded.hasUp = minVal, true
+	case up < beyondMax:
+		rounded.up, rounded.hasUp = T(up), true
+	}
+
+	return rounded, nil
+}
@@ -0,0 +1,82 @@
+package kernels
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/dot5enko/simple-column-db/manager/query"
+)
+
+func TestConvertConditionRoundsArguments(t *testing.T) {
+
+	cases := []struct {
+		name     string
+		filter   query.FilterCondition
+		expected query.FilterCondition
+	}{
+		{"gt", query.FilterCondition{Operand: query.GT, Arguments: []any{3.5}}, query.FilterCondition{Operand: query.GTE, Arguments: []any{int64(4)}}},
+		{"gte", query.FilterCondition{Operand: query.GTE, Arguments: []any{3.5}}, query.FilterCondition{Operand: query.GTE, Arguments: []any{int64(4)}}},
+		{"lt", query.FilterCondition{Operand: query.LT, Arguments: []any{3.5}}, query.FilterCondition{Operand: query.LTE, Arguments: []any{int64(3)}}},
+		{"lte negative", query.FilterCondition{Operand: query.LTE, Arguments: []any{-3.5}}, query.FilterCondition{Operand: query.LTE, Arguments: []any{int64(-4)}}},
+		{"gt exact float", query.FilterCondition{Operand: query.GT, Arguments: []any{float32(3)}}, query.FilterCondition{Operand: query.GT, Arguments: []any{int64(3)}}},
+		{"eq", query.FilterCondition{Operand: query.EQ, Arguments: []any{3.5}}, query.FilterCondition{Operand: query.IN}},
+		{"neq", query.FilterCondition{Operand: query.NEQ, Arguments: []any{3.5}}, query.FilterCondition{Operand: query.NOT_IN}},
+		{"in", query.FilterCondition{Operand: query.IN, Arguments: []any{3.5, 4, uint8(7)}}, query.FilterCondition{Operand: query.IN, Arguments: []any{int64(4), int64(7)}}},
+		{"gt beyond max", query.FilterCondition{Operand: query.GT, Arguments: []any{uint64(math.MaxUint64)}}, query.FilterCondition{Operand: query.IN}},
+		{"lt beyond max", query.FilterCondition{Operand: query.LT, Arguments: []any{1e30}}, query.FilterCondition{Operand: query.LTE, Arguments: []any{int64(math.MaxInt64)}}},
+		{"gt below min", query.FilterCondition{Operand: query.GT, Arguments: []any{math.Inf(-1)}}, query.FilterCondition{Operand: query.GTE, Arguments: []any{int64(math.MinInt64)}}},
+		{
+			"range",
+			query.FilterCondition{Operand: query.RANGE, Arguments: []any{3.5, 8}, Range: query.RangeExclusive},
+			query.FilterCondition{Operand: query.RANGE, Arguments: []any{int64(4), int64(8)}, Range: query.RangeExcludeTo},
+		},
+		{
+			"reversed range",
+			query.FilterCondition{Operand: query.RANGE, Arguments: []any{7.5, 2}, Range: query.RangeExcludeFrom},
+			query.FilterCondition{Operand: query.RANGE, Arguments: []any{int64(2), int64(7)}, Range: query.RangeExcludeFrom},
+		},
+		{"range between integers", query.FilterCondition{Operand: query.RANGE, Arguments: []any{3.2, 3.7}}, query.FilterCondition{Operand: query.IN}},
+	}
+
+	for _, it := range cases {
+		converted, convertErr := convertCondition[int64](it.filter)
+		if convertErr != nil {
+			t.Errorf("%s : unexpected error %v", it.name, convertErr)
+			continue
+		}
+
+		if converted.Operand != it.expected.Operand || converted.Range != it.expected.Range || !slices.Equal(converted.Arguments, it.expected.Arguments) {
+			t.Errorf("%s : expected %s %v (range %d), got %s %v (range %d)", it.name,
+				it.expected.Operand.String(), it.expected.Arguments, it.expected.Range,
+				converted.Operand.String(), converted.Arguments, converted.Range)
+		}
+	}
+
+	if _, convertErr := convertCondition[int64](query.FilterCondition{Operand: query.GT, Arguments: []any{math.NaN()}}); convertErr == nil {
+		t.Errorf("expected error of NaN argument")
+	}
+}
+
+func TestConvertConditionOutOfColumnRange(t *testing.T) {
+
+	converted, _ := convertCondition[int8](query.FilterCondition{Operand: query.GTE, Arguments: []any{-1000}})
+	if converted.Operand != query.GTE || converted.Arguments[0] != int8(math.MinInt8) {
+		t.Errorf("expected GTE -128, got %s %v", converted.Operand.String(), converted.Arguments)
+	}
+
+	converted, _ = convertCondition[uint16](query.FilterCondition{Operand: query.LTE, Arguments: []any{70000.5}})
+	if converted.Operand != query.LTE || converted.Arguments[0] != uint16(math.MaxUint16) {
+		t.Errorf("expected LTE 65535, got %s %v", converted.Operand.String(), converted.Arguments)
+	}
+
+	converted, _ = convertCondition[uint32](query.FilterCondition{Operand: query.LT, Arguments: []any{-1}})
+	if converted.Operand != query.IN || len(converted.Arguments) != 0 {
+		t.Errorf("expected filter matching nothing, got %s %v", converted.Operand.String(), converted.Arguments)
+	}
+
+	converted, _ = convertCondition[float32](query.FilterCondition{Operand: query.GT, Arguments: []any{3.5}})
+	if converted.Operand != query.GT || converted.Arguments[0] != float32(3.5) {
+		t.Errorf("expected float column to keep argument, got %s %v", converted.Operand.String(), converted.Arguments)
+	}
+}
@@ -0,0 +1,116 @@
+package kernels
+
+import (
+	"fmt"
+
+	"github.com/dot5enko/simple-column-db/lists"
+	executortypes "github.com/dot5enko/simple-column-db/manager/executor/executor_types"
+	"github.com/dot5enko/simple-column-db/manager/executor/filters"
+	"github.com/dot5enko/simple-column-db/manager/query"
+	"github.com/dot5enko/simple-column-db/ops"
+	"github.com/dot5enko/simple-column-db/schema"
+)
+
+// query kernels of a field type, instantiated with its Go type.
+// values are typed arrays of runtime blocks, []T passed as any
+type TypeKernels struct {
+
+	// matches filter against block header
+	FilterHeader func(filter query.FilterCondition, header *schema.DiskHeader) (schema.BoundsFilterMatchResult, error)
+
+	// runs filter on block data and merges matched rows
+	FilterBlock func(filter query.FilterCondition, blockData *executortypes.BlockRuntimeInfo, merger *lists.IndiceUnmerged, indicesCache []uint16) (int, error)
+
+	// converts filter arguments to the Go type of column, operand may change with rounded arguments
+	Condition func(filter query.FilterCondition) (query.FilterCondition, error)
+
+	// sum, min and max of values by indices, indices must not be empty
+	Aggregate func(values any, indices []uint16) (sum, minVal, maxVal float64)
+
+	GatherValues func(values any, indices []uint16, out []float64)
+
+	// group and order keys, see query.CompareEncodedValues
+	GatherKeys func(values any, indices []uint16, bucket uint64, out []uint64)
+
+	Project func(values any, indices []uint16, out []any) []any
+
+	// converts values [from, from + len(out)) to float64
+	ToFloat64 func(values any, from int, out []float64)
+}
+
+var typeKernels = [...]*TypeKernels{
+	schema.Int8FieldType:    newSignedKernels[int8](),
+	schema.Int16FieldType:   newSignedKernels[int16](),
+	schema.Int32FieldType:   newSignedKernels[int32](),
+	schema.Int64FieldType:   newSignedKernels[int64](),
+	schema.Float64FieldType: newFloatKernels[float64](),
+	schema.Float32FieldType: newFloatKernels[float32](),
+	schema.Uint64FieldType:  newUnsignedKernels[uint64](),
+	schema.Uint8FieldType:   newUnsignedKernels[uint8](),
+	schema.Uint32FieldType:  newUnsignedKernels[uint32](),
+	schema.Uint16FieldType:  newUnsignedKernels[uint16](),
+
+	// dictionary codes, filters are translated into codes by planner
+	schema.StringFieldType: newUnsignedKernels[uint32](),
+
+	// time.Time arguments are converted into column units by planner
+	schema.TimestampFieldType: newSignedKernels[int64](),
+}
+
+func Of(typ schema.FieldType) (*TypeKernels, error) {
+	if int(typ) >= len(typeKernels) || typeKernels[typ] == nil {
+		return nil, fmt.Errorf("unsupported field type %d", uint8(typ))
+	}
+	return typeKernels[typ], nil
+}
+
+func newUnsignedKernels[T ops.UnsignedInts]() *TypeKernels {
+	k := newNumericKernels[T]()
+	k.FilterBlock = filters.ProcessUnsignedFilterOnColumnWithType[T]
+	k.GatherKeys = func(values any, indices []uint16, bucket uint64, out []uint64) {
+		ops.GatherUnsignedKeys(values.([]T), indices, bucket, out)
+	}
+	return k
+}
+
+func newSignedKernels[T ops.SignedInts]() *TypeKernels {
+	k := newNumericKernels[T]()
+	k.FilterBlock = filters.ProcessSignedFilterOnColumnWithType[T]
+	k.GatherKeys = func(values any, indices []uint16, bucket uint64, out []uint64) {
+		ops.GatherSignedKeys(values.([]T), indices, bucket, out)
+	}
+	return k
+}
+
+func newFloatKernels[T ops.Floats]() *TypeKernels {
+	k := newNumericKernels[T]()
+	k.FilterBlock = filters.ProcessFloatFilterOnColumnWithType[T]
+	k.GatherKeys = func(values any, indices []uint16, _ uint64, out []uint64) {
+		ops.GatherFloatKeys(values.([]T), indices, out)
+	}
+	return k
+}
+
+// kernels shared by all numeric types
+func newNumericKernels[T ops.NumericTypes]() *TypeKernels {
+	return &TypeKernels{
+		FilterHeader: filters.ProcessFilterOnBlockHeader[T],
+		Condition:    convertCondition[T],
+		Aggregate: func(values any, indices []uint16) (float64, float64, float64) {
+			return ops.AggregateByIndices(values.([]T), indices)
+		},
+		GatherValues: func(values any, indices []uint16, out []float64) {
+			ops.GatherAsFloat64(values.([]T), indices, out)
+		},
+		Project: func(values any, indices []uint16, out []any) []any {
+			arr := values.([]T)
+			for _, idx := range indices {
+				out = append(out, arr[idx])
+			}
+			return out
+		},
+		ToFloat64: func(values any, from int, out []float64) {
+			ops.ConvertToFloat64(values.([]T)[from:from+len(out)], out)
+		},
+	}
+}
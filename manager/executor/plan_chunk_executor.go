@@ -3,9 +3,11 @@ package executor
 import (
 	"fmt"
 
+	"github.com/dot5enko/simple-column-db/bits"
 	"github.com/dot5enko/simple-column-db/lists"
 	executortypes "github.com/dot5enko/simple-column-db/manager/executor/executor_types"
 	"github.com/dot5enko/simple-column-db/manager/executor/filters"
+	"github.com/dot5enko/simple-column-db/manager/executor/kernels"
 	"github.com/dot5enko/simple-column-db/manager/meta"
 	"github.com/dot5enko/simple-column-db/manager/query"
 	"github.com/dot5enko/simple-column-db/schema"
@@ -16,103 +18,55 @@ type BlockMergerContext struct {
 	AbsOffsetStart uint64
 	FilterColumn   []query.FilterConditionRuntime
 
-	FilterColumnRuntimeCache []query.RuntimeFilterCache
-
 	FilterSize int
 
 	Blocks                    []executortypes.BlockRuntimeInfo
 	CurrentBlockProcessingIdx int
 
+	// filter tree results per block
 	AbsBlockMaps []lists.IndiceUnmerged
 
+	// results of single filter conditions
+	LeafHeaderResults *[query.MaxFilterLeaves][query.ExecutorChunkSizeBlocks]schema.BoundsFilterMatchResult
+	LeafMaps          *[query.MaxFilterLeaves][query.ExecutorChunkSizeBlocks]lists.IndiceUnmerged
+
+	BlockFilterResults []schema.BoundsFilterMatchResult
+
 	QueryPlan *query.QueryPlan
 }
 
+// matches column filters against block header bounds,
+// block data is loaded later only for blocks that filter tree can't decide on
 func prepareBlockForMerger(
 	mergerContext *BlockMergerContext,
 
 	slabInfo *schema.DiskSlabHeader,
 	blockHeader *schema.DiskHeader,
-
-	slabsManager *meta.SlabManager,
 ) (err error) {
 
-	skipFilters := 0
 	curRelativeBlockId := mergerContext.CurrentBlockProcessingIdx
 	mergerContext.CurrentBlockProcessingIdx++
 
 	for idx := range mergerContext.FilterColumn {
 
-		filter := mergerContext.FilterColumn[idx]
-
-		var processFilterErr error
-		intersectType := schema.UnknownIntersection
+		filter := &mergerContext.FilterColumn[idx]
+		slabFilter := filter.SlabFilter(slabInfo.Uid)
 
-		switch slabInfo.Type {
-		case schema.Uint64FieldType:
-			intersectType, processFilterErr = filters.ProcessFilterOnBounds[uint64](filter.Filter, &blockHeader.Bounds)
-		case schema.Uint8FieldType:
-			intersectType, processFilterErr = filters.ProcessFilterOnBounds[uint8](filter.Filter, &blockHeader.Bounds)
-		case schema.Float32FieldType:
-			intersectType, processFilterErr = filters.ProcessFilterOnBounds[float32](filter.Filter, &blockHeader.Bounds)
-		case schema.Float64FieldType:
-			intersectType, processFilterErr = filters.ProcessFilterOnBounds[float64](filter.Filter, &blockHeader.Bounds)
-		default:
-			return fmt.Errorf("unsupported type %v while filtering block headers", slabInfo.Type.String())
+		typeKernels, typeErr := kernels.Of(slabInfo.Type)
+		if typeErr != nil {
+			return fmt.Errorf("error filtering block headers : %s", typeErr.Error())
 		}
 
+		intersectType, processFilterErr := typeKernels.FilterHeader(slabFilter, blockHeader)
 		if processFilterErr != nil {
 			return fmt.Errorf("error filter processing : %s", processFilterErr.Error())
-		} else {
-
-			skipSingleBlock := intersectType == schema.NoIntersection
-
-			filterColumnCache := &mergerContext.FilterColumnRuntimeCache[idx]
-
-			filterColumnCache.FilterLastBlockHeaderResult = intersectType
-			filterColumnCache.FilterBounds = blockHeader.Bounds
-
-			if skipSingleBlock {
-				skipFilters++
-			}
 		}
-	}
 
-	fullSkipBlock := skipFilters == mergerContext.FilterSize
+		mergerContext.LeafHeaderResults[filter.LeafIdx][curRelativeBlockId] = intersectType
+	}
 
 	blockRT := &mergerContext.Blocks[curRelativeBlockId]
 	blockRT.BlockHeader = blockHeader
-	// blockRT.SlabHeader = slabInfo
-
-	// increase current block pointer
-
-	if !fullSkipBlock {
-		// todo fix
-		blockDecodedInfo, blockErr := slabsManager.LoadBlockToRuntimeBlockData(mergerContext.Schema, slabInfo, blockHeader.Uid)
-
-		// log.Printf("--- loaded block %s: @ %p", blockHeader.Uid.String(), blockDecodedInfo.DataTypedArray)
-
-		if blockErr != nil {
-			return fmt.Errorf("unable to decode block : %s", blockErr.Error())
-		}
-
-		blockRT.Val = blockDecodedInfo
-	} else {
-		absBlockRTInfo := &mergerContext.AbsBlockMaps[curRelativeBlockId]
-
-		// preallocated for each thread executor
-		// check if works correctly
-		absBlockRTInfo.Reset()
-		absBlockRTInfo.SetFullSkip()
-	}
-
-	for filterIdx := range mergerContext.FilterColumn {
-		refResult := &blockRT.HeaderFilterMatchResult[filterIdx]
-
-		//
-		refResult.MatchResult = mergerContext.FilterColumnRuntimeCache[filterIdx].FilterLastBlockHeaderResult
-		refResult.Bounds = mergerContext.FilterColumnRuntimeCache[filterIdx].FilterBounds
-	}
 
 	return nil
 }
@@ -123,42 +77,44 @@ type SingleColumnProcessingResult struct {
 	fullSkips                         int
 }
 
-func preprocessSegmentsIntoBlocksAndHeaderFilter(
+// iterates over blocks of the segments in the order
+// they are laid out in a thread cache
+func forEachSegmentBlock(
 	sm *meta.SlabManager,
-	slabMergerContext *BlockMergerContext,
+	schemaObject *schema.Schema,
 	segments []query.Segment,
+	cb func(slabInfo *schema.DiskSlabHeader, blockIdx int, blockHeader *schema.DiskHeader) error,
 ) error {
 
 	for _, segment := range segments {
 
 		slabBlockOffsetStart := segment.StartBlock
 
-		slabInfo, slabErr := sm.LoadSlabHeaderToCache(&slabMergerContext.Schema, segment.Slab)
+		slabInfo, slabErr := sm.LoadSlabHeaderToCache(schemaObject, segment.Slab)
 		if slabErr != nil {
-			return fmt.Errorf("unable to load slab : %s", slabErr.Error())
+			return withSlab(segment.Slab, fmt.Errorf("unable to load slab : %w", slabErr))
 		}
 
-		blockHeaders := slabInfo.BlockHeaders
-
-		// todo remove internal function call here
-		// move whole loop into separate func
+		// headers are copied, ingest changes the active block and finalizes blocks meanwhile.
+		// copies stay in chunk cache while the chunk is processed
+		blockHeaders := make([]schema.DiskHeader, 0, segment.Size)
 
+		slabInfo.RLock()
 		for i := 0; i < int(segment.Size); i++ {
 			idx := i + slabBlockOffsetStart
 
-			if idx > int(slabInfo.BlocksFinalized) {
+			if idx > int(slabInfo.BlocksFinalized) || idx >= len(slabInfo.BlockHeaders) {
 				break
 			}
 
-			blockHeader := &blockHeaders[idx]
+			blockHeaders = append(blockHeaders, slabInfo.BlockHeaders[idx])
+		}
+		slabInfo.RUnlock()
 
-			preparationErr := prepareBlockForMerger(slabMergerContext,
-				slabInfo,
-				blockHeader,
-				sm,
-			)
-			if preparationErr != nil {
-				return fmt.Errorf("unable to prepare block for merging : %s", preparationErr.Error())
+		for i := range blockHeaders {
+			cbErr := cb(slabInfo, i+slabBlockOffsetStart, &blockHeaders[i])
+			if cbErr != nil {
+				return withSlab(segment.Slab, cbErr)
 			}
 		}
 	}
@@ -166,70 +122,180 @@ func preprocessSegmentsIntoBlocksAndHeaderFilter(
 	return nil
 }
 
-func processFiltersOnPreparedBlocks(mCtx *BlockMergerContext, indicesResultCache []uint16) (result SingleColumnProcessingResult, topErr error) {
+func preprocessSegmentsIntoBlocksAndHeaderFilter(
+	sm *meta.SlabManager,
+	slabMergerContext *BlockMergerContext,
+	segments []query.Segment,
+) error {
 
-	// get slab bounds
-	// curBlocksPerSlab := slabInfo.Type.BlocksPerSlab()
+	return forEachSegmentBlock(sm, &slabMergerContext.Schema, segments, func(slabInfo *schema.DiskSlabHeader, _ int, blockHeader *schema.DiskHeader) error {
 
-	for blockRelativeIdx := range mCtx.CurrentBlockProcessingIdx {
+		preparationErr := prepareBlockForMerger(slabMergerContext,
+			slabInfo,
+			blockHeader,
+		)
+		if preparationErr != nil {
+			return fmt.Errorf("unable to prepare block for merging : %s", preparationErr.Error())
+		}
 
-		blockData := &mCtx.Blocks[blockRelativeIdx]
+		return nil
+	})
+}
 
-		blockGroupMerger := &mCtx.AbsBlockMaps[blockRelativeIdx]
-		if blockGroupMerger.FullSkip() {
+// runs column filters on blocks which are partially matched by the filter tree.
+// results are kept per filter leaf and combined by the tree afterwards
+func processFiltersOnPreparedBlocks(
+	sm *meta.SlabManager,
+	mCtx *BlockMergerContext,
+	segments []query.Segment,
+	indicesResultCache []uint16,
+) (result SingleColumnProcessingResult, topErr error) {
 
-			result.fullSkips += 1
+	mCtx.CurrentBlockProcessingIdx = 0
+
+	topErr = forEachSegmentBlock(sm, &mCtx.Schema, segments, func(slabInfo *schema.DiskSlabHeader, _ int, blockHeader *schema.DiskHeader) error {
+
+		blockRelativeIdx := mCtx.CurrentBlockProcessingIdx
+		mCtx.CurrentBlockProcessingIdx++
 
-			continue
+		if blockRelativeIdx >= len(mCtx.BlockFilterResults) {
+			return nil
 		}
 
-		// slog.Info("processing block OK", "block_relative_idx", blockRelativeIdx, "block_data_is_nil", blockData.Val == nil)
-		blockDataType := blockData.BlockHeader.DataType
+		switch mCtx.BlockFilterResults[blockRelativeIdx] {
+		case schema.NoIntersection:
+			result.fullSkips += 1
+			return nil
+		case schema.FullIntersection:
+			result.skippedBlocksDueToHeaderFiltering += len(mCtx.FilterColumn)
+			return nil
+		}
+
+		blockData := &mCtx.Blocks[blockRelativeIdx]
+		blockData.BlockHeader = blockHeader
+		blockData.Val = nil
 
-		for fIdx, filter := range mCtx.FilterColumn {
+		blockDataType := blockHeader.DataType
 
-			headerMatchResultObj := blockData.HeaderFilterMatchResult[fIdx]
-			headerMatchResult := headerMatchResultObj.MatchResult
+		for filterIdx := range mCtx.FilterColumn {
 
-			isFull := headerMatchResult == schema.FullIntersection
+			filter := &mCtx.FilterColumn[filterIdx]
+			headerMatchResult := mCtx.LeafHeaderResults[filter.LeafIdx][blockRelativeIdx]
 
-			if isFull {
+			if headerMatchResult == schema.FullIntersection || headerMatchResult == schema.NoIntersection {
 				result.skippedBlocksDueToHeaderFiltering += 1
-
-				blockGroupMerger.With(nil, false, true)
 				continue
 			}
 
 			result.processedBlocks += 1
 
-			{
-				var processFilterErr error
-				var filteredSize int
-
-				// process filter on a block
-				switch blockDataType {
-				case schema.Uint64FieldType:
-					filteredSize, processFilterErr = filters.ProcessUnsignedFilterOnColumnWithType[uint64](filter.Filter, blockData, blockGroupMerger, indicesResultCache[:])
-				case schema.Uint8FieldType:
-					filteredSize, processFilterErr = filters.ProcessUnsignedFilterOnColumnWithType[uint8](filter.Filter, blockData, blockGroupMerger, indicesResultCache[:])
-				case schema.Float32FieldType:
-					filteredSize, processFilterErr = filters.ProcessFloatFilterOnColumnWithType[float32](filter.Filter, blockData, blockGroupMerger, indicesResultCache[:])
-				case schema.Float64FieldType:
-					filteredSize, processFilterErr = filters.ProcessFloatFilterOnColumnWithType[float64](filter.Filter, blockData, blockGroupMerger, indicesResultCache[:])
-				default:
-					return SingleColumnProcessingResult{}, fmt.Errorf("unsupported type %v", blockDataType.String())
+			if blockData.Val == nil {
+				// todo fix
+				blockDecodedInfo, blockErr := sm.LoadBlockToRuntimeBlockData(mCtx.Schema, slabInfo, blockHeader.Uid)
+				if blockErr != nil {
+					return fmt.Errorf("unable to decode block : %w", blockErr)
 				}
 
-				_ = filteredSize
+				blockData.Val = blockDecodedInfo
+			}
 
-				if processFilterErr != nil {
-					return SingleColumnProcessingResult{}, fmt.Errorf("error filter processing : %s. sum of bitset = %d, bitcount = %d", processFilterErr.Error(), blockGroupMerger.ResultBitset.Sum(), blockGroupMerger.ResultBitset.Count())
-				}
+			leafMerger := &mCtx.LeafMaps[filter.LeafIdx][blockRelativeIdx]
+
+			slabFilter := filter.SlabFilter(slabInfo.Uid)
+
+			if slabFilter.IsNullCheck() {
+				filters.ProcessNullFilterOnBlock(slabFilter, blockData, leafMerger)
+				continue
+			}
 
-				// slog.Info(" -- [filtered]", "filteredSize", filteredSize, "header_match_cached", headerMatchResult.String(), "arg", filter, "filter_bounds", headerMatchResultObj.Bounds)
+			typeKernels, typeErr := kernels.Of(blockDataType)
+			if typeErr != nil {
+				return fmt.Errorf("error filtering block : %s", typeErr.Error())
 			}
+
+			// process filter on a block
+			_, processFilterErr := typeKernels.FilterBlock(slabFilter, blockData, leafMerger, indicesResultCache[:])
+
+			if processFilterErr != nil {
+				return fmt.Errorf("error filter processing : %s. bitcount = %d", processFilterErr.Error(), leafMerger.ResultBitset.Count())
+			}
+
+			filters.ExcludeNullRows(blockData.Val, leafMerger)
 		}
-	}
+
+		return nil
+	})
 
 	return
 }
+
+// evaluates filter tree on a block using per leaf results.
+// dst is filled only when the result is a partial intersection
+func evalFilterTree(cache *executortypes.ChunkExecutorThreadCache, node *query.FilterNodeRT, blockRelativeIdx int, dst *bits.Bitfield) schema.BoundsFilterMatchResult {
+
+	switch node.Type {
+	case query.FilterLeaf:
+
+		headerMatchResult := cache.LeafHeaderResults[node.LeafIdx][blockRelativeIdx]
+		if headerMatchResult == schema.FullIntersection || headerMatchResult == schema.NoIntersection {
+			return headerMatchResult
+		}
+
+		*dst = cache.LeafMaps[node.LeafIdx][blockRelativeIdx].ResultBitset
+		return schema.PartialIntersection
+
+	case query.FilterAnd, query.FilterOr:
+
+		isAnd := node.Type == query.FilterAnd
+		hasPartial := false
+
+		for idx := range node.Children {
+
+			child := &node.Children[idx]
+			childBits := &cache.FilterNodeBitsets[child.Id]
+
+			switch evalFilterTree(cache, child, blockRelativeIdx, childBits) {
+			case schema.NoIntersection:
+				if isAnd {
+					return schema.NoIntersection
+				}
+			case schema.FullIntersection:
+				if !isAnd {
+					return schema.FullIntersection
+				}
+			default:
+				if !hasPartial {
+					*dst = *childBits
+					hasPartial = true
+				} else if isAnd {
+					dst.And(*childBits)
+				} else {
+					dst.Or(childBits)
+				}
+			}
+		}
+
+		if hasPartial {
+			return schema.PartialIntersection
+		} else if isAnd {
+			return schema.FullIntersection
+		} else {
+			return schema.NoIntersection
+		}
+
+	case query.FilterNot:
+
+		switch evalFilterTree(cache, &node.Children[0], blockRelativeIdx, dst) {
+		case schema.NoIntersection:
+			return schema.FullIntersection
+		case schema.FullIntersection:
+			return schema.NoIntersection
+		default:
+			// bits past the last row are cleared by the caller
+			dst.Not()
+			return schema.PartialIntersection
+		}
+	}
+
+	return schema.NoIntersection
+}
@@ -0,0 +1,82 @@
+package executor
+
+import (
+	"fmt"
+
+	executortypes "github.com/dot5enko/simple-column-db/manager/executor/executor_types"
+	"github.com/dot5enko/simple-column-db/manager/executor/kernels"
+	"github.com/dot5enko/simple-column-db/manager/query"
+	"github.com/dot5enko/simple-column-db/schema"
+)
+
+// appends selected rows of a single block to the chunk projection
+// indices are sorted, so rows keep their global order
+func projectBlock(
+	cache *executortypes.ChunkExecutorThreadCache,
+	plan *query.QueryPlan,
+	relIdx int,
+	indices []uint16,
+	projection *query.Projection,
+) error {
+
+	// unordered limit keeps first rows of the chunk
+	if plan.Limit > 0 && len(plan.OrderBy) == 0 {
+		leftover := plan.Limit - len(projection.RowIds)
+		if leftover <= 0 {
+			return nil
+		}
+		if len(indices) > leftover {
+			indices = indices[:leftover]
+		}
+	}
+
+	blockRowOffset := cache.BlockAbsIdx[relIdx] * schema.BlockRowsSize
+
+	for _, idx := range indices {
+		projection.RowIds = append(projection.RowIds, blockRowOffset+uint64(idx))
+	}
+
+	for projIdx, projected := range plan.Projections {
+
+		blockData := cache.ColumnBlocks[projected.ColumnIdx][relIdx]
+		if blockData == nil {
+			return fmt.Errorf("block %d of column `%s` is not loaded", relIdx, projected.ColumnSchemaInfo.Name)
+		}
+
+		directBlockArray, _ := blockData.DirectAccess()
+		values := projection.Values[projIdx]
+		start := len(values)
+
+		if blockData.Dictionary != nil {
+			values = blockData.Dictionary.DecodeAt(directBlockArray.([]uint32), indices, values)
+		} else {
+			typeKernels, typeErr := kernels.Of(blockData.Header.DataType)
+			if typeErr != nil {
+				return fmt.Errorf("unable to project : %s", typeErr.Error())
+			}
+
+			values = typeKernels.Project(directBlockArray, indices, values)
+		}
+
+		if projected.ColumnSchemaInfo.Type == schema.TimestampFieldType {
+			precision := projected.ColumnSchemaInfo.Precision
+			for i := start; i < len(values); i++ {
+				values[i] = precision.ToTime(values[i].(int64))
+			}
+		}
+
+		// rows without value are projected as nil
+		if blockData.HasNulls() {
+			validity := blockData.ValidityView()
+			for i, idx := range indices {
+				if validity.Get(int(idx)) == 0 {
+					values[start+i] = nil
+				}
+			}
+		}
+
+		projection.Values[projIdx] = values
+	}
+
+	return nil
+}
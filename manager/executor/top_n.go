@@ -0,0 +1,164 @@
+package executor
+
+import (
+	"container/heap"
+	"fmt"
+	"slices"
+
+	executortypes "github.com/dot5enko/simple-column-db/manager/executor/executor_types"
+	"github.com/dot5enko/simple-column-db/manager/query"
+	"github.com/dot5enko/simple-column-db/schema"
+)
+
+type topRow struct {
+	key   query.OrderKey
+	rowId uint64
+
+	// position of the row within a chunk
+	relIdx int
+	idx    uint16
+}
+
+// bounded heap of the best rows of a chunk
+// the worst row is kept on top, so it can be replaced by a better one
+type topRowsHeap struct {
+	rows    []topRow
+	orderBy []query.OrderByRT
+
+	// 0 means unbounded
+	limit int
+}
+
+func newTopRowsHeap(plan *query.QueryPlan) *topRowsHeap {
+	return &topRowsHeap{
+		rows:    make([]topRow, 0, plan.Limit),
+		orderBy: plan.OrderBy,
+		limit:   plan.Limit,
+	}
+}
+
+func (h *topRowsHeap) compare(a, b *topRow) int {
+	c := query.CompareOrderKeys(h.orderBy, &a.key, &b.key)
+	if c == 0 {
+		if a.rowId < b.rowId {
+			return -1
+		} else if a.rowId > b.rowId {
+			return 1
+		}
+	}
+	return c
+}
+
+func (h *topRowsHeap) Len() int           { return len(h.rows) }
+func (h *topRowsHeap) Less(i, j int) bool { return h.compare(&h.rows[i], &h.rows[j]) > 0 }
+func (h *topRowsHeap) Swap(i, j int)      { h.rows[i], h.rows[j] = h.rows[j], h.rows[i] }
+func (h *topRowsHeap) Push(x any)         { h.rows = append(h.rows, x.(topRow)) }
+func (h *topRowsHeap) Pop() any {
+	last := h.rows[len(h.rows)-1]
+	h.rows = h.rows[:len(h.rows)-1]
+	return last
+}
+
+func (h *topRowsHeap) full() bool {
+	return h.limit > 0 && len(h.rows) >= h.limit
+}
+
+func (h *topRowsHeap) offer(row topRow) {
+	if !h.full() {
+		heap.Push(h, row)
+		return
+	}
+
+	if h.compare(&row, &h.rows[0]) < 0 {
+		h.rows[0] = row
+		heap.Fix(h, 0)
+	}
+}
+
+// block can be skipped when heap is full and none of its values
+// can beat the worst row by the first order column
+func (h *topRowsHeap) blockCanBeSkipped(bounds *schema.BoundsFloat) bool {
+	if !h.full() {
+		return false
+	}
+
+	first := h.orderBy[0]
+	worst := query.EncodedValueFloat(first.ColumnSchemaInfo.Type, h.rows[0].key[0])
+
+	if first.Desc {
+		return bounds.Max < worst
+	}
+	return bounds.Min > worst
+}
+
+func collectTopRows(
+	cache *executortypes.ChunkExecutorThreadCache,
+	plan *query.QueryPlan,
+	relIdx int,
+	indices []uint16,
+	topRows *topRowsHeap,
+) error {
+
+	rows := len(indices)
+
+	for keyIdx, orderBy := range plan.OrderBy {
+		blockData := cache.ColumnBlocks[orderBy.ColumnIdx][relIdx]
+		if blockData == nil {
+			return fmt.Errorf("block %d of order column `%s` is not loaded", relIdx, orderBy.ColumnSchemaInfo.Name)
+		}
+
+		gatherErr := gatherGroupKeys(blockData, indices, 0, cache.KeysCache[keyIdx][:rows])
+		if gatherErr != nil {
+			return gatherErr
+		}
+	}
+
+	blockRowOffset := cache.BlockAbsIdx[relIdx] * schema.BlockRowsSize
+
+	for row, idx := range indices {
+
+		candidate := topRow{
+			rowId:  blockRowOffset + uint64(idx),
+			relIdx: relIdx,
+			idx:    idx,
+		}
+
+		for keyIdx := range plan.OrderBy {
+			candidate.key[keyIdx] = cache.KeysCache[keyIdx][row]
+		}
+
+		topRows.offer(candidate)
+	}
+
+	return nil
+}
+
+// materializes heap rows in the result order
+// blocks of the chunk must still be loaded in thread cache
+func materializeTopRows(
+	cache *executortypes.ChunkExecutorThreadCache,
+	plan *query.QueryPlan,
+	topRows *topRowsHeap,
+	projection *query.Projection,
+) error {
+
+	slices.SortFunc(topRows.rows, func(a, b topRow) int {
+		return topRows.compare(&a, &b)
+	})
+
+	var rowIndex [1]uint16
+
+	for _, row := range topRows.rows {
+
+		rowIndex[0] = row.idx
+
+		projectErr := projectBlock(cache, plan, row.relIdx, rowIndex[:], projection)
+		if projectErr != nil {
+			return projectErr
+		}
+
+		projection.OrderKeys = append(projection.OrderKeys, row.key)
+	}
+
+	return nil
+}
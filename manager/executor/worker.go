@@ -1,6 +1,7 @@
 package executor
 
 import (
+	"context"
 	"sync"
 	"sync/atomic"
 
@@ -12,13 +13,70 @@ type TaskStatus struct {
 	ChunksTotal     int
 	ChunksProcessed atomic.Int32
 
+	// set by the first failed chunk, chunks left are skipped
 	Err       atomic.Bool
 	ErrObject error
 
 	ChunkResult ChunkFilterProcessResult
 
-	Waiter sync.WaitGroup
-	Lock   sync.Mutex
+	// projected rows by chunk index
+	// chunks are ordered by blocks, so concatenation keeps global row order
+	Projections []*query.Projection
+
+	// closed once every chunk is processed or any of them failed
+	Done     chan struct{}
+	doneOnce sync.Once
+
+	Lock sync.Mutex
+}
+
+func NewTaskStatus(chunksTotal int) *TaskStatus {
+
+	status := &TaskStatus{
+		ChunksTotal: chunksTotal,
+		Done:        make(chan struct{}),
+	}
+
+	// nothing to wait for
+	if chunksTotal == 0 {
+		status.complete()
+	}
+
+	return status
+}
+
+// counts chunk as processed, skipped chunks are counted too
+func (s *TaskStatus) ChunkDone() {
+	if s.ChunksProcessed.Add(1) == int32(s.ChunksTotal) {
+		s.complete()
+	}
+}
+
+// completes the task with an error, only the first one is kept
+func (s *TaskStatus) Fail(err error) {
+
+	s.Lock.Lock()
+	if s.ErrObject == nil {
+		s.ErrObject = err
+		s.Err.Store(true)
+	}
+	s.Lock.Unlock()
+
+	s.complete()
+}
+
+// error of the task, valid once Done is closed
+func (s *TaskStatus) Error() error {
+	if !s.Err.Load() {
+		return nil
+	}
+	return s.ErrObject
+}
+
+func (s *TaskStatus) complete() {
+	s.doneOnce.Do(func() {
+		close(s.Done)
+	})
 }
 
 type ChunkProcessingTask struct {
@@ -29,4 +87,7 @@ type ChunkProcessingTask struct {
 	ChunkIdx int
 
 	Status *TaskStatus
+
+	// context of the query, chunks are skipped once it's done
+	Ctx context.Context
 }
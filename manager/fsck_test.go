@@ -0,0 +1,197 @@
+package manager
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dot5enko/simple-column-db/manager/meta"
+	"github.com/dot5enko/simple-column-db/schema"
+	"github.com/google/uuid"
+)
+
+// storage with a slab per column, nullable and string columns keep .valid and .dict files.
+// returns slabs of columns id, n and s
+func fsckTestStorage(t *testing.T, dir string) []uuid.UUID {
+
+	t.Helper()
+
+	m := openTestManager(t, dir, schema.Schema{Name: "fsck", Columns: []schema.SchemaColumn{
+		{Name: "id", Type: schema.Uint64FieldType},
+		{Name: "n", Type: schema.Int32FieldType, Nullable: true},
+		{Name: "s", Type: schema.StringFieldType},
+	}})
+
+	data := IngestBufferFromBinary(nil, []string{"id", "n", "s"})
+	for i := range 1000 {
+		data.dataBuffer = binary.LittleEndian.AppendUint64(data.dataBuffer, uint64(i))
+		data.dataBuffer = binary.LittleEndian.AppendUint32(data.dataBuffer, uint32(i))
+		data.dataBuffer = binary.LittleEndian.AppendUint32(data.dataBuffer, data.AddString([]string{"a", "b"}[i%2]))
+		if i%3 == 0 {
+			data.SetNull("n", i)
+		}
+	}
+
+	if ingestErr := m.Ingest("fsck", data); ingestErr != nil {
+		t.Fatal(ingestErr)
+	}
+
+	slabs := []uuid.UUID{}
+	for _, col := range m.Meta.GetSchema("fsck").Columns {
+		slabs = append(slabs, col.Slabs[0])
+	}
+
+	closeTestManager(t, m)
+
+	report, checkErr := meta.CheckStorage(dir, false)
+	if checkErr != nil {
+		t.Fatal(checkErr)
+	}
+
+	if len(report.Issues) != 0 || report.Slabs != 3 {
+		t.Fatalf("expected 3 slabs without issues, got %d slabs : %v", report.Slabs, report.Issues)
+	}
+
+	return slabs
+}
+
+func checkTestStorage(t *testing.T, dir string, repair bool) []meta.FsckIssue {
+
+	t.Helper()
+
+	report, checkErr := meta.CheckStorage(dir, repair)
+	if checkErr != nil {
+		t.Fatal(checkErr)
+	}
+
+	return report.Issues
+}
+
+// issue about slab with problem containing text
+func findTestIssue(issues []meta.FsckIssue, slab uuid.UUID, text string) *meta.FsckIssue {
+	for idx := range issues {
+		if issues[idx].Slab == slab && strings.Contains(issues[idx].Problem, text) {
+			return &issues[idx]
+		}
+	}
+	return nil
+}
+
+func TestFsckCorruptedSlabHeader(t *testing.T) {
+
+	dir := t.TempDir()
+	slabs := fsckTestStorage(t, dir)
+
+	flipTestSlabByte(t, dir, "fsck", slabs[1], 20)
+
+	for _, repair := range []bool{false, true} {
+
+		issues := checkTestStorage(t, dir, repair)
+
+		issue := findTestIssue(issues, slabs[1], "")
+		if issue == nil {
+			t.Fatalf("expected issue of slab with corrupted header, got %v", issues)
+		}
+
+		// nothing else holds rows of the column
+		if issue.Repaired {
+			t.Errorf("expected broken slab to stay unrepaired : %s", issue.String())
+		}
+	}
+}
+
+func TestFsckTruncatedSlab(t *testing.T) {
+
+	dir := t.TempDir()
+	slabs := fsckTestStorage(t, dir)
+
+	truncateErr := os.Truncate(filepath.Join(dir, "fsck", slabs[2].String()+".slab"), schema.SlabHeaderFixedSize+100)
+	if truncateErr != nil {
+		t.Fatal(truncateErr)
+	}
+
+	issues := checkTestStorage(t, dir, false)
+
+	if findTestIssue(issues, slabs[2], "header expects at least") == nil {
+		t.Errorf("expected issue of truncated slab, got %v", issues)
+	}
+
+	if truncateErr = os.Truncate(filepath.Join(dir, "fsck", slabs[2].String()+".slab"), 10); truncateErr != nil {
+		t.Fatal(truncateErr)
+	}
+
+	issues = checkTestStorage(t, dir, false)
+
+	if findTestIssue(issues, slabs[2], "shorter than slab header") == nil {
+		t.Errorf("expected issue of slab shorter than header, got %v", issues)
+	}
+}
+
+func TestFsckOrphanSideFiles(t *testing.T) {
+
+	dir := t.TempDir()
+	slabs := fsckTestStorage(t, dir)
+
+	schemaPath := filepath.Join(dir, "fsck")
+
+	// files left after their slab file is gone
+	lost := uuid.New()
+	for from, to := range map[string]string{
+		slabs[1].String() + ".valid": lost.String() + ".valid",
+		slabs[2].String() + ".dict":  lost.String() + ".dict",
+	} {
+		contents, readErr := os.ReadFile(filepath.Join(schemaPath, from))
+		if readErr != nil {
+			t.Fatal(readErr)
+		}
+
+		if writeErr := os.WriteFile(filepath.Join(schemaPath, to), contents, 0644); writeErr != nil {
+			t.Fatal(writeErr)
+		}
+	}
+
+	// slabs of n and s are no longer listed by their columns
+	metaManager := meta.NewMetaManager(dir)
+	if loadErr := metaManager.LoadSchemesFromDisk(); loadErr != nil {
+		t.Fatal(loadErr)
+	}
+
+	schemaObject := *metaManager.GetSchema("fsck")
+	schemaObject.Columns = append([]schema.SchemaColumn{}, schemaObject.Columns...)
+	for _, col := range []int{1, 2} {
+		schemaObject.Columns[col].Slabs = nil
+		schemaObject.Columns[col].ActiveSlab = uuid.Nil
+	}
+
+	if storeErr := metaManager.StoreSchemeToDisk(schemaObject); storeErr != nil {
+		t.Fatal(storeErr)
+	}
+
+	issues := checkTestStorage(t, dir, true)
+
+	for _, it := range []struct {
+		slab     uuid.UUID
+		text     string
+		repaired bool
+	}{
+		{lost, lost.String() + ".valid, slab file is missing", false},
+		{lost, lost.String() + ".dict, slab file is missing", false},
+		{slabs[1], slabs[1].String() + ".valid of slab not listed", true},
+		{slabs[2], slabs[2].String() + ".dict of slab not listed", true},
+	} {
+		issue := findTestIssue(issues, it.slab, it.text)
+		if issue == nil {
+			t.Errorf("expected issue `%s`, got %v", it.text, issues)
+		} else if issue.Repaired != it.repaired {
+			t.Errorf("expected repaired %v : %s", it.repaired, issue.String())
+		}
+	}
+
+	// orphan slabs are listed again, files of the lost slab stay
+	issues = checkTestStorage(t, dir, false)
+	if len(issues) != 2 || findTestIssue(issues, lost, ".valid") == nil || findTestIssue(issues, lost, ".dict") == nil {
+		t.Errorf("expected only issues of files without slab, got %v", issues)
+	}
+}
@@ -3,17 +3,25 @@ package manager
 import (
 	"errors"
 	"fmt"
+	"log/slog"
+	"math"
 	"reflect"
+	"slices"
+	"sync"
 	"time"
 	"unsafe"
 
 	"github.com/dot5enko/simple-column-db/bits"
 	"github.com/dot5enko/simple-column-db/manager/cache"
+	"github.com/dot5enko/simple-column-db/manager/meta"
 	"github.com/dot5enko/simple-column-db/schema"
 	"github.com/fatih/color"
 	"github.com/google/uuid"
 )
 
+// columns of a batch written at the same time
+const ingestColumnWriters = 4
+
 type layoutFieldInfo struct {
 	index int
 	typ   schema.FieldType
@@ -24,12 +32,30 @@ type layoutFieldInfo struct {
 
 	DataArray any
 
+	// string values are translated into codes of the slab they are written to
+	codes []uint32
+
+	// rows without value, nil for not nullable columns
+	nulls []bool
+
+	// nullable column absent in the ingested data
+	missing bool
+
+	// slabs the column is written to
+	written []uuid.UUID
+
 	ingested int
 	leftover int
 }
 
 func (m *Manager) Ingest(schemaName string, data *IngestBuffer) error {
 
+	operationErr := m.beginOperation()
+	if operationErr != nil {
+		return operationErr
+	}
+	defer m.endOperation()
+
 	// get the schema object from name
 	schemaObject := m.Meta.GetSchema(schemaName)
 
@@ -37,7 +63,176 @@ func (m *Manager) Ingest(schemaName string, data *IngestBuffer) error {
 		return errors.New("schema not found")
 	}
 
-	var fieldsLayout []*layoutFieldInfo = make([]*layoutFieldInfo, len(data.FieldsLayout))
+	unlock, lockErr := m.Meta.LockSchemaWriter(schemaName)
+	if lockErr != nil {
+		return lockErr
+	}
+	defer unlock()
+
+	wal, walErr := m.schemaWal(schemaName)
+	if walErr != nil {
+		return walErr
+	}
+
+	// columns of the schema may have different rows until the failed batch is replayed
+	if wal.Failed() {
+		return fmt.Errorf("unable to ingest into `%s` : %w", schemaName, ErrIngestFailed)
+	}
+
+	fieldsLayout, prepareErr := m.prepareIngest(schemaObject, data)
+	if prepareErr != nil {
+		return prepareErr
+	}
+
+	defer m.unpinIngested(fieldsLayout)
+
+	record := &walRecord{
+		Schema:     schemaName,
+		ColumnRows: map[string]uint64{},
+		Layout:     data.FieldsLayout,
+		Strings:    data.Strings,
+		Nulls:      data.nulls,
+		Data:       data.dataBuffer,
+		Columns:    data.columnData,
+	}
+
+	for _, field := range fieldsLayout {
+		record.ColumnRows[field.name] = columnRows(field.slab.Header)
+	}
+
+	appendErr := wal.Append(record)
+	if appendErr != nil {
+		return appendErr
+	}
+
+	// partially applied batch stays in the log until it's replayed
+	ingestErr := m.ingestPrepared(schemaObject, fieldsLayout)
+	if ingestErr == nil {
+		ingestErr = m.syncIngested(schemaObject, fieldsLayout)
+	}
+
+	// rows of a failed batch are not exposed by it
+	if ingestErr == nil {
+		m.Meta.CommitRows(schemaName, ingestedRows(fieldsLayout))
+	}
+
+	appliedErr := wal.Applied(ingestErr == nil)
+	if ingestErr != nil {
+		return ingestErr
+	}
+
+	return appliedErr
+}
+
+// rows stored in the column, active slab follows fully finalized ones
+func columnRows(activeSlab *schema.DiskSlabHeader) uint64 {
+
+	rows := (activeSlab.SlabOffsetBlocks + uint64(activeSlab.BlocksFinalized)) * schema.BlockRowsSize
+	if activeSlab.BlocksFinalized < activeSlab.BlocksTotal {
+		rows += uint64(activeSlab.BlockHeaders[activeSlab.BlocksFinalized].Items)
+	}
+
+	return rows
+}
+
+// rows present in every column once the batch is written
+func ingestedRows(fieldsLayout []*layoutFieldInfo) uint64 {
+
+	var rows uint64
+	for idx, field := range fieldsLayout {
+		fieldRows := columnRows(field.slab.Header)
+		if idx == 0 || fieldRows < rows {
+			rows = fieldRows
+		}
+	}
+
+	return rows
+}
+
+// rows visible to queries after start, bounded by the shortest column.
+// rows of a schema with unreadable active slab aren't limited,
+// so queries report the corruption instead of start failing
+func (m *Manager) loadCommittedRows() {
+
+schemas:
+	for _, schemaObject := range m.Meta.Schemas() {
+
+		var rows uint64
+
+		for idx, col := range schemaObject.Columns {
+
+			activeSlab, loadErr := m.Slabs.LoadSlabHeaderToCache(schemaObject, col.ActiveSlab)
+			if loadErr != nil {
+				slog.Warn("unable to load active slab", "schema_name", schemaObject.Name, "column", col.Name, "err", loadErr.Error())
+				m.Meta.CommitRows(schemaObject.Name, math.MaxUint64)
+
+				continue schemas
+			}
+
+			colRows := columnRows(activeSlab)
+			if idx == 0 || colRows < rows {
+				rows = colRows
+			}
+		}
+
+		m.Meta.CommitRows(schemaObject.Name, rows)
+	}
+}
+
+// syncs slabs written by the ingest
+func (m *Manager) syncIngested(schemaObject *schema.Schema, fieldsLayout []*layoutFieldInfo) error {
+
+	for _, field := range fieldsLayout {
+		for _, slabUid := range field.written {
+			syncErr := m.Slabs.SyncSlabFiles(*schemaObject, slabUid)
+			if syncErr != nil {
+				return syncErr
+			}
+		}
+	}
+
+	return nil
+}
+
+// written slabs are kept in cache until the ingest is done
+func (m *Manager) unpinIngested(fieldsLayout []*layoutFieldInfo) {
+	for _, field := range fieldsLayout {
+		if field == nil {
+			continue
+		}
+		for _, slabUid := range field.written {
+			m.Slabs.UnpinSlab(slabUid)
+		}
+	}
+}
+
+// validates data against schema and splits it into columns,
+// active slabs of columns are pinned, see unpinIngested
+func (m *Manager) prepareIngest(schemaObject *schema.Schema, data *IngestBuffer) (_ []*layoutFieldInfo, topErr error) {
+
+	var fieldsLayout []*layoutFieldInfo = make([]*layoutFieldInfo, len(schemaObject.Columns))
+
+	defer func() {
+		if topErr != nil {
+			m.unpinIngested(fieldsLayout)
+		}
+	}()
+
+	unevenErr := data.validate()
+	if unevenErr != nil {
+		return nil, unevenErr
+	}
+
+	// buffers made from binary data aren't checked against schema on creation
+	for idx, name := range data.FieldsLayout {
+		if slices.Index(data.FieldsLayout, name) != idx {
+			return nil, fmt.Errorf("column `%s` is listed twice in fields layout", name)
+		}
+
+		if _, col := findSchemaColumn(schemaObject, name); col == nil {
+			return nil, fmt.Errorf("no column `%s` in schema `%s`", name, schemaObject.Name)
+		}
+	}
 
 	rowSize := 0
 
@@ -51,209 +246,419 @@ func (m *Manager) Ingest(schemaName string, data *IngestBuffer) error {
 			}
 		}
 
-		if !found {
-			return errors.New("layout does not match schema, no column " + col.Name + " found in data")
+		if !found && !col.Nullable {
+			return nil, errors.New("layout does not match schema, no column " + col.Name + " found in data")
 		} else {
 
 			var slabHeader *cache.SlabCacheItem
 
-			// slab exists
-			if col.ActiveSlab != uuid.Nil {
-
-				_, loadSlabErr := m.Slabs.LoadSlabHeaderToCache(schemaObject, col.ActiveSlab)
-				if loadSlabErr != nil {
-					return loadSlabErr
-				}
-
-				slabHeader = m.Slabs.GetSlabHeaderFromCache(col.ActiveSlab)
-			} else {
-				return fmt.Errorf("no active slab found for column %s", col.Name)
+			if col.ActiveSlab == uuid.Nil {
+				return nil, fmt.Errorf("no active slab found for column %s", col.Name)
 			}
 
 			fInfo := layoutFieldInfo{
 				index:      idx,
 				typ:        col.Type,
-				slab:       slabHeader,
 				dataOffset: rowSize,
 				name:       col.Name,
+				missing:    !found,
+				written:    []uuid.UUID{col.ActiveSlab},
+			}
+
+			fieldsLayout[idx] = &fInfo
+
+			// pinned before loading, so it's not evicted in between
+			m.Slabs.PinSlab(col.ActiveSlab)
+
+			_, loadSlabErr := m.Slabs.LoadSlabHeaderToCache(schemaObject, col.ActiveSlab)
+			if loadSlabErr != nil {
+				return nil, loadSlabErr
 			}
 
-			rowSize += col.Type.Size()
+			slabHeader = m.Slabs.GetSlabHeaderFromCache(col.ActiveSlab)
+			fInfo.slab = slabHeader
+
+			if found {
+				rowSize += col.Type.Size()
+			}
 
 			// log.Printf("field %s (%d bytes) at offset %d", col.Name, col.Type.Size(), fInfo.dataOffset)
+		}
+	}
 
-			fieldsLayout[idx] = &fInfo
+	for column := range data.nulls {
+		_, col := findSchemaColumn(schemaObject, column)
+		if col == nil || !col.Nullable {
+			return nil, fmt.Errorf("null values of column %s, which is not nullable", column)
 		}
 	}
 
+	if rowSize == 0 {
+		return nil, errors.New("no columns found in data")
+	}
+
 	dataBuffer := data.dataBuffer
 	itemsCount := len(dataBuffer) / rowSize
 
+	if data.columnData != nil {
+		var columnsErr error
+		itemsCount, columnsErr = columnarRows(fieldsLayout, data)
+		if columnsErr != nil {
+			return nil, columnsErr
+		}
+	} else if tail := len(dataBuffer) % rowSize; tail != 0 {
+		return nil, fmt.Errorf("row %d is incomplete, column %s is missing : %d of %d bytes", itemsCount, truncatedColumn(fieldsLayout, tail), tail, rowSize)
+	}
+
 	for _, field := range fieldsLayout {
-		switch field.typ {
-		case schema.Uint8FieldType:
 
-			collectErr := CollectColumnsFromRow[uint8](itemsCount, field, dataBuffer, rowSize)
-			if collectErr != nil {
-				return collectErr
-			}
-		case schema.Uint64FieldType:
+		if schemaObject.Columns[field.index].Nullable {
+			field.nulls = make([]bool, itemsCount)
 
-			collectErr := CollectColumnsFromRow[uint64](itemsCount, field, dataBuffer, rowSize)
-			if collectErr != nil {
-				return collectErr
+			if field.missing {
+				for idx := range field.nulls {
+					field.nulls[idx] = true
+				}
+			} else {
+				copy(field.nulls, data.nulls[field.name])
 			}
+		}
 
-		case schema.Float32FieldType:
+		var collectErr error
+		if field.missing {
+			collectErr = collectMissingColumn(itemsCount, field)
+		} else if data.columnData != nil {
+			collectErr = mapColumn(itemsCount, field, data.columnData[slices.Index(data.FieldsLayout, field.name)])
+		} else {
+			collectErr = CollectColumnsFromRow(itemsCount, field, dataBuffer, rowSize)
+		}
 
-			collectErr := CollectColumnsFromRow[float32](itemsCount, field, dataBuffer, rowSize)
-			if collectErr != nil {
-				return collectErr
-			}
+		if collectErr != nil {
+			return nil, collectErr
+		}
 
-		default:
-			panic(fmt.Sprintf("unsupported type: %s when ingest", field.typ.String()))
+		if field.typ == schema.StringFieldType && !field.missing {
+			stringsErr := resolveStrings(field, data.Strings)
+			if stringsErr != nil {
+				return nil, stringsErr
+			}
 		}
 	}
 
-	// that should be internal api
-	// ingestColumnarInternal(columnData)
+	return fieldsLayout, nil
+}
+
+// writes prepared columns into slabs, columns are written in parallel
+func (m *Manager) ingestPrepared(schemaObject *schema.Schema, fieldsLayout []*layoutFieldInfo) error {
+
+	schemaName := schemaObject.Name
+
+	stats := make([]meta.IngestStats, len(fieldsLayout))
+	errs := make([]error, len(fieldsLayout))
+
+	// bounds slab sized buffers a batch takes from slab manager at once
+	writers := make(chan struct{}, ingestColumnWriters)
+	waiter := sync.WaitGroup{}
+
+	for idx, field := range fieldsLayout {
+
+		waiter.Add(1)
+
+		go func() {
+			defer waiter.Done()
+
+			writers <- struct{}{}
+			defer func() { <-writers }()
+
+			stats[idx], errs[idx] = m.ingestColumn(schemaObject, field)
+		}()
+	}
+
+	waiter.Wait()
 
 	var ioTime time.Duration
 	var ioCalls int
 
-	defer func() {
-		color.Green(" > [%s] finished ingestion, IO took %.2fms/%d io syscalls", schemaName, ioTime.Seconds()*1000, ioCalls)
-	}()
+	for _, it := range stats {
+		ioTime += it.IoTime
+		ioCalls += it.IoCalls
+	}
 
-	for _, field := range fieldsLayout {
+	color.Green(" > [%s] finished ingestion, IO took %.2fms/%d io syscalls", schemaName, ioTime.Seconds()*1000, ioCalls)
 
-		for field.leftover > 0 {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
 
-			sh := field.slab.Header
+	return nil
+}
 
-			if sh.BlocksFinalized >= sh.BlocksTotal {
+// writes leftover rows of the column, creating new slabs once active one is full.
+// other columns of the schema are written at the same time,
+// so only the column's own entry of schema is changed.
+// slab header is written once per slab, after all blocks of the batch
+func (m *Manager) ingestColumn(schemaObject *schema.Schema, field *layoutFieldInfo) (meta.IngestStats, error) {
 
-				// color.Yellow(" > [%s/%s] slab  full (finalized %d, total = %d), creating new one...", sh.Uid.String(), field.name, sh.BlocksFinalized, sh.BlocksTotal)
+	stats := meta.IngestStats{}
 
-				// trim finalized slab here
-				trimErr := m.Slabs.TrimFinalizedBlocksSize(*schemaObject, sh)
-				if trimErr != nil {
-					return fmt.Errorf("unable to trim finalized slab: %s", trimErr.Error())
-				}
+	headerChanged := false
 
-				// check curBlock size
-				// if we changed the block size to be different from 32k rows
-				nextSlabOffset := sh.SlabOffsetBlocks + uint64(sh.BlocksTotal)
+	flushSlabHeader := func(sh *schema.DiskSlabHeader) error {
+		if !headerChanged {
+			return nil
+		}
 
-				newSlab, newSlabCreationErr := m.Slabs.NewSlabForColumn(*schemaObject, schemaObject.Columns[field.index], nextSlabOffset)
-				if newSlabCreationErr != nil {
-					return newSlabCreationErr
-				}
+		headerChanged = false
 
-				{
-					col := &schemaObject.Columns[field.index]
+		ioStart := time.Now()
+		updateErr := m.Slabs.UpdateSlabHeaderOnDisk(*schemaObject, sh)
+		stats.IoTime += time.Since(ioStart)
+		stats.IoCalls += 1
+
+		if updateErr != nil {
+			return fmt.Errorf("unable to update slab info: %s", updateErr.Error())
+		}
 
-					if col.Slabs == nil {
-						col.Slabs = []uuid.UUID{}
-					}
+		return nil
+	}
 
-					col.Slabs = append(col.Slabs, newSlab.Uid)
-					col.ActiveSlab = newSlab.Uid
+	for field.leftover > 0 {
 
-					storeErr := m.Meta.StoreSchemeToDisk(*schemaObject)
-					if storeErr != nil {
-						return fmt.Errorf("unable to update schema config on disk: %s", storeErr.Error())
-					}
+		sh := field.slab.Header
 
-				}
+		if sh.BlocksFinalized >= sh.BlocksTotal {
 
-				var loadErr error
-				sh, loadErr = m.Slabs.LoadSlabHeaderToCache(schemaObject, newSlab.Uid)
-				field.slab.Header = sh
-				if loadErr != nil {
-					return fmt.Errorf("unable to load just created slab: %s", loadErr.Error())
-				}
+			flushErr := flushSlabHeader(sh)
+			if flushErr != nil {
+				return stats, flushErr
 			}
 
-			curBlock := sh.BlockHeaders[sh.BlocksFinalized]
+			// color.Yellow(" > [%s/%s] slab  full (finalized %d, total = %d), creating new one...", sh.Uid.String(), field.name, sh.BlocksFinalized, sh.BlocksTotal)
 
-			// check if slab has free blocks
+			// trim finalized slab here
+			trimErr := m.Slabs.TrimFinalizedBlocksSize(*schemaObject, sh)
+			if trimErr != nil {
+				return stats, fmt.Errorf("unable to trim finalized slab: %s", trimErr.Error())
+			}
 
-			stats, blockErr := m.Slabs.IngestIntoBlock(
-				*schemaObject,
-				sh,
-				curBlock.Uid,
-				field.DataArray,
-				field.ingested,
-			)
+			// check curBlock size
+			// if we changed the block size to be different from 32k rows
+			nextSlabOffset := sh.SlabOffsetBlocks + uint64(sh.BlocksTotal)
 
-			ioTime += stats.IoTime
-			ioCalls += stats.IoCalls
+			newSlab, newSlabCreationErr := m.Slabs.NewSlabForColumn(*schemaObject, schemaObject.Columns[field.index], nextSlabOffset)
+			if newSlabCreationErr != nil {
+				return stats, newSlabCreationErr
+			}
 
-			if blockErr != nil {
-				return blockErr
-			} else {
+			m.Slabs.PinSlab(newSlab.Uid)
+			field.written = append(field.written, newSlab.Uid)
 
-				field.ingested += stats.Written
-				field.leftover -= stats.Written
+			addErr := m.Meta.AddColumnSlab(schemaObject, field.index, newSlab.Uid)
+			if addErr != nil {
+				return stats, addErr
+			}
 
-				// color.Green(" > [%s] ingested %d, left %d. slab %s", field.name, stats.Written, field.leftover, sh.Uid.String())
+			var loadErr error
+			sh, loadErr = m.Slabs.LoadSlabHeaderToCache(schemaObject, newSlab.Uid)
+			if loadErr != nil {
+				return stats, fmt.Errorf("unable to load just created slab: %s", loadErr.Error())
+			}
 
-				if stats.BlockFinished {
-					if sh.BlocksFinalized < sh.BlocksTotal {
-						sh.BlockHeaders[sh.BlocksFinalized] = schema.NewBlockHeader(field.typ)
-					}
-				}
+			// switch to the cache item of the new slab,
+			// the finalized one keeps its own header
+			field.slab = m.Slabs.GetSlabHeaderFromCache(newSlab.Uid)
+		}
+
+		curBlock := sh.BlockHeaders[sh.BlocksFinalized]
 
+		blockData := field.DataArray
+		blockDataOffset := field.ingested
+		blockNulls := field.nulls
+
+		if field.typ == schema.StringFieldType {
+
+			// only values that fit into current block get into slab dictionary
+			size := min(field.leftover, schema.BlockRowsSize-int(curBlock.Items))
+			values := field.DataArray.([]string)[field.ingested : field.ingested+size]
+
+			encodeErr := m.Slabs.EncodeStrings(schemaObject, sh.Uid, values, field.codes[:size])
+			if encodeErr != nil {
+				return stats, fmt.Errorf("unable to encode strings of column %s : %s", field.name, encodeErr.Error())
 			}
 
+			blockData = field.codes[:size]
+			blockDataOffset = 0
+
+			if blockNulls != nil {
+				blockNulls = blockNulls[field.ingested : field.ingested+size]
+			}
 		}
+
+		// check if slab has free blocks
+
+		blockStats, blockErr := m.Slabs.IngestIntoBlock(
+			*schemaObject,
+			sh,
+			curBlock.Uid,
+			blockData,
+			blockDataOffset,
+			blockNulls,
+		)
+
+		stats.IoTime += blockStats.IoTime
+		stats.IoCalls += blockStats.IoCalls
+		headerChanged = headerChanged || blockStats.SlabHeaderChanged
+
+		if blockErr != nil {
+			return stats, blockErr
+		} else {
+
+			field.ingested += blockStats.Written
+			field.leftover -= blockStats.Written
+
+			// color.Green(" > [%s] ingested %d, left %d. slab %s", field.name, stats.Written, field.leftover, sh.Uid.String())
+		}
+
 	}
 
-	return nil
+	// header of a failed batch isn't written, the batch is replayed from log
+	flushErr := flushSlabHeader(field.slab.Header)
 
+	return stats, flushErr
 }
 
-func CollectColumnsFromRow[T any](
+func CollectColumnsFromRow(
 	itemsCount int,
 	field *layoutFieldInfo,
 	dataBuffer []byte,
 	rowSize int,
 ) error {
 
-	outputInts := make([]T, itemsCount)
-	outBuffer := make([]byte, itemsCount*field.typ.Size())
+	typeOps, typeErr := field.typ.Ops()
+	if typeErr != nil {
+		return fmt.Errorf("unable to collect column %s : %s", field.name, typeErr.Error())
+	}
+
+	outBuffer := make([]byte, max(itemsCount, 1)*field.typ.Size())
+	collectColumnBytes(dataBuffer, field.typ.Size(), field.dataOffset, rowSize, itemsCount, outBuffer)
+
+	// buffer is owned by the column, so values are mapped onto it
+	field.DataArray = typeOps.MapBytes(outBuffer, itemsCount)
+	field.ingested = 0
+	field.leftover = itemsCount
+
+	return nil
+
+}
 
-	collectErr := CollectTypedDataToArrayFromBinaryBufferFast[T](dataBuffer,
-		outputInts[:], field.typ,
-		field.dataOffset, rowSize, itemsCount,
-		outBuffer[:],
-	)
+// rows of columnar data, every column must have the same amount of them
+func columnarRows(fieldsLayout []*layoutFieldInfo, data *IngestBuffer) (int, error) {
 
-	if collectErr != nil {
-		return collectErr
+	if len(data.columnData) != len(data.FieldsLayout) {
+		return 0, fmt.Errorf("columnar data has %d columns, fields layout has %d", len(data.columnData), len(data.FieldsLayout))
+	}
+
+	rows := -1
+	first := ""
+
+	for _, field := range fieldsLayout {
+		if field.missing {
+			continue
+		}
+
+		values := data.columnData[slices.Index(data.FieldsLayout, field.name)]
+		if len(values)%field.typ.Size() != 0 {
+			return 0, fmt.Errorf("row %d of column `%s` is incomplete : %d of %d bytes", len(values)/field.typ.Size(), field.name, len(values)%field.typ.Size(), field.typ.Size())
+		}
+
+		fieldRows := len(values) / field.typ.Size()
+		if rows == -1 {
+			rows = fieldRows
+			first = field.name
+		} else if fieldRows != rows {
+			return 0, fmt.Errorf("column `%s` has %d rows, column `%s` has %d", field.name, fieldRows, first, rows)
+		}
+	}
+
+	return max(rows, 0), nil
+}
+
+// values of columnar data are used in place
+func mapColumn(itemsCount int, field *layoutFieldInfo, values []byte) error {
+
+	typeOps, typeErr := field.typ.Ops()
+	if typeErr != nil {
+		return fmt.Errorf("unable to collect column %s : %s", field.name, typeErr.Error())
+	}
+
+	if itemsCount == 0 {
+		field.DataArray = typeOps.MakeSlice(0)
+	} else {
+		field.DataArray = typeOps.MapBytes(values, itemsCount)
 	}
 
-	field.DataArray = outputInts
 	field.ingested = 0
-	field.leftover = len(outputInts)
+	field.leftover = itemsCount
 
 	return nil
+}
+
+// nullable column absent in data is ingested as zero values
+func collectMissingColumn(itemsCount int, field *layoutFieldInfo) error {
 
+	if field.typ == schema.StringFieldType {
+		field.DataArray = make([]string, itemsCount)
+		field.codes = make([]uint32, min(itemsCount, schema.BlockRowsSize))
+	} else {
+		typeOps, typeErr := field.typ.Ops()
+		if typeErr != nil {
+			return fmt.Errorf("unable to collect column %s : %s", field.name, typeErr.Error())
+		}
+
+		field.DataArray = typeOps.MakeSlice(itemsCount)
+	}
+
+	field.ingested = 0
+	field.leftover = itemsCount
+
+	return nil
+}
+
+// replaces collected indices of strings table with values
+func resolveStrings(field *layoutFieldInfo, table []string) error {
+
+	indices := field.DataArray.([]uint32)
+	values := make([]string, len(indices))
+
+	for idx, it := range indices {
+		if int(it) >= len(table) {
+			return fmt.Errorf("string index %d of column %s is out of strings table (%d values)", it, field.name, len(table))
+		}
+		values[idx] = table[it]
+	}
+
+	field.DataArray = values
+	field.codes = make([]uint32, min(len(values), schema.BlockRowsSize))
+
+	return nil
 }
 
 func CollectTypedDataToArray[T any](inputRows []any, outputColumn []T, typ schema.FieldType, columnindex int) error {
 
-	for i, v := range inputRows {
+	for i := range inputRows {
 
-		rowDecoded := inputRows[i].([]any)[columnindex]
+		row, rowOk := inputRows[i].([]any)
+		if !rowOk || columnindex >= len(row) {
+			return fmt.Errorf("row %d has no column %d", i, columnindex)
+		}
 
-		switch t := rowDecoded.(type) {
+		switch t := row[columnindex].(type) {
 		case T:
 			outputColumn[i] = t
 		default:
-			return errors.New(fmt.Sprintf("invalid type %s expected %s", reflect.TypeOf(v), reflect.TypeOf(outputColumn)))
+			return fmt.Errorf("row %d, column %d : invalid type %T, expected %s", i, columnindex, row[columnindex], reflect.TypeFor[T]())
 		}
 	}
 	return nil
@@ -272,16 +677,19 @@ func CollectTypedDataToArrayFromBinaryBuffer[T uint64](
 		converted, convertOk := outputColumn.([]uint64)
 
 		if !convertOk {
-			panic("output column must be array of Ouput")
+			return fmt.Errorf("output column is %T, expected []uint64", outputColumn)
 		}
 
 		for index := 0; index < rows; index++ {
 			skipErr := binReader.Skip(colOffset)
 			if skipErr != nil {
-				panic(fmt.Sprintf(" unable to skip %d : %s", colOffset, skipErr.Error()))
+				return fmt.Errorf("row %d, column at offset %d : unable to skip %d : %s", index, colOffset, colOffset, skipErr.Error())
 			}
 
-			val := binReader.MustReadU64()
+			val, readErr := binReader.ReadU64()
+			if readErr != nil {
+				return fmt.Errorf("row %d, column at offset %d : %s", index, colOffset, readErr.Error())
+			}
 
 			curOffset := colOffset + 8
 			offsetToMove := rowSize - curOffset
@@ -293,12 +701,35 @@ func CollectTypedDataToArrayFromBinaryBuffer[T uint64](
 			converted[index] = uint64(val)
 		}
 	default:
-		panic(fmt.Sprintf("unsupported type: %s when CollectTypedDataToArrayFromBinaryBuffer", typ.String()))
+		return fmt.Errorf("unsupported type: %s when CollectTypedDataToArrayFromBinaryBuffer", typ.String())
 	}
 
 	return nil
 }
 
+// name of the first column not fitting into incomplete row of tail bytes
+func truncatedColumn(fieldsLayout []*layoutFieldInfo, tail int) string {
+
+	for _, field := range fieldsLayout {
+		if !field.missing && field.dataOffset+field.typ.Size() > tail {
+			return "`" + field.name + "`"
+		}
+	}
+
+	return "value"
+}
+
+// copies values of a single column out of row major buffer
+func collectColumnBytes(binReader []byte, valueSize, colOffset, rowSize, rows int, out []byte) {
+
+	readOffset := colOffset
+
+	for index := 0; index < rows; index++ {
+		copy(out[index*valueSize:], binReader[readOffset:readOffset+valueSize])
+		readOffset += rowSize
+	}
+}
+
 func CollectTypedDataToArrayFromBinaryBufferFast[T any](
 	binReader []byte,
 	outputColumn any,
@@ -307,47 +738,39 @@ func CollectTypedDataToArrayFromBinaryBufferFast[T any](
 	buf []byte,
 ) error {
 
-	switch typ {
-	case schema.Uint64FieldType, schema.Float32FieldType:
-
-		converted, convertOk := outputColumn.([]T)
+	converted, convertOk := outputColumn.([]T)
 
-		if !convertOk {
-			panic("output column must be array of Ouput")
-		}
-
-		curOffset := 0
-		readOffset := 0
-
-		readSize := typ.Size()
-
-		for index := 0; index < rows; index++ {
+	if !convertOk {
+		return fmt.Errorf("output column is %T, expected %s", outputColumn, reflect.TypeFor[[]T]())
+	}
 
-			readOffset += colOffset
+	if rows == 0 {
+		return nil
+	}
 
-			copy(buf[curOffset:], binReader[readOffset:readOffset+readSize])
+	valueSize := typ.Size()
 
-			readOffset += readSize
-			curOffset += readSize
+	if len(converted) < rows || len(buf) < rows*valueSize {
+		return fmt.Errorf("output of %d rows can't hold %d rows", min(len(converted), len(buf)/valueSize), rows)
+	}
 
-			curOffset := colOffset + readSize
-			offsetToMove := rowSize - curOffset
+	// first row which value is past the end of data
+	if colOffset+(rows-1)*rowSize+valueSize > len(binReader) {
 
-			readOffset += offsetToMove
+		row := 0
+		if len(binReader) >= colOffset+valueSize {
+			row = (len(binReader)-colOffset-valueSize)/rowSize + 1
 		}
 
-		// using unsafe copy because we know that buffer size is correct
+		return fmt.Errorf("row %d, column at offset %d : value is out of data of %d bytes", row, colOffset, len(binReader))
+	}
 
-		hdr := unsafe.Slice((*T)(unsafe.Pointer(&buf[0])), rows)
-		copiedN := copy(converted[:], hdr[:])
+	collectColumnBytes(binReader, valueSize, colOffset, rowSize, rows, buf)
 
-		if copiedN != rows {
-			panic(fmt.Sprintf("unable to copy all elements: got %d, expected %d)", rows, copiedN))
-		}
+	// using unsafe copy because we know that buffer size is correct
 
-	default:
-		panic(fmt.Sprintf("unsupported type: %s when CollectTypedDataToArrayFromBinaryBuffer", typ.String()))
-	}
+	hdr := unsafe.Slice((*T)(unsafe.Pointer(&buf[0])), rows)
+	copy(converted[:], hdr[:])
 
 	return nil
 }
@@ -0,0 +1,277 @@
+package manager
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/dot5enko/simple-column-db/schema"
+)
+
+const (
+	DefaultBatchMaxRows  = schema.BlockRowsSize
+	DefaultBatchMaxDelay = 10 * time.Millisecond
+)
+
+var ErrBatcherClosed = errors.New("ingest batcher is closed")
+
+// group commit of small ingests into one schema.
+// rows of every caller are collected into a single batch,
+// which is ingested once it has MaxRows rows or MaxDelay passed since its first rows
+type IngestBatcherConfig struct {
+	// DefaultBatchMaxRows if not set
+	MaxRows int
+
+	// DefaultBatchMaxDelay if not set
+	MaxDelay time.Duration
+}
+
+type IngestBatcher struct {
+	manager    *Manager
+	schemaName string
+	config     IngestBatcherConfig
+
+	fieldsLayout []string
+	rowSize      int
+
+	// offsets of string columns in a row, their values are indices into strings table
+	stringOffsets []int
+
+	lock    sync.Mutex
+	pending *ingestBatch
+	last    *ingestBatch
+	closed  bool
+}
+
+type ingestBatch struct {
+	data *IngestBuffer
+	rows int
+
+	timer *time.Timer
+
+	// batches are ingested in order they were collected
+	prev *ingestBatch
+
+	done chan struct{}
+	err  error
+}
+
+// every buffer added to the batcher must have the same fields layout
+func (m *Manager) NewIngestBatcher(schemaName string, fieldsLayout []string, config IngestBatcherConfig) (*IngestBatcher, error) {
+
+	schemaObject := m.Meta.SchemaSnapshot(schemaName)
+	if schemaObject == nil {
+		return nil, fmt.Errorf("schema `%s` not found", schemaName)
+	}
+
+	for _, name := range fieldsLayout {
+		if _, col := findSchemaColumn(schemaObject, name); col == nil {
+			return nil, fmt.Errorf("no column `%s` in schema `%s`", name, schemaName)
+		}
+	}
+
+	if config.MaxRows <= 0 {
+		config.MaxRows = DefaultBatchMaxRows
+	}
+
+	if config.MaxDelay <= 0 {
+		config.MaxDelay = DefaultBatchMaxDelay
+	}
+
+	b := &IngestBatcher{
+		manager:      m,
+		schemaName:   schemaName,
+		config:       config,
+		fieldsLayout: slices.Clone(fieldsLayout),
+	}
+
+	// rows hold values in order of schema columns, see prepareIngest
+	for _, col := range schemaObject.Columns {
+		if !slices.Contains(fieldsLayout, col.Name) {
+			continue
+		}
+
+		if col.Type == schema.StringFieldType {
+			b.stringOffsets = append(b.stringOffsets, b.rowSize)
+		}
+
+		b.rowSize += col.Type.Size()
+	}
+
+	if b.rowSize == 0 {
+		return nil, errors.New("no columns found in fields layout")
+	}
+
+	return b, nil
+}
+
+// adds rows to the current batch and waits until the batch is ingested,
+// returned error is the error of the whole batch
+func (b *IngestBatcher) Ingest(data *IngestBuffer) error {
+
+	if !slices.Equal(data.FieldsLayout, b.fieldsLayout) {
+		return fmt.Errorf("fields layout %v doesn't match layout of the batcher %v", data.FieldsLayout, b.fieldsLayout)
+	}
+
+	if data.columnData != nil {
+		return errors.New("columnar data can't be batched, ingest it directly")
+	}
+
+	unevenErr := data.validate()
+	if unevenErr != nil {
+		return unevenErr
+	}
+
+	if len(data.dataBuffer)%b.rowSize != 0 {
+		return fmt.Errorf("data size %d is not a multiple of row size %d", len(data.dataBuffer), b.rowSize)
+	}
+
+	b.lock.Lock()
+
+	if b.closed {
+		b.lock.Unlock()
+		return ErrBatcherClosed
+	}
+
+	batch := b.pending
+	if batch == nil {
+		batch = b.newBatch()
+	}
+
+	b.merge(batch, data)
+
+	if batch.rows >= b.config.MaxRows {
+		b.takePending()
+		b.lock.Unlock()
+
+		b.commit(batch)
+	} else {
+		b.lock.Unlock()
+	}
+
+	<-batch.done
+
+	return batch.err
+}
+
+// ingests collected rows without waiting for thresholds,
+// returns error of the last batch, including one taken by another caller
+func (b *IngestBatcher) Flush() error {
+
+	b.lock.Lock()
+	batch := b.takePending()
+	last := b.last
+	b.lock.Unlock()
+
+	if batch != nil {
+		b.commit(batch)
+		return batch.err
+	}
+
+	// batch taken by another caller may be still in progress
+	if last != nil {
+		<-last.done
+		return last.err
+	}
+
+	return nil
+}
+
+// flushes collected rows, further ingests are rejected.
+// must be called before closing the manager
+func (b *IngestBatcher) Close() error {
+
+	b.lock.Lock()
+	b.closed = true
+	b.lock.Unlock()
+
+	return b.Flush()
+}
+
+// must be called under lock
+func (b *IngestBatcher) newBatch() *ingestBatch {
+
+	batch := &ingestBatch{
+		data: IngestBufferFromBinary([]byte{}, b.fieldsLayout),
+		prev: b.last,
+		done: make(chan struct{}),
+	}
+
+	batch.timer = time.AfterFunc(b.config.MaxDelay, func() {
+		b.lock.Lock()
+		if b.pending != batch {
+			b.lock.Unlock()
+			return
+		}
+
+		b.takePending()
+		b.lock.Unlock()
+
+		b.commit(batch)
+	})
+
+	b.pending = batch
+	b.last = batch
+
+	return batch
+}
+
+// detaches current batch, so new rows go to the next one.
+// must be called under lock
+func (b *IngestBatcher) takePending() *ingestBatch {
+
+	batch := b.pending
+	if batch != nil {
+		batch.timer.Stop()
+		b.pending = nil
+	}
+
+	return batch
+}
+
+func (b *IngestBatcher) commit(batch *ingestBatch) {
+
+	if batch.prev != nil {
+		<-batch.prev.done
+
+		// only the order matters, finished batches aren't kept
+		batch.prev = nil
+	}
+
+	batch.err = b.manager.Ingest(b.schemaName, batch.data)
+	close(batch.done)
+}
+
+// appends rows of data to the batch, string indices and null rows are shifted
+// by strings and rows already in the batch
+func (b *IngestBatcher) merge(batch *ingestBatch, data *IngestBuffer) {
+
+	rows := len(data.dataBuffer) / b.rowSize
+	start := len(batch.data.dataBuffer)
+	stringsBase := uint32(len(batch.data.Strings))
+
+	batch.data.dataBuffer = append(batch.data.dataBuffer, data.dataBuffer...)
+	batch.data.Strings = append(batch.data.Strings, data.Strings...)
+
+	if stringsBase > 0 {
+		for row := range rows {
+			for _, offset := range b.stringOffsets {
+				value := batch.data.dataBuffer[start+row*b.rowSize+offset:]
+				binary.LittleEndian.PutUint32(value, binary.LittleEndian.Uint32(value)+stringsBase)
+			}
+		}
+	}
+
+	for column, nulls := range data.nulls {
+		for row, isNull := range nulls {
+			if isNull {
+				batch.data.SetNull(column, batch.rows+row)
+			}
+		}
+	}
+
+	batch.rows += rows
+}
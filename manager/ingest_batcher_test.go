@@ -0,0 +1,209 @@
+package manager
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dot5enko/simple-column-db/manager/query"
+)
+
+// binary rows of testSchema with x counting from `from`
+func testBatchRows(from, rows int) *IngestBuffer {
+
+	binData := []byte{}
+	for i := range rows {
+		binData = binary.LittleEndian.AppendUint64(binData, uint64(from+i))
+		binData = binary.LittleEndian.AppendUint64(binData, 1)
+	}
+
+	return IngestBufferFromBinary(binData, []string{"x", "y"})
+}
+
+// ingests data through the batcher in background, error is sent once it returns
+func testBatchIngest(b *IngestBatcher, data *IngestBuffer) chan error {
+
+	result := make(chan error, 1)
+	go func() {
+		result <- b.Ingest(data)
+	}()
+
+	return result
+}
+
+// waits until pending batch has the rows
+func waitPendingRows(t *testing.T, b *IngestBatcher, rows int) {
+
+	t.Helper()
+
+	for start := time.Now(); time.Since(start) < 10*time.Second; time.Sleep(time.Millisecond) {
+		b.lock.Lock()
+		pendingRows := 0
+		if b.pending != nil {
+			pendingRows = b.pending.rows
+		}
+		b.lock.Unlock()
+
+		if pendingRows == rows {
+			return
+		}
+	}
+
+	t.Fatalf("pending batch doesn't get %d rows", rows)
+}
+
+func testIngestedX(t *testing.T, m *Manager) []any {
+
+	t.Helper()
+
+	return testQuery(t, m, "checks", query.Query{Select: []query.Selector{{Type: query.SelectColumn, Arguments: []any{"x"}}}})["x"]
+}
+
+func TestBatcherThresholds(t *testing.T) {
+
+	m := openTestManager(t, t.TempDir(), testSchema("checks"))
+	defer closeTestManager(t, m)
+
+	byRows, batcherErr := m.NewIngestBatcher("checks", []string{"x", "y"}, IngestBatcherConfig{MaxRows: 100, MaxDelay: time.Hour})
+	if batcherErr != nil {
+		t.Fatal(batcherErr)
+	}
+
+	// full batch is ingested right away
+	returnsInTime(t, "ingest of full batch", func() {
+		if ingestErr := byRows.Ingest(testBatchRows(0, 100)); ingestErr != nil {
+			t.Errorf("unable to ingest : %s", ingestErr.Error())
+		}
+	})
+
+	byDelay, batcherErr := m.NewIngestBatcher("checks", []string{"x", "y"}, IngestBatcherConfig{MaxRows: 1000, MaxDelay: 10 * time.Millisecond})
+	if batcherErr != nil {
+		t.Fatal(batcherErr)
+	}
+
+	returnsInTime(t, "ingest of delayed batch", func() {
+		if ingestErr := byDelay.Ingest(testBatchRows(100, 10)); ingestErr != nil {
+			t.Errorf("unable to ingest : %s", ingestErr.Error())
+		}
+	})
+
+	if count, sum := testCountSum(t, m, "checks"); count != 110 || sum != 109*110/2 {
+		t.Errorf("expected 110 rows, got %d rows with sum %f", count, sum)
+	}
+
+	for _, b := range []*IngestBatcher{byRows, byDelay} {
+		if closeErr := b.Close(); closeErr != nil {
+			t.Fatal(closeErr)
+		}
+	}
+
+	if ingestErr := byRows.Ingest(testBatchRows(0, 1)); !errors.Is(ingestErr, ErrBatcherClosed) {
+		t.Errorf("expected ErrBatcherClosed, got %v", ingestErr)
+	}
+
+	if ingestErr := byDelay.Ingest(IngestBufferFromBinary(nil, []string{"y", "x"})); ingestErr == nil {
+		t.Errorf("expected error for buffer with other fields layout")
+	}
+}
+
+// rows are ingested in order they were added, even when a later batch is ready first
+func TestBatcherFlushOrder(t *testing.T) {
+
+	m := openTestManager(t, t.TempDir(), testSchema("checks"))
+	defer closeTestManager(t, m)
+
+	b, batcherErr := m.NewIngestBatcher("checks", []string{"x", "y"}, IngestBatcherConfig{MaxRows: 100, MaxDelay: time.Hour})
+	if batcherErr != nil {
+		t.Fatal(batcherErr)
+	}
+
+	first := testBatchIngest(b, testBatchRows(0, 10))
+	waitPendingRows(t, b, 10)
+
+	second := testBatchIngest(b, testBatchRows(10, 20))
+	waitPendingRows(t, b, 30)
+
+	// detached as by its timer, which didn't commit it yet
+	b.lock.Lock()
+	delayed := b.takePending()
+	b.lock.Unlock()
+
+	// next batch is full and waits for the delayed one
+	third := testBatchIngest(b, testBatchRows(30, 100))
+
+	time.Sleep(20 * time.Millisecond)
+
+	select {
+	case <-third:
+		t.Fatalf("batch is ingested before the batch collected earlier")
+	default:
+	}
+
+	if count, _ := testCountSum(t, m, "checks"); count != 0 {
+		t.Fatalf("expected no rows before delayed batch is committed, got %d", count)
+	}
+
+	b.commit(delayed)
+
+	for _, result := range []chan error{first, second, third} {
+		returnsInTime(t, "ingest", func() {
+			if ingestErr := <-result; ingestErr != nil {
+				t.Errorf("unable to ingest : %s", ingestErr.Error())
+			}
+		})
+	}
+
+	x := testIngestedX(t, m)
+	if len(x) != 130 {
+		t.Fatalf("expected 130 rows, got %d", len(x))
+	}
+
+	for row, value := range x {
+		if value.(uint64) != uint64(row) {
+			t.Fatalf("row %d : expected x %d, got %v", row, row, value)
+		}
+	}
+}
+
+// every caller of a failed batch gets its error
+func TestBatcherErrorFanOut(t *testing.T) {
+
+	m := openTestManager(t, t.TempDir(), testSchema("checks"))
+
+	b, batcherErr := m.NewIngestBatcher("checks", []string{"x", "y"}, IngestBatcherConfig{MaxRows: 1000, MaxDelay: time.Hour})
+	if batcherErr != nil {
+		t.Fatal(batcherErr)
+	}
+
+	results := []chan error{}
+	for i := range 3 {
+		results = append(results, testBatchIngest(b, testBatchRows(i*10, 10)))
+		waitPendingRows(t, b, (i+1)*10)
+	}
+
+	closeTestManager(t, m)
+
+	if flushErr := b.Flush(); !errors.Is(flushErr, ErrManagerClosed) {
+		t.Errorf("expected flush to fail with ErrManagerClosed, got %v", flushErr)
+	}
+
+	// nothing is pending, the failed batch is the last one
+	if flushErr := b.Flush(); !errors.Is(flushErr, ErrManagerClosed) {
+		t.Errorf("expected flush of taken batch to fail with ErrManagerClosed, got %v", flushErr)
+	}
+
+	callers := sync.WaitGroup{}
+	for _, result := range results {
+		callers.Add(1)
+		go func() {
+			defer callers.Done()
+			if ingestErr := <-result; !errors.Is(ingestErr, ErrManagerClosed) {
+				t.Errorf("expected ingest to fail with ErrManagerClosed, got %v", ingestErr)
+			}
+		}()
+	}
+
+	returnsInTime(t, "ingests", callers.Wait)
+}
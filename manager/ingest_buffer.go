@@ -2,8 +2,15 @@ package manager
 
 import (
 	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"time"
 
 	"github.com/dot5enko/simple-column-db/bits"
+	"github.com/dot5enko/simple-column-db/schema"
 )
 
 type IngestBuffer struct {
@@ -11,20 +18,95 @@ type IngestBuffer struct {
 
 	FieldsLayout []string
 
-	bitWriter *bits.BitWriter
+	// values of string columns,
+	// rows hold uint32 index of the value in this table
+	Strings []string
+
+	// rows without value per nullable column,
+	// value of null row is written to the buffer but ignored
+	nulls map[string][]bool
+
+	// values of each FieldsLayout column, set instead of rows for columnar data
+	columnData [][]byte
+
+	// columns of FieldsLayout, set for buffers created for a schema
+	columns []bufferColumn
+	rowSize int
+
+	// rows filled in each column by AddRows and AppendColumn
+	columnRows []int
+
+	// indices of values added to strings table by typed appends
+	stringCodes map[string]uint32
 }
 
-func NewIngestBuffer(fieldsLayout []string) *IngestBuffer {
+type bufferColumn struct {
+	name      string
+	typ       schema.FieldType
+	nullable  bool
+	precision schema.TimePrecision
+
+	// offset of the value in a row, rows hold values in order of schema columns
+	offset int
+
+	// go type of values, strings and timestamps also accept string and time.Time
+	valueType reflect.Type
+}
+
+// values accepted by AppendColumn
+type IngestValue interface {
+	schema.NumericTypes | string | time.Time
+}
+
+// buffer for rows of the schema, filled by AddRows and AppendColumn
+func NewIngestBuffer(schemaObject *schema.Schema, fieldsLayout []string) (*IngestBuffer, error) {
 
 	b := &IngestBuffer{
-		FieldsLayout: fieldsLayout,
+		FieldsLayout: slices.Clone(fieldsLayout),
 		dataBuffer:   []byte{},
+		columns:      make([]bufferColumn, len(fieldsLayout)),
+		columnRows:   make([]int, len(fieldsLayout)),
 	}
 
-	bitWriter := bits.NewEncodeBuffer(b.dataBuffer, binary.LittleEndian)
-	b.bitWriter = &bitWriter
+	for idx, name := range fieldsLayout {
+		if slices.Index(fieldsLayout, name) != idx {
+			return nil, fmt.Errorf("column `%s` is listed twice in fields layout", name)
+		}
+
+		if _, col := findSchemaColumn(schemaObject, name); col == nil {
+			return nil, fmt.Errorf("no column `%s` in schema `%s`", name, schemaObject.Name)
+		}
+	}
 
-	return b
+	for _, col := range schemaObject.Columns {
+
+		idx := slices.Index(fieldsLayout, col.Name)
+		if idx == -1 {
+			continue
+		}
+
+		valueType, typeErr := columnValueType(col.Type)
+		if typeErr != nil {
+			return nil, fmt.Errorf("unable to ingest column `%s` : %s", col.Name, typeErr.Error())
+		}
+
+		b.columns[idx] = bufferColumn{
+			name:      col.Name,
+			typ:       col.Type,
+			nullable:  col.Nullable,
+			precision: col.Precision,
+			offset:    b.rowSize,
+			valueType: valueType,
+		}
+
+		b.rowSize += col.Type.Size()
+	}
+
+	if b.rowSize == 0 {
+		return nil, errors.New("no columns found in fields layout")
+	}
+
+	return b, nil
 }
 
 func IngestBufferFromBinary(binData []byte, fields []string) *IngestBuffer {
@@ -34,6 +116,232 @@ func IngestBufferFromBinary(binData []byte, fields []string) *IngestBuffer {
 	}
 }
 
-func (b *IngestBuffer) AddRows(rows []any) {
+func columnValueType(typ schema.FieldType) (reflect.Type, error) {
+
+	if typ == schema.StringFieldType {
+		return reflect.TypeFor[string](), nil
+	}
+
+	typeOps, typeErr := typ.Ops()
+	if typeErr != nil {
+		return nil, typeErr
+	}
+
+	return reflect.TypeOf(typeOps.MakeSlice(0)).Elem(), nil
+}
+
+// adds value to the strings table,
+// returned index is written into the row in place of string column
+func (b *IngestBuffer) AddString(value string) uint32 {
+	b.Strings = append(b.Strings, value)
+	return uint32(len(b.Strings) - 1)
+}
+
+// marks value of the column in the row as missing
+func (b *IngestBuffer) SetNull(column string, row int) {
+
+	if b.nulls == nil {
+		b.nulls = map[string][]bool{}
+	}
+
+	columnNulls := b.nulls[column]
+	if len(columnNulls) <= row {
+		columnNulls = append(columnNulls, make([]bool, row+1-len(columnNulls))...)
+	}
+
+	columnNulls[row] = true
+	b.nulls[column] = columnNulls
+}
+
+// appends rows holding values in order of FieldsLayout, nil is a null value.
+// values must have go type of the column, timestamps also accept time.Time.
+// every row is checked before any is added, so failed call leaves buffer as it was
+func (b *IngestBuffer) AddRows(rows [][]any) error {
+
+	if b.columns == nil {
+		return errors.New("buffer has no schema, create it with NewIngestBuffer")
+	}
+
+	unevenErr := b.validate()
+	if unevenErr != nil {
+		return unevenErr
+	}
+
+	for rowIdx, row := range rows {
+
+		if len(row) != len(b.columns) {
+			return fmt.Errorf("row %d has %d values, fields layout has %d columns", rowIdx, len(row), len(b.columns))
+		}
+
+		for colIdx, value := range row {
+			valueErr := b.columns[colIdx].check(value)
+			if valueErr != nil {
+				return fmt.Errorf("row %d, column `%s` : %s", rowIdx, b.columns[colIdx].name, valueErr.Error())
+			}
+		}
+	}
+
+	first := b.rows()
+	b.grow(first + len(rows))
+
+	for rowIdx, row := range rows {
+
+		rowOffset := (first + rowIdx) * b.rowSize
+
+		for colIdx, value := range row {
+
+			col := &b.columns[colIdx]
+
+			if value == nil {
+				b.SetNull(col.name, first+rowIdx)
+				continue
+			}
+
+			b.putValue(b.dataBuffer[rowOffset+col.offset:], col, value)
+		}
+	}
+
+	for colIdx := range b.columnRows {
+		b.columnRows[colIdx] = first + len(rows)
+	}
+
+	return nil
+}
+
+// appends values to a single column of the buffer.
+// columns are appended separately, all of them must have the same amount of rows once the buffer is ingested.
+// values can't be null, null rows of nullable column are appended with any value and marked with SetNull
+func AppendColumn[T IngestValue](b *IngestBuffer, name string, values []T) error {
+
+	if b.columns == nil {
+		return errors.New("buffer has no schema, create it with NewIngestBuffer")
+	}
+
+	colIdx := slices.Index(b.FieldsLayout, name)
+	if colIdx == -1 {
+		return fmt.Errorf("no column `%s` in fields layout", name)
+	}
+
+	col := &b.columns[colIdx]
+
+	var zero T
+	typeErr := col.check(zero)
+	if typeErr != nil {
+		return fmt.Errorf("column `%s` : %s", name, typeErr.Error())
+	}
+
+	first := b.columnRows[colIdx]
+	b.grow(first + len(values))
+
+	switch any(values).(type) {
+	case []string, []time.Time:
+		for idx := range values {
+			b.putValue(b.dataBuffer[(first+idx)*b.rowSize+col.offset:], col, any(values[idx]))
+		}
+	default:
+		// numeric values are copied as is, their type matches the column
+		size := col.typ.Size()
+		raw := bits.ArrayToBytes(values)
+
+		for idx := range values {
+			copy(b.dataBuffer[(first+idx)*b.rowSize+col.offset:], raw[idx*size:(idx+1)*size])
+		}
+	}
+
+	b.columnRows[colIdx] = first + len(values)
+
+	return nil
+}
+
+// rows filled in every column
+func (b *IngestBuffer) rows() int {
+	return slices.Min(b.columnRows)
+}
+
+// extends buffer with zeroed rows
+func (b *IngestBuffer) grow(rows int) {
+	if size := rows * b.rowSize; size > len(b.dataBuffer) {
+		b.dataBuffer = append(b.dataBuffer, make([]byte, size-len(b.dataBuffer))...)
+	}
+}
+
+// columns appended separately must end up with the same amount of rows
+func (b *IngestBuffer) validate() error {
+
+	for colIdx, rows := range b.columnRows {
+		if rows != b.columnRows[0] {
+			return fmt.Errorf("column `%s` has %d rows, column `%s` has %d", b.columns[colIdx].name, rows, b.columns[0].name, b.columnRows[0])
+		}
+	}
+
+	return nil
+}
+
+func (col *bufferColumn) check(value any) error {
+
+	switch value.(type) {
+	case nil:
+		if !col.nullable {
+			return errors.New("null value of not nullable column")
+		}
+		return nil
+	case time.Time:
+		if col.typ != schema.TimestampFieldType {
+			return fmt.Errorf("time.Time value of %s column", col.typ.String())
+		}
+		return nil
+	}
+
+	if reflect.TypeOf(value) != col.valueType {
+		return fmt.Errorf("%T value of %s column, expected %s", value, col.typ.String(), col.valueType.String())
+	}
+
+	return nil
+}
+
+// writes checked value of the column, see bufferColumn.check
+func (b *IngestBuffer) putValue(out []byte, col *bufferColumn, value any) {
+
+	switch v := value.(type) {
+	case string:
+		binary.LittleEndian.PutUint32(out, b.stringCode(v))
+	case time.Time:
+		binary.LittleEndian.PutUint64(out, uint64(col.precision.FromTime(v)))
+	case int8:
+		out[0] = byte(v)
+	case uint8:
+		out[0] = v
+	case int16:
+		binary.LittleEndian.PutUint16(out, uint16(v))
+	case uint16:
+		binary.LittleEndian.PutUint16(out, v)
+	case int32:
+		binary.LittleEndian.PutUint32(out, uint32(v))
+	case uint32:
+		binary.LittleEndian.PutUint32(out, v)
+	case int64:
+		binary.LittleEndian.PutUint64(out, uint64(v))
+	case uint64:
+		binary.LittleEndian.PutUint64(out, v)
+	case float32:
+		binary.LittleEndian.PutUint32(out, math.Float32bits(v))
+	case float64:
+		binary.LittleEndian.PutUint64(out, math.Float64bits(v))
+	}
+}
+
+// same values share single entry of strings table
+func (b *IngestBuffer) stringCode(value string) uint32 {
+
+	if b.stringCodes == nil {
+		b.stringCodes = map[string]uint32{}
+	}
+
+	code, ok := b.stringCodes[value]
+	if !ok {
+		code = b.AddString(value)
+		b.stringCodes[value] = code
+	}
 
+	return code
 }
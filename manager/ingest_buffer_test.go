@@ -0,0 +1,164 @@
+package manager
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dot5enko/simple-column-db/schema"
+)
+
+func testBufferSchema() *schema.Schema {
+	return &schema.Schema{Name: "buffers", Columns: []schema.SchemaColumn{
+		{Name: "x", Type: schema.Uint64FieldType},
+		{Name: "at", Type: schema.TimestampFieldType, Precision: schema.PrecisionMillis},
+		{Name: "val", Type: schema.Float32FieldType, Nullable: true},
+	}}
+}
+
+func TestNewIngestBufferRejectsLayout(t *testing.T) {
+
+	for _, it := range []struct {
+		layout   []string
+		expected string
+	}{
+		{[]string{"x", "zzz"}, "no column `zzz` in schema `buffers`"},
+		{[]string{"x", "val", "x"}, "column `x` is listed twice in fields layout"},
+	} {
+		_, bufferErr := NewIngestBuffer(testBufferSchema(), it.layout)
+		if bufferErr == nil || bufferErr.Error() != it.expected {
+			t.Errorf("layout %v : expected `%s`, got %v", it.layout, it.expected, bufferErr)
+		}
+	}
+}
+
+func TestAddRowsErrors(t *testing.T) {
+
+	data, bufferErr := NewIngestBuffer(testBufferSchema(), []string{"x", "at", "val"})
+	if bufferErr != nil {
+		t.Fatal(bufferErr)
+	}
+
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, it := range []struct {
+		rows     [][]any
+		expected string
+	}{
+		{[][]any{{uint64(1), at}}, "row 0 has 2 values, fields layout has 3 columns"},
+		{[][]any{{uint64(1), at, float32(1)}, {1, at, nil}}, "row 1, column `x` : int value of Uint64 column, expected uint64"},
+		{[][]any{{nil, at, nil}}, "row 0, column `x` : null value of not nullable column"},
+		{[][]any{{uint64(1), uint64(2), nil}}, "row 0, column `at` : uint64 value of Timestamp column, expected int64"},
+		{[][]any{{uint64(1), at, at}}, "row 0, column `val` : time.Time value of Float32 column"},
+	} {
+		rowsErr := data.AddRows(it.rows)
+		if rowsErr == nil || rowsErr.Error() != it.expected {
+			t.Errorf("expected `%s`, got %v", it.expected, rowsErr)
+		}
+	}
+
+	// failed calls leave buffer as it was
+	if rows := data.rows(); rows != 0 {
+		t.Fatalf("expected empty buffer, got %d rows", rows)
+	}
+
+	rowsErr := data.AddRows([][]any{{uint64(1), at, float32(0.5)}, {uint64(2), at.UnixMilli(), nil}})
+	if rowsErr != nil {
+		t.Fatalf("unable to add rows : %s", rowsErr.Error())
+	}
+
+	if rows := data.rows(); rows != 2 {
+		t.Errorf("expected 2 rows, got %d", rows)
+	}
+
+	if nulls := data.nulls["val"]; len(nulls) < 2 || nulls[0] || !nulls[1] {
+		t.Errorf("expected second row of val to be null, got %v", nulls)
+	}
+}
+
+func TestAppendColumnErrors(t *testing.T) {
+
+	data, bufferErr := NewIngestBuffer(testBufferSchema(), []string{"x", "val"})
+	if bufferErr != nil {
+		t.Fatal(bufferErr)
+	}
+
+	if appendErr := AppendColumn(data, "at", []int64{1}); appendErr == nil || appendErr.Error() != "no column `at` in fields layout" {
+		t.Errorf("expected missing column error, got %v", appendErr)
+	}
+
+	if appendErr := AppendColumn(data, "x", []uint32{1}); appendErr == nil || appendErr.Error() != "column `x` : uint32 value of Uint64 column, expected uint64" {
+		t.Errorf("expected type error, got %v", appendErr)
+	}
+
+	if appendErr := AppendColumn(IngestBufferFromBinary(nil, []string{"x"}), "x", []uint64{1}); appendErr == nil || !strings.Contains(appendErr.Error(), "create it with NewIngestBuffer") {
+		t.Errorf("expected buffer without schema error, got %v", appendErr)
+	}
+
+	if appendErr := AppendColumn(data, "x", []uint64{1, 2, 3}); appendErr != nil {
+		t.Fatal(appendErr)
+	}
+
+	if appendErr := AppendColumn(data, "val", []float32{1}); appendErr != nil {
+		t.Fatal(appendErr)
+	}
+
+	if validateErr := data.validate(); validateErr == nil || validateErr.Error() != "column `val` has 1 rows, column `x` has 3" {
+		t.Errorf("expected uneven columns error, got %v", validateErr)
+	}
+}
+
+func TestAppendColumnNulls(t *testing.T) {
+
+	data, bufferErr := NewIngestBuffer(testBufferSchema(), []string{"x", "val"})
+	if bufferErr != nil {
+		t.Fatal(bufferErr)
+	}
+
+	if appendErr := AppendColumn(data, "x", []uint64{1, 2, 3}); appendErr != nil {
+		t.Fatal(appendErr)
+	}
+
+	if appendErr := AppendColumn(data, "val", []float32{0.5, 0, 1.5}); appendErr != nil {
+		t.Fatal(appendErr)
+	}
+
+	data.SetNull("val", 1)
+
+	if validateErr := data.validate(); validateErr != nil {
+		t.Fatal(validateErr)
+	}
+
+	if nulls := data.nulls["val"]; len(nulls) < 2 || nulls[0] || !nulls[1] {
+		t.Errorf("expected second row of val to be null, got %v", nulls)
+	}
+}
+
+// layout of binary data is checked when it's ingested
+func TestIngestRejectsUnknownLayout(t *testing.T) {
+
+	m := openTestManager(t, t.TempDir(), testSchema("checks"))
+	defer closeTestManager(t, m)
+
+	binData := binary.LittleEndian.AppendUint64(nil, 1)
+	binData = binary.LittleEndian.AppendUint64(binData, 1)
+	binData = binary.LittleEndian.AppendUint32(binData, 1)
+
+	for _, it := range []struct {
+		layout   []string
+		expected string
+	}{
+		{[]string{"x", "y", "zzz"}, "no column `zzz` in schema `checks`"},
+		{[]string{"x", "x"}, "column `x` is listed twice in fields layout"},
+	} {
+		ingestErr := m.Ingest("checks", IngestBufferFromBinary(binData, it.layout))
+		if ingestErr == nil || ingestErr.Error() != it.expected {
+			t.Errorf("layout %v : expected `%s`, got %v", it.layout, it.expected, ingestErr)
+		}
+	}
+
+	if count, _ := testCountSum(t, m, "checks"); count != 0 {
+		t.Errorf("expected no rows, got %d", count)
+	}
+}
@@ -0,0 +1,299 @@
+package manager
+
+import (
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+	"unsafe"
+
+	"github.com/dot5enko/simple-column-db/schema"
+)
+
+// struct fields are mapped to columns by tag, `scdb:"created_at"`.
+// options follow the column name: time precision of timestamps (s, ms, us, ns)
+// and codec of the column, `scdb:"created_at,ms,delta-of-delta"`.
+// pointer fields are nullable columns, nil is a null value
+const structTag = "scdb"
+
+type structColumn struct {
+	field  reflect.StructField
+	column schema.SchemaColumn
+
+	// offset of the field in the outer struct, fields of embedded structs included
+	offset int
+
+	// field holds a pointer to the value
+	pointer bool
+
+	// value is time.Time, converted to units of column precision
+	time bool
+}
+
+// ingests values of tagged fields, columns are collected from structs directly without row buffer.
+// schema columns without field must be nullable
+func IngestStructs[T any](m *Manager, schemaName string, items []T) error {
+
+	schemaObject := m.Meta.SchemaSnapshot(schemaName)
+	if schemaObject == nil {
+		return fmt.Errorf("schema `%s` not found", schemaName)
+	}
+
+	columns, columnsErr := structColumns(reflect.TypeFor[T]())
+	if columnsErr != nil {
+		return columnsErr
+	}
+
+	data := &IngestBuffer{
+		FieldsLayout: make([]string, len(columns)),
+		columnData:   make([][]byte, len(columns)),
+	}
+
+	// structs are read as raw memory, fields are at the same offset in every item
+	stride := int(reflect.TypeFor[T]().Size())
+
+	var raw []byte
+	if len(items) > 0 {
+		raw = unsafe.Slice((*byte)(unsafe.Pointer(&items[0])), len(items)*stride)
+	}
+
+	for idx, it := range columns {
+
+		_, col := findSchemaColumn(schemaObject, it.column.Name)
+		if col == nil {
+			return fmt.Errorf("no column `%s` in schema `%s` for field %s", it.column.Name, schemaName, it.field.Name)
+		}
+
+		// int64 values are stored in timestamp columns as is
+		timestampUnits := col.Type == schema.TimestampFieldType && it.column.Type == schema.Int64FieldType
+
+		if col.Type != it.column.Type && !timestampUnits {
+			return fmt.Errorf("field %s of %s type can't be stored in %s column `%s`", it.field.Name, it.field.Type.String(), col.Type.String(), col.Name)
+		}
+
+		if it.pointer && !col.Nullable {
+			return fmt.Errorf("field %s is a pointer, but column `%s` is not nullable", it.field.Name, col.Name)
+		}
+
+		data.FieldsLayout[idx] = col.Name
+		data.columnData[idx] = collectStructColumn(data, raw, stride, len(items), it, col)
+	}
+
+	return m.Ingest(schemaName, data)
+}
+
+// values of the field of every item, encoded as column values
+func collectStructColumn(data *IngestBuffer, raw []byte, stride, rows int, it structColumn, col *schema.SchemaColumn) []byte {
+
+	size := col.Type.Size()
+	offset := it.offset
+	out := make([]byte, rows*size)
+
+	// plain numbers are copied as is
+	if !it.pointer && !it.time && col.Type != schema.StringFieldType {
+		collectColumnBytes(raw, size, offset, stride, rows, out)
+		return out
+	}
+
+	for row := range rows {
+
+		value := unsafe.Pointer(&raw[row*stride+offset])
+
+		if it.pointer {
+			value = *(*unsafe.Pointer)(value)
+			if value == nil {
+				data.SetNull(col.Name, row)
+				continue
+			}
+		}
+
+		switch {
+		case col.Type == schema.StringFieldType:
+			binary.LittleEndian.PutUint32(out[row*size:], data.stringCode(*(*string)(value)))
+		case it.time:
+			binary.LittleEndian.PutUint64(out[row*size:], uint64(col.Precision.FromTime(*(*time.Time)(value))))
+		default:
+			copy(out[row*size:], unsafe.Slice((*byte)(value), size))
+		}
+	}
+
+	return out
+}
+
+// schema of tagged fields of the struct
+func SchemaFromStruct[T any](schemaName string) (schema.Schema, error) {
+
+	columns, columnsErr := structColumns(reflect.TypeFor[T]())
+	if columnsErr != nil {
+		return schema.Schema{}, columnsErr
+	}
+
+	result := schema.Schema{
+		Name:    schemaName,
+		Columns: make([]schema.SchemaColumn, len(columns)),
+	}
+
+	for idx, it := range columns {
+		result.Columns[idx] = it.column
+	}
+
+	return result, nil
+}
+
+// creates schema with a column per tagged field of the struct, see structTag
+func CreateSchemaFromStruct[T any](m *Manager, schemaName string) error {
+
+	schemaConfig, schemaErr := SchemaFromStruct[T](schemaName)
+	if schemaErr != nil {
+		return schemaErr
+	}
+
+	return m.CreateSchemaIfNotExists(schemaConfig)
+}
+
+func structColumns(structType reflect.Type) ([]structColumn, error) {
+
+	if structType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%s is not a struct", structType.String())
+	}
+
+	result := []structColumn{}
+
+	for _, field := range reflect.VisibleFields(structType) {
+
+		tag, tagged := field.Tag.Lookup(structTag)
+		if !tagged || tag == "-" || !field.IsExported() {
+			continue
+		}
+
+		options := strings.Split(tag, ",")
+
+		it := structColumn{
+			field:  field,
+			column: schema.SchemaColumn{Name: options[0]},
+		}
+
+		if it.column.Name == "" {
+			return nil, fmt.Errorf("field %s has no column name in `%s` tag", field.Name, structTag)
+		}
+
+		offset, offsetErr := fieldOffset(structType, field.Index)
+		if offsetErr != nil {
+			return nil, fmt.Errorf("field %s : %s", field.Name, offsetErr.Error())
+		}
+
+		it.offset = offset
+
+		valueType := field.Type
+		if valueType.Kind() == reflect.Pointer {
+			valueType = valueType.Elem()
+			it.pointer = true
+			it.column.Nullable = true
+		}
+
+		it.time = valueType == reflect.TypeFor[time.Time]()
+
+		fieldType, typeErr := structFieldType(valueType)
+		if typeErr != nil {
+			return nil, fmt.Errorf("field %s : %s", field.Name, typeErr.Error())
+		}
+
+		it.column.Type = fieldType
+
+		for _, option := range options[1:] {
+			optionErr := applyTagOption(&it.column, option)
+			if optionErr != nil {
+				return nil, fmt.Errorf("field %s : %s", field.Name, optionErr.Error())
+			}
+		}
+
+		result = append(result, it)
+	}
+
+	if len(result) == 0 {
+		return nil, fmt.Errorf("%s has no fields with `%s` tag", structType.String(), structTag)
+	}
+
+	return result, nil
+}
+
+// fields of embedded structs are at offset of the struct and the field in it
+func fieldOffset(structType reflect.Type, index []int) (int, error) {
+
+	offset := 0
+	current := structType
+
+	for depth, fieldIdx := range index {
+
+		field := current.Field(fieldIdx)
+		offset += int(field.Offset)
+
+		if depth == len(index)-1 {
+			break
+		}
+
+		if field.Type.Kind() != reflect.Struct {
+			return 0, fmt.Errorf("embedded %s is not stored in the struct", field.Type.String())
+		}
+
+		current = field.Type
+	}
+
+	return offset, nil
+}
+
+// column type of go type, int64 fields become timestamps with a precision option.
+// named types are matched by their kind
+func structFieldType(valueType reflect.Type) (schema.FieldType, error) {
+
+	if valueType == reflect.TypeFor[time.Time]() {
+		return schema.TimestampFieldType, nil
+	}
+
+	if valueType.Kind() == reflect.String {
+		return schema.StringFieldType, nil
+	}
+
+	for _, typ := range []schema.FieldType{
+		schema.Int8FieldType, schema.Int16FieldType, schema.Int32FieldType, schema.Int64FieldType,
+		schema.Uint8FieldType, schema.Uint16FieldType, schema.Uint32FieldType, schema.Uint64FieldType,
+		schema.Float32FieldType, schema.Float64FieldType,
+	} {
+		if goType, _ := columnValueType(typ); goType.Kind() == valueType.Kind() {
+			return typ, nil
+		}
+	}
+
+	return 0, fmt.Errorf("%s values can't be stored in a column", valueType.String())
+}
+
+// precision option turns int64 column into timestamp one
+func applyTagOption(column *schema.SchemaColumn, option string) error {
+
+	for precision := schema.PrecisionSeconds; precision <= schema.PrecisionNanos; precision++ {
+		if option != precision.String() {
+			continue
+		}
+
+		if column.Type == schema.Int64FieldType {
+			column.Type = schema.TimestampFieldType
+		}
+
+		if column.Type != schema.TimestampFieldType {
+			return fmt.Errorf("time precision `%s` of %s column", option, column.Type.String())
+		}
+
+		column.Precision = precision
+		return nil
+	}
+
+	for codec := schema.CodecNone; codec <= schema.CodecFrameOfReference; codec++ {
+		if option == codec.String() {
+			column.Codec = codec
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown `%s` tag option `%s`", structTag, option)
+}
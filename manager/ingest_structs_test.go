@@ -0,0 +1,267 @@
+package manager
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dot5enko/simple-column-db/manager/query"
+	"github.com/dot5enko/simple-column-db/schema"
+)
+
+type testLevel uint8
+
+type testStructSource struct {
+	Host string `scdb:"host"`
+}
+
+type testStructEvent struct {
+	testStructSource
+
+	Id       uint64     `scdb:"id,delta"`
+	At       time.Time  `scdb:"at,ms"`
+	Received int64      `scdb:"received,us,delta-of-delta"`
+	Level    testLevel  `scdb:"level"`
+	Value    *float32   `scdb:"value"`
+	Seen     *time.Time `scdb:"seen,s"`
+
+	Note     string `scdb:"-"`
+	internal int    `scdb:"internal"`
+	Plain    uint32
+}
+
+func TestSchemaFromStruct(t *testing.T) {
+
+	sc, schemaErr := SchemaFromStruct[testStructEvent]("events")
+	if schemaErr != nil {
+		t.Fatal(schemaErr)
+	}
+
+	expected := []schema.SchemaColumn{
+		{Name: "host", Type: schema.StringFieldType},
+		{Name: "id", Type: schema.Uint64FieldType, Codec: schema.CodecDelta},
+		{Name: "at", Type: schema.TimestampFieldType, Precision: schema.PrecisionMillis},
+		{Name: "received", Type: schema.TimestampFieldType, Precision: schema.PrecisionMicros, Codec: schema.CodecDeltaOfDelta},
+		{Name: "level", Type: schema.Uint8FieldType},
+		{Name: "value", Type: schema.Float32FieldType, Nullable: true},
+		{Name: "seen", Type: schema.TimestampFieldType, Precision: schema.PrecisionSeconds, Nullable: true},
+	}
+
+	if sc.Name != "events" || len(sc.Columns) != len(expected) {
+		t.Fatalf("expected %d columns of events, got %d columns of %s", len(expected), len(sc.Columns), sc.Name)
+	}
+
+	for idx, col := range sc.Columns {
+		it := expected[idx]
+		if col.Name != it.Name || col.Type != it.Type || col.Precision != it.Precision || col.Codec != it.Codec || col.Nullable != it.Nullable {
+			t.Errorf("column %d : expected %+v, got %+v", idx, it, col)
+		}
+	}
+}
+
+func TestSchemaFromStructErrors(t *testing.T) {
+
+	for _, it := range []struct {
+		schemaErr func() error
+		expected  string
+	}{
+		{func() error {
+			_, err := SchemaFromStruct[struct {
+				A uint32 `scdb:"a,ms"`
+			}]("s")
+			return err
+		}, "field A : time precision `ms` of Uint32 column"},
+		{func() error {
+			_, err := SchemaFromStruct[struct {
+				A uint32 `scdb:"a,zstd"`
+			}]("s")
+			return err
+		}, "field A : unknown `scdb` tag option `zstd`"},
+		{func() error {
+			_, err := SchemaFromStruct[struct {
+				A []byte `scdb:"a"`
+			}]("s")
+			return err
+		}, "field A : []uint8 values can't be stored in a column"},
+		{func() error {
+			_, err := SchemaFromStruct[struct {
+				A uint32 `scdb:",delta"`
+			}]("s")
+			return err
+		}, "field A has no column name in `scdb` tag"},
+		{func() error {
+			_, err := SchemaFromStruct[struct{ A uint32 }]("s")
+			return err
+		}, "has no fields with `scdb` tag"},
+		{func() error {
+			_, err := SchemaFromStruct[int]("s")
+			return err
+		}, "int is not a struct"},
+	} {
+		schemaErr := it.schemaErr()
+		if schemaErr == nil || !strings.HasSuffix(schemaErr.Error(), it.expected) {
+			t.Errorf("expected `%s`, got %v", it.expected, schemaErr)
+		}
+	}
+}
+
+// tagged fields get to their columns, nil pointers are nulls
+func TestIngestStructs(t *testing.T) {
+
+	const rows = 50000
+
+	dir := t.TempDir()
+
+	m := openTestManager(t, dir)
+	if createErr := CreateSchemaFromStruct[testStructEvent](m, "events"); createErr != nil {
+		t.Fatal(createErr)
+	}
+
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	items := make([]testStructEvent, rows)
+	for i := range items {
+		items[i] = testStructEvent{
+			testStructSource: testStructSource{Host: fmt.Sprintf("host-%d", i%3)},
+			Id:               uint64(i),
+			At:               start.Add(time.Duration(i) * time.Millisecond),
+			Received:         start.Add(time.Duration(i) * time.Second).UnixMicro(),
+			Level:            testLevel(i % 5),
+			Note:             "not stored",
+			Plain:            1,
+		}
+
+		if i%2 == 0 {
+			value := float32(i % 10)
+			items[i].Value = &value
+		}
+
+		if i%4 == 0 {
+			seen := start.Add(time.Duration(i) * time.Minute)
+			items[i].Seen = &seen
+		}
+	}
+
+	if ingestErr := IngestStructs(m, "events", items); ingestErr != nil {
+		t.Fatal(ingestErr)
+	}
+
+	closeTestManager(t, m)
+
+	m = openTestManager(t, dir)
+	defer closeTestManager(t, m)
+
+	result, queryErr := m.Query("events", query.Query{Select: []query.Selector{
+		{Type: query.SelectColumn, Arguments: []any{"host"}},
+		{Type: query.SelectColumn, Arguments: []any{"id"}},
+		{Type: query.SelectColumn, Arguments: []any{"at"}},
+		{Type: query.SelectColumn, Arguments: []any{"received"}},
+		{Type: query.SelectColumn, Arguments: []any{"level"}},
+		{Type: query.SelectColumn, Arguments: []any{"value"}},
+		{Type: query.SelectColumn, Arguments: []any{"seen"}},
+	}}, t.Context())
+	if queryErr != nil {
+		t.Fatal(queryErr)
+	}
+
+	if len(result.RowIds) != rows {
+		t.Fatalf("expected %d rows, got %d", rows, len(result.RowIds))
+	}
+
+	for row := range rows {
+		item := items[result.RowIds[row]]
+
+		var value, seen any
+		if item.Value != nil {
+			value = *item.Value
+		}
+		if item.Seen != nil {
+			seen = item.Seen.Unix()
+		}
+
+		got := result.Data["seen"][row]
+		if got != nil {
+			got = got.(time.Time).Unix()
+		}
+
+		if result.Data["host"][row] != item.Host ||
+			result.Data["id"][row] != item.Id ||
+			!result.Data["at"][row].(time.Time).Equal(item.At) ||
+			result.Data["received"][row].(time.Time).UnixMicro() != item.Received ||
+			result.Data["level"][row] != uint8(item.Level) ||
+			result.Data["value"][row] != value ||
+			got != seen {
+			t.Fatalf("row %d : unexpected values %v %v %v %v %v %v %v", row,
+				result.Data["host"][row], result.Data["id"][row], result.Data["at"][row], result.Data["received"][row],
+				result.Data["level"][row], result.Data["value"][row], result.Data["seen"][row])
+		}
+	}
+}
+
+func TestIngestStructsErrors(t *testing.T) {
+
+	m := openTestManager(t, t.TempDir(), schema.Schema{Name: "strict", Columns: []schema.SchemaColumn{
+		{Name: "a", Type: schema.Uint32FieldType},
+		{Name: "b", Type: schema.Uint64FieldType, Nullable: true},
+	}})
+	defer closeTestManager(t, m)
+
+	for _, it := range []struct {
+		ingestErr func() error
+		expected  string
+	}{
+		{func() error {
+			return IngestStructs(m, "strict", []struct {
+				A *uint32 `scdb:"a"`
+			}{{}})
+		}, "field A is a pointer, but column `a` is not nullable"},
+		{func() error {
+			return IngestStructs(m, "strict", []struct {
+				A uint32 `scdb:"a"`
+				B uint32 `scdb:"b"`
+			}{{}})
+		}, "field B of uint32 type can't be stored in Uint64 column `b`"},
+		{func() error {
+			return IngestStructs(m, "strict", []struct {
+				C uint32 `scdb:"c"`
+			}{{}})
+		}, "no column `c` in schema `strict` for field C"},
+		{func() error {
+			return IngestStructs(m, "missing", []struct {
+				A uint32 `scdb:"a"`
+			}{{}})
+		}, "schema `missing` not found"},
+	} {
+		ingestErr := it.ingestErr()
+		if ingestErr == nil || ingestErr.Error() != it.expected {
+			t.Errorf("expected `%s`, got %v", it.expected, ingestErr)
+		}
+	}
+
+	// not nullable column can't be left out
+	ingestErr := IngestStructs(m, "strict", []struct {
+		B uint64 `scdb:"b"`
+	}{{B: 1}})
+	if ingestErr == nil {
+		t.Errorf("expected error for struct without not nullable column")
+	}
+
+	if count := testQuery(t, m, "strict", query.Query{Select: []query.Selector{{Arguments: []any{"count"}, Alias: "count"}}})["count"][0].(int); count != 0 {
+		t.Errorf("expected no rows, got %d", count)
+	}
+
+	// nullable column may be left out
+	ingestErr = IngestStructs(m, "strict", []struct {
+		A uint32 `scdb:"a"`
+	}{{A: 1}, {A: 2}})
+	if ingestErr != nil {
+		t.Fatal(ingestErr)
+	}
+
+	data := testQuery(t, m, "strict", query.Query{Select: []query.Selector{{Type: query.SelectColumn, Arguments: []any{"b"}}}})
+	if !slices.Equal(data["b"], []any{nil, nil}) {
+		t.Errorf("expected nulls in left out column, got %v", data["b"])
+	}
+}
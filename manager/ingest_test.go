@@ -0,0 +1,274 @@
+package manager
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/dot5enko/simple-column-db/manager/query"
+	"github.com/dot5enko/simple-column-db/schema"
+)
+
+// queries running next to ingest see whole batches with columns aligned,
+// batches aren't aligned to blocks so the active block is read while written
+func TestQueryWhileIngesting(t *testing.T) {
+
+	const (
+		batches   = 12
+		batchRows = 10000
+	)
+
+	m := openTestManager(t, t.TempDir(), testSchema("checks"))
+	defer closeTestManager(t, m)
+
+	var (
+		done    atomic.Bool
+		queries sync.WaitGroup
+	)
+
+	q := query.Query{Select: []query.Selector{
+		{Arguments: []any{"count"}, Alias: "count"},
+		{Arguments: []any{"sum", "x"}, Alias: "x"},
+		{Arguments: []any{"sum", "y"}, Alias: "y"},
+	}}
+
+	for range 2 {
+		queries.Add(1)
+		go func() {
+			defer queries.Done()
+
+			for !done.Load() {
+				result, queryErr := m.Query("checks", q, t.Context())
+				if queryErr != nil {
+					t.Errorf("unable to query : %s", queryErr.Error())
+					return
+				}
+
+				count := result.Data["count"][0].(int)
+				x := result.Data["x"][0].(float64)
+				y := result.Data["y"][0].(float64)
+
+				if count%batchRows != 0 {
+					t.Errorf("query saw part of a batch : %d rows", count)
+					return
+				}
+
+				if x != float64(count) || y != float64(count) {
+					t.Errorf("columns aren't aligned : %d rows, sum x %f, sum y %f", count, x, y)
+					return
+				}
+			}
+		}()
+	}
+
+	for range batches {
+		if ingestErr := m.Ingest("checks", testRows(batchRows, 1)); ingestErr != nil {
+			t.Fatalf("unable to ingest : %s", ingestErr.Error())
+		}
+	}
+
+	done.Store(true)
+	queries.Wait()
+
+	count, sum := testCountSum(t, m, "checks")
+	if count != batches*batchRows || sum != batches*batchRows {
+		t.Errorf("expected %d rows, got %d rows with sum %f", batches*batchRows, count, sum)
+	}
+}
+
+// slab filled by ingest is stored compressed and reads the same after restart
+func TestFinalizedSlabIsCompressed(t *testing.T) {
+
+	dir := t.TempDir()
+	m := openTestManager(t, dir, testSchema("checks"))
+
+	// x slab holds 40 blocks of uint64
+	const rows = 1400000
+
+	if ingestErr := m.Ingest("checks", testRows(rows, 3)); ingestErr != nil {
+		t.Fatal(ingestErr)
+	}
+
+	closeTestManager(t, m)
+
+	m = openTestManager(t, dir)
+	defer closeTestManager(t, m)
+
+	schemaObject := m.Meta.GetSchema("checks")
+
+	header, headerErr := m.Slabs.LoadSlabHeaderToCache(schemaObject, schemaObject.Columns[0].Slabs[0])
+	if headerErr != nil {
+		t.Fatal(headerErr)
+	}
+
+	if header.CompressionType != schema.SlabCompressionLz4 {
+		t.Errorf("expected finalized slab to be lz4 compressed, got %d", header.CompressionType)
+	}
+
+	count, sum := testCountSum(t, m, "checks")
+	if count != rows || sum != rows*3 {
+		t.Errorf("expected %d rows with sum %d, got %d rows with sum %f", rows, rows*3, count, sum)
+	}
+}
+
+// rows written but not committed yet aren't seen by any kind of query,
+// committed rows are restored on start
+func TestQueriesSeeCommittedRows(t *testing.T) {
+
+	const (
+		rows      = 100000
+		committed = 40000
+	)
+
+	dir := t.TempDir()
+	m := openTestManager(t, dir, testSchema("checks"))
+
+	x := func(i int) uint64 { return uint64(i) }
+	y := func(i int) uint64 { return 1 }
+
+	if ingestErr := m.Ingest("checks", testRowsOf(rows, x, y)); ingestErr != nil {
+		t.Fatal(ingestErr)
+	}
+
+	// as if ingest wrote rows past the middle of a block and didn't commit them yet
+	m.Meta.CommitRows("checks", committed)
+
+	if count, sum := testCountSum(t, m, "checks"); count != committed || sum != committed*(committed-1)/2 {
+		t.Errorf("expected %d rows, got %d rows with sum %f", committed, count, sum)
+	}
+
+	// every row matches, blocks are fully matched by their bounds
+	for _, filter := range []query.FilterCondition{
+		{Field: "y", Operand: query.EQ, Arguments: []any{uint64(1)}},
+		{Field: "x", Operand: query.GTE, Arguments: []any{uint64(0)}},
+	} {
+		data := testQuery(t, m, "checks", query.Query{
+			Filter: []query.FilterCondition{filter},
+			Select: []query.Selector{{Arguments: []any{"count"}, Alias: "count"}},
+		})
+
+		if count := data["count"][0].(int); count != committed {
+			t.Errorf("filter by %s : expected %d rows, got %d", filter.Field, committed, count)
+		}
+	}
+
+	result, queryErr := m.Query("checks", query.Query{
+		Filter: []query.FilterCondition{{Field: "y", Operand: query.EQ, Arguments: []any{uint64(1)}}},
+		Select: []query.Selector{{Type: query.SelectColumn, Arguments: []any{"x"}}},
+	}, t.Context())
+	if queryErr != nil {
+		t.Fatal(queryErr)
+	}
+
+	if len(result.RowIds) != committed || result.RowIds[len(result.RowIds)-1] != committed-1 {
+		t.Errorf("expected projection of %d rows", committed)
+	}
+
+	result, queryErr = m.Query("checks", query.Query{
+		OrderBy: []query.OrderBy{{Field: "x", Desc: true}},
+		Limit:   3,
+		Select:  []query.Selector{{Type: query.SelectColumn, Arguments: []any{"x"}}},
+	}, t.Context())
+	if queryErr != nil {
+		t.Fatal(queryErr)
+	}
+
+	if len(result.Data["x"]) != 3 {
+		t.Fatalf("expected 3 rows, got %d", len(result.Data["x"]))
+	}
+
+	for row, value := range result.Data["x"] {
+		if expected := uint64(committed - 1 - row); value.(uint64) != expected {
+			t.Errorf("row %d : expected x %d, got %v", row, expected, value)
+		}
+	}
+
+	m.Meta.CommitRows("checks", rows)
+	closeTestManager(t, m)
+
+	m = openTestManager(t, dir)
+	defer closeTestManager(t, m)
+
+	if count, sum := testCountSum(t, m, "checks"); count != rows || sum != rows*(rows-1)/2 {
+		t.Errorf("expected %d rows after restart, got %d rows with sum %f", rows, count, sum)
+	}
+}
+
+// ingests into one schema from several goroutines keep batches whole and columns aligned
+func TestConcurrentIngest(t *testing.T) {
+
+	const (
+		writers   = 8
+		batches   = 5
+		batchRows = 9000
+		rows      = writers * batches * batchRows
+	)
+
+	dir := t.TempDir()
+	m := openTestManager(t, dir, testSchema("checks"))
+
+	var ingests sync.WaitGroup
+
+	for w := range writers {
+		ingests.Add(1)
+		go func() {
+			defer ingests.Done()
+
+			for b := range batches {
+				first := uint64((w*batches + b) * batchRows)
+
+				x := func(i int) uint64 { return first + uint64(i) }
+				y := func(i int) uint64 { return 2 * (first + uint64(i)) }
+
+				if ingestErr := m.Ingest("checks", testRowsOf(batchRows, x, y)); ingestErr != nil {
+					t.Errorf("writer %d : unable to ingest : %s", w, ingestErr.Error())
+					return
+				}
+			}
+		}()
+	}
+
+	ingests.Wait()
+
+	result, queryErr := m.Query("checks", query.Query{Select: []query.Selector{
+		{Type: query.SelectColumn, Arguments: []any{"x"}},
+		{Type: query.SelectColumn, Arguments: []any{"y"}},
+	}}, t.Context())
+	if queryErr != nil {
+		t.Fatal(queryErr)
+	}
+
+	xs, ys := result.Data["x"], result.Data["y"]
+	if len(xs) != rows || len(ys) != rows {
+		t.Fatalf("expected %d rows, got %d x and %d y", rows, len(xs), len(ys))
+	}
+
+	seen := make([]bool, rows)
+
+	for row := range rows {
+		x, y := xs[row].(uint64), ys[row].(uint64)
+
+		if x >= rows || seen[x] {
+			t.Fatalf("row %d : unexpected or repeated x %d", row, x)
+		}
+		seen[x] = true
+
+		if y != 2*x {
+			t.Fatalf("row %d : columns aren't aligned, x %d, y %d", row, x, y)
+		}
+
+		// rows of a batch follow each other
+		if row > 0 && x%batchRows != 0 && xs[row-1].(uint64) != x-1 {
+			t.Fatalf("row %d : batch is interleaved, x %d follows %d", row, x, xs[row-1])
+		}
+	}
+
+	closeTestManager(t, m)
+
+	m = openTestManager(t, dir)
+	defer closeTestManager(t, m)
+
+	if count, sum := testCountSum(t, m, "checks"); count != rows || sum != rows*(rows-1)/2 {
+		t.Errorf("expected %d rows after restart, got %d rows with sum %f", rows, count, sum)
+	}
+}
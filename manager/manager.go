@@ -1,7 +1,9 @@
 package manager
 
 import (
+	"fmt"
 	"runtime"
+	"sync"
 
 	"github.com/dot5enko/simple-column-db/manager/executor"
 	"github.com/dot5enko/simple-column-db/manager/meta"
@@ -11,6 +13,7 @@ import (
 type ManagerConfig struct {
 	PathToStorage string
 
+	// limit of slab header, data and block caches, meta.DefaultCacheMaxBytes if not set
 	CacheMaxBytes uint64
 
 	ExecutorsMaxConcurentThreads int
@@ -26,21 +29,44 @@ type Manager struct {
 	queryOptions query.QueryOptions
 
 	chunksQueue chan *executor.ChunkProcessingTask
+
+	// logs of schemas, ingests into a schema are applied one at a time, in order of its log
+	wals     map[string]*ingestWal
+	walsLock sync.Mutex
+
+	// queries and ingests in flight, new ones are rejected once manager is closed
+	lifecycleLock sync.Mutex
+	closed        bool
+	operations    sync.WaitGroup
+
+	workers []*sync.WaitGroup
+
+	// closed while no worker is running, queries don't wait for chunks then
+	runningWorkers int
+	workersStopped chan struct{}
+
+	// chunks queue is closed once, by the call of Close that got past operations in flight
+	closeLock   sync.Mutex
+	queueClosed bool
 }
 
 func (m *Manager) SetQueryOptions(qopts query.QueryOptions) {
 	m.queryOptions = qopts
 }
 
-func New(config ManagerConfig) *Manager {
+func New(config ManagerConfig) (*Manager, error) {
 
 	man := &Manager{
 		Planner:     NewQueryPlanner(),
 		Meta:        meta.NewMetaManager(config.PathToStorage),
 		chunksQueue: make(chan *executor.ChunkProcessingTask, 100),
+
+		workersStopped: make(chan struct{}),
 	}
 
-	man.Slabs = meta.NewSlabManager(config.PathToStorage, man.Meta)
+	close(man.workersStopped)
+
+	man.Slabs = meta.NewSlabManager(config.PathToStorage, config.CacheMaxBytes, man.Meta)
 
 	{ // executor cache setup
 		maxThreadsCache := config.ExecutorsMaxConcurentThreads
@@ -56,9 +82,18 @@ func New(config ManagerConfig) *Manager {
 
 	loadErr := man.Meta.LoadSchemesFromDisk()
 	if loadErr != nil {
-		panic(loadErr) // todo return error
+		return nil, fmt.Errorf("unable to load schemas : %s", loadErr.Error())
+	}
+
+	man.wals = map[string]*ingestWal{}
+
+	replayErr := man.replayWal()
+	if replayErr != nil {
+		man.closeWals()
+		return nil, replayErr
 	}
 
-	return man
+	man.loadCommittedRows()
 
+	return man, nil
 }
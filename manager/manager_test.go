@@ -0,0 +1,118 @@
+package manager
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dot5enko/simple-column-db/manager/query"
+	"github.com/dot5enko/simple-column-db/schema"
+	"github.com/google/uuid"
+)
+
+// manager over storage directory with the schemas created and workers started
+func openTestManager(t *testing.T, dir string, schemas ...schema.Schema) *Manager {
+
+	t.Helper()
+
+	m, newErr := New(ManagerConfig{PathToStorage: dir})
+	if newErr != nil {
+		t.Fatalf("unable to open manager : %s", newErr.Error())
+	}
+
+	for _, it := range schemas {
+		createErr := m.CreateSchemaIfNotExists(it)
+		if createErr != nil {
+			t.Fatalf("unable to create schema %s : %s", it.Name, createErr.Error())
+		}
+	}
+
+	m.StartWorkers(2, context.Background())
+
+	return m
+}
+
+func closeTestManager(t *testing.T, m *Manager) {
+
+	t.Helper()
+
+	closeErr := m.Close(context.Background())
+	if closeErr != nil {
+		t.Fatalf("unable to close manager : %s", closeErr.Error())
+	}
+}
+
+// schema with uint64 columns x and y
+func testSchema(name string) schema.Schema {
+	return schema.Schema{Name: name, Columns: []schema.SchemaColumn{
+		{Name: "x", Type: schema.Uint64FieldType},
+		{Name: "y", Type: schema.Uint64FieldType},
+	}}
+}
+
+// rows of testSchema with the same x and y
+func testRows(rows int, value uint64) *IngestBuffer {
+	return testRowsOf(rows, func(int) uint64 { return value }, func(int) uint64 { return value })
+}
+
+// rows of testSchema with values computed from row index
+func testRowsOf(rows int, x func(i int) uint64, y func(i int) uint64) *IngestBuffer {
+
+	binData := make([]byte, 0, rows*16)
+	for i := range rows {
+		binData = binary.LittleEndian.AppendUint64(binData, x(i))
+		binData = binary.LittleEndian.AppendUint64(binData, y(i))
+	}
+
+	return IngestBufferFromBinary(binData, []string{"x", "y"})
+}
+
+func testQuery(t *testing.T, m *Manager, schemaName string, q query.Query) map[string][]any {
+
+	t.Helper()
+
+	result, queryErr := m.Query(schemaName, q, context.Background())
+	if queryErr != nil {
+		t.Fatalf("unable to query %s : %s", schemaName, queryErr.Error())
+	}
+
+	return result.Data
+}
+
+// count of rows and sum of x
+func testCountSum(t *testing.T, m *Manager, schemaName string) (int, float64) {
+
+	t.Helper()
+
+	data := testQuery(t, m, schemaName, query.Query{Select: []query.Selector{
+		{Arguments: []any{"count"}, Alias: "count"},
+		{Arguments: []any{"sum", "x"}, Alias: "sum"},
+	}})
+
+	return data["count"][0].(int), data["sum"][0].(float64)
+}
+
+// flips a byte of slab file while manager is closed
+func flipTestSlabByte(t *testing.T, dir string, schemaName string, slabUid uuid.UUID, offset int64) {
+
+	t.Helper()
+
+	f, openErr := os.OpenFile(filepath.Join(dir, schemaName, slabUid.String()+".slab"), os.O_RDWR, 0)
+	if openErr != nil {
+		t.Fatal(openErr)
+	}
+	defer f.Close()
+
+	flipped := []byte{0}
+	if _, readErr := f.ReadAt(flipped, offset); readErr != nil {
+		t.Fatal(readErr)
+	}
+
+	flipped[0] ^= 0x5a
+
+	if _, writeErr := f.WriteAt(flipped, offset); writeErr != nil {
+		t.Fatal(writeErr)
+	}
+}
@@ -2,18 +2,60 @@ package manager
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"sync"
 
 	"github.com/dot5enko/simple-column-db/manager/executor"
 )
 
-// todo handle context
+var ErrNoWorkers = errors.New("no workers are running, start them with StartWorkers")
+
+// workers stop once ctx is done or manager is closed.
+// no workers are started by a closed manager
 func (sm *Manager) StartWorkers(routines int, ctx context.Context) *sync.WaitGroup {
 
+	// workers are registered along with starting,
+	// so Close either waits for them or they are never started
+	sm.lifecycleLock.Lock()
+	defer sm.lifecycleLock.Unlock()
+
+	if sm.closed {
+		slog.Warn("manager is closed, workers are not started")
+		return &sync.WaitGroup{}
+	}
+
 	slog.Info("starting workers", "max_executors", routines)
 
-	return StartWorkerThreads(routines, func(threadId int) {
-		executor.ChunkSingleThreadProcessor(threadId, sm.Slabs, sm.chunksQueue)
+	if sm.runningWorkers == 0 && routines > 0 {
+		sm.workersStopped = make(chan struct{})
+	}
+	sm.runningWorkers += routines
+
+	workers := StartWorkerThreads(routines, func(threadId int) {
+		defer sm.workerStopped()
+
+		executor.ChunkSingleThreadProcessor(threadId, sm.Slabs, sm.chunksQueue, ctx)
 	})
+
+	sm.workers = append(sm.workers, workers)
+
+	return workers
+}
+
+// the last stopped worker fails chunks left in the queue,
+// queries waiting for them return ErrNoWorkers
+func (sm *Manager) workerStopped() {
+
+	sm.lifecycleLock.Lock()
+	sm.runningWorkers--
+	last := sm.runningWorkers == 0
+	if last {
+		close(sm.workersStopped)
+	}
+	sm.lifecycleLock.Unlock()
+
+	if last {
+		executor.FailQueuedChunks(sm.chunksQueue, ErrNoWorkers)
+	}
 }
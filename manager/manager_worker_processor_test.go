@@ -0,0 +1,199 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dot5enko/simple-column-db/manager/executor"
+	"github.com/dot5enko/simple-column-db/manager/query"
+	"github.com/dot5enko/simple-column-db/schema"
+)
+
+// fails the test when cb doesn't return in time
+func returnsInTime(t *testing.T, what string, cb func()) {
+
+	t.Helper()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cb()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("%s doesn't return", what)
+	}
+}
+
+func TestQueryCancelled(t *testing.T) {
+
+	m := openTestManager(t, t.TempDir(), testSchema("checks"))
+	defer closeTestManager(t, m)
+
+	if ingestErr := m.Ingest("checks", testRows(100000, 1)); ingestErr != nil {
+		t.Fatal(ingestErr)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, queryErr := m.Query("checks", query.Query{Select: []query.Selector{{Arguments: []any{"count"}}}}, ctx)
+	if !errors.Is(queryErr, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", queryErr)
+	}
+}
+
+func TestQueryWithoutWorkers(t *testing.T) {
+
+	m, newErr := New(ManagerConfig{PathToStorage: t.TempDir()})
+	if newErr != nil {
+		t.Fatal(newErr)
+	}
+
+	if createErr := m.CreateSchemaIfNotExists(testSchema("checks")); createErr != nil {
+		t.Fatal(createErr)
+	}
+
+	if ingestErr := m.Ingest("checks", testRows(100000, 1)); ingestErr != nil {
+		t.Fatal(ingestErr)
+	}
+
+	q := query.Query{Select: []query.Selector{{Arguments: []any{"count"}}}}
+
+	_, queryErr := m.Query("checks", q, context.Background())
+	if !errors.Is(queryErr, ErrNoWorkers) {
+		t.Fatalf("expected ErrNoWorkers before workers are started, got %v", queryErr)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	workers := m.StartWorkers(2, ctx)
+
+	if _, queryErr = m.Query("checks", q, context.Background()); queryErr != nil {
+		t.Fatalf("unable to query : %s", queryErr.Error())
+	}
+
+	cancel()
+	returnsInTime(t, "workers", workers.Wait)
+
+	returnsInTime(t, "query", func() {
+		_, queryErr = m.Query("checks", q, context.Background())
+	})
+
+	if !errors.Is(queryErr, ErrNoWorkers) {
+		t.Errorf("expected ErrNoWorkers once workers are stopped, got %v", queryErr)
+	}
+
+	returnsInTime(t, "close", func() { closeTestManager(t, m) })
+}
+
+// chunks queued when workers stop fail the queries they belong to
+func TestQueuedChunksFailWhenWorkersStop(t *testing.T) {
+
+	m, newErr := New(ManagerConfig{PathToStorage: t.TempDir()})
+	if newErr != nil {
+		t.Fatal(newErr)
+	}
+
+	if createErr := m.CreateSchemaIfNotExists(testSchema("checks")); createErr != nil {
+		t.Fatal(createErr)
+	}
+
+	// a few chunks per query
+	if ingestErr := m.Ingest("checks", testRows(1000000, 1)); ingestErr != nil {
+		t.Fatal(ingestErr)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.StartWorkers(1, ctx)
+
+	q := query.Query{Select: []query.Selector{{Arguments: []any{"sum", "x"}}}}
+
+	queries := sync.WaitGroup{}
+	for range 8 {
+		queries.Add(1)
+		go func() {
+			defer queries.Done()
+
+			for {
+				_, queryErr := m.Query("checks", q, context.Background())
+				if errors.Is(queryErr, ErrNoWorkers) {
+					return
+				}
+
+				if queryErr != nil {
+					t.Errorf("expected ErrNoWorkers, got %s", queryErr.Error())
+					return
+				}
+			}
+		}()
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	returnsInTime(t, "queries", queries.Wait)
+	returnsInTime(t, "close", func() { closeTestManager(t, m) })
+}
+
+// failed chunk completes its query with the slab it failed on,
+// workers keep serving other queries
+func TestChunkErrorNamesSlab(t *testing.T) {
+
+	dir := t.TempDir()
+
+	m := openTestManager(t, dir, testSchema("broken"), testSchema("checks"))
+	for _, name := range []string{"broken", "checks"} {
+		if ingestErr := m.Ingest(name, testRows(100000, 1)); ingestErr != nil {
+			t.Fatal(ingestErr)
+		}
+	}
+
+	schemaObject := m.Meta.GetSchema("broken")
+	slabUid := schemaObject.Columns[0].Slabs[0]
+
+	header, headerErr := m.Slabs.LoadSlabHeaderToCache(schemaObject, slabUid)
+	if headerErr != nil {
+		t.Fatal(headerErr)
+	}
+
+	closeTestManager(t, m)
+
+	// data of the first block
+	flipTestSlabByte(t, dir, "broken", slabUid, int64(schema.SlabHeaderFixedSize)+int64(header.BlocksTotal)*schema.TotalHeaderSize+100)
+
+	m = openTestManager(t, dir)
+	defer closeTestManager(t, m)
+
+	q := query.Query{Select: []query.Selector{{Arguments: []any{"sum", "x"}, Alias: "sum"}}}
+
+	// more failed queries than workers
+	for range 4 {
+		var queryErr error
+		returnsInTime(t, "query", func() {
+			_, queryErr = m.Query("broken", q, context.Background())
+		})
+
+		var chunkErr *executor.ChunkError
+		if !errors.As(queryErr, &chunkErr) {
+			t.Fatalf("expected chunk error, got %v", queryErr)
+		}
+
+		if chunkErr.Slab != slabUid {
+			t.Fatalf("expected chunk error on slab %s, got %s", slabUid.String(), chunkErr.Error())
+		}
+
+		var corruption *schema.CorruptionError
+		if !errors.As(queryErr, &corruption) {
+			t.Fatalf("expected chunk error to wrap corruption error, got %s", queryErr.Error())
+		}
+	}
+
+	if count, sum := testCountSum(t, m, "checks"); count != 100000 || sum != 100000 {
+		t.Errorf("expected 100000 rows with sum 100000, got %d rows with sum %f", count, sum)
+	}
+}
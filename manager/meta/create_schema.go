@@ -3,12 +3,32 @@ package meta
 import (
 	"fmt"
 	"os"
+	"slices"
 
 	"github.com/dot5enko/simple-column-db/schema"
 	"github.com/google/uuid"
 )
 
 func (sm *SlabManager) CreateSchema(schemaConfig schema.Schema) error {
+
+	for _, col := range schemaConfig.Columns {
+		if _, typeErr := col.Type.Ops(); typeErr != nil {
+			return fmt.Errorf("column `%s` : %s", col.Name, typeErr.Error())
+		}
+
+		if !col.Codec.SupportsType(col.Type) {
+			return fmt.Errorf("codec %s is not supported for column `%s` of type %s", col.Codec.String(), col.Name, col.Type.String())
+		}
+
+		if precisionErr := col.Precision.Validate(); precisionErr != nil {
+			return fmt.Errorf("column `%s` : %s", col.Name, precisionErr.Error())
+		}
+
+		if col.Precision != schema.PrecisionSeconds && col.Type != schema.TimestampFieldType {
+			return fmt.Errorf("precision can only be set on timestamp columns, `%s` is %s", col.Name, col.Type.String())
+		}
+	}
+
 	storagePath := sm.getAbsStoragePath(schemaConfig.Name)
 
 	_, err := os.Stat(storagePath)
@@ -26,6 +46,10 @@ func (sm *SlabManager) CreateSchema(schemaConfig schema.Schema) error {
 		return fmt.Errorf("unable to create schema folder: `%s`", err.Error())
 	}
 
+	// slab lists are added to columns of the stored schema,
+	// columns passed by caller are left as is
+	schemaConfig.Columns = slices.Clone(schemaConfig.Columns)
+
 	// for each column create slab on disk
 	for colIdx := range schemaConfig.Columns {
 
@@ -0,0 +1,116 @@
+package meta
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+
+	"github.com/dot5enko/simple-column-db/schema"
+	"github.com/google/uuid"
+)
+
+// dictionary of string column slab is kept next to the slab file.
+// values are appended as uvarint length followed by bytes,
+// so codes are positions of values in the file
+func (sm *SlabManager) GetSlabDictionaryPath(s schema.Schema, id uuid.UUID) string {
+	return sm.getAbsStoragePath(s.Name, id.String()+".dict")
+}
+
+func (sm *SlabManager) LoadSlabDictionary(schemaObject *schema.Schema, slabUid uuid.UUID) (*schema.StringDictionary, error) {
+
+	sm.dictionariesLocker.RLock()
+	dict, ok := sm.dictionaries[slabUid]
+	sm.dictionariesLocker.RUnlock()
+
+	if ok {
+		return dict, nil
+	}
+
+	v, err, _ := sm.loadGroup.Do("dict-"+slabUid.String(), func() (any, error) {
+
+		path := sm.GetSlabDictionaryPath(*schemaObject, slabUid)
+
+		contents, readErr := os.ReadFile(path)
+		if readErr != nil && !os.IsNotExist(readErr) {
+			return nil, fmt.Errorf("unable to read slab dictionary : %s", readErr.Error())
+		}
+
+		values := []string{}
+		pos := 0
+
+		for pos < len(contents) {
+			size, n := binary.Uvarint(contents[pos:])
+			if n <= 0 || pos+n+int(size) > len(contents) {
+				break
+			}
+
+			values = append(values, string(contents[pos+n:pos+n+int(size)]))
+			pos += n + int(size)
+		}
+
+		// tail of interrupted append, no block refers to it
+		if pos < len(contents) {
+			truncateErr := os.Truncate(path, int64(pos))
+			if truncateErr != nil {
+				return nil, fmt.Errorf("unable to truncate broken slab dictionary tail : %s", truncateErr.Error())
+			}
+		}
+
+		dict := schema.NewStringDictionary(values)
+
+		sm.dictionariesLocker.Lock()
+		sm.dictionaries[slabUid] = dict
+		sm.dictionariesLocker.Unlock()
+
+		sm.trackCachedDictionary(slabUid, pos)
+
+		return dict, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return v.(*schema.StringDictionary), nil
+}
+
+// translates strings into codes of slab dictionary,
+// new values are persisted before codes get into blocks
+func (sm *SlabManager) EncodeStrings(schemaObject *schema.Schema, slabUid uuid.UUID, values []string, out []uint32) error {
+
+	dict, dictErr := sm.LoadSlabDictionary(schemaObject, slabUid)
+	if dictErr != nil {
+		return dictErr
+	}
+
+	added := dict.Encode(values, out)
+	if len(added) == 0 {
+		return nil
+	}
+
+	encoded := []byte{}
+	for _, value := range added {
+		encoded = binary.AppendUvarint(encoded, uint64(len(value)))
+		encoded = append(encoded, value...)
+	}
+
+	path := sm.GetSlabDictionaryPath(*schemaObject, slabUid)
+
+	f, openErr := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if openErr != nil {
+		dict.Rollback(added)
+		return fmt.Errorf("unable to open slab dictionary : %s", openErr.Error())
+	}
+
+	defer f.Close()
+
+	_, writeErr := f.Write(encoded)
+	if writeErr != nil {
+		dict.Rollback(added)
+		return fmt.Errorf("unable to append to slab dictionary : %s", writeErr.Error())
+	}
+
+	sm.trackCachedDictionary(slabUid, len(encoded))
+
+	return nil
+}
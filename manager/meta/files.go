@@ -1,6 +1,7 @@
 package meta
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
@@ -51,3 +52,33 @@ func (sm *SlabManager) GetSlabFile(s schema.Schema, id uuid.UUID, writeAccess bo
 
 	return fileManager, openErr
 }
+
+// flushes slab file and files kept next to it to disk
+func (sm *SlabManager) SyncSlabFiles(s schema.Schema, id uuid.UUID) error {
+
+	paths := []string{
+		sm.GetSlabPath(s, id),
+		sm.GetSlabValidityPath(s, id),
+		sm.GetSlabDictionaryPath(s, id),
+	}
+
+	for _, path := range paths {
+
+		f, openErr := os.OpenFile(path, os.O_RDWR, 0644)
+		if openErr != nil {
+			if os.IsNotExist(openErr) {
+				continue
+			}
+			return fmt.Errorf("unable to open %s for sync : %s", path, openErr.Error())
+		}
+
+		syncErr := f.Sync()
+		f.Close()
+
+		if syncErr != nil {
+			return fmt.Errorf("unable to sync %s : %s", path, syncErr.Error())
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,522 @@
+package meta
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/dot5enko/simple-column-db/compression"
+	"github.com/dot5enko/simple-column-db/schema"
+	"github.com/google/uuid"
+)
+
+// problem found by storage check
+type FsckIssue struct {
+	Schema string
+	Column string
+	Slab   uuid.UUID
+
+	Problem string
+
+	// metadata was rewritten to fix the problem
+	Repaired bool
+}
+
+func (i FsckIssue) String() string {
+
+	location := i.Schema
+	if i.Column != "" {
+		location += "." + i.Column
+	}
+	if i.Slab != uuid.Nil {
+		location += " slab " + i.Slab.String()
+	}
+
+	status := ""
+	if i.Repaired {
+		status = " [repaired]"
+	}
+
+	return fmt.Sprintf("%s : %s%s", location, i.Problem, status)
+}
+
+type FsckReport struct {
+	Schemas int
+	Slabs   int
+	Issues  []FsckIssue
+}
+
+func (r *FsckReport) Print(w io.Writer) {
+
+	for _, issue := range r.Issues {
+		fmt.Fprintln(w, issue.String())
+	}
+
+	repaired := 0
+	for _, issue := range r.Issues {
+		if issue.Repaired {
+			repaired++
+		}
+	}
+
+	fmt.Fprintf(w, "checked %d schemas, %d slabs : %d issues, %d repaired\n", r.Schemas, r.Slabs, len(r.Issues), repaired)
+}
+
+// slab file as found in schema folder
+type fsckSlab struct {
+	uid    uuid.UUID
+	header *schema.DiskSlabHeader
+	rows   uint64
+
+	// header is unreadable or corrupted
+	broken bool
+}
+
+// offline check of storage directory, no manager should use it meanwhile.
+// with repair, Slabs and ActiveSlab of columns that don't match slab files
+// are rebuilt from slab headers and schema is stored back to disk
+func CheckStorage(storagePath string, repair bool) (*FsckReport, error) {
+
+	report := &FsckReport{}
+
+	metaManager := NewMetaManager(storagePath)
+	loadErr := metaManager.LoadSchemesFromDisk()
+	if loadErr != nil {
+		return nil, fmt.Errorf("unable to load schemes : %s", loadErr.Error())
+	}
+
+	entries, readErr := os.ReadDir(storagePath)
+	if readErr != nil {
+		return nil, fmt.Errorf("unable to read storage directory : %s", readErr.Error())
+	}
+
+	for _, entry := range entries {
+
+		if !entry.IsDir() {
+			continue
+		}
+
+		report.Schemas++
+
+		schemaObject := metaManager.GetSchema(entry.Name())
+		if schemaObject == nil {
+			report.Issues = append(report.Issues, FsckIssue{Schema: entry.Name(), Problem: "schema.json is missing or unreadable"})
+			continue
+		}
+
+		checkErr := checkSchema(metaManager, schemaObject, report, repair)
+		if checkErr != nil {
+			return report, fmt.Errorf("unable to check schema `%s` : %s", schemaObject.Name, checkErr.Error())
+		}
+	}
+
+	return report, nil
+}
+
+func checkSchema(metaManager *MetaManager, schemaObject *schema.Schema, report *FsckReport, repair bool) error {
+
+	schemaPath := metaManager.getAbsStoragePath(schemaObject.Name)
+
+	entries, readErr := os.ReadDir(schemaPath)
+	if readErr != nil {
+		return fmt.Errorf("unable to read schema directory : %s", readErr.Error())
+	}
+
+	slabs := map[uuid.UUID]*fsckSlab{}
+
+	// validity and dictionary files kept next to slab files, by name
+	sideFiles := map[string]uuid.UUID{}
+	sideNames := []string{}
+
+	for _, entry := range entries {
+
+		name := entry.Name()
+
+		if ext := filepath.Ext(name); ext == ".valid" || ext == ".dict" {
+			uid, parseErr := uuid.Parse(strings.TrimSuffix(name, ext))
+			if parseErr != nil {
+				report.Issues = append(report.Issues, FsckIssue{Schema: schemaObject.Name, Problem: fmt.Sprintf("unexpected file %s", name)})
+				continue
+			}
+
+			sideFiles[name] = uid
+			sideNames = append(sideNames, name)
+			continue
+		}
+
+		if name == "ingest.wal" {
+			if info, infoErr := entry.Info(); infoErr == nil && info.Size() > 0 {
+				report.Issues = append(report.Issues, FsckIssue{Schema: schemaObject.Name, Problem: fmt.Sprintf("ingest log holds %d bytes of batches that will be replayed on start, row counts may differ until then", info.Size())})
+			}
+			continue
+		}
+
+		if !strings.HasSuffix(name, ".slab") {
+			continue
+		}
+
+		uid, parseErr := uuid.Parse(strings.TrimSuffix(name, ".slab"))
+		if parseErr != nil {
+			report.Issues = append(report.Issues, FsckIssue{Schema: schemaObject.Name, Problem: fmt.Sprintf("unexpected file %s", name)})
+			continue
+		}
+
+		report.Slabs++
+
+		slab := &fsckSlab{uid: uid}
+		slabs[uid] = slab
+
+		header, problems := checkSlabFile(filepath.Join(schemaPath, name), uid)
+		for _, problem := range problems {
+			report.Issues = append(report.Issues, FsckIssue{Schema: schemaObject.Name, Slab: uid, Problem: problem})
+		}
+
+		if header == nil {
+			slab.broken = true
+			continue
+		}
+
+		slab.header = header
+		slab.rows = uint64(header.BlocksFinalized) * uint64(header.SingleBlockRowsSize)
+		if header.BlocksFinalized < header.BlocksTotal {
+			slab.rows += uint64(header.BlockHeaders[header.BlocksFinalized].Items)
+		}
+	}
+
+	referenced := map[uuid.UUID]bool{}
+	schemaChanged := false
+	columnRows := map[string]uint64{}
+
+	for colIdx := range schemaObject.Columns {
+
+		col := &schemaObject.Columns[colIdx]
+		issue := func(slab uuid.UUID, format string, args ...any) {
+			report.Issues = append(report.Issues, FsckIssue{Schema: schemaObject.Name, Column: col.Name, Slab: slab, Problem: fmt.Sprintf(format, args...)})
+		}
+
+		// slabs of the column, ordered by offset
+		owned := []*fsckSlab{}
+		for _, slab := range slabs {
+			if !slab.broken && int(slab.header.SchemaFieldId) == colIdx+1 {
+				owned = append(owned, slab)
+			}
+		}
+
+		slices.SortFunc(owned, func(a, b *fsckSlab) int {
+			return cmp.Compare(a.header.SlabOffsetBlocks, b.header.SlabOffsetBlocks)
+		})
+
+		firstIssue := len(report.Issues)
+
+		var nextOffset uint64
+		var rows uint64
+
+		// offset of the slab following a broken one is unknown
+		offsetKnown := true
+
+		for idx, uid := range col.Slabs {
+
+			referenced[uid] = true
+
+			if slices.Index(col.Slabs, uid) != idx {
+				issue(uid, "slab is listed more than once")
+				continue
+			}
+
+			slab, ok := slabs[uid]
+			if !ok {
+				issue(uid, "slab file is missing")
+				continue
+			}
+
+			if slab.broken {
+				offsetKnown = false
+				continue
+			}
+
+			header := slab.header
+
+			if int(header.SchemaFieldId) != colIdx+1 {
+				issue(uid, "slab belongs to column #%d", header.SchemaFieldId)
+			}
+
+			if header.Type != col.Type {
+				issue(uid, "slab type is %s, column type is %s", header.Type.String(), col.Type.String())
+			}
+
+			if offsetKnown && header.SlabOffsetBlocks != nextOffset {
+				issue(uid, "slab starts at block %d, expected %d", header.SlabOffsetBlocks, nextOffset)
+			}
+
+			if idx < len(col.Slabs)-1 && header.BlocksFinalized != header.BlocksTotal {
+				issue(uid, "slab is not the last one, but only %d of %d blocks are finalized", header.BlocksFinalized, header.BlocksTotal)
+			}
+
+			nextOffset = header.SlabOffsetBlocks + uint64(header.BlocksTotal)
+			offsetKnown = true
+			rows = header.SlabOffsetBlocks*uint64(header.SingleBlockRowsSize) + slab.rows
+		}
+
+		if len(col.Slabs) == 0 {
+			issue(uuid.Nil, "column has no slabs")
+		} else if col.ActiveSlab != col.Slabs[len(col.Slabs)-1] {
+			issue(col.ActiveSlab, "active slab is not the last slab of column")
+		}
+
+		orphans := map[int]uuid.UUID{}
+		for _, slab := range owned {
+			if !referenced[slab.uid] {
+				orphans[len(report.Issues)] = slab.uid
+				issue(slab.uid, "orphan slab of column at block %d, %d rows", slab.header.SlabOffsetBlocks, slab.rows)
+			}
+		}
+
+		if repair && len(report.Issues) > firstIssue {
+
+			rebuilt := rebuildColumnSlabs(col, owned)
+			if rebuilt != nil && (!slices.Equal(rebuilt, col.Slabs) || col.ActiveSlab != rebuilt[len(rebuilt)-1]) {
+
+				col.Slabs = rebuilt
+				col.ActiveSlab = rebuilt[len(rebuilt)-1]
+				schemaChanged = true
+
+				last := slabs[col.ActiveSlab]
+				rows = last.header.SlabOffsetBlocks*uint64(last.header.SingleBlockRowsSize) + last.rows
+
+				// orphans that are not part of rebuilt chain stay on disk
+				for idx := firstIssue; idx < len(report.Issues); idx++ {
+					orphan, isOrphan := orphans[idx]
+					report.Issues[idx].Repaired = !isOrphan || slices.Contains(rebuilt, orphan)
+				}
+			}
+		}
+
+		columnRows[col.Name] = rows
+	}
+
+	for uid, slab := range slabs {
+		if !referenced[uid] && (slab.broken || int(slab.header.SchemaFieldId) > len(schemaObject.Columns) || slab.header.SchemaFieldId == 0) {
+			report.Issues = append(report.Issues, FsckIssue{Schema: schemaObject.Name, Slab: uid, Problem: "orphan slab doesn't belong to any column"})
+		}
+	}
+
+	// repair may list orphan slabs again, their files are used with them
+	listed := map[uuid.UUID]bool{}
+	for _, col := range schemaObject.Columns {
+		for _, uid := range col.Slabs {
+			listed[uid] = true
+		}
+	}
+
+	for _, name := range sideNames {
+
+		uid := sideFiles[name]
+
+		if _, hasSlab := slabs[uid]; !hasSlab {
+			report.Issues = append(report.Issues, FsckIssue{Schema: schemaObject.Name, Slab: uid, Problem: fmt.Sprintf("orphan file %s, slab file is missing", name)})
+		} else if !referenced[uid] {
+			report.Issues = append(report.Issues, FsckIssue{Schema: schemaObject.Name, Slab: uid, Problem: fmt.Sprintf("orphan file %s of slab not listed by any column", name), Repaired: listed[uid]})
+		}
+	}
+
+	if len(schemaObject.Columns) > 0 {
+		expected := columnRows[schemaObject.Columns[0].Name]
+		for _, col := range schemaObject.Columns[1:] {
+			if columnRows[col.Name] != expected {
+				report.Issues = append(report.Issues, FsckIssue{Schema: schemaObject.Name, Problem: fmt.Sprintf("columns have different row counts : `%s` has %d rows, `%s` has %d", schemaObject.Columns[0].Name, expected, col.Name, columnRows[col.Name])})
+				break
+			}
+		}
+	}
+
+	if schemaChanged {
+		storeErr := metaManager.StoreSchemeToDisk(*schemaObject)
+		if storeErr != nil {
+			return fmt.Errorf("unable to store repaired schema : %s", storeErr.Error())
+		}
+	}
+
+	return nil
+}
+
+// contiguous chain of column slabs starting at block 0,
+// slabs already listed by column are preferred over orphans at the same offset.
+// nil if there is no such chain
+func rebuildColumnSlabs(col *schema.SchemaColumn, owned []*fsckSlab) []uuid.UUID {
+
+	result := []uuid.UUID{}
+	var nextOffset uint64
+
+	for {
+
+		var selected *fsckSlab
+
+		for _, slab := range owned {
+
+			if slab.header.SlabOffsetBlocks != nextOffset || slab.header.Type != col.Type {
+				continue
+			}
+
+			if selected == nil {
+				selected = slab
+			} else if slices.Contains(col.Slabs, slab.uid) && !slices.Contains(col.Slabs, selected.uid) {
+				selected = slab
+			} else if slices.Contains(col.Slabs, slab.uid) == slices.Contains(col.Slabs, selected.uid) && slab.rows > selected.rows {
+				selected = slab
+			}
+		}
+
+		if selected == nil {
+			break
+		}
+
+		result = append(result, selected.uid)
+
+		if selected.header.BlocksFinalized < selected.header.BlocksTotal {
+			break
+		}
+
+		nextOffset = selected.header.SlabOffsetBlocks + uint64(selected.header.BlocksTotal)
+	}
+
+	if len(result) == 0 {
+		return nil
+	}
+
+	return result
+}
+
+// reads slab header, block headers and block data of the slab file,
+// header is nil if slab can't be used
+func checkSlabFile(path string, uid uuid.UUID) (*schema.DiskSlabHeader, []string) {
+
+	contents, readErr := os.ReadFile(path)
+	if readErr != nil {
+		return nil, []string{fmt.Sprintf("unable to read slab file : %s", readErr.Error())}
+	}
+
+	if len(contents) < schema.SlabHeaderFixedSize {
+		return nil, []string{fmt.Sprintf("slab file is %d bytes, shorter than slab header", len(contents))}
+	}
+
+	header := &schema.DiskSlabHeader{}
+
+	parseErr := header.FromBytes(bytes.NewReader(contents))
+	if parseErr == nil {
+		parseErr = header.Verify(uid)
+	}
+
+	if parseErr != nil {
+		return nil, []string{parseErr.Error()}
+	}
+
+	if header.Uid != uid {
+		return nil, []string{fmt.Sprintf("slab header has uid %s", header.Uid.String())}
+	}
+
+	if header.BlocksFinalized > header.BlocksTotal {
+		return nil, []string{fmt.Sprintf("%d blocks finalized out of %d", header.BlocksFinalized, header.BlocksTotal)}
+	}
+
+	if _, typeErr := header.Type.Ops(); typeErr != nil {
+		return nil, []string{typeErr.Error()}
+	}
+
+	headersEnd := schema.SlabHeaderFixedSize + int(header.BlocksTotal)*schema.TotalHeaderSize
+	dataEnd := headersEnd + int(header.CompressedSlabContentSize)
+
+	if len(contents) < dataEnd {
+		return nil, []string{fmt.Sprintf("slab file is %d bytes, header expects at least %d", len(contents), dataEnd)}
+	}
+
+	problems := []string{}
+
+	header.BlockHeaders = make([]schema.DiskHeader, header.BlocksTotal)
+
+	blocksToCheck := min(int(header.BlocksFinalized)+1, int(header.BlocksTotal))
+	for blockIdx := range blocksToCheck {
+
+		blockHeader := &header.BlockHeaders[blockIdx]
+		headerStart := schema.SlabHeaderFixedSize + blockIdx*schema.TotalHeaderSize
+
+		blockErr := blockHeader.FromBytes(bytes.NewReader(contents[headerStart : headerStart+schema.TotalHeaderSize]))
+
+		// header of the block following finalized one is written with its first rows
+		if blockErr == nil && blockIdx == int(header.BlocksFinalized) && blockHeader.Uid == uuid.Nil && blockHeader.HeaderChecksum == 0 {
+			*blockHeader = schema.NewBlockHeader(header.Type)
+			continue
+		}
+
+		if blockErr == nil {
+			blockErr = blockHeader.Verify(uid)
+		}
+
+		if blockErr != nil {
+			return nil, []string{fmt.Sprintf("block #%d : %s", blockIdx, blockErr.Error())}
+		}
+
+		if blockHeader.DataType != header.Type {
+			problems = append(problems, fmt.Sprintf("block #%d has type %s", blockIdx, blockHeader.DataType.String()))
+		}
+
+		if blockIdx < int(header.BlocksFinalized) && blockHeader.Items != header.SingleBlockRowsSize {
+			problems = append(problems, fmt.Sprintf("finalized block #%d has %d rows, expected %d", blockIdx, blockHeader.Items, header.SingleBlockRowsSize))
+		}
+
+		if blockHeader.Items > header.SingleBlockRowsSize {
+			problems = append(problems, fmt.Sprintf("block #%d has %d rows, more than %d", blockIdx, blockHeader.Items, header.SingleBlockRowsSize))
+		}
+
+		if blockHeader.NullCount > blockHeader.Items {
+			problems = append(problems, fmt.Sprintf("block #%d has %d nulls out of %d rows", blockIdx, blockHeader.NullCount, blockHeader.Items))
+		}
+	}
+
+	if len(problems) > 0 {
+		return header, problems
+	}
+
+	data := contents[headersEnd:dataEnd]
+
+	if header.CompressionType == schema.SlabCompressionLz4 {
+
+		uncompressed := make([]byte, int(header.BlocksTotal)*header.Type.BlockSize())
+
+		decompressedSize, decompressErr := compression.DecompressLz4(data, uncompressed)
+		if decompressErr == nil && decompressedSize != len(uncompressed) {
+			decompressErr = fmt.Errorf("decompressed to %d bytes, expected %d", decompressedSize, len(uncompressed))
+		}
+
+		if decompressErr != nil {
+			return header, []string{fmt.Sprintf("unable to decompress slab data : %s", decompressErr.Error())}
+		}
+
+		data = uncompressed
+
+	} else if header.CompressionType != schema.SlabCompressionNone {
+		return header, []string{fmt.Sprintf("unsupported compression type %d", header.CompressionType)}
+	}
+
+	blockSize := header.Type.BlockSize()
+
+	for blockIdx := range blocksToCheck {
+
+		blockStart := blockIdx * blockSize
+		if blockStart > len(data) {
+			problems = append(problems, fmt.Sprintf("block #%d is out of slab data", blockIdx))
+			break
+		}
+
+		verifyErr := header.BlockHeaders[blockIdx].VerifyData(uid, data[blockStart:])
+		if verifyErr != nil {
+			problems = append(problems, fmt.Sprintf("block #%d : %s", blockIdx, verifyErr.Error()))
+		}
+	}
+
+	return header, problems
+}
@@ -12,16 +12,22 @@ type IngestStats struct {
 	Written       int
 	BlockFinished bool
 
+	// slab header has to be written by the caller,
+	// it's done once per batch instead of every block
+	SlabHeaderChanged bool
+
 	IoTime  time.Duration
 	IoCalls int
 }
 
+// writes as many values as fit into the block, slab header is updated in memory only
 func (m *SlabManager) IngestIntoBlock(
 	schemaObject schema.Schema,
 	slab *schema.DiskSlabHeader,
 	block uuid.UUID,
 	columnDataArray any,
 	dataArrayStartOffset int,
+	nulls []bool,
 ) (IngestStats, error) {
 
 	stats := IngestStats{}
@@ -33,13 +39,21 @@ func (m *SlabManager) IngestIntoBlock(
 	if err != nil {
 		return stats, fmt.Errorf("unable to load block into runtime: %s", err.Error())
 	} else {
-		written, writeErr, bounds := data.Write(columnDataArray, dataArrayStartOffset, slab.Type)
+
+		// block header lives in the slab header, queries copy both under read lock
+		slab.Lock()
+
+		written, writeErr, _ := data.Write(columnDataArray, dataArrayStartOffset, slab.Type, nulls)
 		if writeErr != nil {
+			slab.Unlock()
+
 			stats.Written = written
 			return stats, writeErr
 		} else {
 
-			slabHeaderChanged := slab.Bounds.Morph(bounds)
+			// block bounds are morphed as a whole, so a slab header lost in a crash
+			// gets bounds of rows written before it
+			slabHeaderChanged := slab.Bounds.Morph(data.Header.Bounds)
 
 			// move to write function above
 
@@ -48,41 +62,39 @@ func (m *SlabManager) IngestIntoBlock(
 			if data.Items == data.Cap {
 				// finalize block
 
+				if slab.BlocksFinalized+1 < slab.BlocksTotal {
+					slab.BlockHeaders[slab.BlocksFinalized+1] = schema.NewBlockHeader(slab.Type)
+				}
+
 				slab.BlocksFinalized += 1
-				// write updated slab header content to disk
 
 				slabHeaderChanged = true
 				blockFinished = true
 			}
 
-			stats.BlockFinished = blockFinished
-
-			if slabHeaderChanged {
+			slab.Unlock()
 
-				ioStart := time.Now()
-				updateSlabHeaderErr := m.UpdateSlabHeaderOnDisk(schemaObject, slab)
-				ioTook := time.Since(ioStart)
-
-				stats.IoTime += ioTook
+			stats.BlockFinished = blockFinished
+			stats.Written = written
 
-				if updateSlabHeaderErr != nil {
+			// write block validity, header and data to disk,
+			// slab header goes last so it never counts blocks that aren't written
+			ioStart := time.Now()
+			diskBlockUpdateErr := m.UpdateBlockValidityOnDisk(schemaObject, slab, data)
+			if diskBlockUpdateErr == nil {
+				diskBlockUpdateErr = m.UpdateBlockHeaderAndDataOnDisk(schemaObject, slab, data, data.Items-written)
+			}
 
-					stats.Written = written
+			stats.IoTime += time.Since(ioStart)
+			stats.IoCalls = 2
 
-					return stats, fmt.Errorf("unable to update slab info: %s", updateSlabHeaderErr.Error())
-				}
+			if diskBlockUpdateErr != nil {
+				return stats, diskBlockUpdateErr
 			}
 
-			// write block header and data to disk
-			ioStart := time.Now()
-			diskBlockUpdateErr := m.UpdateBlockHeaderAndDataOnDisk(schemaObject, slab, data)
-			ioTook := time.Since(ioStart)
-
-			stats.Written = written
-			stats.IoTime += ioTook
-			stats.IoCalls = 2
+			stats.SlabHeaderChanged = slabHeaderChanged
 
-			return stats, diskBlockUpdateErr
+			return stats, nil
 		}
 
 	}
@@ -3,9 +3,7 @@ package meta
 import (
 	"bytes"
 	"fmt"
-	"time"
 
-	"github.com/davecgh/go-spew/spew"
 	"github.com/dot5enko/simple-column-db/compression"
 	"github.com/dot5enko/simple-column-db/manager/cache"
 	"github.com/dot5enko/simple-column-db/schema"
@@ -52,11 +50,14 @@ func (m *SlabManager) LoadSlabHeaderToCache(schemaObject *schema.Schema, slabUid
 
 					// ioTime := time.Since(readStart).Seconds()
 
-					var headerCacheEntryId uint16
-					result, headerCacheEntryId = m.slabHeaderCache.Get()
+					result = &schema.DiskSlabHeader{}
 
 					headerBytes := bytes.NewReader(headerReadBuffer)
 					headerParseErr := result.FromBytes(headerBytes)
+					if headerParseErr == nil {
+						headerParseErr = result.Verify(slabUid)
+					}
+
 					if headerParseErr != nil {
 						return nil, headerParseErr
 					} else {
@@ -88,24 +89,39 @@ func (m *SlabManager) LoadSlabHeaderToCache(schemaObject *schema.Schema, slabUid
 								blockOffset := i * int(schema.TotalHeaderSize)
 								headerBuffer := slabReadCache[blockOffset:]
 
-								headerDecodeErr := result.BlockHeaders[i].FromBytes(bytes.NewReader(headerBuffer))
+								blockHeader := &result.BlockHeaders[i]
+								headerDecodeErr := blockHeader.FromBytes(bytes.NewReader(headerBuffer))
 
 								if headerDecodeErr != nil {
 									return nil, headerDecodeErr
 								}
+
+								// header of the block following finalized one is written with its first rows,
+								// until then reserved space holds zeroes
+								if i == int(result.BlocksFinalized) && blockHeader.Uid == uuid.Nil && blockHeader.HeaderChecksum == 0 {
+									*blockHeader = schema.NewBlockHeader(result.Type)
+									continue
+								}
+
+								headerVerifyErr := blockHeader.Verify(slabUid)
+								if headerVerifyErr != nil {
+									return nil, headerVerifyErr
+								}
 							}
 						}
 
 					}
 
+					headerItem := &cache.SlabCacheItem{
+						Header:  result,
+						RtStats: m.slabCacheStats(slabUid),
+					}
+
 					m.slabHeaderCacheLocker.Lock()
-					defer m.slabHeaderCacheLocker.Unlock()
+					m.slabHeaderCacheItem[slabUid] = headerItem
+					m.slabHeaderCacheLocker.Unlock()
 
-					m.slabHeaderCacheItem[slabUid] = &cache.SlabCacheItem{
-						CacheEntryId: headerCacheEntryId,
-						Header:       result,
-						RtStats:      &cache.CacheStats{Created: time.Now()},
-					}
+					m.trackCachedHeader(slabUid, result)
 
 					return result, nil
 
@@ -138,6 +154,22 @@ func (m *SlabManager) LoadSlabDataContents(schemaObject *schema.Schema, uid uuid
 		return nil, headerLoadErr
 	}
 
+	result.RLock()
+	defer result.RUnlock()
+
+	return m.loadSlabDataContents(schemaObject, result)
+}
+
+// caller holds read lock of the header
+func (m *SlabManager) loadSlabDataContents(schemaObject *schema.Schema, result *schema.DiskSlabHeader) (*cache.SlabDataCacheItem, error) {
+
+	uid := result.Uid
+
+	slabData := m.getSlabDataFromCache(uid)
+	if slabData != nil {
+		return slabData, nil
+	}
+
 	// fix key construction, do not use allocations
 	key := "d-" + uid.String()
 
@@ -153,46 +185,51 @@ func (m *SlabManager) LoadSlabDataContents(schemaObject *schema.Schema, uid uuid
 		}
 
 		defer fileReader.Close()
-		item, slabId := m.slabRuntimeCache.Get()
 
-		// todo improve this part
-		// should be done on .Get inside RingBuffer
-		item.Reset()
-		item.RtStats.CacheEntryId = slabId
+		// todo reuse data of evicted slabs
+		item := &cache.SlabDataCacheItem{RtStats: m.slabCacheStats(uid)}
+
+		switch result.CompressionType {
+		case schema.SlabCompressionNone:
 
-		// at this point we need to lock slab's data for reading
-		// as it may be compressed
-		readCompressedDataErr := fileReader.ReadAt(item.Data[:], dataOffset, int(result.CompressedSlabContentSize))
+			readDataErr := fileReader.ReadAt(item.Data[:], dataOffset, int(result.CompressedSlabContentSize))
+			if readDataErr != nil {
+				return nil, readDataErr
+			}
 
-		if readCompressedDataErr != nil {
-			return nil, readCompressedDataErr
-		} else {
+		case schema.SlabCompressionLz4:
 
-			if result.CompressionType != 0 {
+			// lz4 can't decompress in place
+			compressedBuffer, compressedBufferIdx := m.fullSlabBufferRing.Get()
+			defer m.fullSlabBufferRing.Return(compressedBufferIdx)
 
-				panic("compression not implemented while LoadSlabDataContents")
+			readCompressedDataErr := fileReader.ReadAt(compressedBuffer, dataOffset, int(result.CompressedSlabContentSize))
+			if readCompressedDataErr != nil {
+				return nil, readCompressedDataErr
+			}
 
-				switch result.CompressionType {
-				case 1:
-					_, decompressErr := compression.DecompressLz4(item.Data[:result.CompressedSlabContentSize], item.Data[:])
-					if decompressErr != nil {
+			uncompressedSize := int(result.BlocksTotal) * result.Type.BlockSize()
 
-						spew.Dump("input buffers to decompress ", item.Data[:256])
+			decompressedSize, decompressErr := compression.DecompressLz4(compressedBuffer[:result.CompressedSlabContentSize], item.Data[:])
+			if decompressErr != nil {
+				return nil, fmt.Errorf("unable to decompress slab data [input length %d, output buffer: %d]: %s", result.CompressedSlabContentSize, len(item.Data[:]), decompressErr.Error())
+			}
 
-						return nil, fmt.Errorf("unable to decompress slab data [input length %d, outputd buffer: %d]: %s", result.CompressedSlabContentSize, len(item.Data[:]), decompressErr.Error())
-					}
-				default:
-					return nil, fmt.Errorf("unsupported compression type: %d", result.CompressionType)
-				}
+			if decompressedSize != uncompressedSize {
+				return nil, fmt.Errorf("slab data decompressed to %d bytes, expected %d", decompressedSize, uncompressedSize)
 			}
 
-			m.slabDataCacheLocker.Lock()
-			defer m.slabDataCacheLocker.Unlock()
+		default:
+			return nil, fmt.Errorf("unsupported compression type: %d", result.CompressionType)
+		}
 
-			m.slabDataCache[uid] = item
+		m.slabDataCacheLocker.Lock()
+		m.slabDataCache[uid] = item
+		m.slabDataCacheLocker.Unlock()
 
-			return item, nil
-		}
+		m.trackCachedData(uid, item)
+
+		return item, nil
 	})
 
 	if err != nil {
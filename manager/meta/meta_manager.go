@@ -1,26 +1,41 @@
 package meta
 
 import (
-	"bufio"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"sync"
+	"sync/atomic"
 
-	"github.com/dot5enko/simple-column-db/io"
 	"github.com/dot5enko/simple-column-db/schema"
+	"github.com/google/uuid"
 )
 
 type MetaManager struct {
 	schemas map[string]*schema.Schema
+	states  map[string]*schemaState
 	lock    sync.RWMutex
 
 	storagePath string
 }
 
+// ingests into a schema are serialized, queries run along with them.
+// slab lists of columns are changed only under columns lock,
+// readers take a snapshot of the schema instead of reading it directly
+type schemaState struct {
+	writer  sync.Mutex
+	columns sync.RWMutex
+
+	// rows of every column written and synced by finished ingests,
+	// queries don't see rows past it
+	committedRows atomic.Uint64
+}
+
 func (sm *MetaManager) getAbsStoragePath(segments ...string) string {
 
 	pathSegments := []string{sm.storagePath}
@@ -32,6 +47,7 @@ func (sm *MetaManager) getAbsStoragePath(segments ...string) string {
 func NewMetaManager(storagePath string) *MetaManager {
 	return &MetaManager{
 		schemas: map[string]*schema.Schema{},
+		states:  map[string]*schemaState{},
 		lock:    sync.RWMutex{},
 
 		storagePath: storagePath,
@@ -44,34 +60,129 @@ func (qp *MetaManager) AddSchema(schemaObject *schema.Schema) {
 	defer qp.lock.Unlock()
 
 	qp.schemas[schemaObject.Name] = schemaObject
+
+	if _, ok := qp.states[schemaObject.Name]; !ok {
+		qp.states[schemaObject.Name] = &schemaState{}
+	}
 }
 
-func (qp *MetaManager) GetSchema(name string) *schema.Schema {
+func (qp *MetaManager) stateOf(name string) *schemaState {
 	qp.lock.RLock()
 	defer qp.lock.RUnlock()
 
-	return qp.schemas[name]
+	return qp.states[name]
 }
 
-func (m *MetaManager) StoreSchemeToDisk(schemeObject schema.Schema) error {
-	schemesPath := m.getAbsStoragePath(schemeObject.Name, "schema.json")
+// blocks until other ingests into the schema are done,
+// returned func releases the lock
+func (qp *MetaManager) LockSchemaWriter(name string) (func(), error) {
+
+	state := qp.stateOf(name)
+	if state == nil {
+		return nil, fmt.Errorf("schema `%s` not found", name)
+	}
+
+	state.writer.Lock()
+
+	return state.writer.Unlock, nil
+}
+
+// rows of the schema visible to queries
+func (qp *MetaManager) CommittedRows(name string) uint64 {
+
+	state := qp.stateOf(name)
+	if state == nil {
+		return 0
+	}
+
+	return state.committedRows.Load()
+}
+
+// makes rows written by the schema writer visible to queries.
+// must be called once every column has the rows and slab lists are updated
+func (qp *MetaManager) CommitRows(name string, rows uint64) {
+
+	state := qp.stateOf(name)
+	if state == nil {
+		return
+	}
+
+	state.committedRows.Store(rows)
+}
 
-	fr := io.NewFileReader(schemesPath)
-	createFileErr := fr.Open(false)
+// copy of the schema with its own slab lists, safe to read while ingests add slabs
+func (qp *MetaManager) SchemaSnapshot(name string) *schema.Schema {
 
-	if createFileErr != nil {
-		return createFileErr
+	schemaObject := qp.GetSchema(name)
+	if schemaObject == nil {
+		return nil
+	}
+
+	state := qp.stateOf(name)
+
+	state.columns.RLock()
+	defer state.columns.RUnlock()
+
+	snapshot := *schemaObject
+	snapshot.Columns = slices.Clone(schemaObject.Columns)
+
+	for idx := range snapshot.Columns {
+		snapshot.Columns[idx].Slabs = slices.Clone(snapshot.Columns[idx].Slabs)
 	}
 
-	defer fr.Close()
+	return &snapshot
+}
+
+// makes slab the active one of the column and stores schema to disk.
+// must be called by the schema writer
+func (qp *MetaManager) AddColumnSlab(schemaObject *schema.Schema, columnIdx int, slab uuid.UUID) error {
+
+	state := qp.stateOf(schemaObject.Name)
+
+	state.columns.Lock()
+	defer state.columns.Unlock()
+
+	col := &schemaObject.Columns[columnIdx]
+
+	if col.Slabs == nil {
+		col.Slabs = []uuid.UUID{}
+	}
+
+	col.Slabs = append(col.Slabs, slab)
+	col.ActiveSlab = slab
+
+	storeErr := qp.StoreSchemeToDisk(*schemaObject)
+	if storeErr != nil {
+		return fmt.Errorf("unable to update schema config on disk: %s", storeErr.Error())
+	}
+
+	return nil
+}
+
+func (qp *MetaManager) GetSchema(name string) *schema.Schema {
+	qp.lock.RLock()
+	defer qp.lock.RUnlock()
+
+	return qp.schemas[name]
+}
+
+// schema is written aside and renamed over the old one,
+// so a crash leaves either the old or the new schema
+func (m *MetaManager) StoreSchemeToDisk(schemeObject schema.Schema) error {
+	schemesPath := m.getAbsStoragePath(schemeObject.Name, "schema.json")
+	tempPath := schemesPath + ".tmp"
 
 	jschemeBytes, _ := json.Marshal(schemeObject)
 
-	linesWriter := bufio.NewWriter(fr.Raw())
-	linesWriter.Write(jschemeBytes)
-	return linesWriter.Flush()
+	writeErr := writeFileSynced(tempPath, jschemeBytes)
+	if writeErr != nil {
+		os.Remove(tempPath)
+		return writeErr
+	}
 
+	return os.Rename(tempPath, schemesPath)
 }
+
 func (m *MetaManager) LoadSchemesFromDisk() error {
 
 	entries, err := os.ReadDir(m.storagePath)
@@ -126,3 +237,21 @@ func (m *MetaManager) LoadSchemesFromDisk() error {
 
 	return nil
 }
+
+// snapshots of all schemas
+func (qp *MetaManager) Schemas() []*schema.Schema {
+
+	qp.lock.RLock()
+	names := make([]string, 0, len(qp.schemas))
+	for name := range qp.schemas {
+		names = append(names, name)
+	}
+	qp.lock.RUnlock()
+
+	result := make([]*schema.Schema, 0, len(names))
+	for _, name := range names {
+		result = append(result, qp.SchemaSnapshot(name))
+	}
+
+	return result
+}
@@ -0,0 +1,250 @@
+package meta
+
+import (
+	"github.com/dot5enko/simple-column-db/bits"
+	"github.com/dot5enko/simple-column-db/manager/cache"
+	"github.com/dot5enko/simple-column-db/schema"
+	"github.com/google/uuid"
+)
+
+// used when ManagerConfig.CacheMaxBytes is not set
+const DefaultCacheMaxBytes = 1 << 30
+
+// slab is a unit of eviction : its header, data and blocks are dropped together,
+// so runtime blocks never refer to a header or data that is not cached.
+// evicted items are left to gc, queries still holding them keep them alive
+type cachedSlab struct {
+	// shared by header, data and block items of the slab
+	stats *cache.CacheStats
+
+	bytes     uint64
+	dataBytes uint64
+	blocks    []cachedBlock
+
+	// pinned slab is not evicted
+	pins int
+}
+
+type cachedBlock struct {
+	id    [32]byte
+	bytes uint64
+}
+
+// cache entry of the slab, created if it doesn't exist.
+// must be called with cachedLocker held
+func (m *SlabManager) cachedSlabEntry(uid uuid.UUID) *cachedSlab {
+
+	entry, ok := m.cachedSlabs[uid]
+	if !ok {
+		entry = &cachedSlab{stats: cache.NewCacheStats()}
+		m.cachedSlabs[uid] = entry
+	}
+
+	return entry
+}
+
+// stats of the slab cache items
+func (m *SlabManager) slabCacheStats(uid uuid.UUID) *cache.CacheStats {
+
+	m.cachedLocker.Lock()
+	defer m.cachedLocker.Unlock()
+
+	return m.cachedSlabEntry(uid).stats
+}
+
+// accounts items just put into one of the caches and evicts slabs over the limit.
+// must not be called while holding any of cache locks
+func (m *SlabManager) trackCachedHeader(uid uuid.UUID, header *schema.DiskSlabHeader) {
+	m.trackCached(uid, uint64(schema.SlabHeaderFixedSize+len(header.BlockHeaders)*schema.TotalHeaderSize), nil)
+}
+
+func (m *SlabManager) trackCachedData(uid uuid.UUID, item *cache.SlabDataCacheItem) {
+
+	size := uint64(len(item.Data))
+
+	m.trackCached(uid, size, func(entry *cachedSlab) {
+		entry.dataBytes += size
+	})
+}
+
+// validity bitmaps and dictionary of the slab are dropped along with it
+func (m *SlabManager) trackCachedValidity(uid uuid.UUID, validity []bits.Bitfield) {
+	m.trackCached(uid, uint64(len(validity)*BlockValiditySize), nil)
+}
+
+// dictionary is accounted by size of its values on disk, appended values included
+func (m *SlabManager) trackCachedDictionary(uid uuid.UUID, size int) {
+	m.trackCached(uid, uint64(size), nil)
+}
+
+// raw blocks are mapped onto slab data, only decoded blocks allocate memory
+func (m *SlabManager) trackCachedBlock(uid uuid.UUID, blockId [32]byte, header *schema.DiskHeader) {
+
+	var size uint64
+	if header.Codec != schema.CodecNone {
+		size = uint64(header.DataType.BlockSize())
+	}
+
+	m.trackCached(uid, size, func(entry *cachedSlab) {
+		entry.blocks = append(entry.blocks, cachedBlock{id: blockId, bytes: size})
+	})
+}
+
+func (m *SlabManager) trackCached(uid uuid.UUID, size uint64, update func(entry *cachedSlab)) {
+
+	m.cachedLocker.Lock()
+	defer m.cachedLocker.Unlock()
+
+	entry := m.cachedSlabEntry(uid)
+	entry.bytes += size
+	m.cachedBytes += size
+
+	if update != nil {
+		update(entry)
+	}
+
+	m.evictOverLimit()
+}
+
+// pinned slabs are kept in cache until they are unpinned as many times,
+// used for slabs being written and slabs read by in-flight queries
+func (m *SlabManager) PinSlab(uid uuid.UUID) {
+
+	m.cachedLocker.Lock()
+	defer m.cachedLocker.Unlock()
+
+	m.cachedSlabEntry(uid).pins++
+}
+
+func (m *SlabManager) UnpinSlab(uid uuid.UUID) {
+
+	m.cachedLocker.Lock()
+	defer m.cachedLocker.Unlock()
+
+	entry, ok := m.cachedSlabs[uid]
+	if !ok || entry.pins == 0 {
+		return
+	}
+
+	entry.pins--
+
+	if entry.pins == 0 && entry.bytes == 0 {
+		delete(m.cachedSlabs, uid)
+	}
+
+	m.evictOverLimit()
+}
+
+// total size of cached items
+func (m *SlabManager) CachedBytes() uint64 {
+
+	m.cachedLocker.Lock()
+	defer m.cachedLocker.Unlock()
+
+	return m.cachedBytes
+}
+
+// evicts least recently used slabs until cache fits the limit,
+// pinned slabs may keep it over the limit.
+// must be called with cachedLocker held
+func (m *SlabManager) evictOverLimit() {
+
+	for m.cachedBytes > m.cacheMaxBytes {
+
+		var victimUid uuid.UUID
+		var victim *cachedSlab
+
+		for uid, entry := range m.cachedSlabs {
+
+			if entry.pins > 0 || entry.bytes == 0 {
+				continue
+			}
+
+			if victim == nil || entry.stats.LastUsed().Before(victim.stats.LastUsed()) {
+				victim = entry
+				victimUid = uid
+			}
+		}
+
+		if victim == nil {
+			return
+		}
+
+		m.dropCachedSlab(victimUid, victim)
+	}
+}
+
+// must be called with cachedLocker held
+func (m *SlabManager) dropCachedSlab(uid uuid.UUID, entry *cachedSlab) {
+
+	m.slabHeaderCacheLocker.Lock()
+	delete(m.slabHeaderCacheItem, uid)
+	m.slabHeaderCacheLocker.Unlock()
+
+	m.slabDataCacheLocker.Lock()
+	delete(m.slabDataCache, uid)
+	m.slabDataCacheLocker.Unlock()
+
+	m.dictionariesLocker.Lock()
+	delete(m.dictionaries, uid)
+	m.dictionariesLocker.Unlock()
+
+	m.validityLocker.Lock()
+	delete(m.validity, uid)
+	m.validityLocker.Unlock()
+
+	m.dropCachedData(entry)
+
+	m.cachedBytes -= entry.bytes
+	delete(m.cachedSlabs, uid)
+}
+
+// drops data and blocks of the slab, header is kept.
+// must be called with cachedLocker held
+func (m *SlabManager) dropCachedData(entry *cachedSlab) {
+
+	m.locker.Lock()
+	for _, block := range entry.blocks {
+		delete(m.cache, block.id)
+		entry.bytes -= block.bytes
+		m.cachedBytes -= block.bytes
+	}
+	m.locker.Unlock()
+
+	entry.blocks = nil
+
+	entry.bytes -= entry.dataBytes
+	m.cachedBytes -= entry.dataBytes
+	entry.dataBytes = 0
+}
+
+// drops every cached item including pinned slabs,
+// used once nothing reads or writes slabs anymore
+func (m *SlabManager) DropCaches() {
+
+	m.cachedLocker.Lock()
+	defer m.cachedLocker.Unlock()
+
+	m.locker.Lock()
+	m.cache = map[[32]byte]BlockCacheItem{}
+	m.locker.Unlock()
+
+	m.slabHeaderCacheLocker.Lock()
+	m.slabHeaderCacheItem = map[uuid.UUID]*cache.SlabCacheItem{}
+	m.slabHeaderCacheLocker.Unlock()
+
+	m.slabDataCacheLocker.Lock()
+	m.slabDataCache = map[uuid.UUID]*cache.SlabDataCacheItem{}
+	m.slabDataCacheLocker.Unlock()
+
+	m.dictionariesLocker.Lock()
+	m.dictionaries = map[uuid.UUID]*schema.StringDictionary{}
+	m.dictionariesLocker.Unlock()
+
+	m.validityLocker.Lock()
+	m.validity = map[uuid.UUID][]bits.Bitfield{}
+	m.validityLocker.Unlock()
+
+	m.cachedSlabs = map[uuid.UUID]*cachedSlab{}
+	m.cachedBytes = 0
+}
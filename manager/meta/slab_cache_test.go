@@ -0,0 +1,149 @@
+package meta
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dot5enko/simple-column-db/manager/cache"
+	"github.com/dot5enko/simple-column-db/schema"
+	"github.com/google/uuid"
+)
+
+// puts slab data into cache the way slab loading does
+func cacheTestSlab(m *SlabManager, uid uuid.UUID, item *cache.SlabDataCacheItem) {
+
+	m.slabDataCacheLocker.Lock()
+	m.slabDataCache[uid] = item
+	m.slabDataCacheLocker.Unlock()
+
+	m.trackCachedData(uid, item)
+
+	// orders last use of slabs
+	m.slabCacheStats(uid).Touch()
+	time.Sleep(time.Millisecond)
+}
+
+func cachedTestSlabs(m *SlabManager, uids ...uuid.UUID) []bool {
+
+	m.slabDataCacheLocker.Lock()
+	defer m.slabDataCacheLocker.Unlock()
+
+	result := make([]bool, len(uids))
+	for i, uid := range uids {
+		_, result[i] = m.slabDataCache[uid]
+	}
+
+	return result
+}
+
+func TestEvictionSkipsPinnedSlabs(t *testing.T) {
+
+	const slabBytes = schema.SlabDiskContentsUncompressed
+
+	// fits two slabs
+	m := NewSlabManager(t.TempDir(), slabBytes*5/2, nil)
+	item := &cache.SlabDataCacheItem{}
+
+	a, b, c, d := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+
+	m.PinSlab(a)
+	cacheTestSlab(m, a, item)
+	cacheTestSlab(m, b, item)
+
+	// a is least recently used but pinned
+	cacheTestSlab(m, c, item)
+
+	if cached := cachedTestSlabs(m, a, b, c); !cached[0] || cached[1] || !cached[2] {
+		t.Fatalf("expected b to be evicted, cached a, b, c : %v", cached)
+	}
+
+	if cachedBytes := m.CachedBytes(); cachedBytes != 2*slabBytes {
+		t.Fatalf("expected %d cached bytes, got %d", 2*slabBytes, cachedBytes)
+	}
+
+	// pinned slabs keep cache over the limit
+	m.PinSlab(c)
+	m.PinSlab(d)
+	cacheTestSlab(m, d, item)
+
+	if cached := cachedTestSlabs(m, a, c, d); !cached[0] || !cached[1] || !cached[2] {
+		t.Fatalf("expected pinned slabs to stay cached, cached a, c, d : %v", cached)
+	}
+
+	if cachedBytes := m.CachedBytes(); cachedBytes != 3*slabBytes {
+		t.Fatalf("expected %d cached bytes, got %d", 3*slabBytes, cachedBytes)
+	}
+
+	// unpinned slab is evicted once cache is over the limit, pins are counted
+	m.PinSlab(a)
+	m.UnpinSlab(a)
+
+	if cached := cachedTestSlabs(m, a); !cached[0] {
+		t.Fatalf("expected a to stay cached while pinned once more")
+	}
+
+	m.UnpinSlab(a)
+
+	if cached := cachedTestSlabs(m, a, c, d); cached[0] || !cached[1] || !cached[2] {
+		t.Fatalf("expected a to be evicted once unpinned, cached a, c, d : %v", cached)
+	}
+
+	if cachedBytes := m.CachedBytes(); cachedBytes != 2*slabBytes {
+		t.Errorf("expected %d cached bytes, got %d", 2*slabBytes, cachedBytes)
+	}
+}
+
+func TestEvictionDropsValidityAndDictionary(t *testing.T) {
+
+	const slabBytes = schema.SlabDiskContentsUncompressed
+
+	dir := t.TempDir()
+	schemaObject := &schema.Schema{Name: "checks"}
+
+	mkdirErr := os.Mkdir(filepath.Join(dir, schemaObject.Name), 0755)
+	if mkdirErr != nil {
+		t.Fatal(mkdirErr)
+	}
+
+	// fits a single slab
+	m := NewSlabManager(dir, slabBytes, nil)
+
+	a, b := uuid.New(), uuid.New()
+	slab := &schema.DiskSlabHeader{Uid: a, BlocksTotal: 4}
+
+	_, validityErr := m.LoadSlabValidity(schemaObject, slab)
+	if validityErr != nil {
+		t.Fatal(validityErr)
+	}
+
+	encodeErr := m.EncodeStrings(schemaObject, a, []string{"x", "yz"}, make([]uint32, 2))
+	if encodeErr != nil {
+		t.Fatal(encodeErr)
+	}
+
+	// bitmaps of every block and uvarint prefixed values
+	expected := uint64(4*BlockValiditySize + 2 + 3)
+	if cachedBytes := m.CachedBytes(); cachedBytes != expected {
+		t.Fatalf("expected %d cached bytes, got %d", expected, cachedBytes)
+	}
+
+	cacheTestSlab(m, b, &cache.SlabDataCacheItem{})
+
+	m.validityLocker.RLock()
+	_, validityCached := m.validity[a]
+	m.validityLocker.RUnlock()
+
+	m.dictionariesLocker.RLock()
+	_, dictionaryCached := m.dictionaries[a]
+	m.dictionariesLocker.RUnlock()
+
+	if validityCached || dictionaryCached {
+		t.Fatalf("expected validity and dictionary of evicted slab to be dropped, cached %v, %v", validityCached, dictionaryCached)
+	}
+
+	if cachedBytes := m.CachedBytes(); cachedBytes != slabBytes {
+		t.Errorf("expected %d cached bytes, got %d", slabBytes, cachedBytes)
+	}
+}
@@ -3,9 +3,9 @@ package meta
 import (
 	"fmt"
 	"sync"
-	"time"
 
 	"github.com/dot5enko/simple-column-db/bits"
+	"github.com/dot5enko/simple-column-db/compression"
 	"github.com/dot5enko/simple-column-db/manager/cache"
 	"github.com/dot5enko/simple-column-db/schema"
 	"github.com/google/uuid"
@@ -13,6 +13,7 @@ import (
 )
 
 type BlockCacheItem struct {
+	slab    *schema.DiskSlabHeader
 	header  *schema.DiskHeader
 	runtime *schema.RuntimeBlockData
 
@@ -34,11 +35,23 @@ type SlabManager struct {
 	slabDataCache       map[uuid.UUID]*cache.SlabDataCacheItem
 	slabDataCacheLocker sync.RWMutex
 
+	// dictionaries of string column slabs
+	dictionaries       map[uuid.UUID]*schema.StringDictionary
+	dictionariesLocker sync.RWMutex
+
+	// validity bitmaps of nullable column slabs
+	validity       map[uuid.UUID][]bits.Bitfield
+	validityLocker sync.RWMutex
+
+	// usage of header, data and block caches per slab
+	cachedSlabs   map[uuid.UUID]*cachedSlab
+	cachedBytes   uint64
+	cacheMaxBytes uint64
+	cachedLocker  sync.Mutex
+
 	// buffers
 	headerReaderBufferRing *cache.FixedSizeBufferPool
 	fullSlabBufferRing     *cache.FixedSizeBufferPool
-	slabHeaderCache        *cache.TypedRingBuffer[schema.DiskSlabHeader]
-	slabRuntimeCache       *cache.TypedRingBuffer[cache.SlabDataCacheItem]
 
 	meta *MetaManager
 
@@ -46,12 +59,21 @@ type SlabManager struct {
 }
 
 // todo : remove const/literals, add config param
-func NewSlabManager(storagePath string, meta *MetaManager) *SlabManager {
+func NewSlabManager(storagePath string, cacheMaxBytes uint64, meta *MetaManager) *SlabManager {
+
+	if cacheMaxBytes == 0 {
+		cacheMaxBytes = DefaultCacheMaxBytes
+	}
+
 	sm := &SlabManager{
 		storagePath:         storagePath,
 		cache:               map[[32]byte]BlockCacheItem{},
 		slabHeaderCacheItem: map[uuid.UUID]*cache.SlabCacheItem{},
 		slabDataCache:       map[uuid.UUID]*cache.SlabDataCacheItem{},
+		dictionaries:        map[uuid.UUID]*schema.StringDictionary{},
+		validity:            map[uuid.UUID][]bits.Bitfield{},
+		cachedSlabs:         map[uuid.UUID]*cachedSlab{},
+		cacheMaxBytes:       cacheMaxBytes,
 		meta:                meta,
 	}
 
@@ -59,12 +81,6 @@ func NewSlabManager(storagePath string, meta *MetaManager) *SlabManager {
 	sm.fullSlabBufferRing = cache.NewFixedSizeBufferPool(16, schema.SlabDiskContentsUncompressed)
 	sm.headerReaderBufferRing = cache.NewFixedSizeBufferPool(32, schema.SlabHeaderFixedSize)
 
-	sm.slabRuntimeCache = cache.NewTypedRingBuffer[cache.SlabDataCacheItem](32)
-
-	// slab reusing header
-	// todo profile and optimize
-	sm.slabHeaderCache = cache.NewTypedRingBuffer[schema.DiskSlabHeader](128)
-
 	return sm
 }
 
@@ -78,7 +94,7 @@ func (m *SlabManager) getSlabHeaderFromCache(uid uuid.UUID) *cache.SlabCacheItem
 
 	if item, ok := m.slabHeaderCacheItem[uid]; ok {
 
-		item.RtStats.Reads++
+		item.RtStats.Touch()
 		return item
 	}
 
@@ -92,13 +108,28 @@ func (m *SlabManager) getSlabDataFromCache(uid uuid.UUID) *cache.SlabDataCacheIt
 
 	if item, ok := m.slabDataCache[uid]; ok {
 
-		item.RtStats.Reads++
+		item.RtStats.Touch()
 		return item
 	}
 
 	return nil
 }
 
+// drops slab data and blocks mapped onto it, header is kept
+func (m *SlabManager) dropSlabDataFromCache(uid uuid.UUID) {
+
+	m.cachedLocker.Lock()
+	defer m.cachedLocker.Unlock()
+
+	m.slabDataCacheLocker.Lock()
+	delete(m.slabDataCache, uid)
+	m.slabDataCacheLocker.Unlock()
+
+	if entry, ok := m.cachedSlabs[uid]; ok {
+		m.dropCachedData(entry)
+	}
+}
+
 // IngestIntoBlock(field.slab, curBlock, field.Data[field.ingested:])
 
 func GetUniqueBlockId(slab, block uuid.UUID) [32]byte {
@@ -111,18 +142,20 @@ func GetUniqueBlockId(slab, block uuid.UUID) [32]byte {
 	return uid
 }
 
-func (m *SlabManager) getBlockFromCache(slab, block uuid.UUID) *BlockCacheItem {
+func (m *SlabManager) getBlockFromCache(slab *schema.DiskSlabHeader, block uuid.UUID) *BlockCacheItem {
 
 	m.locker.RLock()
 	defer m.locker.RUnlock()
 
-	uid := GetUniqueBlockId(slab, block)
+	uid := GetUniqueBlockId(slab.Uid, block)
 
-	if item, ok := m.cache[uid]; ok {
+	// block cached along with another header of the slab,
+	// one loaded by a query while slab was evicted
+	if item, ok := m.cache[uid]; ok && item.slab == slab {
 
 		// log.Printf(" --- reading block %s from cache : %d", block.String(), item.rtStats.Reads)
 
-		item.rtStats.Reads++
+		item.rtStats.Touch()
 		return &item
 	}
 
@@ -136,20 +169,26 @@ func (m *SlabManager) LoadBlockToRuntimeBlockData(
 	block uuid.UUID,
 ) (*schema.RuntimeBlockData, error) {
 
-	cached := m.getBlockFromCache(slab.Uid, block)
+	cached := m.getBlockFromCache(slab, block)
 
 	if cached != nil {
 		return cached.runtime, nil
 	} else {
 		// put into cache
 
-		var blockHeader schema.DiskHeader
+		// headers are compared with cached data, both are replaced once the slab is compressed
+		slab.RLock()
+		defer slab.RUnlock()
+
+		var blockHeader *schema.DiskHeader
 		blockIdx := -1
 		blockStartOffset := 0
 
-		for idx, it := range slab.BlockHeaders {
-			if it.Uid == block {
-				blockHeader = it
+		// runtime block refers to the header stored in slab
+		// so ingested items and bounds are visible through the slab header cache
+		for idx := range slab.BlockHeaders {
+			if slab.BlockHeaders[idx].Uid == block {
+				blockHeader = &slab.BlockHeaders[idx]
 				blockIdx = idx
 				break
 			}
@@ -162,36 +201,68 @@ func (m *SlabManager) LoadBlockToRuntimeBlockData(
 			blockSize := blockHeader.DataType.BlockSize()
 			blockStartOffset = blockIdx * blockSize
 
+			// loaded data may be evicted right away, when cache is full of pinned slabs
 			slabData := m.getSlabDataFromCache(slab.Uid)
-			if slabData == nil {
-				_, loadSlabErr := m.LoadSlabDataContents(&schemaObject, slab.Uid)
+			fromDisk := slabData == nil
+
+			if fromDisk {
+				var loadSlabErr error
+				slabData, loadSlabErr = m.loadSlabDataContents(&schemaObject, slab)
 				if loadSlabErr != nil {
 					return nil, loadSlabErr
 				}
-				slabData = m.getSlabDataFromCache(slab.Uid)
-				if slabData == nil {
-					panic("cache should be loaded by now, probably out of memory?")
-				}
 			}
 
 			blockRawData := slabData.Data[blockStartOffset:]
 
 			// log.Printf(" --- loading %s block. blockHeader.StartOffset:%d", blockHeader.Uid.String(), blockHeader.StartOffset)
 
-			runtimeBlockData, runtimeDecodeErr := DecodeRawBlockData(blockRawData, &blockHeader)
+			// cached data of the active block is changed by ingest along with its checksum
+			if fromDisk || blockIdx < int(slab.BlocksFinalized) {
+				verifyErr := blockHeader.VerifyData(slab.Uid, blockRawData)
+				if verifyErr != nil {
+					return nil, verifyErr
+				}
+			}
+
+			runtimeBlockData, runtimeDecodeErr := DecodeRawBlockData(blockRawData, blockHeader)
 
 			if runtimeDecodeErr != nil {
-				return nil, fmt.Errorf("unable to decoded raw block data for slab %s. block %s: %s", slab.Uid.String(), block.String(), runtimeDecodeErr.Error())
+				return nil, fmt.Errorf("unable to decoded raw block data for slab %s. block %s: %w", slab.Uid.String(), block.String(), runtimeDecodeErr)
 			} else {
-				m.locker.Lock()
-				defer m.locker.Unlock()
+
+				if slab.Type == schema.StringFieldType {
+					dict, dictErr := m.LoadSlabDictionary(&schemaObject, slab.Uid)
+					if dictErr != nil {
+						return nil, dictErr
+					}
+
+					runtimeBlockData.Dictionary = dict
+				}
+
+				if schemaObject.Columns[slab.SchemaFieldId-1].Nullable {
+					validity, validityErr := m.LoadSlabValidity(&schemaObject, slab)
+					if validityErr != nil {
+						return nil, validityErr
+					}
+
+					runtimeBlockData.Validity = &validity[blockIdx]
+				}
 
 				blockId := GetUniqueBlockId(slab.Uid, block)
 
+				m.locker.Lock()
+				_, replaced := m.cache[blockId]
 				m.cache[blockId] = BlockCacheItem{
-					header:  &blockHeader,
+					slab:    slab,
+					header:  blockHeader,
 					runtime: runtimeBlockData,
-					rtStats: &cache.CacheStats{CacheEntryId: slabData.RtStats.CacheEntryId, Created: time.Now(), Reads: 1},
+					rtStats: slabData.RtStats,
+				}
+				m.locker.Unlock()
+
+				if !replaced {
+					m.trackCachedBlock(slab.Uid, blockId, blockHeader)
 				}
 
 				return runtimeBlockData, nil
@@ -206,33 +277,48 @@ func (m *SlabManager) LoadBlockToRuntimeBlockData(
 // return RuntimeBlockData
 func DecodeRawBlockData(blockData []byte, bheader *schema.DiskHeader) (*schema.RuntimeBlockData, error) {
 
-	var runtimeData *schema.RuntimeBlockData
+	typeOps, typeErr := bheader.DataType.Ops()
+	if typeErr != nil {
+		return nil, fmt.Errorf("unknown type while decoding raw block data: %s", typeErr.Error())
+	}
 
-	switch bheader.DataType {
+	result, decodeErr := blockTypedArray(typeOps, blockData, bheader)
+	if decodeErr != nil {
+		return nil, fmt.Errorf("unable to decode %s block : %s", bheader.Codec.String(), decodeErr.Error())
+	}
 
-	case schema.Float64FieldType:
-		result := bits.MapBytesToArray[float64](blockData, schema.BlockRowsSize)
-		runtimeData = schema.NewRuntimeBlockDataFromSlice(result, int(bheader.Items))
+	runtimeData := schema.NewRuntimeBlockDataFromSlice(result, int(bheader.Items))
+	runtimeData.Header = bheader
 
-	case schema.Float32FieldType:
-		result := bits.MapBytesToArray[float32](blockData, schema.BlockRowsSize)
-		runtimeData = schema.NewRuntimeBlockDataFromSlice(result, int(bheader.Items))
+	return runtimeData, nil
 
-	case schema.Uint64FieldType:
+}
 
-		result := bits.MapBytesToArray[uint64](blockData, schema.BlockRowsSize)
-		runtimeData = schema.NewRuntimeBlockDataFromSlice(result, int(bheader.Items))
+// raw blocks are mapped in place, encoded ones are decoded into a new array
+func blockTypedArray(typeOps *schema.TypeOps, blockData []byte, bheader *schema.DiskHeader) (any, error) {
 
-	case schema.Uint8FieldType:
-		result := bits.MapBytesToArray[uint8](blockData, schema.BlockRowsSize)
-		runtimeData = schema.NewRuntimeBlockDataFromSlice(result, int(bheader.Items))
+	if bheader.Codec == schema.CodecNone {
+		return typeOps.MapBytes(blockData, schema.BlockRowsSize), nil
+	}
 
-	default:
-		return nil, fmt.Errorf("unknown type while decoding raw block data: %s", bheader.DataType.String())
+	if uint64(len(blockData)) < bheader.CompressedSize {
+		return nil, fmt.Errorf("encoded block size %d is out of slab data", bheader.CompressedSize)
 	}
 
-	runtimeData.Header = bheader
+	// todo reuse decoded arrays when block cache evicts them
+	result := typeOps.MakeSlice(schema.BlockRowsSize)
+	decodeErr := compression.DecodeBlock(bheader.Codec, blockData[:bheader.CompressedSize], result, int(bheader.Items))
 
-	return runtimeData, nil
+	return result, decodeErr
+}
+
+// encodes raw block values into out, returns encoded size
+func EncodeRawBlockData(blockData []byte, bheader *schema.DiskHeader, codec schema.ColumnCodec, out []byte) (int, error) {
+
+	typeOps, typeErr := bheader.DataType.Ops()
+	if typeErr != nil {
+		return 0, fmt.Errorf("unknown type while encoding raw block data: %s", typeErr.Error())
+	}
 
+	return compression.EncodeBlock(codec, typeOps.MapBytes(blockData, int(bheader.Items)), out)
 }
@@ -6,8 +6,7 @@ import (
 	"github.com/dot5enko/simple-column-db/schema"
 )
 
-// todo check thread safety
-
+// header is serialized under its lock, queries read it meanwhile
 func (sm *SlabManager) UpdateSlabHeaderOnDisk(s schema.Schema, slab *schema.DiskSlabHeader) error {
 
 	headerReadBuffer, headerBufferIdx := sm.headerReaderBufferRing.Get()
@@ -16,7 +15,10 @@ func (sm *SlabManager) UpdateSlabHeaderOnDisk(s schema.Schema, slab *schema.Disk
 		sm.headerReaderBufferRing.Return(headerBufferIdx)
 	}()
 
+	slab.Lock()
 	serializedBytes, headerBytesErr := slab.WriteTo(headerReadBuffer)
+	slab.Unlock()
+
 	if headerBytesErr != nil {
 		return fmt.Errorf("unable to finalize block, slab header won't serialize : %s", headerBytesErr.Error())
 	} else {
@@ -1,20 +1,22 @@
 package meta
 
 import (
-	"bytes"
 	"encoding/binary"
 	"fmt"
 	"log"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
 	"time"
 
-	"github.com/davecgh/go-spew/spew"
 	"github.com/dot5enko/simple-column-db/bits"
 	"github.com/dot5enko/simple-column-db/compression"
-	"github.com/dot5enko/simple-column-db/io"
 	"github.com/dot5enko/simple-column-db/schema"
-	"github.com/fatih/color"
 )
 
+// compresses data of a fully finalized slab and trims preallocated space.
+// finalized slabs are never written again, so their data region is stored compressed
 func (sm *SlabManager) TrimFinalizedBlocksSize(
 	schemaObject schema.Schema,
 	slab *schema.DiskSlabHeader,
@@ -26,6 +28,13 @@ func (sm *SlabManager) TrimFinalizedBlocksSize(
 
 	headersSize := slab.BlocksTotal * schema.TotalHeaderSize
 
+	if slab.CompressionType == schema.SlabCompressionNone {
+		compressErr := sm.compressFinalizedSlab(schemaObject, slab)
+		if compressErr != nil {
+			return fmt.Errorf("unable to compress finalized slab : %s", compressErr.Error())
+		}
+	}
+
 	fileManager, slabErr := sm.GetSlabFile(schemaObject, slab.Uid, true)
 	if slabErr != nil {
 		return fmt.Errorf("unable to get slab file : %s", slabErr.Error())
@@ -40,128 +49,316 @@ func (sm *SlabManager) TrimFinalizedBlocksSize(
 	return fileManager.Raw().Truncate(finalSize)
 }
 
-// todo work on thread safety
-func (sm *SlabManager) UpdateBlockHeaderAndDataOnDisk(
-	s schema.Schema,
-	slab *schema.DiskSlabHeader,
-	block *schema.RuntimeBlockData,
-) error {
+// rewrites slab file with blocks encoded by column codec and lz4 compressed data region.
+// encoded block is stored at the start of its raw block slot, so block offsets don't change
+// and zeroed tail of the slot is squeezed by lz4.
+// new file is written aside and renamed over the old one,
+// so a crash leaves either the old or the new slab.
+// blocks that don't shrink are left raw, data that doesn't shrink is left uncompressed
+func (sm *SlabManager) compressFinalizedSlab(schemaObject schema.Schema, slab *schema.DiskSlabHeader) error {
+
+	slabData, loadErr := sm.LoadSlabDataContents(&schemaObject, slab.Uid)
+	if loadErr != nil {
+		return fmt.Errorf("unable to load slab data : %s", loadErr.Error())
+	}
 
-	foundIdx := -1
-	for idx, it := range slab.BlockHeaders {
-		if it.Uid == block.Header.Uid {
-			foundIdx = idx
-			break
+	uncompressedSize := int(slab.CompressedSlabContentSize)
+	contents := slabData.Data[:uncompressedSize]
+
+	start := time.Now()
+
+	codec := schemaObject.Columns[slab.SchemaFieldId-1].Codec
+	blockHeaders := slices.Clone(slab.BlockHeaders)
+	encodedBlocks := 0
+
+	// both buffers are taken at once, taking them one by one deadlocks parallel compressions on the pool
+	encodedBuffer, encodedBufferIdx, compressedBuffer, compressedBufferIdx := sm.fullSlabBufferRing.GetPair()
+	defer sm.fullSlabBufferRing.Return(encodedBufferIdx)
+	defer sm.fullSlabBufferRing.Return(compressedBufferIdx)
+
+	if codec != schema.CodecNone {
+
+		blockSize := slab.Type.BlockSize()
+		contents = encodedBuffer[:uncompressedSize]
+
+		for blockIdx := range blockHeaders {
+
+			header := &blockHeaders[blockIdx]
+			rawBlock := slabData.Data[blockIdx*blockSize : (blockIdx+1)*blockSize]
+			slot := contents[blockIdx*blockSize : (blockIdx+1)*blockSize]
+
+			// already encoded by interrupted previous run
+			if header.Codec != schema.CodecNone {
+				copy(slot, rawBlock)
+				continue
+			}
+
+			clear(slot)
+
+			encodedSize, encodeErr := EncodeRawBlockData(rawBlock, header, codec, slot)
+			if encodeErr != nil {
+				if encodeErr != compression.ErrCodecOverflow {
+					return fmt.Errorf("unable to encode block %s : %s", header.Uid.String(), encodeErr.Error())
+				}
+
+				copy(slot, rawBlock)
+				continue
+			}
+
+			header.Codec = codec
+			header.CompressedSize = uint64(encodedSize)
+			header.DataChecksum = schema.Checksum(slot[:encodedSize])
+			encodedBlocks++
 		}
 	}
 
-	if foundIdx == -1 {
-		return fmt.Errorf("block with uid `%s` doesn't exist in slab", block.Header.Uid.String())
+	compressionType := schema.SlabCompressionNone
+	storedData := contents
+
+	// zero size means data is incompressible
+	compressedSize, compressErr := compression.CompressLz4(contents, compressedBuffer)
+	if compressErr == nil && compressedSize > 0 && compressedSize < uncompressedSize {
+		compressionType = schema.SlabCompressionLz4
+		storedData = compressedBuffer[:compressedSize]
+	} else if encodedBlocks == 0 {
+		return nil
 	}
 
-	// compression buffer
-	slabReadCache, slabCacheIdx := sm.fullSlabBufferRing.Get()
-	defer sm.fullSlabBufferRing.Return(slabCacheIdx)
+	compressionTook := time.Since(start)
 
-	slabReadCache1, slabCacheIdx1 := sm.fullSlabBufferRing.Get()
-	defer sm.fullSlabBufferRing.Return(slabCacheIdx1)
+	// slab header and block headers are copied as is
+	dataOffset := int(schema.SlabHeaderFixedSize) + int(slab.BlocksTotal)*int(schema.TotalHeaderSize)
+	headersBuffer := make([]byte, dataOffset)
 
-	{
-		singleBlockUncompressedSize := slab.Type.BlockSize()
-		blockDataOffset := singleBlockUncompressedSize * foundIdx
+	fileReader, openErr := sm.GetSlabFile(schemaObject, slab.Uid, false)
+	if openErr != nil {
+		return fmt.Errorf("unable to open slab file : %s", openErr.Error())
+	}
 
-		headersHeaderOffset := schema.TotalHeaderSize * uint64(foundIdx)
-		slabHeaderAbsOffset := schema.SlabHeaderFixedSize + headersHeaderOffset
-		headersSize := schema.TotalHeaderSize * int(slab.BlocksTotal)
+	readErr := fileReader.ReadAt(headersBuffer, 0, dataOffset)
+	fileReader.Close()
 
-		writeBuf := bytes.NewBuffer(slabReadCache[:0])
-		dataSize, writeErr := io.DumpNumbersArrayBlockAny(writeBuf, block.DataTypedArray)
-		if writeErr != nil {
-			return fmt.Errorf("unable to finalize block : %s", writeErr.Error())
-		}
+	if readErr != nil {
+		return fmt.Errorf("unable to read slab headers : %s", readErr.Error())
+	}
+
+	compressedHeader := slab.Clone()
+	compressedHeader.CompressionType = compressionType
+	compressedHeader.CompressedSlabContentSize = uint64(len(storedData))
+
+	_, headerErr := compressedHeader.WriteTo(headersBuffer[:schema.SlabHeaderFixedSize])
+	if headerErr != nil {
+		return fmt.Errorf("unable to serialize slab header : %s", headerErr.Error())
+	}
 
-		slabDataCacheItem := sm.getSlabDataFromCache(slab.Uid)
-		if slabDataCacheItem == nil {
-			return fmt.Errorf("unable to find slab cache item, need to load whole slab from disk first")
+	for blockIdx := range blockHeaders {
+		headerOffset := int(schema.SlabHeaderFixedSize) + blockIdx*int(schema.TotalHeaderSize)
+
+		headerWriter := bits.NewEncodeBuffer(headersBuffer[headerOffset:headerOffset+int(schema.TotalHeaderSize)], binary.LittleEndian)
+		_, blockHeaderErr := blockHeaders[blockIdx].WriteTo(&headerWriter)
+		if blockHeaderErr != nil {
+			return fmt.Errorf("unable to serialize block header : %s", blockHeaderErr.Error())
 		}
+	}
 
-		copy(slabDataCacheItem.Data[blockDataOffset:], writeBuf.Bytes())
+	slabPath := sm.GetSlabPath(schemaObject, slab.Uid)
+	tempPath := slabPath + ".compressed"
 
-		slabHeader := sm.getSlabHeaderFromCache(slab.Uid)
+	writeErr := writeFileSynced(tempPath, headersBuffer, storedData)
+	if writeErr != nil {
+		os.Remove(tempPath)
+		return writeErr
+	}
 
-		// compress whole slab
-		compressionSizeTotal := dataSize * int(slabHeader.Header.BlocksTotal)
+	// blocks being loaded see either raw cached data with old headers
+	// or encoded data from disk with new ones
+	slab.Lock()
 
-		if false {
+	renameErr := os.Rename(tempPath, slabPath)
+	if renameErr != nil {
+		slab.Unlock()
+		os.Remove(tempPath)
+		return fmt.Errorf("unable to replace slab file : %s", renameErr.Error())
+	}
 
-			start := time.Now()
+	slab.CompressionType = compressionType
+	slab.CompressedSlabContentSize = uint64(len(storedData))
 
-			compressedSize, compressErr := compression.CompressLz4(slabDataCacheItem.Data[:compressionSizeTotal], slabReadCache)
-			if compressedSize > 0 {
-				compressionTook := time.Since(start)
+	// runtime blocks refer to slab headers, so they are updated in place
+	for blockIdx := range blockHeaders {
+		slab.BlockHeaders[blockIdx].Codec = blockHeaders[blockIdx].Codec
+		slab.BlockHeaders[blockIdx].CompressedSize = blockHeaders[blockIdx].CompressedSize
+		slab.BlockHeaders[blockIdx].DataChecksum = blockHeaders[blockIdx].DataChecksum
+		slab.BlockHeaders[blockIdx].HeaderChecksum = blockHeaders[blockIdx].HeaderChecksum
+	}
 
-				showSize := 128
+	// cached data is raw while disk holds encoded blocks now,
+	// already loaded runtime blocks stay valid
+	if encodedBlocks > 0 {
+		sm.dropSlabDataFromCache(slab.Uid)
+	}
 
-				spew.Dump("compression input ", slabDataCacheItem.Data[:showSize], slabDataCacheItem.Data[compressionSizeTotal-showSize:compressionSizeTotal])
+	slab.Unlock()
 
-				if compressErr != nil {
-					return fmt.Errorf("unable to compress slab data : %s", compressErr.Error())
-				}
+	// rename is durable once directory entry is synced
+	syncErr := syncDirectory(filepath.Dir(slabPath))
+	if syncErr != nil {
+		return syncErr
+	}
 
-				// log.Printf(" input : %d -> output %d", dataSize*int(slab.BlocksFinalized+1), compressedSize)
+	slog.Debug("compressed slab",
+		"slab_uid", slab.Uid.String(),
+		"type", slab.Type.String(),
+		"codec", codec.String(),
+		"encoded_blocks", encodedBlocks,
+		"size", uncompressedSize,
+		"compressed_size", len(storedData),
+		"took_ms", fmt.Sprintf("%.2f", compressionTook.Seconds()*1000),
+	)
+
+	return nil
+}
 
-				compressRatio := float64(compressedSize) / float64(compressionSizeTotal)
-				fillRatio := float64(slab.BlocksFinalized) / float64(slab.BlocksTotal)
+func syncDirectory(path string) error {
 
-				color.Yellow(" compressed slab [type=%s][%d/%d] %d -> %d [%.2f%%] fill %.2f%% %.2fms", slab.Type.String(), slab.BlocksFinalized, slab.BlocksTotal, compressionSizeTotal, compressedSize, compressRatio*100.0, fillRatio*100, compressionTook.Seconds()*1000)
+	dir, openErr := os.Open(path)
+	if openErr != nil {
+		return fmt.Errorf("unable to open directory for sync : %s", openErr.Error())
+	}
 
-				spew.Dump("compressed data", slabReadCache[:showSize], slabReadCache[compressedSize-showSize:compressedSize])
+	syncErr := dir.Sync()
+	dir.Close()
 
-				slab.CompressedSlabContentSize = uint64(compressedSize)
-				slab.CompressionType = 1
-			} else {
-				slab.CompressedSlabContentSize = uint64(compressionSizeTotal)
-			}
-		} else {
-			slab.CompressedSlabContentSize = uint64(compressionSizeTotal)
-		}
+	if syncErr != nil {
+		return fmt.Errorf("unable to sync directory : %s", syncErr.Error())
+	}
 
-		// header update
-		fileManager, slabErr := sm.GetSlabFile(s, slab.Uid, true)
-		if slabErr != nil {
-			return fmt.Errorf("unable to get slab file : %s", slabErr.Error())
+	return nil
+}
+
+func writeFileSynced(path string, parts ...[]byte) error {
+
+	f, createErr := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if createErr != nil {
+		return fmt.Errorf("unable to create file : %s", createErr.Error())
+	}
+
+	for _, part := range parts {
+		_, writeErr := f.Write(part)
+		if writeErr != nil {
+			f.Close()
+			return fmt.Errorf("unable to write file : %s", writeErr.Error())
 		}
+	}
 
-		defer fileManager.Close()
+	syncErr := f.Sync()
+	if syncErr != nil {
+		f.Close()
+		return fmt.Errorf("unable to sync file : %s", syncErr.Error())
+	}
 
-		buf := bits.NewEncodeBuffer(slabReadCache1, binary.LittleEndian)
-		serializedBytes, headerBytesErr := block.Header.WriteTo(&buf)
+	return f.Close()
+}
 
-		// log.Printf("%s block bounds written : min %.2f. items in block : %d", block.BlockHeader.Uid.String(), block.BlockHeader.Bounds.Min, block.BlockHeader.Items)
+// only the writer of the schema updates blocks, one goroutine per column,
+// so a slab is never updated concurrently.
+// rows of the block before fromRow are already on disk, only the rest of them is written
+func (sm *SlabManager) UpdateBlockHeaderAndDataOnDisk(
+	s schema.Schema,
+	slab *schema.DiskSlabHeader,
+	block *schema.RuntimeBlockData,
+	fromRow int,
+) error {
 
-		if headerBytesErr != nil {
-			return fmt.Errorf("unable to serialize block header, header won't serialize : %s", headerBytesErr.Error())
-		} else {
-			headerBlockUpdateErr := fileManager.WriteAt(slabReadCache1[:], int(slabHeaderAbsOffset), serializedBytes)
-			if headerBlockUpdateErr != nil {
-				return fmt.Errorf("unable to update block header : %s", headerBlockUpdateErr.Error())
-			}
+	foundIdx := -1
+	for idx, it := range slab.BlockHeaders {
+		if it.Uid == block.Header.Uid {
+			foundIdx = idx
+			break
 		}
+	}
 
-		var writeDataErr error
+	if foundIdx == -1 {
+		return fmt.Errorf("block with uid `%s` doesn't exist in slab", block.Header.Uid.String())
+	}
 
-		if slab.CompressionType != 0 {
-			writeDataErr = fileManager.WriteAt(slabReadCache[:slab.CompressedSlabContentSize], int(schema.SlabHeaderFixedSize+headersSize), int(slab.CompressedSlabContentSize))
-		} else {
-			writeDataErr = fileManager.WriteAt(slabDataCacheItem.Data[:], schema.SlabHeaderFixedSize+headersSize, int(slab.CompressedSlabContentSize))
-		}
+	if slab.CompressionType != schema.SlabCompressionNone {
+		return fmt.Errorf("slab `%s` is compressed, finalized slabs can't be updated", slab.Uid.String())
+	}
 
+	typeOps, typeErr := slab.Type.Ops()
+	if typeErr != nil {
+		return fmt.Errorf("unable to update block : %s", typeErr.Error())
+	}
+
+	singleBlockUncompressedSize := slab.Type.BlockSize()
+	blockDataOffset := singleBlockUncompressedSize * foundIdx
+
+	headersHeaderOffset := schema.TotalHeaderSize * uint64(foundIdx)
+	slabHeaderAbsOffset := schema.SlabHeaderFixedSize + headersHeaderOffset
+	headersSize := schema.TotalHeaderSize * int(slab.BlocksTotal)
+
+	slabDataCacheItem := sm.getSlabDataFromCache(slab.Uid)
+	if slabDataCacheItem == nil {
+		return fmt.Errorf("unable to find slab cache item, need to load whole slab from disk first")
+	}
+
+	// raw blocks of active slab are mapped onto cached slab data,
+	// rows are copied only when data was reloaded after the block was decoded.
+	// written rows are visible to queries already, so they are never copied onto themselves
+	typeSize := slab.Type.Size()
+	dirty := typeOps.Bytes(block.DataTypedArray)[fromRow*typeSize : block.Items*typeSize]
+	dirtyOffset := blockDataOffset + fromRow*typeSize
+
+	if len(dirty) > 0 && &dirty[0] != &slabDataCacheItem.Data[dirtyOffset] {
+		copy(slabDataCacheItem.Data[dirtyOffset:], dirty)
+	}
+
+	// queries copy headers of the active block under read lock
+	slab.Lock()
+
+	payload, payloadErr := block.Header.BlockPayload(slabDataCacheItem.Data[blockDataOffset:])
+	if payloadErr != nil {
+		slab.Unlock()
+		return payloadErr
+	}
+
+	block.Header.DataChecksum = schema.Checksum(payload)
+
+	// data of the active slab is stored uncompressed,
+	// it's compressed once all blocks are finalized
+	slab.CompressedSlabContentSize = uint64(singleBlockUncompressedSize * int(slab.BlocksTotal))
+
+	var headerBuffer [schema.TotalHeaderSize]byte
+
+	buf := bits.NewEncodeBuffer(headerBuffer[:], binary.LittleEndian)
+	serializedBytes, headerBytesErr := block.Header.WriteTo(&buf)
+
+	slab.Unlock()
+
+	if headerBytesErr != nil {
+		return fmt.Errorf("unable to serialize block header, header won't serialize : %s", headerBytesErr.Error())
+	}
+
+	fileManager, slabErr := sm.GetSlabFile(s, slab.Uid, true)
+	if slabErr != nil {
+		return fmt.Errorf("unable to get slab file : %s", slabErr.Error())
+	}
+
+	defer fileManager.Close()
+
+	// data goes first, so header never counts rows that aren't written
+	if len(dirty) > 0 {
+		writeDataErr := fileManager.WriteAt(dirty, int(schema.SlabHeaderFixedSize)+headersSize+dirtyOffset, len(dirty))
 		if writeDataErr != nil {
 			return fmt.Errorf("unable to update block data : %s", writeDataErr.Error())
 		}
+	}
 
-		return nil
+	headerBlockUpdateErr := fileManager.WriteAt(headerBuffer[:], int(slabHeaderAbsOffset), serializedBytes)
+	if headerBlockUpdateErr != nil {
+		return fmt.Errorf("unable to update block header : %s", headerBlockUpdateErr.Error())
 	}
 
-	// return nil
+	return nil
 }
@@ -0,0 +1,113 @@
+package meta
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+
+	"github.com/dot5enko/simple-column-db/bits"
+	"github.com/dot5enko/simple-column-db/schema"
+	"github.com/google/uuid"
+)
+
+// size of a single block validity bitmap on disk
+const BlockValiditySize = len(bits.Bitfield{}) * 8
+
+// validity bitmaps of nullable column slab are kept next to the slab file,
+// block bitmap is stored at blockIdx * BlockValiditySize.
+// missing bitmap means block has no rows yet
+func (sm *SlabManager) GetSlabValidityPath(s schema.Schema, id uuid.UUID) string {
+	return sm.getAbsStoragePath(s.Name, id.String()+".valid")
+}
+
+func (sm *SlabManager) LoadSlabValidity(schemaObject *schema.Schema, slab *schema.DiskSlabHeader) ([]bits.Bitfield, error) {
+
+	sm.validityLocker.RLock()
+	validity, ok := sm.validity[slab.Uid]
+	sm.validityLocker.RUnlock()
+
+	if ok {
+		return validity, nil
+	}
+
+	v, err, _ := sm.loadGroup.Do("valid-"+slab.Uid.String(), func() (any, error) {
+
+		path := sm.GetSlabValidityPath(*schemaObject, slab.Uid)
+
+		contents, readErr := os.ReadFile(path)
+		if readErr != nil && !os.IsNotExist(readErr) {
+			return nil, fmt.Errorf("unable to read slab validity : %s", readErr.Error())
+		}
+
+		validity := make([]bits.Bitfield, slab.BlocksTotal)
+
+		for blockIdx := range validity {
+			blockStart := blockIdx * BlockValiditySize
+			if blockStart+BlockValiditySize > len(contents) {
+				break
+			}
+
+			for word := range validity[blockIdx] {
+				validity[blockIdx][word] = binary.LittleEndian.Uint64(contents[blockStart+word*8:])
+			}
+		}
+
+		sm.validityLocker.Lock()
+		sm.validity[slab.Uid] = validity
+		sm.validityLocker.Unlock()
+
+		sm.trackCachedValidity(slab.Uid, validity)
+
+		return validity, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return v.([]bits.Bitfield), nil
+}
+
+// persists validity of a runtime block,
+// written before block header so rows counted by header always have their bitmap
+func (sm *SlabManager) UpdateBlockValidityOnDisk(
+	s schema.Schema,
+	slab *schema.DiskSlabHeader,
+	block *schema.RuntimeBlockData,
+) error {
+
+	if block.Validity == nil {
+		return nil
+	}
+
+	foundIdx := -1
+	for idx, it := range slab.BlockHeaders {
+		if it.Uid == block.Header.Uid {
+			foundIdx = idx
+			break
+		}
+	}
+
+	if foundIdx == -1 {
+		return fmt.Errorf("block with uid `%s` doesn't exist in slab", block.Header.Uid.String())
+	}
+
+	encoded := make([]byte, BlockValiditySize)
+	for word, v := range block.Validity {
+		binary.LittleEndian.PutUint64(encoded[word*8:], v)
+	}
+
+	f, openErr := os.OpenFile(sm.GetSlabValidityPath(s, slab.Uid), os.O_CREATE|os.O_WRONLY, 0644)
+	if openErr != nil {
+		return fmt.Errorf("unable to open slab validity : %s", openErr.Error())
+	}
+
+	defer f.Close()
+
+	_, writeErr := f.WriteAt(encoded, int64(foundIdx*BlockValiditySize))
+	if writeErr != nil {
+		return fmt.Errorf("unable to write block validity : %s", writeErr.Error())
+	}
+
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"runtime/debug"
 	"sync"
 	"time"
 
@@ -23,12 +24,11 @@ func StartWorkerThreads(workerCount int, cb func(threadId int)) *sync.WaitGroup
 			defer func() {
 				swg.Done()
 
+				// panics of chunks are handled by executor,
+				// anything else stops only this worker
 				rec := recover()
 				if rec != nil {
-
-					panic(rec)
-
-					slog.Error("executor panicked", "err", fmt.Sprintf("%v", rec))
+					slog.Error("executor panicked", "thread_id", i, "err", fmt.Sprintf("%v", rec), "stack", string(debug.Stack()))
 				}
 			}()
 
@@ -42,8 +42,13 @@ func StartWorkerThreads(workerCount int, cb func(threadId int)) *sync.WaitGroup
 type QueryResult struct {
 	Data map[string][]any
 
+	// global row ids of projected rows
+	RowIds []uint64
+
 	Metrics executor.ChunkFilterProcessResult
 
+	// *executor.ChunkError of the first failed chunk,
+	// also returned by Query. data and metrics are not set in that case
 	Error error
 }
 
@@ -53,6 +58,22 @@ func (sm *Manager) Query(
 	ctx context.Context,
 ) (*QueryResult, error) {
 
+	operationErr := sm.beginOperation()
+	if operationErr != nil {
+		return nil, operationErr
+	}
+	defer sm.endOperation()
+
+	sm.lifecycleLock.Lock()
+	workersStopped := sm.workersStopped
+	sm.lifecycleLock.Unlock()
+
+	select {
+	case <-workersStopped:
+		return nil, ErrNoWorkers
+	default:
+	}
+
 	before := time.Now()
 	result := &QueryResult{}
 
@@ -63,6 +84,10 @@ func (sm *Manager) Query(
 		return nil, fmt.Errorf("no such schema '%s'", schemaName)
 	}
 
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
 	plan, planErr := sm.Planner.Plan(
 		schemaName, queryData,
 		sm.Meta,
@@ -75,16 +100,29 @@ func (sm *Manager) Query(
 	// discard non intersecting blocks from the plan
 
 	if planErr != nil {
-		return nil, fmt.Errorf("unable to construct query execution plan : %s", planErr.Error())
+		return nil, fmt.Errorf("unable to construct query execution plan : %w", planErr)
+	}
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
 	}
 
 	bChunksSize := len(plan.BlockChunks)
 
-	taskStatus := &executor.TaskStatus{ChunksTotal: bChunksSize}
-	taskStatus.Waiter.Add(1)
+	taskStatus := executor.NewTaskStatus(bChunksSize)
+	taskStatus.ChunkResult.Aggregates = executor.NewAggregateStates(len(plan.Aggregates))
+
+	if len(plan.GroupBy) > 0 {
+		taskStatus.ChunkResult.Groups = query.NewGroupTable(len(plan.Aggregates))
+	}
+
+	if len(plan.Projections) > 0 {
+		taskStatus.Projections = make([]*query.Projection, bChunksSize)
+	}
 
 	for bChunkIdx := 0; bChunkIdx < bChunksSize; bChunkIdx++ {
-		sm.chunksQueue <- &executor.ChunkProcessingTask{
+
+		task := &executor.ChunkProcessingTask{
 			Bchunk: &plan.BlockChunks[bChunkIdx],
 			Slabs:  sm.Slabs,
 			Plan:   &plan,
@@ -92,12 +130,47 @@ func (sm *Manager) Query(
 			ChunkIdx: bChunkIdx,
 
 			Status: taskStatus,
+			Ctx:    ctx,
+		}
+
+		// queued chunks of cancelled query are skipped by workers
+		select {
+		case sm.chunksQueue <- task:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-workersStopped:
+			taskStatus.Fail(ErrNoWorkers)
+			return nil, ErrNoWorkers
 		}
 	}
+
 	timeBefore := time.Now()
-	taskStatus.Waiter.Wait()
+
+	select {
+	case <-taskStatus.Done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-workersStopped:
+		// chunks may be done right before workers stopped,
+		// otherwise the ones left are never processed
+		select {
+		case <-taskStatus.Done:
+		default:
+			taskStatus.Fail(ErrNoWorkers)
+			return nil, ErrNoWorkers
+		}
+	}
+
 	waitTookMs := time.Since(timeBefore)
 
+	// chunks still running may write to the status of failed task,
+	// so only the error is read
+	chunkErr := taskStatus.Error()
+	if chunkErr != nil {
+		result.Error = chunkErr
+		return result, chunkErr
+	}
+
 	queryTookMs := time.Since(before)
 
 	cummResult := taskStatus.ChunkResult
@@ -109,6 +182,14 @@ func (sm *Manager) Query(
 
 	result.Metrics = cummResult
 
+	if len(plan.Projections) > 0 {
+		result.RowIds, result.Data = projectionsToData(&plan, taskStatus.Projections)
+	} else if cummResult.Groups != nil {
+		result.Data = groupTableToData(&plan, cummResult.Groups)
+	} else {
+		result.Data = aggregatesToData(&plan, cummResult.Aggregates)
+	}
+
 	return result, nil
 }
 
@@ -9,6 +9,20 @@ const (
 	GT
 	LT
 	RANGE
+	NEQ
+	GTE
+	LTE
+
+	// value is one of arguments
+	IN
+	NOT_IN
+
+	// string value starts with argument
+	PREFIX
+
+	// row has no value in nullable column
+	IS_NULL
+	IS_NOT_NULL
 )
 
 func (c CondOperand) String() string {
@@ -21,7 +35,23 @@ func (c CondOperand) String() string {
 		return "LT"
 	case RANGE:
 		return "RANGE"
+	case NEQ:
+		return "NEQ"
+	case GTE:
+		return "GTE"
+	case LTE:
+		return "LTE"
+	case IN:
+		return "IN"
+	case NOT_IN:
+		return "NOT_IN"
+	case PREFIX:
+		return "PREFIX"
+	case IS_NULL:
+		return "IS_NULL"
+	case IS_NOT_NULL:
+		return "IS_NOT_NULL"
 	default:
-		panic(fmt.Sprintf("unknown operand %v", c))
+		panic(fmt.Sprintf("unknown operand %d", byte(c)))
 	}
 }
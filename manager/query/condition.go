@@ -1,13 +1,79 @@
 package query
 
-import "fmt"
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+)
+
+// endpoints of RANGE operand, both are included by default
+type RangeFlags byte
+
+const (
+	RangeInclusive   RangeFlags = 0
+	RangeExcludeFrom RangeFlags = 1
+	RangeExcludeTo   RangeFlags = 2
+	RangeExclusive   RangeFlags = RangeExcludeFrom | RangeExcludeTo
+)
 
 type FilterCondition struct {
 	Field     string
 	Operand   CondOperand
 	Arguments []any
+
+	// used by RANGE only
+	Range RangeFlags
+
+	// left side expression, used instead of Field when set
+	Expr *Expr
 }
 
+// checks amount of arguments required by operand
+func (fc FilterCondition) Validate() error {
+
+	switch fc.Operand {
+	case EQ, NEQ, GT, GTE, LT, LTE:
+		if len(fc.Arguments) != 1 {
+			return fmt.Errorf("%s filter on `%s` expects 1 argument, got %d", fc.Operand.String(), fc.Field, len(fc.Arguments))
+		}
+	case RANGE:
+		if len(fc.Arguments) != 2 {
+			return fmt.Errorf("RANGE filter on `%s` expects 2 arguments, got %d", fc.Field, len(fc.Arguments))
+		}
+	case IN, NOT_IN:
+		if len(fc.Arguments) == 0 {
+			return fmt.Errorf("%s filter on `%s` expects at least 1 argument", fc.Operand.String(), fc.Field)
+		}
+
+		if fc.HasExpressions() {
+			return fmt.Errorf("%s filter on `%s` can't be used with expressions", fc.Operand.String(), fc.Field)
+		}
+	case PREFIX:
+		if len(fc.Arguments) != 1 {
+			return fmt.Errorf("PREFIX filter on `%s` expects 1 argument, got %d", fc.Field, len(fc.Arguments))
+		}
+
+		if _, isString := fc.Arguments[0].(string); !isString {
+			return fmt.Errorf("PREFIX filter on `%s` expects string argument", fc.Field)
+		}
+	case IS_NULL, IS_NOT_NULL:
+		if len(fc.Arguments) != 0 || fc.Expr != nil {
+			return fmt.Errorf("%s filter on `%s` expects a column and no arguments", fc.Operand.String(), fc.Field)
+		}
+	default:
+		return fmt.Errorf("unknown operand %d on `%s`", byte(fc.Operand), fc.Field)
+	}
+
+	return nil
+}
+
+// true if condition checks presence of value instead of value itself
+func (fc FilterCondition) IsNullCheck() bool {
+	return fc.Operand == IS_NULL || fc.Operand == IS_NOT_NULL
+}
+
+// time.Time arguments are returned as seconds since unix epoch
 func (fc FilterCondition) ArgumentFloatValue(idx int) float64 {
 
 	arg := fc.Arguments[idx]
@@ -35,7 +101,68 @@ func (fc FilterCondition) ArgumentFloatValue(idx int) float64 {
 		return float64(v)
 	case float32:
 		return float64(v)
+	case time.Time:
+		return float64(v.Unix()) + float64(v.Nanosecond())/float64(time.Second)
 	default:
 		panic(fmt.Sprintf("filter cond argument is not numeric: %T", arg))
 	}
 }
+
+// orders plain number or time arguments, comparable is false for other kinds of arguments.
+// numbers of different types are compared exactly
+func CompareArguments(a, b any) (order int, comparable bool) {
+
+	if at, isTime := a.(time.Time); isTime {
+		bt, isTime := b.(time.Time)
+		if !isTime {
+			return 0, false
+		}
+		return at.Compare(bt), true
+	}
+
+	av, aNumber := exactArgumentValue(a)
+	bv, bNumber := exactArgumentValue(b)
+	if !aNumber || !bNumber {
+		return 0, false
+	}
+
+	return av.Cmp(bv), true
+}
+
+func exactArgumentValue(arg any) (*big.Float, bool) {
+
+	switch v := arg.(type) {
+	case int:
+		return new(big.Float).SetInt64(int64(v)), true
+	case int8:
+		return new(big.Float).SetInt64(int64(v)), true
+	case int16:
+		return new(big.Float).SetInt64(int64(v)), true
+	case int32:
+		return new(big.Float).SetInt64(int64(v)), true
+	case int64:
+		return new(big.Float).SetInt64(v), true
+	case uint:
+		return new(big.Float).SetUint64(uint64(v)), true
+	case uint8:
+		return new(big.Float).SetUint64(uint64(v)), true
+	case uint16:
+		return new(big.Float).SetUint64(uint64(v)), true
+	case uint32:
+		return new(big.Float).SetUint64(uint64(v)), true
+	case uint64:
+		return new(big.Float).SetUint64(v), true
+	case float32:
+		if math.IsNaN(float64(v)) {
+			return nil, false
+		}
+		return new(big.Float).SetFloat64(float64(v)), true
+	case float64:
+		if math.IsNaN(v) {
+			return nil, false
+		}
+		return new(big.Float).SetFloat64(v), true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,201 @@
+package query
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/dot5enko/simple-column-db/schema"
+)
+
+// rows evaluated at once by expression filters
+const ExprBatchSize = 1024
+
+type ExprType byte
+
+const (
+	ExprColumn ExprType = iota
+	ExprConst
+	ExprAdd
+	ExprSub
+	ExprMul
+	ExprDiv
+)
+
+func (t ExprType) String() string {
+	switch t {
+	case ExprColumn:
+		return "column"
+	case ExprConst:
+		return "const"
+	case ExprAdd:
+		return "+"
+	case ExprSub:
+		return "-"
+	case ExprMul:
+		return "*"
+	case ExprDiv:
+		return "/"
+	default:
+		return fmt.Sprintf("unknown expression %d", byte(t))
+	}
+}
+
+// arithmetic expression over columns of a single row,
+// evaluated in float64. timestamps are evaluated in seconds since unix epoch
+//
+// can be used as a left side of a filter condition or in place of its argument
+type Expr struct {
+	Type ExprType
+
+	Column string
+	Value  float64
+
+	Left  *Expr
+	Right *Expr
+}
+
+func Col(name string) *Expr {
+	return &Expr{Type: ExprColumn, Column: name}
+}
+
+func Const(value any) *Expr {
+	fc := FilterCondition{Arguments: []any{value}}
+	return &Expr{Type: ExprConst, Value: fc.ArgumentFloatValue(0)}
+}
+
+func Add(left, right *Expr) *Expr {
+	return &Expr{Type: ExprAdd, Left: left, Right: right}
+}
+
+func Sub(left, right *Expr) *Expr {
+	return &Expr{Type: ExprSub, Left: left, Right: right}
+}
+
+func Mul(left, right *Expr) *Expr {
+	return &Expr{Type: ExprMul, Left: left, Right: right}
+}
+
+func Div(left, right *Expr) *Expr {
+	return &Expr{Type: ExprDiv, Left: left, Right: right}
+}
+
+// planned expression, columns are resolved to schema indices
+type ExprRT struct {
+	Type ExprType
+
+	// index of the node in the expression, used for per node scratch buffers
+	Id int
+
+	ColumnIdx int
+	Value     float64
+
+	// multiplier of column values, used to bring timestamps to seconds.
+	// 0 keeps values as is
+	Scale float64
+
+	Left  *ExprRT
+	Right *ExprRT
+}
+
+// filter condition on expressions, planned as `Diff <Operand> 0`
+type ExpressionFilterRT struct {
+	LeafIdx int
+	Operand CondOperand
+
+	// left side minus right side of the condition
+	Diff *ExprRT
+
+	// distinct columns used by the expression
+	Columns []int
+}
+
+// interval arithmetic over column bounds.
+// returns false when the result can't be bound, e.g. on division by interval containing zero
+func (e *ExprRT) EvalBounds(columnBounds func(columnIdx int) (schema.BoundsFloat, bool)) (schema.BoundsFloat, bool) {
+
+	switch e.Type {
+	case ExprConst:
+		return schema.NewBoundsFromValues(e.Value, e.Value), true
+	case ExprColumn:
+		bounds, ok := columnBounds(e.ColumnIdx)
+		if ok && e.Scale != 0 {
+			bounds = schema.NewBoundsFromValues(bounds.Min*e.Scale, bounds.Max*e.Scale)
+		}
+		return bounds, ok
+	}
+
+	left, leftOk := e.Left.EvalBounds(columnBounds)
+	if !leftOk {
+		return schema.BoundsFloat{}, false
+	}
+
+	right, rightOk := e.Right.EvalBounds(columnBounds)
+	if !rightOk {
+		return schema.BoundsFloat{}, false
+	}
+
+	var minVal, maxVal float64
+
+	switch e.Type {
+	case ExprAdd:
+		minVal, maxVal = left.Min+right.Min, left.Max+right.Max
+	case ExprSub:
+		minVal, maxVal = left.Min-right.Max, left.Max-right.Min
+	case ExprMul:
+		minVal, maxVal = intervalEndpoints(left.Min*right.Min, left.Min*right.Max, left.Max*right.Min, left.Max*right.Max)
+	case ExprDiv:
+		if right.Min <= 0 && right.Max >= 0 {
+			return schema.BoundsFloat{}, false
+		}
+		minVal, maxVal = intervalEndpoints(left.Min/right.Min, left.Min/right.Max, left.Max/right.Min, left.Max/right.Max)
+	default:
+		return schema.BoundsFloat{}, false
+	}
+
+	if math.IsNaN(minVal) || math.IsNaN(maxVal) {
+		return schema.BoundsFloat{}, false
+	}
+
+	return schema.NewBoundsFromValues(minVal, maxVal), true
+}
+
+func intervalEndpoints(values ...float64) (minVal, maxVal float64) {
+	minVal, maxVal = values[0], values[0]
+	for _, v := range values[1:] {
+		minVal = min(minVal, v)
+		maxVal = max(maxVal, v)
+	}
+	return
+}
+
+// true if condition compares expressions instead of a column with literals
+func (fc FilterCondition) HasExpressions() bool {
+
+	if fc.Expr != nil {
+		return true
+	}
+
+	for _, arg := range fc.Arguments {
+		if _, isExpr := arg.(*Expr); isExpr {
+			return true
+		}
+	}
+
+	return false
+}
+
+// left side of the condition as an expression
+func (fc FilterCondition) LeftExpr() *Expr {
+	if fc.Expr != nil {
+		return fc.Expr
+	}
+	return Col(fc.Field)
+}
+
+// argument as an expression, literals become constants
+func (fc FilterCondition) ArgumentExpr(idx int) *Expr {
+	if expr, isExpr := fc.Arguments[idx].(*Expr); isExpr {
+		return expr
+	}
+	return Const(fc.Arguments[idx])
+}
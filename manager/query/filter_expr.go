@@ -0,0 +1,249 @@
+package query
+
+import (
+	"fmt"
+
+	"github.com/dot5enko/simple-column-db/schema"
+)
+
+const MaxFilterLeaves = 16
+
+type FilterNodeType byte
+
+const (
+	FilterLeaf FilterNodeType = iota
+	FilterAnd
+	FilterOr
+	FilterNot
+)
+
+func (t FilterNodeType) String() string {
+	switch t {
+	case FilterLeaf:
+		return "LEAF"
+	case FilterAnd:
+		return "AND"
+	case FilterOr:
+		return "OR"
+	case FilterNot:
+		return "NOT"
+	default:
+		return fmt.Sprintf("unknown filter node %d", byte(t))
+	}
+}
+
+// boolean filter expression
+type FilterExpr struct {
+	Type FilterNodeType
+
+	// set for leaves only
+	Condition FilterCondition
+
+	Children []FilterExpr
+}
+
+func Cond(field string, operand CondOperand, arguments ...any) FilterExpr {
+	return FilterExpr{
+		Type: FilterLeaf,
+		Condition: FilterCondition{
+			Field:     field,
+			Operand:   operand,
+			Arguments: arguments,
+		},
+	}
+}
+
+func CondRange(field string, from, to any, flags RangeFlags) FilterExpr {
+	return FilterExpr{
+		Type: FilterLeaf,
+		Condition: FilterCondition{
+			Field:     field,
+			Operand:   RANGE,
+			Arguments: []any{from, to},
+			Range:     flags,
+		},
+	}
+}
+
+func And(children ...FilterExpr) FilterExpr {
+	return FilterExpr{Type: FilterAnd, Children: children}
+}
+
+func Or(children ...FilterExpr) FilterExpr {
+	return FilterExpr{Type: FilterOr, Children: children}
+}
+
+func Not(child FilterExpr) FilterExpr {
+	return FilterExpr{Type: FilterNot, Children: []FilterExpr{child}}
+}
+
+// rewrites the tree so NOT is applied to leaves only, NOT of a comparison becomes the opposite comparison.
+// rows without value fail a comparison and its opposite alike, as they do in sql,
+// while NOT over a leaf would match them
+func (e FilterExpr) PushNotToLeaves() FilterExpr {
+	return pushNot(e, false)
+}
+
+func pushNot(e FilterExpr, negate bool) FilterExpr {
+
+	switch e.Type {
+	case FilterNot:
+		// malformed NOT is left for the planner to report
+		if len(e.Children) != 1 {
+			if negate {
+				return Not(e)
+			}
+			return e
+		}
+
+		return pushNot(e.Children[0], !negate)
+
+	case FilterAnd, FilterOr:
+		nodeType := e.Type
+		if negate {
+			nodeType = FilterOr
+			if e.Type == FilterOr {
+				nodeType = FilterAnd
+			}
+		}
+
+		children := make([]FilterExpr, len(e.Children))
+		for idx := range e.Children {
+			children[idx] = pushNot(e.Children[idx], negate)
+		}
+
+		return FilterExpr{Type: nodeType, Children: children}
+
+	case FilterLeaf:
+		// invalid condition is left for the planner to report
+		if !negate || e.Condition.Validate() != nil {
+			return e
+		}
+
+		return negateCondition(e.Condition)
+	}
+
+	return e
+}
+
+func negateCondition(condition FilterCondition) FilterExpr {
+
+	negated := condition
+
+	switch condition.Operand {
+	case EQ:
+		negated.Operand = NEQ
+	case NEQ:
+		negated.Operand = EQ
+	case GT:
+		negated.Operand = LTE
+	case GTE:
+		negated.Operand = LT
+	case LT:
+		negated.Operand = GTE
+	case LTE:
+		negated.Operand = GT
+	case IN:
+		negated.Operand = NOT_IN
+	case NOT_IN:
+		negated.Operand = IN
+	case IS_NULL:
+		negated.Operand = IS_NOT_NULL
+	case IS_NOT_NULL:
+		negated.Operand = IS_NULL
+
+	case RANGE:
+		from, to := condition.Arguments[0], condition.Arguments[1]
+
+		// kernels match reversed endpoints as the range between them,
+		// flags stay at the lower and the upper end
+		if order, comparable := CompareArguments(from, to); comparable && order > 0 {
+			from, to = to, from
+		}
+
+		below, above := condition, condition
+		below.Operand, below.Arguments, below.Range = LT, []any{from}, RangeInclusive
+		above.Operand, above.Arguments, above.Range = GT, []any{to}, RangeInclusive
+
+		if condition.Range&RangeExcludeFrom != 0 {
+			below.Operand = LTE
+		}
+		if condition.Range&RangeExcludeTo != 0 {
+			above.Operand = GTE
+		}
+
+		return Or(FilterExpr{Type: FilterLeaf, Condition: below}, FilterExpr{Type: FilterLeaf, Condition: above})
+
+	default:
+		// no opposite operand, rows without value are excluded explicitly
+		return And(
+			Not(FilterExpr{Type: FilterLeaf, Condition: condition}),
+			Cond(condition.Field, IS_NOT_NULL),
+		)
+	}
+
+	return FilterExpr{Type: FilterLeaf, Condition: negated}
+}
+
+// planned filter tree, leaves refer to conditions by index
+type FilterNodeRT struct {
+	Type FilterNodeType
+
+	// index of the node in the tree, used for per node scratch buffers
+	Id int
+
+	LeafIdx  int
+	Children []FilterNodeRT
+}
+
+// evaluates the tree on bounds match results of leaves
+//
+// a block can be skipped only when the whole tree excludes it,
+// so for OR every branch has to exclude the block
+func (n *FilterNodeRT) EvalBounds(leafResult func(leafIdx int) schema.BoundsFilterMatchResult) schema.BoundsFilterMatchResult {
+
+	switch n.Type {
+	case FilterLeaf:
+		result := leafResult(n.LeafIdx)
+		if result == schema.UnknownIntersection {
+			return schema.PartialIntersection
+		}
+		return result
+
+	case FilterAnd:
+		result := schema.FullIntersection
+		for idx := range n.Children {
+			switch n.Children[idx].EvalBounds(leafResult) {
+			case schema.NoIntersection:
+				return schema.NoIntersection
+			case schema.PartialIntersection:
+				result = schema.PartialIntersection
+			}
+		}
+		return result
+
+	case FilterOr:
+		result := schema.NoIntersection
+		for idx := range n.Children {
+			switch n.Children[idx].EvalBounds(leafResult) {
+			case schema.FullIntersection:
+				return schema.FullIntersection
+			case schema.PartialIntersection:
+				result = schema.PartialIntersection
+			}
+		}
+		return result
+
+	case FilterNot:
+		switch n.Children[0].EvalBounds(leafResult) {
+		case schema.NoIntersection:
+			return schema.FullIntersection
+		case schema.FullIntersection:
+			return schema.NoIntersection
+		default:
+			return schema.PartialIntersection
+		}
+	}
+
+	return schema.PartialIntersection
+}
@@ -0,0 +1,112 @@
+package query
+
+import (
+	"time"
+
+	"github.com/dot5enko/simple-column-db/schema"
+)
+
+const MaxGroupByColumns = 4
+
+type GroupBy struct {
+	Field string
+
+	// bucket width for integer columns (e.g. unix timestamps)
+	// 0 groups by exact value
+	Bucket uint64
+
+	// time functions of timestamp columns, see TimeBucket and DateTrunc
+	Interval time.Duration
+	Trunc    TimeUnit
+
+	// time zone of time functions and returned timestamps, UTC if not set
+	Location *time.Location
+
+	Alias string
+}
+
+type GroupByRT struct {
+	ColumnIdx        int
+	ColumnSchemaInfo *schema.SchemaColumn
+
+	Bucket uint64
+
+	Interval time.Duration
+	Trunc    TimeUnit
+	Location *time.Location
+
+	Alias string
+}
+
+// values of group by columns, encoded as uint64
+// see CompareEncodedValues
+type GroupKey [MaxGroupByColumns]uint64
+
+// hash aggregation table
+// states of a single group are stored continuously in States
+type GroupTable struct {
+	offsets map[GroupKey]int
+
+	Keys   []GroupKey
+	States []AggregateState
+
+	aggregates int
+}
+
+func NewGroupTable(aggregates int) *GroupTable {
+	return &GroupTable{
+		offsets:    map[GroupKey]int{},
+		Keys:       []GroupKey{},
+		States:     []AggregateState{},
+		aggregates: aggregates,
+	}
+}
+
+func (t *GroupTable) Reset(aggregates int) {
+	clear(t.offsets)
+
+	t.Keys = t.Keys[:0]
+	t.States = t.States[:0]
+	t.aggregates = aggregates
+}
+
+func (t *GroupTable) Size() int {
+	return len(t.Keys)
+}
+
+// states of the group, created if not exists
+func (t *GroupTable) Get(key GroupKey) []AggregateState {
+
+	offset, ok := t.offsets[key]
+	if !ok {
+		offset = len(t.Keys)
+
+		t.offsets[key] = offset
+		t.Keys = append(t.Keys, key)
+
+		for range t.aggregates {
+			t.States = append(t.States, NewAggregateState())
+		}
+	}
+
+	start := offset * t.aggregates
+
+	return t.States[start : start+t.aggregates]
+}
+
+func (t *GroupTable) GroupStates(groupIdx int) []AggregateState {
+	start := groupIdx * t.aggregates
+	return t.States[start : start+t.aggregates]
+}
+
+func (t *GroupTable) Merge(other *GroupTable) {
+	for groupIdx, key := range other.Keys {
+
+		states := t.Get(key)
+		otherStates := other.GroupStates(groupIdx)
+
+		for i := range states {
+			states[i].Merge(otherStates[i])
+		}
+	}
+}
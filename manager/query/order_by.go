@@ -0,0 +1,88 @@
+package query
+
+import (
+	"cmp"
+	"math"
+
+	"github.com/dot5enko/simple-column-db/schema"
+)
+
+const MaxOrderByColumns = 4
+
+type OrderBy struct {
+	Field string
+	Desc  bool
+}
+
+type OrderByRT struct {
+	ColumnIdx        int
+	ColumnSchemaInfo *schema.SchemaColumn
+
+	Desc bool
+}
+
+// values of order by columns, encoded the same way as group keys
+type OrderKey [MaxOrderByColumns]uint64
+
+// negative if a goes before b in the result
+func CompareOrderKeys(orderBy []OrderByRT, a, b *OrderKey) int {
+	for keyIdx, it := range orderBy {
+		c := CompareEncodedValues(it.ColumnSchemaInfo.Type, a[keyIdx], b[keyIdx])
+		if c != 0 {
+			if it.Desc {
+				return -c
+			}
+			return c
+		}
+	}
+	return 0
+}
+
+// values of all types are encoded into uint64:
+// unsigned as is, signed as int64 bits, floats as float64 bits
+func CompareEncodedValues(typ schema.FieldType, a, b uint64) int {
+	switch {
+	case typ.IsFloat():
+		return cmp.Compare(math.Float64frombits(a), math.Float64frombits(b))
+	case typ.IsSigned():
+		return cmp.Compare(int64(a), int64(b))
+	default:
+		return cmp.Compare(a, b)
+	}
+}
+
+func EncodedValueFloat(typ schema.FieldType, v uint64) float64 {
+	switch {
+	case typ.IsFloat():
+		return math.Float64frombits(v)
+	case typ.IsSigned():
+		return float64(int64(v))
+	default:
+		return float64(v)
+	}
+}
+
+func DecodeEncodedValue(typ schema.FieldType, v uint64) any {
+	switch typ {
+	case schema.Float32FieldType:
+		return float32(math.Float64frombits(v))
+	case schema.Float64FieldType:
+		return math.Float64frombits(v)
+	case schema.Int8FieldType:
+		return int8(v)
+	case schema.Int16FieldType:
+		return int16(v)
+	case schema.Int32FieldType:
+		return int32(v)
+	case schema.Int64FieldType, schema.TimestampFieldType:
+		return int64(v)
+	case schema.Uint8FieldType:
+		return uint8(v)
+	case schema.Uint16FieldType:
+		return uint16(v)
+	case schema.Uint32FieldType:
+		return uint32(v)
+	default:
+		return v
+	}
+}
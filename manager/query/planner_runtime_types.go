@@ -1,6 +1,11 @@
 package query
 
-import "github.com/dot5enko/simple-column-db/schema"
+import (
+	"math"
+
+	"github.com/dot5enko/simple-column-db/schema"
+	"github.com/google/uuid"
+)
 
 type RuntimeFilterCache struct {
 	// column                      schema.SchemaColumn
@@ -10,7 +15,21 @@ type RuntimeFilterCache struct {
 
 type FilterConditionRuntime struct {
 	Filter FilterCondition
+
+	// index of the leaf in the filter tree
+	LeafIdx int
 	// Runtime *RuntimeFilterCache
+
+	// conditions on string columns translated into codes of each slab dictionary
+	SlabFilters map[uuid.UUID]FilterCondition
+}
+
+// condition to match blocks of the slab with
+func (fc *FilterConditionRuntime) SlabFilter(slabUid uuid.UUID) FilterCondition {
+	if fc.SlabFilters != nil {
+		return fc.SlabFilters[slabUid]
+	}
+	return fc.Filter
 }
 
 type FilterGroupedRT struct {
@@ -21,3 +40,94 @@ type FilterGroupedRT struct {
 
 	Conditions []FilterConditionRuntime
 }
+
+type AggregateRT struct {
+	Function AggregateFunction
+	Alias    string
+
+	// -1 for count without a column
+	ColumnIdx        int
+	ColumnSchemaInfo *schema.SchemaColumn
+}
+
+// partial aggregate, merged across blocks and chunks
+type AggregateState struct {
+	Count int
+	Sum   float64
+	Min   float64
+	Max   float64
+}
+
+func NewAggregateState() AggregateState {
+	return AggregateState{
+		Min: math.MaxFloat64,
+		Max: -math.MaxFloat64,
+	}
+}
+
+func (s *AggregateState) Merge(other AggregateState) {
+	s.Count += other.Count
+	s.Sum += other.Sum
+
+	if other.Min < s.Min {
+		s.Min = other.Min
+	}
+	if other.Max > s.Max {
+		s.Max = other.Max
+	}
+}
+
+func (s *AggregateState) Add(value float64) {
+	s.Count++
+	s.Sum += value
+
+	if value < s.Min {
+		s.Min = value
+	}
+	if value > s.Max {
+		s.Max = value
+	}
+}
+
+// final value of the aggregate, nil if nothing matched
+func (s *AggregateState) Result(fn AggregateFunction) any {
+	switch fn {
+	case AggCount:
+		return s.Count
+	case AggSum:
+		return s.Sum
+	}
+
+	if s.Count == 0 {
+		return nil
+	}
+
+	switch fn {
+	case AggAvg:
+		return s.Sum / float64(s.Count)
+	case AggMin:
+		return s.Min
+	case AggMax:
+		return s.Max
+	default:
+		return nil
+	}
+}
+
+type ProjectionRT struct {
+	ColumnIdx        int
+	ColumnSchemaInfo *schema.SchemaColumn
+
+	Alias string
+}
+
+// projected rows of a single chunk
+type Projection struct {
+	RowIds []uint64
+
+	// set only for ordered projections
+	OrderKeys []OrderKey
+
+	// values per projected column
+	Values [][]any
+}
@@ -1,9 +1,13 @@
 package query
 
+import "fmt"
+
 type SelectorType byte
 
 const (
 	SelectFunction SelectorType = iota
+	// returns column values of every matched row
+	SelectColumn
 )
 
 type Selector struct {
@@ -12,3 +16,47 @@ type Selector struct {
 
 	Alias string
 }
+
+type AggregateFunction byte
+
+const (
+	AggCount AggregateFunction = iota
+	AggSum
+	AggAvg
+	AggMin
+	AggMax
+)
+
+func (a AggregateFunction) String() string {
+	switch a {
+	case AggCount:
+		return "count"
+	case AggSum:
+		return "sum"
+	case AggAvg:
+		return "avg"
+	case AggMin:
+		return "min"
+	case AggMax:
+		return "max"
+	default:
+		return fmt.Sprintf("unknown aggregate %d", byte(a))
+	}
+}
+
+func ParseAggregateFunction(name string) (AggregateFunction, error) {
+	switch name {
+	case "count":
+		return AggCount, nil
+	case "sum":
+		return AggSum, nil
+	case "avg":
+		return AggAvg, nil
+	case "min":
+		return AggMin, nil
+	case "max":
+		return AggMax, nil
+	default:
+		return AggCount, fmt.Errorf("unknown aggregate function `%s`", name)
+	}
+}
@@ -0,0 +1,205 @@
+package query
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/dot5enko/simple-column-db/schema"
+)
+
+// calendar unit of date_trunc
+type TimeUnit byte
+
+const (
+	TruncNone TimeUnit = iota
+	TruncSecond
+	TruncMinute
+	TruncHour
+	TruncDay
+	// weeks start on monday
+	TruncWeek
+	TruncMonth
+	TruncQuarter
+	TruncYear
+)
+
+func (u TimeUnit) String() string {
+	switch u {
+	case TruncNone:
+		return "none"
+	case TruncSecond:
+		return "second"
+	case TruncMinute:
+		return "minute"
+	case TruncHour:
+		return "hour"
+	case TruncDay:
+		return "day"
+	case TruncWeek:
+		return "week"
+	case TruncMonth:
+		return "month"
+	case TruncQuarter:
+		return "quarter"
+	case TruncYear:
+		return "year"
+	default:
+		return fmt.Sprintf("unknown time unit %d", byte(u))
+	}
+}
+
+func ParseTimeUnit(name string) (TimeUnit, error) {
+	for u := TruncSecond; u <= TruncYear; u++ {
+		if u.String() == name {
+			return u, nil
+		}
+	}
+	return TruncNone, fmt.Errorf("unknown time unit `%s`", name)
+}
+
+// time_bucket(interval, field) : groups timestamps into fixed width buckets,
+// aligned to unix epoch in the time zone of group
+func TimeBucket(field string, interval time.Duration) GroupBy {
+	return GroupBy{Field: field, Interval: interval}
+}
+
+// date_trunc(unit, field) : groups timestamps by calendar unit in the time zone of group
+func DateTrunc(field string, unit TimeUnit) GroupBy {
+	return GroupBy{Field: field, Trunc: unit}
+}
+
+// converts group to the time zone, affects bucket boundaries and returned values
+func (g GroupBy) In(loc *time.Location) GroupBy {
+	g.Location = loc
+	return g
+}
+
+// true if group keys are computed from timestamps by time_bucket or date_trunc
+func (g *GroupByRT) HasTimeFunction() bool {
+	return g.Interval > 0 || g.Trunc != TruncNone
+}
+
+// replaces timestamps of the column with starts of their buckets.
+// timestamps usually come ordered, so boundaries of the last bucket are reused
+func (g *GroupByRT) TruncateTimeKeys(keys []uint64) {
+
+	var bucket, from, to int64
+	found := false
+
+	for i, key := range keys {
+
+		v := int64(key)
+		if !found || v < from || v >= to {
+			bucket, from, to = g.timeBucket(v)
+			found = true
+		}
+
+		keys[i] = uint64(bucket)
+	}
+}
+
+// start of the bucket containing timestamp
+// and range [from, to) of timestamps sharing it, in column units
+func (g *GroupByRT) timeBucket(v int64) (int64, int64, int64) {
+
+	precision := g.ColumnSchemaInfo.Precision
+	unit := int64(precision.Unit())
+
+	// units shorter than a day are fixed width buckets in local time
+	width := g.Interval
+	switch g.Trunc {
+	case TruncSecond:
+		width = time.Second
+	case TruncMinute:
+		width = time.Minute
+	case TruncHour:
+		width = time.Hour
+	}
+
+	if width > 0 {
+
+		interval := int64(width) / unit
+
+		var offset int64
+		zoneFrom, zoneTo := int64(math.MinInt64), int64(math.MaxInt64)
+
+		if g.Location != time.UTC {
+			t := precision.ToTime(v).In(g.Location)
+			_, offsetSeconds := t.Zone()
+			offset = int64(offsetSeconds) * (int64(time.Second) / unit)
+
+			// offset changes on zone transitions, e.g. daylight saving time
+			zoneStart, zoneEnd := t.ZoneBounds()
+			if !zoneStart.IsZero() {
+				zoneFrom = precision.FromTime(zoneStart)
+			}
+			if !zoneEnd.IsZero() {
+				zoneTo = precision.FromTime(zoneEnd)
+			}
+		}
+
+		local := v + offset
+
+		rem := local % interval
+		if rem < 0 {
+			rem += interval
+		}
+
+		// bucket starts at local wall time, range of timestamps sharing it is bound by zone of the timestamp
+		localStart := local - rem
+		from, to := localStart-offset, localStart-offset+interval
+
+		// bucket starting before zone change, e.g. a day with daylight saving change,
+		// starts with offset of the earlier zone. start skipped by the change is shifted forward to the change
+		bucket := from
+		if from < zoneFrom {
+			_, earlierSeconds := precision.ToTime(from).In(g.Location).Zone()
+			bucket = min(localStart-int64(earlierSeconds)*(int64(time.Second)/unit), zoneFrom)
+		}
+
+		return bucket, max(from, zoneFrom), min(to, zoneTo)
+	}
+
+	t := precision.ToTime(v).In(g.Location)
+	year, month, day := t.Date()
+
+	var start, end time.Time
+
+	switch g.Trunc {
+	case TruncDay:
+		start = time.Date(year, month, day, 0, 0, 0, 0, g.Location)
+		end = start.AddDate(0, 0, 1)
+	case TruncWeek:
+		sinceMonday := (int(t.Weekday()) + 6) % 7
+		start = time.Date(year, month, day-sinceMonday, 0, 0, 0, 0, g.Location)
+		end = start.AddDate(0, 0, 7)
+	case TruncMonth:
+		start = time.Date(year, month, 1, 0, 0, 0, 0, g.Location)
+		end = start.AddDate(0, 1, 0)
+	case TruncQuarter:
+		start = time.Date(year, (month-1)/3*3+1, 1, 0, 0, 0, 0, g.Location)
+		end = start.AddDate(0, 3, 0)
+	default:
+		start = time.Date(year, 1, 1, 0, 0, 0, 0, g.Location)
+		end = start.AddDate(1, 0, 0)
+	}
+
+	from, to := precision.FromTime(start), precision.FromTime(end)
+
+	// midnight that doesn't exist in the zone is shifted forward,
+	// such timestamps are kept in a bucket of their own
+	if v < from || v >= to {
+		return v, v, v + 1
+	}
+
+	return from, from, to
+}
+
+// group value as returned by query, timestamps are converted to the time zone of group
+func (g *GroupByRT) DecodeKey(v uint64) any {
+	if g.ColumnSchemaInfo.Type == schema.TimestampFieldType {
+		return g.ColumnSchemaInfo.Precision.ToTime(int64(v)).In(g.Location)
+	}
+	return DecodeEncodedValue(g.ColumnSchemaInfo.Type, v)
+}
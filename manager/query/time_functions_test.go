@@ -0,0 +1,177 @@
+package query
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dot5enko/simple-column-db/schema"
+)
+
+// timestamps every 10 minutes over [from, to) in column units
+func timeKeys(precision schema.TimePrecision, from, to time.Time) []uint64 {
+
+	keys := []uint64{}
+	for t := from; t.Before(to); t = t.Add(10 * time.Minute) {
+		keys = append(keys, uint64(precision.FromTime(t)))
+	}
+
+	return keys
+}
+
+func truncatedKeys(group GroupByRT, keys []uint64) []time.Time {
+
+	truncated := append([]uint64{}, keys...)
+	group.TruncateTimeKeys(truncated)
+
+	result := make([]time.Time, len(truncated))
+	for i, it := range truncated {
+		result[i] = group.DecodeKey(it).(time.Time)
+	}
+
+	return result
+}
+
+// fixed width buckets are aligned to local wall time across daylight saving changes
+func TestTimeBucketAcrossDaylightSaving(t *testing.T) {
+
+	berlin, locationErr := time.LoadLocation("Europe/Berlin")
+	if locationErr != nil {
+		t.Skipf("no time zone data : %s", locationErr.Error())
+	}
+
+	column := &schema.SchemaColumn{Name: "at", Type: schema.TimestampFieldType, Precision: schema.PrecisionMillis}
+
+	for _, it := range []struct {
+		name     string
+		interval time.Duration
+		from, to time.Time
+	}{
+		{"spring day", 24 * time.Hour, time.Date(2024, 3, 29, 12, 0, 0, 0, berlin), time.Date(2024, 4, 2, 12, 0, 0, 0, berlin)},
+		{"autumn day", 24 * time.Hour, time.Date(2024, 10, 25, 12, 0, 0, 0, berlin), time.Date(2024, 10, 29, 12, 0, 0, 0, berlin)},
+		{"spring hours", 3 * time.Hour, time.Date(2024, 3, 30, 18, 0, 0, 0, berlin), time.Date(2024, 3, 31, 12, 0, 0, 0, berlin)},
+		{"autumn hours", time.Hour, time.Date(2024, 10, 26, 22, 0, 0, 0, berlin), time.Date(2024, 10, 27, 6, 0, 0, 0, berlin)},
+	} {
+		t.Run(it.name, func(t *testing.T) {
+
+			keys := timeKeys(column.Precision, it.from, it.to)
+			buckets := truncatedKeys(GroupByRT{ColumnSchemaInfo: column, Interval: it.interval, Location: berlin}, keys)
+
+			for i, bucket := range buckets {
+
+				ts := column.Precision.ToTime(int64(keys[i])).In(berlin)
+
+				if bucket.After(ts) {
+					t.Fatalf("%s is put into later bucket %s", ts.String(), bucket.String())
+				}
+
+				wall := time.Date(ts.Year(), ts.Month(), ts.Day(), ts.Hour(), ts.Minute(), 0, 0, time.UTC)
+				expected := wall.Truncate(it.interval)
+
+				if bucket.Year() != expected.Year() || bucket.YearDay() != expected.YearDay() || bucket.Hour() != expected.Hour() || bucket.Minute() != 0 {
+					t.Fatalf("%s is put into bucket %s, expected local %s", ts.String(), bucket.String(), expected.Format(time.DateTime))
+				}
+			}
+		})
+	}
+
+	// bucket starting at wall time skipped by the change starts at the change
+	keys := timeKeys(column.Precision, time.Date(2024, 3, 31, 3, 0, 0, 0, berlin), time.Date(2024, 3, 31, 4, 0, 0, 0, berlin))
+	for _, bucket := range truncatedKeys(GroupByRT{ColumnSchemaInfo: column, Interval: 2 * time.Hour, Location: berlin}, keys) {
+		if expected := time.Date(2024, 3, 31, 3, 0, 0, 0, berlin); !bucket.Equal(expected) {
+			t.Fatalf("expected bucket %s, got %s", expected.String(), bucket.String())
+		}
+	}
+
+	// a day bucket is a calendar day there
+	keys = timeKeys(column.Precision, time.Date(2024, 3, 29, 0, 0, 0, 0, berlin), time.Date(2024, 4, 2, 0, 0, 0, 0, berlin))
+
+	buckets := truncatedKeys(GroupByRT{ColumnSchemaInfo: column, Interval: 24 * time.Hour, Location: berlin}, keys)
+	days := truncatedKeys(GroupByRT{ColumnSchemaInfo: column, Trunc: TruncDay, Location: berlin}, keys)
+
+	distinct := map[time.Time]bool{}
+	for i := range buckets {
+		if !buckets[i].Equal(days[i]) {
+			t.Fatalf("time_bucket(24h) gives %s, date_trunc(day) gives %s", buckets[i].String(), days[i].String())
+		}
+		distinct[buckets[i]] = true
+	}
+
+	if len(distinct) != 4 {
+		t.Errorf("expected 4 days, got %d", len(distinct))
+	}
+}
+
+func TestTimeBucketUTC(t *testing.T) {
+
+	column := &schema.SchemaColumn{Name: "at", Type: schema.TimestampFieldType, Precision: schema.PrecisionSeconds}
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	keys := timeKeys(column.Precision, from, from.Add(6*time.Hour))
+
+	buckets := truncatedKeys(GroupByRT{ColumnSchemaInfo: column, Interval: 90 * time.Minute, Location: time.UTC}, keys)
+
+	for i, bucket := range buckets {
+		ts := column.Precision.ToTime(int64(keys[i]))
+		if !bucket.Equal(ts.Truncate(90 * time.Minute)) {
+			t.Fatalf("%s is put into bucket %s", ts.String(), bucket.String())
+		}
+	}
+}
+
+func TestDateTrunc(t *testing.T) {
+
+	berlin, locationErr := time.LoadLocation("Europe/Berlin")
+	if locationErr != nil {
+		t.Skipf("no time zone data : %s", locationErr.Error())
+	}
+
+	column := &schema.SchemaColumn{Name: "at", Type: schema.TimestampFieldType, Precision: schema.PrecisionMicros}
+
+	// a week around both daylight saving changes and new year
+	for _, from := range []time.Time{
+		time.Date(2024, 3, 27, 0, 0, 0, 0, berlin),
+		time.Date(2024, 10, 24, 0, 0, 0, 0, berlin),
+		time.Date(2024, 12, 28, 0, 0, 0, 0, berlin),
+	} {
+		keys := timeKeys(column.Precision, from, from.AddDate(0, 0, 7))
+
+		for _, it := range []struct {
+			unit  TimeUnit
+			start func(ts time.Time) time.Time
+		}{
+			// offsets of the zone are whole hours
+			{TruncMinute, func(ts time.Time) time.Time { return ts.Truncate(time.Minute) }},
+			{TruncHour, func(ts time.Time) time.Time { return ts.Truncate(time.Hour) }},
+			{TruncDay, func(ts time.Time) time.Time { return time.Date(ts.Year(), ts.Month(), ts.Day(), 0, 0, 0, 0, berlin) }},
+			{TruncWeek, func(ts time.Time) time.Time {
+				return time.Date(ts.Year(), ts.Month(), ts.Day()-(int(ts.Weekday())+6)%7, 0, 0, 0, 0, berlin)
+			}},
+			{TruncMonth, func(ts time.Time) time.Time { return time.Date(ts.Year(), ts.Month(), 1, 0, 0, 0, 0, berlin) }},
+			{TruncQuarter, func(ts time.Time) time.Time { return time.Date(ts.Year(), (ts.Month()-1)/3*3+1, 1, 0, 0, 0, 0, berlin) }},
+			{TruncYear, func(ts time.Time) time.Time { return time.Date(ts.Year(), 1, 1, 0, 0, 0, 0, berlin) }},
+		} {
+			buckets := truncatedKeys(GroupByRT{ColumnSchemaInfo: column, Trunc: it.unit, Location: berlin}, keys)
+
+			for i, bucket := range buckets {
+				ts := column.Precision.ToTime(int64(keys[i])).In(berlin)
+				if expected := it.start(ts); !bucket.Equal(expected) {
+					t.Fatalf("date_trunc(%s) of %s is %s, expected %s", it.unit.String(), ts.String(), bucket.String(), expected.String())
+				}
+			}
+		}
+	}
+}
+
+func TestParseTimeUnit(t *testing.T) {
+
+	for u := TruncSecond; u <= TruncYear; u++ {
+		parsed, parseErr := ParseTimeUnit(u.String())
+		if parseErr != nil || parsed != u {
+			t.Errorf("unit %s is parsed as %s, err %v", u.String(), parsed.String(), parseErr)
+		}
+	}
+
+	if _, parseErr := ParseTimeUnit("fortnight"); parseErr == nil {
+		t.Errorf("expected error for unknown unit")
+	}
+}
@@ -19,9 +19,14 @@ type (
 
 		StartBlock int
 		Size       int
+
+		// absolute index of the first block of the segment
+		AbsBlock uint64
 	}
 
 	BlockChunk struct {
+		// absolute index of the first block in the chunk
+		// blocks of a chunk may be not continuous due to prunning
 		GlobalBlockOffset uint64
 
 		// for each field there will be an array of segments
@@ -33,15 +38,40 @@ type (
 	QueryPlan struct {
 		Schema                schema.Schema
 		FilterGroupedByFields []FilterGroupedRT
-		BlockChunks           []BlockChunk
 
+		// nil when query has no filters
+		FilterTree  *FilterNodeRT
+		FilterNodes int
+
+		// filter leaves on expressions, evaluated over several columns
+		ExpressionFilters []ExpressionFilterRT
+		ExpressionNodes   int
+		BlockChunks       []BlockChunk
+		Aggregates        []AggregateRT
+		GroupBy           []GroupByRT
+		Projections       []ProjectionRT
+		OrderBy           []OrderByRT
+
+		// 0 means no limit
+		Limit int
+
+		// columns that have to be loaded for selectors
+		SelectColumns []int
+
+		// amount of filter tree leaves
 		FilterSize int
+
+		// rows committed when the query was planned,
+		// every column is scanned up to the same row
+		Rows uint64
 	}
 
 	// chunk
 	SingleChunk struct {
 		Segments     []Segment
 		BlocksFilled int
+
+		FirstAbsBlock uint64
 	}
 
 	ColumnChunks struct {
@@ -52,7 +82,13 @@ type (
 	}
 
 	Query struct {
+		// implicit AND of conditions, combined with Where
 		Filter []FilterCondition
-		Select []Selector
+		Where  *FilterExpr
+
+		Select  []Selector
+		GroupBy []GroupBy
+		OrderBy []OrderBy
+		Limit   int
 	}
 )
@@ -1,6 +1,7 @@
 package manager
 
 import (
+	"cmp"
 	"fmt"
 	"log/slog"
 	"slices"
@@ -8,6 +9,7 @@ import (
 	"time"
 
 	"github.com/dot5enko/simple-column-db/manager/executor/filters"
+	"github.com/dot5enko/simple-column-db/manager/executor/kernels"
 	"github.com/dot5enko/simple-column-db/manager/meta"
 	"github.com/dot5enko/simple-column-db/manager/query"
 	"github.com/dot5enko/simple-column-db/schema"
@@ -28,24 +30,55 @@ func (qp *QueryPlanner) Plan(
 	slabManager *meta.SlabManager,
 	options *query.QueryOptions,
 ) (query.QueryPlan, error) {
-	schemaObject := metaManager.GetSchema(schemaName)
+	// read before slab lists, so every committed row is in the listed slabs.
+	// rows and slabs added by ingests after this point are not seen by the query
+	committedRows := metaManager.CommittedRows(schemaName)
+	schemaObject := metaManager.SchemaSnapshot(schemaName)
 	if schemaObject == nil {
 		return query.QueryPlan{}, query.ErrSchemaNotFound
 	} else {
 
-		// check that all fields are valid
-		for _, filter := range queryData.Filter {
+		filterTree, filterLeaves, expressionFilters, filterErr := planFilterTree(schemaObject, queryData)
+		if filterErr != nil {
+			return query.QueryPlan{}, filterErr
+		}
 
-			found := false
-			for _, it := range schemaObject.Columns {
-				if it.Name == filter.Field {
-					found = true
-					break
-				}
+		aggregates, projections, selectColumns, selectErr := planSelectors(schemaObject, queryData.Select)
+		if selectErr != nil {
+			return query.QueryPlan{}, selectErr
+		}
+
+		groupBy, groupColumns, groupErr := planGroupBy(schemaObject, queryData.GroupBy)
+		if groupErr != nil {
+			return query.QueryPlan{}, groupErr
+		}
+
+		if len(projections) > 0 && (len(aggregates) > 0 || len(groupBy) > 0) {
+			return query.QueryPlan{}, fmt.Errorf("column selectors can't be mixed with aggregates or group by")
+		}
+
+		if queryData.Limit < 0 {
+			return query.QueryPlan{}, fmt.Errorf("negative limit %d", queryData.Limit)
+		}
+
+		orderBy, orderErr := planOrderBy(schemaObject, queryData.OrderBy)
+		if orderErr != nil {
+			return query.QueryPlan{}, orderErr
+		}
+
+		if len(orderBy) > 0 && len(projections) == 0 {
+			return query.QueryPlan{}, fmt.Errorf("order by is supported only with column selectors")
+		}
+
+		for _, it := range orderBy {
+			if !slices.Contains(selectColumns, it.ColumnIdx) {
+				selectColumns = append(selectColumns, it.ColumnIdx)
 			}
+		}
 
-			if !found {
-				return query.QueryPlan{}, fmt.Errorf("column `%v` not found on schema `%v`", filter.Field, schemaName)
+		for _, columnIdx := range groupColumns {
+			if !slices.Contains(selectColumns, columnIdx) {
+				selectColumns = append(selectColumns, columnIdx)
 			}
 		}
 
@@ -93,14 +126,21 @@ func (qp *QueryPlanner) Plan(
 
 		// group filters by columns
 		filtersByColumns := map[string][]query.FilterConditionRuntime{}
-		for _, filter := range queryData.Filter {
+		for leafIdx, filter := range filterLeaves {
+
+			// evaluated separately over several columns
+			if filter.HasExpressions() {
+				continue
+			}
+
 			old, isOk := filtersByColumns[filter.Field]
 			if !isOk {
 				old = []query.FilterConditionRuntime{}
 			}
 
 			filtersByColumns[filter.Field] = append(old, query.FilterConditionRuntime{
-				Filter: filter,
+				Filter:  filter,
+				LeafIdx: leafIdx,
 			})
 		}
 
@@ -119,6 +159,46 @@ func (qp *QueryPlanner) Plan(
 				}
 			}
 
+			typeKernels, typeErr := kernels.Of(columnInfo.Type)
+			if typeErr != nil {
+				return query.QueryPlan{}, fmt.Errorf("unable to filter column `%s` : %s", fname, typeErr.Error())
+			}
+
+			for condIdx := range it {
+
+				condition := &it[condIdx]
+
+				// checks presence of values, arguments are not involved
+				if condition.Filter.IsNullCheck() {
+					continue
+				}
+
+				if columnInfo.Type != schema.StringFieldType {
+					if condition.Filter.Operand == query.PREFIX {
+						return query.QueryPlan{}, fmt.Errorf("PREFIX filter is supported only on string columns, `%s` is %s", fname, columnInfo.Type.String())
+					}
+
+					if columnInfo.Type == schema.TimestampFieldType {
+						condition.Filter.Arguments = timeArguments(condition.Filter.Arguments, columnInfo.Precision)
+					}
+
+					converted, argumentsErr := typeKernels.Condition(condition.Filter)
+					if argumentsErr != nil {
+						return query.QueryPlan{}, fmt.Errorf("invalid %s filter on `%s` : %s", condition.Filter.Operand.String(), fname, argumentsErr.Error())
+					}
+
+					condition.Filter = converted
+					continue
+				}
+
+				slabFilters, stringFilterErr := planStringFilter(schemaObject, slabManager, &columnInfo, condition.Filter)
+				if stringFilterErr != nil {
+					return query.QueryPlan{}, stringFilterErr
+				}
+
+				condition.SlabFilters = slabFilters
+			}
+
 			filterByColumnsArray = append(filterByColumnsArray, query.FilterGroupedRT{
 				FieldName:        fname,
 				Conditions:       it,
@@ -151,63 +231,105 @@ func (qp *QueryPlanner) Plan(
 
 		absBlocksFullSkipArray := make([]SkipArrayCacheEntry, maxBlocks)
 
+		// bounds match results of each filter leaf, per absolute block
+		leavesSize := len(filterLeaves)
+		absBlocksLeafResults := make([]schema.BoundsFilterMatchResult, maxBlocks*leavesSize)
+
 		// filter slab headers
 		blockPrunningStart := time.Now()
 		for _, filtersGroup := range filterByColumnsArray {
 			slabs := slabsByColumns[filtersGroup.FieldName]
 
+			// type is checked when conditions are planned
+			typeKernels, _ := kernels.Of(filtersGroup.ColumnSchemaInfo.Type)
+
 			for _, slabUid := range slabs {
 				for _, filter := range filtersGroup.Conditions {
 
 					slabInfo, slabLoadErr := slabManager.LoadSlabHeaderToCache(schemaObject, slabUid)
 					if slabLoadErr != nil {
-						return query.QueryPlan{}, fmt.Errorf("error loading slab into cache : %s", slabLoadErr.Error())
+						return query.QueryPlan{}, fmt.Errorf("error loading slab into cache : %w", slabLoadErr)
 					}
 
+					slabFilter := filter.SlabFilter(slabUid)
+
+					// ingest finalizes blocks of the active slab meanwhile
+					slabInfo.RLock()
+
 					blockHeaders := slabInfo.BlockHeaders
 					for i := 0; i < int(slabInfo.BlocksFinalized); i++ {
 
-						blockHeader := &blockHeaders[i]
-
-						if i > int(slabInfo.BlocksFinalized) {
-							break
+						matchResult, matchErr := typeKernels.FilterHeader(slabFilter, &blockHeaders[i])
+						if matchErr != nil {
+							slabInfo.RUnlock()
+							return query.QueryPlan{}, fmt.Errorf("error filtering bounds on block header : %s", matchErr.Error())
 						}
 
-						var matchResult schema.BoundsFilterMatchResult
-						var matchErr error
+						absOffset := i + int(slabInfo.SlabOffsetBlocks)
+						absBlocksLeafResults[absOffset*leavesSize+filter.LeafIdx] = matchResult
+					}
 
-						ftype := filtersGroup.ColumnSchemaInfo.Type
+					slabInfo.RUnlock()
+				}
+			}
+		}
 
-						switch ftype {
-						case schema.Uint64FieldType:
-							matchResult, matchErr = filters.ProcessFilterOnBounds[uint64](filter.Filter, &blockHeader.Bounds)
-						case schema.Float32FieldType:
-							matchResult, matchErr = filters.ProcessFilterOnBounds[float32](filter.Filter, &blockHeader.Bounds)
+		if len(expressionFilters) > 0 {
+			expressionErr := pruneExpressionFilters(schemaObject, slabManager, expressionFilters, maxBlocks, func(absIdx int, leafIdx int, matchResult schema.BoundsFilterMatchResult) {
+				absBlocksLeafResults[absIdx*leavesSize+leafIdx] = matchResult
+			})
+			if expressionErr != nil {
+				return query.QueryPlan{}, expressionErr
+			}
+		}
 
-						default:
-							panic(fmt.Sprintf("unsupported type in query planner : %s (field_name : %s)", ftype.String(), filtersGroup.FieldName))
-						}
+		// a block is skipped only when the whole filter tree excludes it,
+		// leaves of not finalized blocks stay unknown and never skip them
+		if filterTree != nil {
+			for absIdx := range absBlocksFullSkipArray {
+
+				blockLeafResults := absBlocksLeafResults[absIdx*leavesSize : (absIdx+1)*leavesSize]
+				matchResult := filterTree.EvalBounds(func(leafIdx int) schema.BoundsFilterMatchResult {
+					return blockLeafResults[leafIdx]
+				})
+
+				switch matchResult {
+				case schema.NoIntersection:
+					absBlocksFullSkipArray[absIdx].None += 1
+				case schema.FullIntersection:
+					absBlocksFullSkipArray[absIdx].Full += 1
+				default:
+					absBlocksFullSkipArray[absIdx].Partial += 1
+				}
+			}
+		}
 
-						if matchErr != nil {
-							return query.QueryPlan{}, fmt.Errorf("error filtering bounds on block header : %s", matchErr.Error())
-						}
+		// blocks written after the query was planned
+		committedBlocks := committedRows / schema.BlockRowsSize
+		if committedRows%schema.BlockRowsSize != 0 {
+			committedBlocks++
+		}
 
-						absOffset := i + int(slabInfo.SlabOffsetBlocks)
+		for absIdx := int(min(committedBlocks, uint64(maxBlocks))); absIdx < maxBlocks; absIdx++ {
+			absBlocksFullSkipArray[absIdx].None += 1
+		}
 
-						if matchResult == schema.NoIntersection {
-							absBlocksFullSkipArray[absOffset].None += 1
-						}
+		if len(orderBy) > 0 && queryData.Limit > 0 {
 
-						if matchResult == schema.FullIntersection {
-							absBlocksFullSkipArray[absOffset].Full += 1
-						}
+			fullMatchBlocks := make([]bool, maxBlocks)
+			for absIdx, skip := range absBlocksFullSkipArray {
+				fullMatchBlocks[absIdx] = skip.None == 0 && (filterTree == nil || skip.Full > 0)
+			}
 
-						if matchResult == schema.PartialIntersection || matchResult == schema.UnknownIntersection {
-							absBlocksFullSkipArray[absOffset].Partial += 1
-						}
+			prunedBlocks, orderPruneErr := pruneBlocksByOrder(schemaObject, slabManager, orderBy[0], queryData.Limit, committedRows, fullMatchBlocks, func(absIdx int) bool {
+				return absBlocksFullSkipArray[absIdx].None > 0
+			})
+			if orderPruneErr != nil {
+				return query.QueryPlan{}, orderPruneErr
+			}
 
-					}
-				}
+			for _, absIdx := range prunedBlocks {
+				absBlocksFullSkipArray[absIdx].None += 1
 			}
 		}
 
@@ -226,7 +348,7 @@ func (qp *QueryPlanner) Plan(
 				// 	skip.None, skip.Full, skip.Partial,
 				// })
 
-				if skip.Full == 3 {
+				if skip.Full > 0 {
 					blocksFull += 1
 				} else {
 					blocksOk += 1
@@ -284,10 +406,15 @@ func (qp *QueryPlanner) Plan(
 					}
 
 					if size > 0 {
+						if curChunkSlabsItem.BlocksFilled == 0 {
+							curChunkSlabsItem.FirstAbsBlock = uint64(absSlabBase + start)
+						}
+
 						curChunkSlabsItem.Segments = append(curChunkSlabsItem.Segments, query.Segment{
 							Slab:       slabUid,
 							StartBlock: start,
 							Size:       size,
+							AbsBlock:   uint64(absSlabBase + start),
 						})
 						curChunkSlabsItem.BlocksFilled += size
 					}
@@ -316,7 +443,7 @@ func (qp *QueryPlanner) Plan(
 
 				if curChunkObject.ChunkSegmentsByFieldIndexMap == nil {
 					curChunkObject.ChunkSegmentsByFieldIndexMap = make([][]query.Segment, fieldsCount)
-					curChunkObject.GlobalBlockOffset = uint64(chunkIdx) * query.ExecutorChunkSizeBlocks
+					curChunkObject.GlobalBlockOffset = chunk.FirstAbsBlock
 				}
 
 				curChunkObject.ChunkSegmentsByFieldIndexMap[columnIdx] = chunk.Segments
@@ -327,9 +454,787 @@ func (qp *QueryPlanner) Plan(
 			Schema:                *schemaObject,
 			FilterGroupedByFields: filterByColumnsArray,
 			BlockChunks:           chunks,
-			FilterSize:            len(queryData.Filter),
+			Aggregates:            aggregates,
+			GroupBy:               groupBy,
+			Projections:           projections,
+			OrderBy:               orderBy,
+			Limit:                 queryData.Limit,
+			SelectColumns:         selectColumns,
+			FilterTree:            filterTree,
+			FilterNodes:           filterNodes(filterTree),
+			ExpressionFilters:     expressionFilters,
+			ExpressionNodes:       expressionNodes(expressionFilters),
+			FilterSize:            len(filterLeaves),
+			Rows:                  committedRows,
 		}, nil
 
 	}
 
 }
+
+// combines flat filter list and filter expression into a single tree.
+// leaves are numbered in the order of appearance
+//
+// NOT is pushed down to leaves, so rows without value don't match negated conditions.
+// conditions on expressions are planned as `left - right <op> 0`,
+// ranges on expressions are split into two such comparisons
+func planFilterTree(schemaObject *schema.Schema, queryData query.Query) (*query.FilterNodeRT, []query.FilterCondition, []query.ExpressionFilterRT, error) {
+
+	children := []query.FilterExpr{}
+	for _, filter := range queryData.Filter {
+		children = append(children, query.FilterExpr{
+			Type:      query.FilterLeaf,
+			Condition: filter,
+		})
+	}
+
+	if queryData.Where != nil {
+		children = append(children, *queryData.Where)
+	}
+
+	if len(children) == 0 {
+		return nil, nil, nil, nil
+	}
+
+	root := query.And(children...)
+	if len(children) == 1 {
+		root = children[0]
+	}
+
+	root = root.PushNotToLeaves()
+
+	leaves := []query.FilterCondition{}
+	expressionFilters := []query.ExpressionFilterRT{}
+	nodeId := 0
+
+	addExpressionLeaf := func(condition query.FilterCondition, operand query.CondOperand, right *query.Expr) (query.FilterNodeRT, error) {
+
+		node := query.FilterNodeRT{
+			Type:    query.FilterLeaf,
+			Id:      nodeId,
+			LeafIdx: len(leaves),
+		}
+		nodeId++
+
+		if len(leaves) == query.MaxFilterLeaves {
+			return node, fmt.Errorf("too many filter conditions, max %d", query.MaxFilterLeaves)
+		}
+
+		columns := []int{}
+		exprNodeId := 0
+
+		diff, exprErr := planExpr(schemaObject, query.Sub(condition.LeftExpr(), right), &exprNodeId, &columns)
+		if exprErr != nil {
+			return node, exprErr
+		}
+
+		leaves = append(leaves, condition)
+		expressionFilters = append(expressionFilters, query.ExpressionFilterRT{
+			LeafIdx: node.LeafIdx,
+			Operand: operand,
+			Diff:    diff,
+			Columns: columns,
+		})
+
+		return node, nil
+	}
+
+	var planNode func(expr *query.FilterExpr) (query.FilterNodeRT, error)
+	planNode = func(expr *query.FilterExpr) (query.FilterNodeRT, error) {
+
+		node := query.FilterNodeRT{
+			Type: expr.Type,
+			Id:   nodeId,
+		}
+		nodeId++
+
+		switch expr.Type {
+		case query.FilterLeaf:
+
+			condErr := expr.Condition.Validate()
+			if condErr != nil {
+				return node, condErr
+			}
+
+			if expr.Condition.HasExpressions() {
+
+				// leaf ids are assigned by expression leaves
+				nodeId--
+
+				if expr.Condition.Operand != query.RANGE {
+					return addExpressionLeaf(expr.Condition, expr.Condition.Operand, expr.Condition.ArgumentExpr(0))
+				}
+
+				fromOperand, toOperand := query.GTE, query.LTE
+				if expr.Condition.Range&query.RangeExcludeFrom != 0 {
+					fromOperand = query.GT
+				}
+				if expr.Condition.Range&query.RangeExcludeTo != 0 {
+					toOperand = query.LT
+				}
+
+				node.Type = query.FilterAnd
+				node.Id = nodeId
+				nodeId++
+
+				fromLeaf, fromErr := addExpressionLeaf(expr.Condition, fromOperand, expr.Condition.ArgumentExpr(0))
+				if fromErr != nil {
+					return node, fromErr
+				}
+
+				toLeaf, toErr := addExpressionLeaf(expr.Condition, toOperand, expr.Condition.ArgumentExpr(1))
+				if toErr != nil {
+					return node, toErr
+				}
+
+				node.Children = []query.FilterNodeRT{fromLeaf, toLeaf}
+				return node, nil
+			}
+
+			_, column := findSchemaColumn(schemaObject, expr.Condition.Field)
+			if column == nil {
+				return node, fmt.Errorf("column `%v` not found on schema `%v`", expr.Condition.Field, schemaObject.Name)
+			}
+
+			if len(leaves) == query.MaxFilterLeaves {
+				return node, fmt.Errorf("too many filter conditions, max %d", query.MaxFilterLeaves)
+			}
+
+			node.LeafIdx = len(leaves)
+			leaves = append(leaves, expr.Condition)
+
+			return node, nil
+
+		case query.FilterNot:
+			if len(expr.Children) != 1 {
+				return node, fmt.Errorf("NOT expects a single operand, got %d", len(expr.Children))
+			}
+		case query.FilterAnd, query.FilterOr:
+			if len(expr.Children) == 0 {
+				return node, fmt.Errorf("%s expects at least one operand", expr.Type.String())
+			}
+		default:
+			return node, fmt.Errorf("unknown filter node type %d", expr.Type)
+		}
+
+		node.Children = make([]query.FilterNodeRT, 0, len(expr.Children))
+		for idx := range expr.Children {
+
+			child, childErr := planNode(&expr.Children[idx])
+			if childErr != nil {
+				return node, childErr
+			}
+
+			node.Children = append(node.Children, child)
+		}
+
+		return node, nil
+	}
+
+	tree, treeErr := planNode(&root)
+	if treeErr != nil {
+		return nil, nil, nil, treeErr
+	}
+
+	return &tree, leaves, expressionFilters, nil
+}
+
+func planExpr(schemaObject *schema.Schema, expr *query.Expr, nodeId *int, columns *[]int) (*query.ExprRT, error) {
+
+	if expr == nil {
+		return nil, fmt.Errorf("missing expression operand")
+	}
+
+	result := &query.ExprRT{
+		Type:  expr.Type,
+		Id:    *nodeId,
+		Value: expr.Value,
+	}
+	*nodeId++
+
+	switch expr.Type {
+	case query.ExprConst:
+		return result, nil
+
+	case query.ExprColumn:
+
+		columnIdx, column := findSchemaColumn(schemaObject, expr.Column)
+		if column == nil {
+			return nil, fmt.Errorf("column `%v` not found on schema `%v`", expr.Column, schemaObject.Name)
+		}
+
+		if column.Type == schema.StringFieldType {
+			return nil, fmt.Errorf("string column `%s` can't be used in expressions", expr.Column)
+		}
+
+		result.ColumnIdx = columnIdx
+		if column.Type == schema.TimestampFieldType {
+			result.Scale = column.Precision.Unit().Seconds()
+		}
+
+		if !slices.Contains(*columns, columnIdx) {
+			*columns = append(*columns, columnIdx)
+		}
+
+		return result, nil
+
+	case query.ExprAdd, query.ExprSub, query.ExprMul, query.ExprDiv:
+
+		left, leftErr := planExpr(schemaObject, expr.Left, nodeId, columns)
+		if leftErr != nil {
+			return nil, leftErr
+		}
+
+		right, rightErr := planExpr(schemaObject, expr.Right, nodeId, columns)
+		if rightErr != nil {
+			return nil, rightErr
+		}
+
+		result.Left = left
+		result.Right = right
+
+		return result, nil
+
+	default:
+		return nil, fmt.Errorf("unknown expression type %d", expr.Type)
+	}
+}
+
+func expressionNodes(expressionFilters []query.ExpressionFilterRT) int {
+
+	var countNodes func(expr *query.ExprRT) int
+	countNodes = func(expr *query.ExprRT) int {
+		if expr == nil {
+			return 0
+		}
+		return 1 + countNodes(expr.Left) + countNodes(expr.Right)
+	}
+
+	total := 0
+	for _, it := range expressionFilters {
+		total = max(total, countNodes(it.Diff))
+	}
+
+	return total
+}
+
+// matches expression filters against finalized blocks of all used columns
+func pruneExpressionFilters(
+	schemaObject *schema.Schema,
+	slabManager *meta.SlabManager,
+	expressionFilters []query.ExpressionFilterRT,
+	maxBlocks int,
+	setResult func(absIdx int, leafIdx int, matchResult schema.BoundsFilterMatchResult),
+) error {
+
+	type columnBlockBounds struct {
+		headers []*schema.DiskHeader
+	}
+
+	columnBounds := map[int]*columnBlockBounds{}
+
+	for _, it := range expressionFilters {
+		for _, columnIdx := range it.Columns {
+
+			if _, loaded := columnBounds[columnIdx]; loaded {
+				continue
+			}
+
+			blockBounds := &columnBlockBounds{
+				headers: make([]*schema.DiskHeader, maxBlocks),
+			}
+			columnBounds[columnIdx] = blockBounds
+
+			for _, slabUid := range schemaObject.Columns[columnIdx].Slabs {
+
+				slabInfo, slabLoadErr := slabManager.LoadSlabHeaderToCache(schemaObject, slabUid)
+				if slabLoadErr != nil {
+					return fmt.Errorf("error loading slab into cache : %w", slabLoadErr)
+				}
+
+				// headers are copied, compression of the slab changes them in place
+				slabInfo.RLock()
+				for i := 0; i < int(slabInfo.BlocksFinalized); i++ {
+					absIdx := i + int(slabInfo.SlabOffsetBlocks)
+					if absIdx >= maxBlocks {
+						break
+					}
+
+					header := slabInfo.BlockHeaders[i]
+					blockBounds.headers[absIdx] = &header
+				}
+				slabInfo.RUnlock()
+			}
+		}
+	}
+
+	for absIdx := range maxBlocks {
+		for _, it := range expressionFilters {
+
+			matchResult, matchErr := filters.ProcessDiffOnBlockHeaders(it, func(columnIdx int) *schema.DiskHeader {
+				return columnBounds[columnIdx].headers[absIdx]
+			})
+			if matchErr != nil {
+				return fmt.Errorf("error filtering bounds on block header : %s", matchErr.Error())
+			}
+
+			if matchResult != schema.UnknownIntersection {
+				setResult(absIdx, it.LeafIdx, matchResult)
+			}
+		}
+	}
+
+	return nil
+}
+
+// time.Time arguments of timestamp conditions are converted into column units,
+// other arguments are kept as raw units
+func timeArguments(args []any, precision schema.TimePrecision) []any {
+
+	result := make([]any, len(args))
+
+	for idx, arg := range args {
+		if t, isTime := arg.(time.Time); isTime {
+			result[idx] = precision.FromTime(t)
+		} else {
+			result[idx] = arg
+		}
+	}
+
+	return result
+}
+
+// string conditions are matched on codes, which differ between slab dictionaries,
+// so each slab of the column gets its own IN / NOT_IN condition
+func planStringFilter(
+	schemaObject *schema.Schema,
+	slabManager *meta.SlabManager,
+	column *schema.SchemaColumn,
+	filter query.FilterCondition,
+) (map[uuid.UUID]query.FilterCondition, error) {
+
+	operand := query.IN
+
+	switch filter.Operand {
+	case query.EQ, query.IN, query.PREFIX:
+	case query.NEQ, query.NOT_IN:
+		operand = query.NOT_IN
+	default:
+		return nil, fmt.Errorf("%s filter is not supported on string column `%s`", filter.Operand.String(), column.Name)
+	}
+
+	values := make([]string, len(filter.Arguments))
+	for idx, arg := range filter.Arguments {
+		value, isString := arg.(string)
+		if !isString {
+			return nil, fmt.Errorf("filter on string column `%s` expects string arguments, got %T", column.Name, arg)
+		}
+		values[idx] = value
+	}
+
+	result := make(map[uuid.UUID]query.FilterCondition, len(column.Slabs))
+
+	for _, slabUid := range column.Slabs {
+
+		dict, dictErr := slabManager.LoadSlabDictionary(schemaObject, slabUid)
+		if dictErr != nil {
+			return nil, fmt.Errorf("unable to load dictionary of column `%s` : %w", column.Name, dictErr)
+		}
+
+		codes := []any{}
+
+		if filter.Operand == query.PREFIX {
+			for _, code := range dict.CodesWithPrefix(values[0]) {
+				codes = append(codes, code)
+			}
+		} else {
+			for _, value := range values {
+				if code, ok := dict.Code(value); ok {
+					codes = append(codes, code)
+				}
+			}
+		}
+
+		result[slabUid] = query.FilterCondition{
+			Field:     filter.Field,
+			Operand:   operand,
+			Arguments: codes,
+		}
+	}
+
+	return result, nil
+}
+
+func filterNodes(tree *query.FilterNodeRT) int {
+	if tree == nil {
+		return 0
+	}
+
+	total := 1
+	for idx := range tree.Children {
+		total += filterNodes(&tree.Children[idx])
+	}
+
+	return total
+}
+
+func findSchemaColumn(schemaObject *schema.Schema, name string) (int, *schema.SchemaColumn) {
+	for idx := range schemaObject.Columns {
+		if schemaObject.Columns[idx].Name == name {
+			return idx, &schemaObject.Columns[idx]
+		}
+	}
+
+	return -1, nil
+}
+
+// parses selectors into aggregates and projections,
+// also returns distinct columns which data is needed to calculate them
+func planSelectors(schemaObject *schema.Schema, selectors []query.Selector) ([]query.AggregateRT, []query.ProjectionRT, []int, error) {
+
+	aggregates := make([]query.AggregateRT, 0, len(selectors))
+	projections := []query.ProjectionRT{}
+	selectColumns := []int{}
+
+	for _, selector := range selectors {
+
+		switch selector.Type {
+		case query.SelectFunction:
+
+			aggregate, aggErr := planAggregate(schemaObject, selector)
+			if aggErr != nil {
+				return nil, nil, nil, aggErr
+			}
+
+			// count does not need column data, unless nulls of the column are skipped
+			needsData := aggregate.Function != query.AggCount || (aggregate.ColumnSchemaInfo != nil && aggregate.ColumnSchemaInfo.Nullable)
+
+			if needsData && !slices.Contains(selectColumns, aggregate.ColumnIdx) {
+				selectColumns = append(selectColumns, aggregate.ColumnIdx)
+			}
+
+			aggregates = append(aggregates, aggregate)
+
+		case query.SelectColumn:
+
+			if len(selector.Arguments) != 1 {
+				return nil, nil, nil, fmt.Errorf("column selector expects a single column name, got %d arguments", len(selector.Arguments))
+			}
+
+			fieldName, isString := selector.Arguments[0].(string)
+			if !isString {
+				return nil, nil, nil, fmt.Errorf("selector column must be a string, got %T", selector.Arguments[0])
+			}
+
+			columnIdx, column := findSchemaColumn(schemaObject, fieldName)
+			if column == nil {
+				return nil, nil, nil, fmt.Errorf("column `%v` not found on schema `%v`", fieldName, schemaObject.Name)
+			}
+
+			alias := selector.Alias
+			if alias == "" {
+				alias = fieldName
+			}
+
+			projections = append(projections, query.ProjectionRT{
+				ColumnIdx:        columnIdx,
+				ColumnSchemaInfo: column,
+				Alias:            alias,
+			})
+
+			if !slices.Contains(selectColumns, columnIdx) {
+				selectColumns = append(selectColumns, columnIdx)
+			}
+
+		default:
+			return nil, nil, nil, fmt.Errorf("unsupported selector type %d", selector.Type)
+		}
+	}
+
+	return aggregates, projections, selectColumns, nil
+}
+
+func planAggregate(schemaObject *schema.Schema, selector query.Selector) (query.AggregateRT, error) {
+
+	if len(selector.Arguments) == 0 {
+		return query.AggregateRT{}, fmt.Errorf("selector `%s` has no function", selector.Alias)
+	}
+
+	fnName, isString := selector.Arguments[0].(string)
+	if !isString {
+		return query.AggregateRT{}, fmt.Errorf("selector function name must be a string, got %T", selector.Arguments[0])
+	}
+
+	fn, fnErr := query.ParseAggregateFunction(fnName)
+	if fnErr != nil {
+		return query.AggregateRT{}, fnErr
+	}
+
+	aggregate := query.AggregateRT{
+		Function:  fn,
+		Alias:     selector.Alias,
+		ColumnIdx: -1,
+	}
+
+	if len(selector.Arguments) > 1 {
+
+		fieldName, isString := selector.Arguments[1].(string)
+		if !isString {
+			return query.AggregateRT{}, fmt.Errorf("selector `%s` column must be a string, got %T", fnName, selector.Arguments[1])
+		}
+
+		columnIdx, column := findSchemaColumn(schemaObject, fieldName)
+		if column == nil {
+			return query.AggregateRT{}, fmt.Errorf("column `%v` not found on schema `%v`", fieldName, schemaObject.Name)
+		}
+
+		if column.Type == schema.StringFieldType && fn != query.AggCount {
+			return query.AggregateRT{}, fmt.Errorf("aggregate `%s` can't be used on string column `%s`", fnName, fieldName)
+		}
+
+		aggregate.ColumnIdx = columnIdx
+		aggregate.ColumnSchemaInfo = column
+
+		if aggregate.Alias == "" {
+			aggregate.Alias = fmt.Sprintf("%s(%s)", fnName, fieldName)
+		}
+
+	} else if fn != query.AggCount {
+		return query.AggregateRT{}, fmt.Errorf("aggregate `%s` requires a column", fnName)
+	}
+
+	if aggregate.Alias == "" {
+		aggregate.Alias = fnName
+	}
+
+	return aggregate, nil
+}
+
+func planGroupBy(schemaObject *schema.Schema, groupBy []query.GroupBy) ([]query.GroupByRT, []int, error) {
+
+	if len(groupBy) > query.MaxGroupByColumns {
+		return nil, nil, fmt.Errorf("too many group by columns: %d, max %d", len(groupBy), query.MaxGroupByColumns)
+	}
+
+	result := make([]query.GroupByRT, 0, len(groupBy))
+	columns := []int{}
+
+	for _, it := range groupBy {
+
+		columnIdx, column := findSchemaColumn(schemaObject, it.Field)
+		if column == nil {
+			return nil, nil, fmt.Errorf("column `%v` not found on schema `%v`", it.Field, schemaObject.Name)
+		}
+
+		// codes of equal strings differ between slabs
+		if column.Type == schema.StringFieldType {
+			return nil, nil, fmt.Errorf("group by string column `%s` is not supported", it.Field)
+		}
+
+		if column.Nullable {
+			return nil, nil, fmt.Errorf("group by nullable column `%s` is not supported", it.Field)
+		}
+
+		if it.Bucket > 0 {
+			switch column.Type {
+			case schema.Float32FieldType, schema.Float64FieldType:
+				return nil, nil, fmt.Errorf("bucket can only be used on integer columns, `%s` is %s", it.Field, column.Type.String())
+			}
+		}
+
+		timeFunctionErr := validateGroupTimeFunction(it, column)
+		if timeFunctionErr != nil {
+			return nil, nil, timeFunctionErr
+		}
+
+		location := it.Location
+		if location == nil {
+			location = time.UTC
+		}
+
+		alias := it.Alias
+		if alias == "" {
+			alias = it.Field
+		}
+
+		result = append(result, query.GroupByRT{
+			ColumnIdx:        columnIdx,
+			ColumnSchemaInfo: column,
+			Bucket:           it.Bucket,
+			Interval:         it.Interval,
+			Trunc:            it.Trunc,
+			Location:         location,
+			Alias:            alias,
+		})
+
+		if !slices.Contains(columns, columnIdx) {
+			columns = append(columns, columnIdx)
+		}
+	}
+
+	return result, columns, nil
+}
+
+// time_bucket and date_trunc are computed on timestamp columns only
+func validateGroupTimeFunction(it query.GroupBy, column *schema.SchemaColumn) error {
+
+	if it.Interval == 0 && it.Trunc == query.TruncNone && it.Location == nil {
+		return nil
+	}
+
+	if column.Type != schema.TimestampFieldType {
+		return fmt.Errorf("time functions can only be used on timestamp columns, `%s` is %s", it.Field, column.Type.String())
+	}
+
+	if it.Bucket > 0 && (it.Interval != 0 || it.Trunc != query.TruncNone) {
+		return fmt.Errorf("bucket can't be combined with time functions on `%s`", it.Field)
+	}
+
+	if it.Interval != 0 && it.Trunc != query.TruncNone {
+		return fmt.Errorf("time_bucket and date_trunc can't be combined on `%s`", it.Field)
+	}
+
+	if it.Interval < 0 {
+		return fmt.Errorf("time_bucket interval on `%s` must be positive, got %s", it.Field, it.Interval.String())
+	}
+
+	unit := column.Precision.Unit()
+	if it.Interval%unit != 0 {
+		return fmt.Errorf("time_bucket interval on `%s` must be a multiple of column precision %s, got %s", it.Field, column.Precision.String(), it.Interval.String())
+	}
+
+	if it.Trunc > query.TruncYear {
+		return fmt.Errorf("unknown date_trunc unit %d on `%s`", byte(it.Trunc), it.Field)
+	}
+
+	return nil
+}
+
+func planOrderBy(schemaObject *schema.Schema, orderBy []query.OrderBy) ([]query.OrderByRT, error) {
+
+	if len(orderBy) > query.MaxOrderByColumns {
+		return nil, fmt.Errorf("too many order by columns: %d, max %d", len(orderBy), query.MaxOrderByColumns)
+	}
+
+	result := make([]query.OrderByRT, 0, len(orderBy))
+
+	for _, it := range orderBy {
+
+		columnIdx, column := findSchemaColumn(schemaObject, it.Field)
+		if column == nil {
+			return nil, fmt.Errorf("column `%v` not found on schema `%v`", it.Field, schemaObject.Name)
+		}
+
+		if column.Type == schema.StringFieldType {
+			return nil, fmt.Errorf("order by string column `%s` is not supported", it.Field)
+		}
+
+		if column.Nullable {
+			return nil, fmt.Errorf("order by nullable column `%s` is not supported", it.Field)
+		}
+
+		result = append(result, query.OrderByRT{
+			ColumnIdx:        columnIdx,
+			ColumnSchemaInfo: column,
+			Desc:             it.Desc,
+		})
+	}
+
+	return result, nil
+}
+
+// finds blocks that can't get into top `limit` rows by the first order column.
+//
+// blocks that match all the filters completely guarantee their rows get into the result,
+// so the best of them, having at least `limit` rows in total, give the bound of Nth value.
+// any block which bounds are worse than that can be skipped
+func pruneBlocksByOrder(
+	schemaObject *schema.Schema,
+	slabManager *meta.SlabManager,
+	orderBy query.OrderByRT,
+	limit int,
+	committedRows uint64,
+	fullMatchBlocks []bool,
+	isSkipped func(absIdx int) bool,
+) ([]int, error) {
+
+	type orderBlockCandidate struct {
+		absIdx int
+		items  int
+		bounds schema.BoundsFloat
+	}
+
+	candidates := []orderBlockCandidate{}
+
+	for _, slabUid := range orderBy.ColumnSchemaInfo.Slabs {
+
+		slabInfo, slabLoadErr := slabManager.LoadSlabHeaderToCache(schemaObject, slabUid)
+		if slabLoadErr != nil {
+			return nil, fmt.Errorf("error loading slab into cache : %w", slabLoadErr)
+		}
+
+		slabInfo.RLock()
+		for i := 0; i < int(slabInfo.BlocksFinalized); i++ {
+
+			absIdx := i + int(slabInfo.SlabOffsetBlocks)
+			if absIdx >= len(fullMatchBlocks) || isSkipped(absIdx) {
+				continue
+			}
+
+			candidates = append(candidates, orderBlockCandidate{
+				absIdx: absIdx,
+				items:  int(slabInfo.BlockHeaders[i].Items),
+				bounds: slabInfo.BlockHeaders[i].Bounds,
+			})
+		}
+		slabInfo.RUnlock()
+	}
+
+	// the most promising guaranteed values first.
+	// bounds of partially committed block include rows the query doesn't see
+	guaranteed := []orderBlockCandidate{}
+	for _, it := range candidates {
+		if fullMatchBlocks[it.absIdx] && uint64(it.absIdx+1)*schema.BlockRowsSize <= committedRows {
+			guaranteed = append(guaranteed, it)
+		}
+	}
+
+	slices.SortFunc(guaranteed, func(a, b orderBlockCandidate) int {
+		if orderBy.Desc {
+			return cmp.Compare(b.bounds.Min, a.bounds.Min)
+		}
+		return cmp.Compare(a.bounds.Max, b.bounds.Max)
+	})
+
+	rows := 0
+	threshold := 0.0
+	found := false
+
+	for _, it := range guaranteed {
+		rows += it.items
+		if rows >= limit {
+			if orderBy.Desc {
+				threshold = it.bounds.Min
+			} else {
+				threshold = it.bounds.Max
+			}
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		return nil, nil
+	}
+
+	pruned := []int{}
+	for _, it := range candidates {
+		if orderBy.Desc && it.bounds.Max < threshold {
+			pruned = append(pruned, it.absIdx)
+		} else if !orderBy.Desc && it.bounds.Min > threshold {
+			pruned = append(pruned, it.absIdx)
+		}
+	}
+
+	return pruned, nil
+}
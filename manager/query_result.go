@@ -0,0 +1,121 @@
+package manager
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/dot5enko/simple-column-db/manager/query"
+)
+
+func aggregatesToData(plan *query.QueryPlan, states []query.AggregateState) map[string][]any {
+
+	data := map[string][]any{}
+
+	for aggIdx, aggregate := range plan.Aggregates {
+		data[aggregate.Alias] = []any{states[aggIdx].Result(aggregate.Function)}
+	}
+
+	return data
+}
+
+// concatenates chunk projections in chunk order,
+// ordered projections are merged by their order keys
+func projectionsToData(plan *query.QueryPlan, projections []*query.Projection) ([]uint64, map[string][]any) {
+
+	type rowRef struct {
+		chunk int
+		row   int
+	}
+
+	refs := []rowRef{}
+	for chunkIdx, projection := range projections {
+		if projection == nil {
+			continue
+		}
+		for row := range projection.RowIds {
+			refs = append(refs, rowRef{chunk: chunkIdx, row: row})
+		}
+	}
+
+	if len(plan.OrderBy) > 0 {
+		slices.SortStableFunc(refs, func(a, b rowRef) int {
+			pa, pb := projections[a.chunk], projections[b.chunk]
+
+			c := query.CompareOrderKeys(plan.OrderBy, &pa.OrderKeys[a.row], &pb.OrderKeys[b.row])
+			if c == 0 {
+				return cmp.Compare(pa.RowIds[a.row], pb.RowIds[b.row])
+			}
+			return c
+		})
+	}
+
+	if plan.Limit > 0 && len(refs) > plan.Limit {
+		refs = refs[:plan.Limit]
+	}
+
+	rowIds := make([]uint64, len(refs))
+	for i, ref := range refs {
+		rowIds[i] = projections[ref.chunk].RowIds[ref.row]
+	}
+
+	data := map[string][]any{}
+
+	for projIdx, projected := range plan.Projections {
+
+		values := make([]any, len(refs))
+		for i, ref := range refs {
+			values[i] = projections[ref.chunk].Values[projIdx][ref.row]
+		}
+
+		data[projected.Alias] = values
+	}
+
+	return rowIds, data
+}
+
+// one row per group, ordered by group by columns
+func groupTableToData(plan *query.QueryPlan, table *query.GroupTable) map[string][]any {
+
+	groups := table.Size()
+	order := make([]int, groups)
+	for i := range order {
+		order[i] = i
+	}
+
+	slices.SortFunc(order, func(a, b int) int {
+		for groupIdx, groupBy := range plan.GroupBy {
+			c := query.CompareEncodedValues(groupBy.ColumnSchemaInfo.Type, table.Keys[a][groupIdx], table.Keys[b][groupIdx])
+			if c != 0 {
+				return c
+			}
+		}
+		return 0
+	})
+
+	if plan.Limit > 0 && len(order) > plan.Limit {
+		order = order[:plan.Limit]
+		groups = plan.Limit
+	}
+
+	data := map[string][]any{}
+
+	for groupIdx := range plan.GroupBy {
+		groupBy := &plan.GroupBy[groupIdx]
+
+		values := make([]any, groups)
+		for row, tableIdx := range order {
+			values[row] = groupBy.DecodeKey(table.Keys[tableIdx][groupIdx])
+		}
+		data[groupBy.Alias] = values
+	}
+
+	for aggIdx, aggregate := range plan.Aggregates {
+		values := make([]any, groups)
+		for row, tableIdx := range order {
+			values[row] = table.GroupStates(tableIdx)[aggIdx].Result(aggregate.Function)
+		}
+		data[aggregate.Alias] = values
+	}
+
+	return data
+}
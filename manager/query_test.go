@@ -0,0 +1,1222 @@
+package manager
+
+import (
+	"cmp"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dot5enko/simple-column-db/manager/query"
+	"github.com/dot5enko/simple-column-db/schema"
+)
+
+// byte flipped on disk gets to query caller as a corruption error
+func TestCorruptedSlabFailsQuery(t *testing.T) {
+
+	for _, it := range []struct {
+		name string
+		part schema.CorruptedPart
+
+		// offset of the flipped byte, by offset of slab data
+		offset func(dataOffset int64) int64
+	}{
+		{"block header", schema.CorruptedBlockHeader, func(int64) int64 { return schema.SlabHeaderFixedSize + 20 }},
+		{"block data", schema.CorruptedBlockData, func(dataOffset int64) int64 { return dataOffset + 100 }},
+	} {
+		t.Run(it.name, func(t *testing.T) {
+
+			dir := t.TempDir()
+
+			m := openTestManager(t, dir, testSchema("checks"))
+			if ingestErr := m.Ingest("checks", testRows(100000, 1)); ingestErr != nil {
+				t.Fatal(ingestErr)
+			}
+
+			schemaObject := m.Meta.GetSchema("checks")
+			slabUid := schemaObject.Columns[0].Slabs[0]
+
+			header, headerErr := m.Slabs.LoadSlabHeaderToCache(schemaObject, slabUid)
+			if headerErr != nil {
+				t.Fatal(headerErr)
+			}
+			dataOffset := int64(schema.SlabHeaderFixedSize) + int64(header.BlocksTotal)*schema.TotalHeaderSize
+
+			closeTestManager(t, m)
+
+			flipTestSlabByte(t, dir, "checks", slabUid, it.offset(dataOffset))
+
+			m = openTestManager(t, dir)
+			defer closeTestManager(t, m)
+
+			_, queryErr := m.Query("checks", query.Query{Select: []query.Selector{
+				{Arguments: []any{"sum", "x"}, Alias: "sum"},
+			}}, t.Context())
+
+			var corruption *schema.CorruptionError
+			if !errors.As(queryErr, &corruption) {
+				t.Fatalf("expected corruption error, got %v", queryErr)
+			}
+
+			if corruption.Part != it.part || corruption.Slab != slabUid {
+				t.Errorf("expected %s corruption of slab %s, got %s", it.part.String(), slabUid.String(), corruption.Error())
+			}
+		})
+	}
+}
+
+// NOT over a nullable column matches the same rows as the opposite condition,
+// rows without value match neither of them
+func TestNotOverNullableColumn(t *testing.T) {
+
+	const rows = 100000
+
+	m := openTestManager(t, t.TempDir(), schema.Schema{Name: "nulls", Columns: []schema.SchemaColumn{
+		{Name: "ts", Type: schema.Uint64FieldType},
+		{Name: "val", Type: schema.Float32FieldType, Nullable: true},
+		{Name: "name", Type: schema.StringFieldType, Nullable: true},
+	}})
+	defer closeTestManager(t, m)
+
+	// every 5th row has no val, second block has no val at all, every 7th row has no name
+	valNull := func(i int) bool { return i%5 == 0 || (i >= 32768 && i < 65536) }
+	nameNull := func(i int) bool { return i%7 == 0 }
+	val := func(i int) float32 { return float32(i % 10) }
+	name := func(i int) string { return fmt.Sprintf("%c%d", 'a'+i%3, i%4) }
+
+	data := IngestBufferFromBinary(nil, []string{"ts", "val", "name"})
+	for i := range rows {
+		data.dataBuffer = binary.LittleEndian.AppendUint64(data.dataBuffer, uint64(i))
+		data.dataBuffer = binary.LittleEndian.AppendUint32(data.dataBuffer, math.Float32bits(val(i)))
+		data.dataBuffer = binary.LittleEndian.AppendUint32(data.dataBuffer, data.AddString(name(i)))
+		if valNull(i) {
+			data.SetNull("val", i)
+		}
+		if nameNull(i) {
+			data.SetNull("name", i)
+		}
+	}
+
+	if ingestErr := m.Ingest("nulls", data); ingestErr != nil {
+		t.Fatal(ingestErr)
+	}
+
+	hasVal := func(i int, pred func(v float32) bool) bool { return !valNull(i) && pred(val(i)) }
+
+	for _, it := range []struct {
+		name     string
+		where    query.FilterExpr
+		expected func(i int) bool
+	}{
+		{"not eq", query.Not(query.Cond("val", query.EQ, float32(3))), func(i int) bool { return hasVal(i, func(v float32) bool { return v != 3 }) }},
+		{"neq", query.Cond("val", query.NEQ, float32(3)), func(i int) bool { return hasVal(i, func(v float32) bool { return v != 3 }) }},
+		{"not in", query.Not(query.Cond("val", query.IN, float32(1), float32(2))), func(i int) bool { return hasVal(i, func(v float32) bool { return v != 1 && v != 2 }) }},
+		{"not not_in", query.Not(query.Cond("val", query.NOT_IN, float32(1), float32(2))), func(i int) bool { return hasVal(i, func(v float32) bool { return v == 1 || v == 2 }) }},
+		{"not gt", query.Not(query.Cond("val", query.GT, float32(6))), func(i int) bool { return hasVal(i, func(v float32) bool { return v <= 6 }) }},
+		{"not range", query.Not(query.CondRange("val", float32(2), float32(5), query.RangeExcludeTo)), func(i int) bool { return hasVal(i, func(v float32) bool { return v < 2 || v >= 5 }) }},
+		{"not reversed range", query.Not(query.CondRange("val", float32(5), float32(2), query.RangeInclusive)), func(i int) bool { return hasVal(i, func(v float32) bool { return v < 2 || v > 5 }) }},
+		{
+			// reversed endpoints are swapped, flags stay at the lower and the upper end
+			"not reversed range without lower end",
+			query.Not(query.CondRange("val", float32(5), float32(2), query.RangeExcludeFrom)),
+			func(i int) bool { return hasVal(i, func(v float32) bool { return v <= 2 || v > 5 }) },
+		},
+		{"not not", query.Not(query.Not(query.Cond("val", query.EQ, float32(3)))), func(i int) bool { return hasVal(i, func(v float32) bool { return v == 3 }) }},
+		{"not is null", query.Not(query.Cond("val", query.IS_NULL)), func(i int) bool { return !valNull(i) }},
+		{
+			"not and",
+			query.Not(query.And(query.Cond("val", query.EQ, float32(3)), query.Cond("ts", query.LT, uint64(50000)))),
+			func(i int) bool { return hasVal(i, func(v float32) bool { return v != 3 }) || i >= 50000 },
+		},
+		{
+			"not or",
+			query.Not(query.Or(query.Cond("val", query.EQ, float32(3)), query.Cond("ts", query.LT, uint64(50000)))),
+			func(i int) bool { return hasVal(i, func(v float32) bool { return v != 3 }) && i >= 50000 },
+		},
+		{
+			"not prefix",
+			query.Not(query.Cond("name", query.PREFIX, "a")),
+			func(i int) bool { return !nameNull(i) && !strings.HasPrefix(name(i), "a") },
+		},
+	} {
+		t.Run(it.name, func(t *testing.T) {
+
+			expected := 0
+			for i := range rows {
+				if it.expected(i) {
+					expected++
+				}
+			}
+
+			where := it.where
+			data := testQuery(t, m, "nulls", query.Query{Where: &where, Select: []query.Selector{
+				{Arguments: []any{"count"}, Alias: "count"},
+			}})
+
+			if count := data["count"][0].(int); count != expected {
+				t.Errorf("expected %d rows, got %d", expected, count)
+			}
+		})
+	}
+}
+
+// aggregates over rows matched in several chunks, compared to a scan of ingested rows
+func TestAggregates(t *testing.T) {
+
+	// more than a single chunk of blocks
+	const rows = 400000
+
+	m := openTestManager(t, t.TempDir(), testSchema("values"))
+	defer closeTestManager(t, m)
+
+	x := func(i int) uint64 { return uint64(i*7919%rows) + 10 }
+	y := func(i int) uint64 { return uint64(i % 10) }
+
+	if ingestErr := m.Ingest("values", testRowsOf(rows, x, y)); ingestErr != nil {
+		t.Fatal(ingestErr)
+	}
+
+	for _, it := range []struct {
+		name   string
+		filter []query.FilterCondition
+		match  func(i int) bool
+	}{
+		{"all rows", nil, func(int) bool { return true }},
+		{"filtered", []query.FilterCondition{{Field: "y", Operand: query.EQ, Arguments: []any{uint64(3)}}}, func(i int) bool { return y(i) == 3 }},
+		{"two conditions", []query.FilterCondition{
+			{Field: "x", Operand: query.GT, Arguments: []any{uint64(1000)}},
+			{Field: "x", Operand: query.LT, Arguments: []any{uint64(250000)}},
+		}, func(i int) bool { return x(i) > 1000 && x(i) < 250000 }},
+		{"nothing matched", []query.FilterCondition{{Field: "x", Operand: query.GT, Arguments: []any{uint64(rows + 10)}}}, func(int) bool { return false }},
+	} {
+		t.Run(it.name, func(t *testing.T) {
+
+			count, sum := 0, 0.0
+			var minX, maxX any
+
+			for i := range rows {
+				if !it.match(i) {
+					continue
+				}
+
+				value := float64(x(i))
+				if count == 0 || value < minX.(float64) {
+					minX = value
+				}
+				if count == 0 || value > maxX.(float64) {
+					maxX = value
+				}
+
+				count++
+				sum += value
+			}
+
+			var avg any
+			if count > 0 {
+				avg = sum / float64(count)
+			}
+
+			data := testQuery(t, m, "values", query.Query{
+				Filter: it.filter,
+				Select: []query.Selector{
+					{Arguments: []any{"count"}},
+					{Arguments: []any{"sum", "x"}},
+					{Arguments: []any{"avg", "x"}, Alias: "avg"},
+					{Arguments: []any{"min", "x"}, Alias: "min"},
+					{Arguments: []any{"max", "x"}, Alias: "max"},
+				},
+			})
+
+			if data["count"][0] != count || data["sum(x)"][0] != sum {
+				t.Fatalf("expected %d rows with sum %f, got %v rows with sum %v", count, sum, data["count"][0], data["sum(x)"][0])
+			}
+
+			if data["avg"][0] != avg || data["min"][0] != minX || data["max"][0] != maxX {
+				t.Errorf("expected avg %v, min %v, max %v, got %v, %v, %v", avg, minX, maxX, data["avg"][0], data["min"][0], data["max"][0])
+			}
+		})
+	}
+}
+
+func TestGroupBy(t *testing.T) {
+
+	const rows = 100000
+
+	m := openTestManager(t, t.TempDir(), testSchema("groups"))
+	defer closeTestManager(t, m)
+
+	x := func(i int) uint64 { return uint64(i % 7) }
+	y := func(i int) uint64 { return uint64(i % 3) }
+
+	if ingestErr := m.Ingest("groups", testRowsOf(rows, x, y)); ingestErr != nil {
+		t.Fatal(ingestErr)
+	}
+
+	type group struct{ x, y uint64 }
+
+	for _, it := range []struct {
+		name    string
+		groupBy []query.GroupBy
+		filter  []query.FilterCondition
+		match   func(i int) bool
+		key     func(i int) group
+	}{
+		{"value", []query.GroupBy{{Field: "x"}}, nil, nil, func(i int) group { return group{x: x(i)} }},
+		{"bucket", []query.GroupBy{{Field: "x", Bucket: 3}}, nil, nil, func(i int) group { return group{x: x(i) / 3 * 3} }},
+		{"two columns", []query.GroupBy{{Field: "x"}, {Field: "y", Alias: "yy"}}, nil, nil, func(i int) group { return group{x: x(i), y: y(i)} }},
+		{
+			"filtered",
+			[]query.GroupBy{{Field: "x"}},
+			[]query.FilterCondition{{Field: "x", Operand: query.GT, Arguments: []any{uint64(4)}}},
+			func(i int) bool { return x(i) > 4 },
+			func(i int) group { return group{x: x(i)} },
+		},
+	} {
+		t.Run(it.name, func(t *testing.T) {
+
+			counts := map[group]int{}
+			sums := map[group]float64{}
+			for i := range rows {
+				if it.match != nil && !it.match(i) {
+					continue
+				}
+				key := it.key(i)
+				counts[key]++
+				sums[key] += float64(y(i))
+			}
+
+			data := testQuery(t, m, "groups", query.Query{
+				Filter:  it.filter,
+				GroupBy: it.groupBy,
+				Select: []query.Selector{
+					{Arguments: []any{"count"}, Alias: "count"},
+					{Arguments: []any{"sum", "y"}, Alias: "sum"},
+				},
+			})
+
+			if len(data["count"]) != len(counts) {
+				t.Fatalf("expected %d groups, got %d", len(counts), len(data["count"]))
+			}
+
+			for row := range data["count"] {
+
+				key := group{x: data["x"][row].(uint64)}
+				if len(it.groupBy) > 1 {
+					key.y = data["yy"][row].(uint64)
+				}
+
+				if row > 0 && data["x"][row-1].(uint64) > key.x {
+					t.Errorf("groups aren't ordered by key at row %d", row)
+				}
+
+				if data["count"][row].(int) != counts[key] || data["sum"][row].(float64) != sums[key] {
+					t.Errorf("group %+v : expected count %d and sum %f, got %v and %v", key, counts[key], sums[key], data["count"][row], data["sum"][row])
+				}
+			}
+		})
+	}
+}
+
+// projected rows come in global order across chunks
+func TestProjectionOrder(t *testing.T) {
+
+	// more than a single chunk of blocks
+	const rows = 400000
+
+	m := openTestManager(t, t.TempDir(), testSchema("rows"))
+	defer closeTestManager(t, m)
+
+	x := func(i int) uint64 { return uint64(i) * 2 }
+	y := func(i int) uint64 { return uint64(i % 1000) }
+
+	if ingestErr := m.Ingest("rows", testRowsOf(rows, x, y)); ingestErr != nil {
+		t.Fatal(ingestErr)
+	}
+
+	result, queryErr := m.Query("rows", query.Query{
+		Filter: []query.FilterCondition{{Field: "y", Operand: query.EQ, Arguments: []any{uint64(7)}}},
+		Select: []query.Selector{
+			{Type: query.SelectColumn, Arguments: []any{"y"}},
+			{Type: query.SelectColumn, Arguments: []any{"x"}, Alias: "doubled"},
+		},
+	}, t.Context())
+	if queryErr != nil {
+		t.Fatal(queryErr)
+	}
+
+	expected := []uint64{}
+	for i := range rows {
+		if y(i) == 7 {
+			expected = append(expected, uint64(i))
+		}
+	}
+
+	if len(result.RowIds) != len(expected) || len(result.Data["doubled"]) != len(expected) || len(result.Data["y"]) != len(expected) {
+		t.Fatalf("expected %d rows, got %d ids, %d and %d values", len(expected), len(result.RowIds), len(result.Data["doubled"]), len(result.Data["y"]))
+	}
+
+	for row, rowId := range expected {
+		if result.RowIds[row] != rowId {
+			t.Fatalf("row %d : expected id %d, got %d", row, rowId, result.RowIds[row])
+		}
+
+		if result.Data["doubled"][row].(uint64) != x(int(rowId)) || result.Data["y"][row].(uint64) != 7 {
+			t.Fatalf("row %d : values %v and %v don't belong to row %d", row, result.Data["doubled"][row], result.Data["y"][row], rowId)
+		}
+	}
+}
+
+func TestOrderByWithLimit(t *testing.T) {
+
+	const rows = 400000
+
+	m := openTestManager(t, t.TempDir(), testSchema("ordered"))
+	defer closeTestManager(t, m)
+
+	// unique values spread over all blocks
+	x := func(i int) uint64 { return uint64(i*7919%rows) + 1000 }
+	y := func(i int) uint64 { return uint64(i % 50) }
+
+	if ingestErr := m.Ingest("ordered", testRowsOf(rows, x, y)); ingestErr != nil {
+		t.Fatal(ingestErr)
+	}
+
+	for _, it := range []struct {
+		name    string
+		orderBy []query.OrderBy
+		filter  []query.FilterCondition
+		limit   int
+		match   func(i int) bool
+		compare func(a, b int) int
+	}{
+		{
+			"desc", []query.OrderBy{{Field: "x", Desc: true}}, nil, 10, nil,
+			func(a, b int) int { return -cmp.Compare(x(a), x(b)) },
+		},
+		{
+			"two columns", []query.OrderBy{{Field: "y"}, {Field: "x", Desc: true}}, nil, 25, nil,
+			func(a, b int) int {
+				if c := cmp.Compare(y(a), y(b)); c != 0 {
+					return c
+				}
+				return -cmp.Compare(x(a), x(b))
+			},
+		},
+		{
+			"filtered", []query.OrderBy{{Field: "x"}},
+			[]query.FilterCondition{{Field: "y", Operand: query.EQ, Arguments: []any{uint64(42)}}},
+			7, func(i int) bool { return y(i) == 42 },
+			func(a, b int) int { return cmp.Compare(x(a), x(b)) },
+		},
+	} {
+		t.Run(it.name, func(t *testing.T) {
+
+			expected := []int{}
+			for i := range rows {
+				if it.match == nil || it.match(i) {
+					expected = append(expected, i)
+				}
+			}
+
+			slices.SortFunc(expected, it.compare)
+			expected = expected[:it.limit]
+
+			result, queryErr := m.Query("ordered", query.Query{
+				Filter:  it.filter,
+				OrderBy: it.orderBy,
+				Limit:   it.limit,
+				Select:  []query.Selector{{Type: query.SelectColumn, Arguments: []any{"x"}}},
+			}, t.Context())
+			if queryErr != nil {
+				t.Fatal(queryErr)
+			}
+
+			if len(result.RowIds) != it.limit {
+				t.Fatalf("expected %d rows, got %d", it.limit, len(result.RowIds))
+			}
+
+			for row, rowId := range expected {
+				if result.RowIds[row] != uint64(rowId) || result.Data["x"][row].(uint64) != x(rowId) {
+					t.Fatalf("row %d : expected row %d with x %d, got row %d with x %v", row, rowId, x(rowId), result.RowIds[row], result.Data["x"][row])
+				}
+			}
+		})
+	}
+}
+
+func TestFilterTrees(t *testing.T) {
+
+	const rows = 400000
+
+	m := openTestManager(t, t.TempDir(), testSchema("trees"))
+	defer closeTestManager(t, m)
+
+	// x grows, so blocks are pruned by its bounds
+	x := func(i int) uint64 { return uint64(i) }
+	y := func(i int) uint64 { return uint64(i % 10) }
+
+	if ingestErr := m.Ingest("trees", testRowsOf(rows, x, y)); ingestErr != nil {
+		t.Fatal(ingestErr)
+	}
+
+	for _, it := range []struct {
+		name     string
+		where    query.FilterExpr
+		expected func(i int) bool
+	}{
+		{
+			"or of ranges",
+			query.Or(query.Cond("x", query.LT, uint64(1000)), query.Cond("x", query.GTE, uint64(399000))),
+			func(i int) bool { return i < 1000 || i >= 399000 },
+		},
+		{
+			"and of or and not",
+			query.And(
+				query.Or(query.Cond("y", query.EQ, uint64(1)), query.Cond("y", query.EQ, uint64(2))),
+				query.Not(query.Cond("x", query.LT, uint64(200000))),
+			),
+			func(i int) bool { return (y(i) == 1 || y(i) == 2) && i >= 200000 },
+		},
+		{
+			"not of or",
+			query.Not(query.Or(query.Cond("y", query.IN, uint64(3), uint64(4)), query.CondRange("x", uint64(50000), uint64(350000), query.RangeInclusive))),
+			func(i int) bool { return y(i) != 3 && y(i) != 4 && (i < 50000 || i > 350000) },
+		},
+		{
+			"nested",
+			query.Or(
+				query.And(query.Cond("x", query.LT, uint64(40000)), query.Cond("y", query.GT, uint64(7))),
+				query.And(query.Cond("x", query.GT, uint64(300000)), query.Not(query.Cond("y", query.NEQ, uint64(0)))),
+			),
+			func(i int) bool { return (i < 40000 && y(i) > 7) || (i > 300000 && y(i) == 0) },
+		},
+		{
+			"nothing",
+			query.And(query.Cond("x", query.LT, uint64(1000)), query.Cond("x", query.GT, uint64(2000))),
+			func(i int) bool { return false },
+		},
+	} {
+		t.Run(it.name, func(t *testing.T) {
+
+			expected := 0
+			for i := range rows {
+				if it.expected(i) {
+					expected++
+				}
+			}
+
+			where := it.where
+			data := testQuery(t, m, "trees", query.Query{Where: &where, Select: []query.Selector{
+				{Arguments: []any{"count"}, Alias: "count"},
+			}})
+
+			if count := data["count"][0].(int); count != expected {
+				t.Errorf("expected %d rows, got %d", expected, count)
+			}
+		})
+	}
+
+	// blocks between the ranges aren't processed
+	where := query.Or(query.Cond("x", query.LT, uint64(1000)), query.Cond("x", query.GTE, uint64(399000)))
+	result, queryErr := m.Query("trees", query.Query{Where: &where, Select: []query.Selector{{Arguments: []any{"count"}}}}, t.Context())
+	if queryErr != nil {
+		t.Fatal(queryErr)
+	}
+
+	if result.Metrics.ProcessedBlocks > 2 {
+		t.Errorf("expected at most 2 processed blocks, got %d", result.Metrics.ProcessedBlocks)
+	}
+}
+
+// column-vs-column and arithmetic conditions match the rows a scan evaluating them in float64 does
+func TestExpressionFilters(t *testing.T) {
+
+	const rows = 100000
+
+	m := openTestManager(t, t.TempDir(), testSchema("exprs"))
+	defer closeTestManager(t, m)
+
+	// x grows, so blocks are pruned by bounds of expressions over it
+	x := func(i int) uint64 { return uint64(i) }
+	y := func(i int) uint64 { return uint64(i * 7919 % 5000) }
+
+	if ingestErr := m.Ingest("exprs", testRowsOf(rows, x, y)); ingestErr != nil {
+		t.Fatal(ingestErr)
+	}
+
+	for _, it := range []struct {
+		name      string
+		condition query.FilterCondition
+		match     func(x, y float64) bool
+		maxBlocks int
+	}{
+		{
+			"column vs column",
+			query.FilterCondition{Field: "x", Operand: query.LT, Arguments: []any{query.Mul(query.Col("y"), query.Const(20))}},
+			func(x, y float64) bool { return x < y*20 },
+			4,
+		},
+		{
+			"sum",
+			query.FilterCondition{Expr: query.Add(query.Col("x"), query.Col("y")), Operand: query.LTE, Arguments: []any{uint64(3000)}},
+			func(x, y float64) bool { return x+y <= 3000 },
+			1,
+		},
+		{
+			"difference of unsigned columns",
+			query.FilterCondition{Expr: query.Sub(query.Col("y"), query.Col("x")), Operand: query.GT, Arguments: []any{uint64(0)}},
+			func(x, y float64) bool { return y-x > 0 },
+			1,
+		},
+		{
+			"division",
+			query.FilterCondition{Expr: query.Div(query.Sub(query.Col("x"), query.Col("y")), query.Const(4)), Operand: query.GTE, Arguments: []any{uint64(23750)}},
+			func(x, y float64) bool { return (x-y)/4 >= 23750 },
+			2,
+		},
+		{
+			"expressions on both sides",
+			query.FilterCondition{Expr: query.Add(query.Col("x"), query.Const(1)), Operand: query.LTE, Arguments: []any{query.Mul(query.Col("y"), query.Const(3))}},
+			func(x, y float64) bool { return x+1 <= y*3 },
+			1,
+		},
+	} {
+		t.Run(it.name, func(t *testing.T) {
+
+			expected := 0
+			for i := range rows {
+				if it.match(float64(x(i)), float64(y(i))) {
+					expected++
+				}
+			}
+
+			result, queryErr := m.Query("exprs", query.Query{
+				Filter: []query.FilterCondition{it.condition},
+				Select: []query.Selector{{Arguments: []any{"count"}, Alias: "count"}},
+			}, t.Context())
+			if queryErr != nil {
+				t.Fatal(queryErr)
+			}
+
+			if count := result.Data["count"][0].(int); count != expected {
+				t.Errorf("expected %d rows, got %d", expected, count)
+			}
+
+			if result.Metrics.ProcessedBlocks > it.maxBlocks {
+				t.Errorf("expected at most %d processed blocks, got %d", it.maxBlocks, result.Metrics.ProcessedBlocks)
+			}
+		})
+	}
+
+	// expression conditions in a filter tree
+	where := query.Or(
+		query.FilterExpr{Type: query.FilterLeaf, Condition: query.FilterCondition{Field: "x", Operand: query.LT, Arguments: []any{query.Col("y")}}},
+		query.Not(query.Cond("y", query.GT, uint64(10))),
+	)
+
+	expected := 0
+	for i := range rows {
+		if x(i) < y(i) || y(i) <= 10 {
+			expected++
+		}
+	}
+
+	if count := testQuery(t, m, "exprs", query.Query{Where: &where, Select: []query.Selector{{Arguments: []any{"count"}, Alias: "count"}}})["count"][0].(int); count != expected {
+		t.Errorf("filter tree : expected %d rows, got %d", expected, count)
+	}
+}
+
+// rows matched by expressions over negative values are the rows of a scan,
+// also when blocks are skipped or fully matched by bounds of expressions
+func TestExpressionRowsMatchScan(t *testing.T) {
+
+	const rows = 6 * schema.BlockRowsSize
+
+	m := openTestManager(t, t.TempDir(), schema.Schema{Name: "signed", Columns: []schema.SchemaColumn{
+		{Name: "id", Type: schema.Uint64FieldType},
+		{Name: "v", Type: schema.Float32FieldType},
+		{Name: "a", Type: schema.Float32FieldType},
+		{Name: "b", Type: schema.Float32FieldType},
+	}})
+	defer closeTestManager(t, m)
+
+	// v and a grow through negative values, b is noise around zero
+	v := func(i int) float32 { return float32(i - rows/2) }
+	a := func(i int) float32 { return float32(i*3 - rows) }
+	b := func(i int) float32 { return float32(i*7919%20000 - 10000) }
+
+	binData := make([]byte, 0, rows*20)
+	for i := range rows {
+		binData = binary.LittleEndian.AppendUint64(binData, uint64(i))
+		binData = binary.LittleEndian.AppendUint32(binData, math.Float32bits(v(i)))
+		binData = binary.LittleEndian.AppendUint32(binData, math.Float32bits(a(i)))
+		binData = binary.LittleEndian.AppendUint32(binData, math.Float32bits(b(i)))
+	}
+
+	if ingestErr := m.Ingest("signed", IngestBufferFromBinary(binData, []string{"id", "v", "a", "b"})); ingestErr != nil {
+		t.Fatal(ingestErr)
+	}
+
+	leaf := func(condition query.FilterCondition) query.FilterExpr {
+		return query.FilterExpr{Type: query.FilterLeaf, Condition: condition}
+	}
+
+	for _, it := range []struct {
+		name      string
+		where     query.FilterExpr
+		match     func(i int) bool
+		maxBlocks int
+	}{
+		{
+			"v*2 > 10",
+			leaf(query.FilterCondition{Expr: query.Mul(query.Col("v"), query.Const(2)), Operand: query.GT, Arguments: []any{10}}),
+			func(i int) bool { return v(i)*2 > 10 },
+			2,
+		},
+		{
+			"a-b >= 0",
+			leaf(query.FilterCondition{Expr: query.Sub(query.Col("a"), query.Col("b")), Operand: query.GTE, Arguments: []any{0}}),
+			func(i int) bool { return a(i)-b(i) >= 0 },
+			2,
+		},
+		{
+			"a-b < -150000",
+			leaf(query.FilterCondition{Expr: query.Sub(query.Col("a"), query.Col("b")), Operand: query.LT, Arguments: []any{-150000}}),
+			func(i int) bool { return a(i)-b(i) < -150000 },
+			1,
+		},
+		{
+			"not v*2 > 10",
+			query.Not(leaf(query.FilterCondition{Expr: query.Mul(query.Col("v"), query.Const(2)), Operand: query.GT, Arguments: []any{10}})),
+			func(i int) bool { return v(i)*2 <= 10 },
+			2,
+		},
+		{
+			"v*3 > a matches no row",
+			leaf(query.FilterCondition{Expr: query.Mul(query.Col("v"), query.Const(3)), Operand: query.GT, Arguments: []any{query.Col("a")}}),
+			func(i int) bool { return v(i)*3 > a(i) },
+			1,
+		},
+		{
+			"a > v*4 matches every row",
+			leaf(query.FilterCondition{Field: "a", Operand: query.GT, Arguments: []any{query.Mul(query.Col("v"), query.Const(4))}}),
+			func(i int) bool { return a(i) > v(i)*4 },
+			4,
+		},
+		{
+			"(a-b)/2 <= v and b < 0",
+			query.And(
+				leaf(query.FilterCondition{Expr: query.Div(query.Sub(query.Col("a"), query.Col("b")), query.Const(2)), Operand: query.LTE, Arguments: []any{query.Col("v")}}),
+				query.Cond("b", query.LT, float32(0)),
+			),
+			func(i int) bool { return (a(i)-b(i))/2 <= v(i) && b(i) < 0 },
+			6,
+		},
+	} {
+		t.Run(it.name, func(t *testing.T) {
+
+			expected := []any{}
+			for i := range rows {
+				if it.match(i) {
+					expected = append(expected, uint64(i))
+				}
+			}
+
+			where := it.where
+			result, queryErr := m.Query("signed", query.Query{Where: &where, Select: []query.Selector{
+				{Type: query.SelectColumn, Arguments: []any{"id"}},
+			}}, t.Context())
+			if queryErr != nil {
+				t.Fatal(queryErr)
+			}
+
+			if !slices.Equal(result.Data["id"], expected) {
+				t.Errorf("expected %d rows, got %d rows which differ from scan", len(expected), len(result.Data["id"]))
+			}
+
+			if result.Metrics.ProcessedBlocks > it.maxBlocks {
+				t.Errorf("expected at most %d processed blocks, got %d", it.maxBlocks, result.Metrics.ProcessedBlocks)
+			}
+		})
+	}
+}
+
+// strings are filtered and projected through slab dictionaries, also after restart
+func TestDictionaryStrings(t *testing.T) {
+
+	const rows = 100000
+
+	dir := t.TempDir()
+
+	m := openTestManager(t, dir, schema.Schema{Name: "strs", Columns: []schema.SchemaColumn{
+		{Name: "id", Type: schema.Uint64FieldType},
+		{Name: "name", Type: schema.StringFieldType},
+	}})
+
+	names := []string{"alpha", "beta", "alpine", "gamma", ""}
+	name := func(i int) string { return names[i%len(names)] }
+
+	data := IngestBufferFromBinary(nil, []string{"id", "name"})
+	for i := range rows {
+		data.dataBuffer = binary.LittleEndian.AppendUint64(data.dataBuffer, uint64(i))
+		data.dataBuffer = binary.LittleEndian.AppendUint32(data.dataBuffer, data.AddString(name(i)))
+	}
+
+	if ingestErr := m.Ingest("strs", data); ingestErr != nil {
+		t.Fatal(ingestErr)
+	}
+
+	check := func(m *Manager) {
+
+		for _, it := range []struct {
+			name     string
+			where    query.FilterExpr
+			expected func(i int) bool
+		}{
+			{"eq", query.Cond("name", query.EQ, "beta"), func(i int) bool { return name(i) == "beta" }},
+			{"neq", query.Cond("name", query.NEQ, "beta"), func(i int) bool { return name(i) != "beta" }},
+			{"in", query.Cond("name", query.IN, "gamma", "missing", ""), func(i int) bool { return name(i) == "gamma" || name(i) == "" }},
+			{"not in", query.Cond("name", query.NOT_IN, "alpha", "beta"), func(i int) bool { return name(i) != "alpha" && name(i) != "beta" }},
+			{"prefix", query.Cond("name", query.PREFIX, "alp"), func(i int) bool { return strings.HasPrefix(name(i), "alp") }},
+			{"unknown", query.Cond("name", query.EQ, "delta"), func(i int) bool { return false }},
+		} {
+			expected := 0
+			for i := range rows {
+				if it.expected(i) {
+					expected++
+				}
+			}
+
+			where := it.where
+			data := testQuery(t, m, "strs", query.Query{Where: &where, Select: []query.Selector{
+				{Arguments: []any{"count"}, Alias: "count"},
+			}})
+
+			if count := data["count"][0].(int); count != expected {
+				t.Errorf("%s : expected %d rows, got %d", it.name, expected, count)
+			}
+		}
+
+		result, queryErr := m.Query("strs", query.Query{
+			Filter: []query.FilterCondition{{Field: "id", Operand: query.RANGE, Arguments: []any{uint64(70000), uint64(70009)}}},
+			Select: []query.Selector{{Type: query.SelectColumn, Arguments: []any{"name"}}},
+		}, t.Context())
+		if queryErr != nil {
+			t.Fatal(queryErr)
+		}
+
+		if len(result.Data["name"]) != 10 {
+			t.Fatalf("expected 10 names, got %d", len(result.Data["name"]))
+		}
+
+		for row, value := range result.Data["name"] {
+			if value.(string) != name(70000+row) {
+				t.Errorf("row %d : expected `%s`, got `%v`", 70000+row, name(70000+row), value)
+			}
+		}
+	}
+
+	check(m)
+	closeTestManager(t, m)
+
+	// dictionaries are read from disk after restart
+	m = openTestManager(t, dir)
+	defer closeTestManager(t, m)
+
+	check(m)
+}
+
+// ingests values of a column type with its min and max values between negative and positive ones,
+// aggregates over filtered rows are compared with a scan
+func testTypeAggregates[T int8 | int16 | int32 | int64 | float64](t *testing.T, typ schema.FieldType, minVal, maxVal T, small func(i int) T) {
+
+	const rows = 100000
+
+	m := openTestManager(t, t.TempDir(), schema.Schema{Name: "typed", Columns: []schema.SchemaColumn{
+		{Name: "v", Type: typ},
+	}})
+	defer closeTestManager(t, m)
+
+	value := func(i int) T {
+		switch i % 1000 {
+		case 500:
+			return minVal
+		case 501:
+			return maxVal
+		}
+		return small(i)
+	}
+
+	values := make([]T, rows)
+	binData := make([]byte, 0, rows*int(typ.Size()))
+	for i := range rows {
+		values[i] = value(i)
+		binData, _ = binary.Append(binData, binary.LittleEndian, values[i])
+	}
+
+	if ingestErr := m.Ingest("typed", IngestBufferFromBinary(binData, []string{"v"})); ingestErr != nil {
+		t.Fatal(ingestErr)
+	}
+
+	for _, it := range []struct {
+		name  string
+		where query.FilterExpr
+		match func(v T) bool
+	}{
+		{"negative", query.Cond("v", query.LT, T(0)), func(v T) bool { return v < 0 }},
+		{"not negative", query.Cond("v", query.GTE, T(0)), func(v T) bool { return v >= 0 }},
+		{"min", query.Cond("v", query.EQ, minVal), func(v T) bool { return v == minVal }},
+		{"max", query.Cond("v", query.EQ, maxVal), func(v T) bool { return v == maxVal }},
+		{"up to min", query.Cond("v", query.LTE, minVal), func(v T) bool { return v <= minVal }},
+		{"beyond max", query.Cond("v", query.GT, maxVal), func(v T) bool { return false }},
+		{"from min to -1", query.CondRange("v", minVal, T(-1), query.RangeInclusive), func(v T) bool { return v <= -1 }},
+		{"between min and max", query.CondRange("v", minVal, maxVal, query.RangeExclusive), func(v T) bool { return v != minVal && v != maxVal }},
+		{"not min or max", query.Cond("v", query.NOT_IN, minVal, maxVal), func(v T) bool { return v != minVal && v != maxVal }},
+		{"all", query.Or(query.Cond("v", query.LT, T(0)), query.Cond("v", query.GTE, T(0))), func(T) bool { return true }},
+	} {
+		t.Run(it.name, func(t *testing.T) {
+
+			count := 0
+			sum, absSum := 0.0, 0.0
+			var minV, maxV any
+
+			for _, v := range values {
+				if !it.match(v) {
+					continue
+				}
+
+				f := float64(v)
+				if count == 0 || f < minV.(float64) {
+					minV = f
+				}
+				if count == 0 || f > maxV.(float64) {
+					maxV = f
+				}
+
+				count++
+				sum += f
+				absSum += math.Abs(f)
+			}
+
+			where := it.where
+			data := testQuery(t, m, "typed", query.Query{Where: &where, Select: []query.Selector{
+				{Arguments: []any{"count"}, Alias: "count"},
+				{Arguments: []any{"sum", "v"}, Alias: "sum"},
+				{Arguments: []any{"min", "v"}, Alias: "min"},
+				{Arguments: []any{"max", "v"}, Alias: "max"},
+			}})
+
+			if data["count"][0] != count || data["min"][0] != minV || data["max"][0] != maxV {
+				t.Fatalf("expected %d rows, min %v, max %v, got %v rows, min %v, max %v", count, minV, maxV, data["count"][0], data["min"][0], data["max"][0])
+			}
+
+			// float64 sums of the widest values depend on order of addition,
+			// sum of both float64 extremes is off by more than any tolerance
+			if count > 0 && !math.IsInf(absSum, 0) {
+				if got := data["sum"][0].(float64); math.Abs(got-sum) > absSum*1e-12 {
+					t.Errorf("expected sum %v, got %v", sum, got)
+				}
+			}
+		})
+	}
+}
+
+func TestTypeAggregates(t *testing.T) {
+
+	t.Run("int8", func(t *testing.T) {
+		testTypeAggregates(t, schema.Int8FieldType, int8(math.MinInt8), int8(math.MaxInt8), func(i int) int8 { return int8(i%201 - 100) })
+	})
+
+	t.Run("int16", func(t *testing.T) {
+		testTypeAggregates(t, schema.Int16FieldType, int16(math.MinInt16), int16(math.MaxInt16), func(i int) int16 { return int16(i*7919%20001 - 10000) })
+	})
+
+	t.Run("int32", func(t *testing.T) {
+		testTypeAggregates(t, schema.Int32FieldType, int32(math.MinInt32), int32(math.MaxInt32), func(i int) int32 { return int32(i*7919%2000001 - 1000000) })
+	})
+
+	t.Run("int64", func(t *testing.T) {
+		testTypeAggregates(t, schema.Int64FieldType, int64(math.MinInt64), int64(math.MaxInt64), func(i int) int64 { return int64(i*7919%2000001-1000000) << 20 })
+	})
+
+	t.Run("float64", func(t *testing.T) {
+		testTypeAggregates(t, schema.Float64FieldType, -math.MaxFloat64, math.MaxFloat64, func(i int) float64 { return float64(i*7919%20001-10000) / 7 })
+	})
+}
+
+// aggregates of a nullable column skip rows without value
+func TestNullableAggregates(t *testing.T) {
+
+	const rows = 100000
+
+	m := openTestManager(t, t.TempDir(), schema.Schema{Name: "nullable", Columns: []schema.SchemaColumn{
+		{Name: "ts", Type: schema.Uint64FieldType},
+		{Name: "val", Type: schema.Float32FieldType, Nullable: true},
+	}})
+	defer closeTestManager(t, m)
+
+	// every 3rd row has no value, rows of the second block have none at all
+	valNull := func(i int) bool { return i%3 == 0 || (i >= 32768 && i < 65536) }
+	val := func(i int) float32 { return float32(i%100) / 4 }
+
+	data := IngestBufferFromBinary(nil, []string{"ts", "val"})
+	for i := range rows {
+		data.dataBuffer = binary.LittleEndian.AppendUint64(data.dataBuffer, uint64(i))
+		data.dataBuffer = binary.LittleEndian.AppendUint32(data.dataBuffer, math.Float32bits(val(i)))
+		if valNull(i) {
+			data.SetNull("val", i)
+		}
+	}
+
+	if ingestErr := m.Ingest("nullable", data); ingestErr != nil {
+		t.Fatal(ingestErr)
+	}
+
+	selectors := []query.Selector{
+		{Arguments: []any{"count"}, Alias: "rows"},
+		{Arguments: []any{"count", "val"}, Alias: "count"},
+		{Arguments: []any{"sum", "val"}, Alias: "sum"},
+		{Arguments: []any{"avg", "val"}, Alias: "avg"},
+		{Arguments: []any{"min", "val"}, Alias: "min"},
+		{Arguments: []any{"max", "val"}, Alias: "max"},
+	}
+
+	// aggregates of rows [from, to)
+	expected := func(from, to int) map[string]any {
+		count, sum := 0, 0.0
+		minVal, maxVal := float64(1000), float64(-1)
+		for i := from; i < to; i++ {
+			if valNull(i) {
+				continue
+			}
+			count++
+			sum += float64(val(i))
+			minVal = min(minVal, float64(val(i)))
+			maxVal = max(maxVal, float64(val(i)))
+		}
+
+		if count == 0 {
+			return map[string]any{"rows": to - from, "count": 0, "sum": 0.0, "avg": nil, "min": nil, "max": nil}
+		}
+
+		return map[string]any{"rows": to - from, "count": count, "sum": sum, "avg": sum / float64(count), "min": minVal, "max": maxVal}
+	}
+
+	compare := func(label string, got map[string][]any, row int, want map[string]any) {
+		for name, value := range want {
+			if fmt.Sprint(got[name][row]) != fmt.Sprint(value) {
+				t.Errorf("%s : expected %s %v, got %v", label, name, value, got[name][row])
+			}
+		}
+	}
+
+	compare("all rows", testQuery(t, m, "nullable", query.Query{Select: selectors}), 0, expected(0, rows))
+
+	// second bucket has no values
+	groups := testQuery(t, m, "nullable", query.Query{Select: selectors, GroupBy: []query.GroupBy{{Field: "ts", Bucket: 32768}}})
+	if len(groups["rows"]) != 4 {
+		t.Fatalf("expected 4 groups, got %d", len(groups["rows"]))
+	}
+
+	for row := range 4 {
+		compare(fmt.Sprintf("group %d", row), groups, row, expected(row*32768, min((row+1)*32768, rows)))
+	}
+}
+
+// nanosecond timestamps next to each other share rounded float64 block bounds,
+// conditions on them still match the rows a scan does
+func TestNanosecondTimestampBounds(t *testing.T) {
+
+	const rows = 40000
+
+	m := openTestManager(t, t.TempDir(), schema.Schema{Name: "events", Columns: []schema.SchemaColumn{
+		{Name: "t", Type: schema.TimestampFieldType, Precision: schema.PrecisionNanos},
+	}})
+	defer closeTestManager(t, m)
+
+	base := int64(1_700_000_000_000_000_000)
+	ts := func(i int) int64 { return base + int64(i) }
+
+	binData := make([]byte, 0, rows*8)
+	for i := range rows {
+		binData = binary.LittleEndian.AppendUint64(binData, uint64(ts(i)))
+	}
+
+	if ingestErr := m.Ingest("events", IngestBufferFromBinary(binData, []string{"t"})); ingestErr != nil {
+		t.Fatal(ingestErr)
+	}
+
+	at := func(v int64) time.Time { return time.Unix(0, v) }
+
+	// 32767 is the last row of the first block
+	for _, it := range []struct {
+		name     string
+		where    query.FilterExpr
+		expected func(v int64) bool
+	}{
+		{"gt", query.Cond("t", query.GT, at(base+7332)), func(v int64) bool { return v > base+7332 }},
+		{"gt next to last of block", query.Cond("t", query.GT, at(base+32766)), func(v int64) bool { return v > base+32766 }},
+		{"gte", query.Cond("t", query.GTE, at(base+1)), func(v int64) bool { return v >= base+1 }},
+		{"lt", query.Cond("t", query.LT, at(base+1)), func(v int64) bool { return v < base+1 }},
+		{"lte", query.Cond("t", query.LTE, at(base+32768)), func(v int64) bool { return v <= base+32768 }},
+		{"eq", query.Cond("t", query.EQ, at(base+39999)), func(v int64) bool { return v == base+39999 }},
+		{"neq", query.Cond("t", query.NEQ, at(base)), func(v int64) bool { return v != base }},
+		{"in", query.Cond("t", query.IN, at(base+1), at(base+32768)), func(v int64) bool { return v == base+1 || v == base+32768 }},
+		{
+			"exclusive range",
+			query.CondRange("t", at(base+32767), at(base+32769), query.RangeExclusive),
+			func(v int64) bool { return v > base+32767 && v < base+32769 },
+		},
+	} {
+		t.Run(it.name, func(t *testing.T) {
+
+			expected := 0
+			for i := range rows {
+				if it.expected(ts(i)) {
+					expected++
+				}
+			}
+
+			where := it.where
+			data := testQuery(t, m, "events", query.Query{Where: &where, Select: []query.Selector{
+				{Arguments: []any{"count"}, Alias: "count"},
+			}})
+
+			if count := data["count"][0].(int); count != expected {
+				t.Errorf("expected %d rows, got %d", expected, count)
+			}
+		})
+	}
+}
+
+// int64 and uint64 values past 2^53 share rounded float64 block bounds,
+// conditions on them still match the rows a scan does
+func TestWideIntegerBounds(t *testing.T) {
+
+	const rows = 40000
+
+	m := openTestManager(t, t.TempDir(), schema.Schema{Name: "wide", Columns: []schema.SchemaColumn{
+		{Name: "i", Type: schema.Int64FieldType},
+		{Name: "u", Type: schema.Uint64FieldType},
+	}})
+	defer closeTestManager(t, m)
+
+	// last row holds the max value of the type
+	i64 := func(i int) int64 { return math.MaxInt64 - int64(rows-1-i) }
+	u64 := func(i int) uint64 { return math.MaxUint64 - uint64(rows-1-i) }
+
+	binData := make([]byte, 0, rows*16)
+	for i := range rows {
+		binData = binary.LittleEndian.AppendUint64(binData, uint64(i64(i)))
+		binData = binary.LittleEndian.AppendUint64(binData, u64(i))
+	}
+
+	if ingestErr := m.Ingest("wide", IngestBufferFromBinary(binData, []string{"i", "u"})); ingestErr != nil {
+		t.Fatal(ingestErr)
+	}
+
+	for _, it := range []struct {
+		name     string
+		where    query.FilterExpr
+		expected func(i int) bool
+	}{
+		{"int64 gt", query.Cond("i", query.GT, int64(math.MaxInt64-1)), func(i int) bool { return i64(i) > math.MaxInt64-1 }},
+		{"int64 gte", query.Cond("i", query.GTE, i64(1)), func(i int) bool { return i >= 1 }},
+		{"int64 lt", query.Cond("i", query.LT, i64(1)), func(i int) bool { return i < 1 }},
+		{"int64 eq", query.Cond("i", query.EQ, int64(math.MaxInt64)), func(i int) bool { return i == rows-1 }},
+		{"int64 neq", query.Cond("i", query.NEQ, i64(0)), func(i int) bool { return i != 0 }},
+		{"uint64 gt", query.Cond("u", query.GT, uint64(math.MaxUint64-1)), func(i int) bool { return u64(i) > math.MaxUint64-1 }},
+		{"uint64 lte", query.Cond("u", query.LTE, u64(32766)), func(i int) bool { return i <= 32766 }},
+		{"uint64 range", query.CondRange("u", u64(32767), u64(32769), query.RangeExclusive), func(i int) bool { return i == 32768 }},
+	} {
+		t.Run(it.name, func(t *testing.T) {
+
+			expected := 0
+			for i := range rows {
+				if it.expected(i) {
+					expected++
+				}
+			}
+
+			where := it.where
+			data := testQuery(t, m, "wide", query.Query{Where: &where, Select: []query.Selector{
+				{Arguments: []any{"count"}, Alias: "count"},
+			}})
+
+			if count := data["count"][0].(int); count != expected {
+				t.Errorf("expected %d rows, got %d", expected, count)
+			}
+		})
+	}
+}
+
+// arguments integer columns can't hold are rounded to the nearest values matching the same rows
+func TestFractionalArguments(t *testing.T) {
+
+	const rows = 20000
+
+	m := openTestManager(t, t.TempDir(), schema.Schema{Name: "fractions", Columns: []schema.SchemaColumn{
+		{Name: "v", Type: schema.Int64FieldType},
+		{Name: "s", Type: schema.Int8FieldType},
+	}})
+	defer closeTestManager(t, m)
+
+	v := func(i int) int64 { return int64(i%41) - 20 }
+	s := func(i int) int8 { return int8(i%256 - 128) }
+
+	binData := make([]byte, 0, rows*9)
+	for i := range rows {
+		binData = binary.LittleEndian.AppendUint64(binData, uint64(v(i)))
+		binData = append(binData, byte(s(i)))
+	}
+
+	if ingestErr := m.Ingest("fractions", IngestBufferFromBinary(binData, []string{"v", "s"})); ingestErr != nil {
+		t.Fatal(ingestErr)
+	}
+
+	for _, it := range []struct {
+		name     string
+		where    query.FilterExpr
+		expected func(i int) bool
+	}{
+		{"gt", query.Cond("v", query.GT, 3.5), func(i int) bool { return float64(v(i)) > 3.5 }},
+		{"gte", query.Cond("v", query.GTE, -3.5), func(i int) bool { return float64(v(i)) >= -3.5 }},
+		{"lt", query.Cond("v", query.LT, 3.5), func(i int) bool { return float64(v(i)) < 3.5 }},
+		{"lte", query.Cond("v", query.LTE, -3.5), func(i int) bool { return float64(v(i)) <= -3.5 }},
+		{"eq", query.Cond("v", query.EQ, 3.5), func(i int) bool { return false }},
+		{"neq", query.Cond("v", query.NEQ, 3.5), func(i int) bool { return true }},
+		{"in", query.Cond("v", query.IN, 3.5, 4, -7.25), func(i int) bool { return v(i) == 4 }},
+		{"not in", query.Cond("v", query.NOT_IN, 3.5, 4), func(i int) bool { return v(i) != 4 }},
+		{"not gt", query.Not(query.Cond("v", query.GT, 3.5)), func(i int) bool { return float64(v(i)) <= 3.5 }},
+		{"range", query.CondRange("v", -2.5, 7, query.RangeExclusive), func(i int) bool { return float64(v(i)) > -2.5 && v(i) < 7 }},
+		{"reversed range", query.CondRange("v", 7.5, -2.5, query.RangeInclusive), func(i int) bool { return float64(v(i)) >= -2.5 && float64(v(i)) <= 7.5 }},
+		{"range between integers", query.CondRange("v", 3.2, 3.7, query.RangeInclusive), func(i int) bool { return false }},
+		{"int8 gt beyond max", query.Cond("s", query.GT, 1000), func(i int) bool { return false }},
+		{"int8 lt beyond max", query.Cond("s", query.LT, 1000), func(i int) bool { return true }},
+		{"int8 gte below min", query.Cond("s", query.GTE, -128.5), func(i int) bool { return true }},
+		{"int8 lte", query.Cond("s", query.LTE, -127.5), func(i int) bool { return s(i) == math.MinInt8 }},
+	} {
+		t.Run(it.name, func(t *testing.T) {
+
+			expected := 0
+			for i := range rows {
+				if it.expected(i) {
+					expected++
+				}
+			}
+
+			where := it.where
+			data := testQuery(t, m, "fractions", query.Query{Where: &where, Select: []query.Selector{
+				{Arguments: []any{"count"}, Alias: "count"},
+			}})
+
+			if count := data["count"][0].(int); count != expected {
+				t.Errorf("expected %d rows, got %d", expected, count)
+			}
+		})
+	}
+}
@@ -0,0 +1,521 @@
+package manager
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// ingest batches of a schema are appended to its log and synced before they are applied to slabs,
+// log is truncated once slabs and schema of the batch are synced.
+// ingests into a schema are serialized, so the log holds a single batch unless one failed.
+//
+// record is stored as [payload size u32][crc32 of payload u32][payload],
+// a record with broken size or checksum is a tail of interrupted append
+type ingestWal struct {
+	path string
+	file *os.File
+
+	// log holds a batch that failed to apply, it's replayed on the next start.
+	// rows of the batch may be partly written, so further ingests are rejected until then
+	failed bool
+
+	lock sync.Mutex
+}
+
+// ingest batch as stored in the log
+type walRecord struct {
+	Schema string
+
+	// rows of every column before the batch,
+	// rows above that are already applied when the record is replayed
+	ColumnRows map[string]uint64
+
+	Layout  []string
+	Strings []string
+	Nulls   map[string][]bool
+	Data    []byte
+
+	// values of each layout column, batches of columnar data have no rows
+	Columns [][]byte
+}
+
+const (
+	walRecordHeaderSize = 8
+	walFileName         = "ingest.wal"
+)
+
+var errWalRecordBroken = errors.New("broken wal record")
+
+var ErrIngestFailed = errors.New("schema has a batch that failed to apply, ingests are rejected until it's replayed on the next start")
+
+func openIngestWal(schemaPath string) (*ingestWal, error) {
+
+	mkdirErr := os.MkdirAll(schemaPath, 0755)
+	if mkdirErr != nil {
+		return nil, fmt.Errorf("unable to create schema directory : %s", mkdirErr.Error())
+	}
+
+	path := filepath.Join(schemaPath, walFileName)
+
+	f, openErr := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if openErr != nil {
+		return nil, fmt.Errorf("unable to open wal : %s", openErr.Error())
+	}
+
+	return &ingestWal{path: path, file: f}, nil
+}
+
+// log of the schema, opened on first use
+func (m *Manager) schemaWal(schemaName string) (*ingestWal, error) {
+
+	m.walsLock.Lock()
+	defer m.walsLock.Unlock()
+
+	if m.wals == nil {
+		return nil, ErrManagerClosed
+	}
+
+	if wal, ok := m.wals[schemaName]; ok {
+		return wal, nil
+	}
+
+	wal, openErr := openIngestWal(filepath.Join(m.config.PathToStorage, schemaName))
+	if openErr != nil {
+		return nil, openErr
+	}
+
+	m.wals[schemaName] = wal
+
+	return wal, nil
+}
+
+func (m *Manager) closeWals() error {
+
+	m.walsLock.Lock()
+	defer m.walsLock.Unlock()
+
+	var closeErr error
+	for name, wal := range m.wals {
+		if err := wal.Close(); err != nil && closeErr == nil {
+			closeErr = fmt.Errorf("unable to close wal of `%s` : %s", name, err.Error())
+		}
+	}
+
+	m.wals = nil
+
+	return closeErr
+}
+
+func (w *ingestWal) Append(record *walRecord) error {
+
+	payload := record.encode()
+
+	buf := make([]byte, walRecordHeaderSize, walRecordHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(buf, uint32(len(payload)))
+	binary.LittleEndian.PutUint32(buf[4:], crc32.ChecksumIEEE(payload))
+	buf = append(buf, payload...)
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.failed {
+		return ErrIngestFailed
+	}
+
+	_, writeErr := w.file.Write(buf)
+	if writeErr != nil {
+		return fmt.Errorf("unable to append to wal : %s", writeErr.Error())
+	}
+
+	syncErr := w.file.Sync()
+	if syncErr != nil {
+		return fmt.Errorf("unable to sync wal : %s", syncErr.Error())
+	}
+
+	return nil
+}
+
+// marks appended batch as done, log of applied batch is truncated
+func (w *ingestWal) Applied(ok bool) error {
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if !ok {
+		w.failed = true
+		return nil
+	}
+
+	return w.truncate()
+}
+
+func (w *ingestWal) Failed() bool {
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.failed
+}
+
+// complete records of the log, broken tail is skipped
+func (w *ingestWal) Records() ([]*walRecord, error) {
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	contents, readErr := os.ReadFile(w.path)
+	if readErr != nil {
+		return nil, fmt.Errorf("unable to read wal : %s", readErr.Error())
+	}
+
+	records := []*walRecord{}
+	pos := 0
+
+	for pos+walRecordHeaderSize <= len(contents) {
+
+		size := int(binary.LittleEndian.Uint32(contents[pos:]))
+		checksum := binary.LittleEndian.Uint32(contents[pos+4:])
+
+		start := pos + walRecordHeaderSize
+		if start+size > len(contents) {
+			break
+		}
+
+		payload := contents[start : start+size]
+		if crc32.ChecksumIEEE(payload) != checksum {
+			break
+		}
+
+		record, decodeErr := decodeWalRecord(payload)
+		if decodeErr != nil {
+			break
+		}
+
+		records = append(records, record)
+		pos = start + size
+	}
+
+	return records, nil
+}
+
+func (w *ingestWal) Truncate() error {
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	return w.truncate()
+}
+
+func (w *ingestWal) truncate() error {
+
+	truncateErr := w.file.Truncate(0)
+	if truncateErr != nil {
+		return fmt.Errorf("unable to truncate wal : %s", truncateErr.Error())
+	}
+
+	syncErr := w.file.Sync()
+	if syncErr != nil {
+		return fmt.Errorf("unable to sync wal : %s", syncErr.Error())
+	}
+
+	return nil
+}
+
+func (w *ingestWal) Close() error {
+	return w.file.Close()
+}
+
+// fields are written as uvarint counts and sizes followed by contents,
+// maps are written in order of keys
+func (r *walRecord) encode() []byte {
+
+	buf := []byte{}
+
+	putBytes := func(b []byte) {
+		buf = binary.AppendUvarint(buf, uint64(len(b)))
+		buf = append(buf, b...)
+	}
+
+	putStrings := func(values []string) {
+		buf = binary.AppendUvarint(buf, uint64(len(values)))
+		for _, it := range values {
+			putBytes([]byte(it))
+		}
+	}
+
+	putBytes([]byte(r.Schema))
+
+	columns := sortedKeys(r.ColumnRows)
+	buf = binary.AppendUvarint(buf, uint64(len(columns)))
+	for _, column := range columns {
+		putBytes([]byte(column))
+		buf = binary.AppendUvarint(buf, r.ColumnRows[column])
+	}
+
+	putStrings(r.Layout)
+	putStrings(r.Strings)
+
+	nullColumns := sortedKeys(r.Nulls)
+	buf = binary.AppendUvarint(buf, uint64(len(nullColumns)))
+	for _, column := range nullColumns {
+		putBytes([]byte(column))
+
+		nulls := r.Nulls[column]
+		packed := make([]byte, (len(nulls)+7)/8)
+		for row, isNull := range nulls {
+			if isNull {
+				packed[row/8] |= 1 << (row % 8)
+			}
+		}
+
+		buf = binary.AppendUvarint(buf, uint64(len(nulls)))
+		buf = append(buf, packed...)
+	}
+
+	putBytes(r.Data)
+
+	// columns go last, so records without them are read as before
+	if r.Columns != nil {
+		buf = binary.AppendUvarint(buf, uint64(len(r.Columns)))
+		for _, column := range r.Columns {
+			putBytes(column)
+		}
+	}
+
+	return buf
+}
+
+func decodeWalRecord(payload []byte) (*walRecord, error) {
+
+	pos := 0
+
+	getUvarint := func() (uint64, error) {
+		v, n := binary.Uvarint(payload[pos:])
+		if n <= 0 {
+			return 0, errWalRecordBroken
+		}
+		pos += n
+		return v, nil
+	}
+
+	getBytes := func() ([]byte, error) {
+		size, sizeErr := getUvarint()
+		if sizeErr != nil || uint64(len(payload)-pos) < size {
+			return nil, errWalRecordBroken
+		}
+		b := payload[pos : pos+int(size)]
+		pos += int(size)
+		return b, nil
+	}
+
+	getStrings := func() ([]string, error) {
+		count, countErr := getUvarint()
+		if countErr != nil || count > uint64(len(payload)) {
+			return nil, errWalRecordBroken
+		}
+		values := make([]string, count)
+		for idx := range values {
+			b, err := getBytes()
+			if err != nil {
+				return nil, err
+			}
+			values[idx] = string(b)
+		}
+		return values, nil
+	}
+
+	record := &walRecord{
+		ColumnRows: map[string]uint64{},
+		Nulls:      map[string][]bool{},
+	}
+
+	schemaName, err := getBytes()
+	if err != nil {
+		return nil, err
+	}
+	record.Schema = string(schemaName)
+
+	columns, err := getUvarint()
+	if err != nil {
+		return nil, err
+	}
+
+	for range columns {
+		column, err := getBytes()
+		if err != nil {
+			return nil, err
+		}
+
+		rows, err := getUvarint()
+		if err != nil {
+			return nil, err
+		}
+
+		record.ColumnRows[string(column)] = rows
+	}
+
+	if record.Layout, err = getStrings(); err != nil {
+		return nil, err
+	}
+
+	if record.Strings, err = getStrings(); err != nil {
+		return nil, err
+	}
+
+	nullColumns, err := getUvarint()
+	if err != nil {
+		return nil, err
+	}
+
+	for range nullColumns {
+		column, err := getBytes()
+		if err != nil {
+			return nil, err
+		}
+
+		rows, err := getUvarint()
+		if err != nil || uint64(len(payload)-pos) < (rows+7)/8 {
+			return nil, errWalRecordBroken
+		}
+
+		packed := payload[pos : pos+int((rows+7)/8)]
+		pos += len(packed)
+
+		nulls := make([]bool, rows)
+		for row := range nulls {
+			nulls[row] = packed[row/8]&(1<<(row%8)) != 0
+		}
+
+		record.Nulls[string(column)] = nulls
+	}
+
+	data, err := getBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	// payload is a part of the log contents
+	record.Data = slices.Clone(data)
+
+	if pos < len(payload) {
+		columns, err := getUvarint()
+		if err != nil || columns > uint64(len(payload)) {
+			return nil, errWalRecordBroken
+		}
+
+		record.Columns = make([][]byte, columns)
+		for idx := range record.Columns {
+			column, err := getBytes()
+			if err != nil {
+				return nil, err
+			}
+
+			record.Columns[idx] = slices.Clone(column)
+		}
+	}
+
+	return record, nil
+}
+
+// applies batches left in logs of schemas by interrupted ingests.
+// rows of the batch stored before the crash are skipped
+func (m *Manager) replayWal() error {
+
+	for _, schemaObject := range m.Meta.Schemas() {
+
+		wal, walErr := m.schemaWal(schemaObject.Name)
+		if walErr != nil {
+			return walErr
+		}
+
+		records, readErr := wal.Records()
+		if readErr != nil {
+			return readErr
+		}
+
+		for _, record := range records {
+			replayErr := m.replayRecord(record)
+			if replayErr != nil {
+				return replayErr
+			}
+		}
+
+		if len(records) == 0 {
+			continue
+		}
+
+		truncateErr := wal.Truncate()
+		if truncateErr != nil {
+			return truncateErr
+		}
+	}
+
+	return nil
+}
+
+// writes rows of the batch that didn't reach slabs before the crash.
+// batch with all rows on disk is already applied
+func (m *Manager) replayRecord(record *walRecord) error {
+
+	schemaObject := m.Meta.GetSchema(record.Schema)
+	if schemaObject == nil {
+		return fmt.Errorf("unable to replay wal, schema `%s` not found", record.Schema)
+	}
+
+	data := IngestBufferFromBinary(record.Data, record.Layout)
+	data.Strings = record.Strings
+	data.nulls = record.Nulls
+	data.columnData = record.Columns
+
+	fieldsLayout, prepareErr := m.prepareIngest(schemaObject, data)
+	if prepareErr != nil {
+		return fmt.Errorf("unable to replay wal : %s", prepareErr.Error())
+	}
+
+	defer m.unpinIngested(fieldsLayout)
+
+	leftover := 0
+
+	for _, field := range fieldsLayout {
+
+		rowsBefore, ok := record.ColumnRows[field.name]
+		rows := columnRows(field.slab.Header)
+
+		if !ok || rows < rowsBefore {
+			return fmt.Errorf("unable to replay wal, column `%s` of `%s` has %d rows, batch of %d rows was started at %d", field.name, record.Schema, rows, field.leftover, rowsBefore)
+		}
+
+		applied := int(min(rows-rowsBefore, uint64(field.leftover)))
+
+		field.ingested += applied
+		field.leftover -= applied
+
+		leftover += field.leftover
+	}
+
+	if leftover == 0 {
+		return nil
+	}
+
+	ingestErr := m.ingestPrepared(schemaObject, fieldsLayout)
+	if ingestErr != nil {
+		return fmt.Errorf("unable to replay wal : %s", ingestErr.Error())
+	}
+
+	return m.syncIngested(schemaObject, fieldsLayout)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
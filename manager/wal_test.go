@@ -0,0 +1,154 @@
+package manager
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWalRecordRoundTrip(t *testing.T) {
+
+	record := &walRecord{
+		Schema:     "checks",
+		ColumnRows: map[string]uint64{"x": 32768, "y": 32770},
+		Layout:     []string{"y", "x"},
+		Strings:    []string{"a", ""},
+		Nulls:      map[string][]bool{"y": {false, true, false, false, false, false, false, false, true}},
+		Data:       []byte{1, 2, 3, 4},
+		Columns:    [][]byte{{5, 6}, {}},
+	}
+
+	decoded, decodeErr := decodeWalRecord(record.encode())
+	if decodeErr != nil {
+		t.Fatalf("unable to decode : %s", decodeErr.Error())
+	}
+
+	if !reflect.DeepEqual(record, decoded) {
+		t.Errorf("decoded record differs : %+v", decoded)
+	}
+
+	// records of row data have no columns trailer
+	record.Columns = nil
+
+	decoded, decodeErr = decodeWalRecord(record.encode())
+	if decodeErr != nil {
+		t.Fatalf("unable to decode : %s", decodeErr.Error())
+	}
+
+	if decoded.Columns != nil {
+		t.Errorf("expected no columns, got %d", len(decoded.Columns))
+	}
+}
+
+func TestWalBrokenTailIsSkipped(t *testing.T) {
+
+	wal, openErr := openIngestWal(t.TempDir())
+	if openErr != nil {
+		t.Fatal(openErr)
+	}
+	defer wal.Close()
+
+	first := &walRecord{Schema: "checks", ColumnRows: map[string]uint64{}, Nulls: map[string][]bool{}, Layout: []string{}, Strings: []string{}, Data: []byte{1}}
+	second := &walRecord{Schema: "checks", ColumnRows: map[string]uint64{}, Nulls: map[string][]bool{}, Layout: []string{}, Strings: []string{}, Data: make([]byte, 64)}
+
+	for _, it := range []*walRecord{first, second} {
+		if appendErr := wal.Append(it); appendErr != nil {
+			t.Fatal(appendErr)
+		}
+	}
+
+	contents, _ := os.ReadFile(wal.path)
+
+	check := func(name string, broken []byte) {
+		os.WriteFile(wal.path, broken, 0644)
+
+		records, readErr := wal.Records()
+		if readErr != nil {
+			t.Fatalf("%s: %s", name, readErr.Error())
+		}
+
+		if len(records) != 1 || !reflect.DeepEqual(records[0], first) {
+			t.Errorf("%s: expected only the first record, got %d", name, len(records))
+		}
+	}
+
+	check("torn tail", contents[:len(contents)-10])
+
+	flipped := append([]byte{}, contents...)
+	flipped[len(flipped)-1] ^= 0xff
+	check("checksum mismatch", flipped)
+}
+
+func TestWalReplay(t *testing.T) {
+
+	dir := t.TempDir()
+
+	m := openTestManager(t, dir, testSchema("a"), testSchema("b"))
+
+	for _, rows := range []int{600, 400} {
+		if ingestErr := m.Ingest("a", testRows(rows, 1)); ingestErr != nil {
+			t.Fatal(ingestErr)
+		}
+	}
+
+	applied := testRows(600, 1)
+	lost := testRows(500, 2)
+
+	closeTestManager(t, m)
+
+	// log of a crash: batch with later rows on top of it and the one that didn't reach slabs
+	wal, openErr := openIngestWal(filepath.Join(dir, "a"))
+	if openErr != nil {
+		t.Fatal(openErr)
+	}
+
+	wal.Append(&walRecord{Schema: "a", ColumnRows: map[string]uint64{"x": 0, "y": 0}, Layout: applied.FieldsLayout, Data: applied.dataBuffer})
+	wal.Append(&walRecord{Schema: "a", ColumnRows: map[string]uint64{"x": 1000, "y": 1000}, Layout: lost.FieldsLayout, Data: lost.dataBuffer})
+	wal.Close()
+
+	m = openTestManager(t, dir)
+	defer closeTestManager(t, m)
+
+	count, sum := testCountSum(t, m, "a")
+	if count != 1500 || sum != 2000 {
+		t.Errorf("expected 1500 rows with sum 2000 after replay, got %d rows with sum %v", count, sum)
+	}
+
+	if info, _ := os.Stat(filepath.Join(dir, "a", walFileName)); info.Size() != 0 {
+		t.Errorf("expected log to be truncated after replay, it has %d bytes", info.Size())
+	}
+
+	// log of a schema holds only its batch in flight
+	if ingestErr := m.Ingest("b", testRows(10, 1)); ingestErr != nil {
+		t.Fatal(ingestErr)
+	}
+
+	if info, _ := os.Stat(filepath.Join(dir, "b", walFileName)); info.Size() != 0 {
+		t.Errorf("expected log of applied batch to be truncated, it has %d bytes", info.Size())
+	}
+}
+
+func TestIngestRejectedAfterFailedBatch(t *testing.T) {
+
+	m := openTestManager(t, t.TempDir(), testSchema("a"), testSchema("b"))
+	defer closeTestManager(t, m)
+
+	wal, walErr := m.schemaWal("a")
+	if walErr != nil {
+		t.Fatal(walErr)
+	}
+
+	wal.Applied(false)
+
+	ingestErr := m.Ingest("a", testRows(10, 1))
+	if !errors.Is(ingestErr, ErrIngestFailed) {
+		t.Errorf("expected ingest to be rejected, got %v", ingestErr)
+	}
+
+	ingestErr = m.Ingest("b", testRows(10, 1))
+	if ingestErr != nil {
+		t.Errorf("ingest into other schema failed : %s", ingestErr.Error())
+	}
+}
@@ -0,0 +1,24 @@
+package ops
+
+// sum, min and max of values picked by indices
+// indices must not be empty
+func AggregateByIndices[T NumericTypes](arr []T, indices []uint16) (sum float64, min, max float64) {
+
+	first := arr[indices[0]]
+	minVal, maxVal := first, first
+
+	for _, idx := range indices {
+		v := arr[idx]
+
+		sum += float64(v)
+
+		if v < minVal {
+			minVal = v
+		}
+		if v > maxVal {
+			maxVal = v
+		}
+	}
+
+	return sum, float64(minVal), float64(maxVal)
+}
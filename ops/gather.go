@@ -0,0 +1,71 @@
+package ops
+
+import "math"
+
+// picks values by indices converting them to float64
+func GatherAsFloat64[T NumericTypes](arr []T, indices []uint16, out []float64) int {
+	for i, idx := range indices {
+		out[i] = float64(arr[idx])
+	}
+	return len(indices)
+}
+
+// converts a continuous range of values to float64
+func ConvertToFloat64[T NumericTypes](arr []T, out []float64) int {
+	for i, v := range arr {
+		out[i] = float64(v)
+	}
+	return len(arr)
+}
+
+// picks values by indices as group keys, rounding down to bucket if it's not zero
+func GatherUnsignedKeys[T UnsignedInts](arr []T, indices []uint16, bucket uint64, out []uint64) int {
+
+	if bucket == 0 {
+		for i, idx := range indices {
+			out[i] = uint64(arr[idx])
+		}
+		return len(indices)
+	}
+
+	for i, idx := range indices {
+		v := uint64(arr[idx])
+		out[i] = v - v%bucket
+	}
+
+	return len(indices)
+}
+
+// same as GatherUnsignedKeys, negative values are rounded towards negative infinity
+func GatherSignedKeys[T SignedInts](arr []T, indices []uint16, bucket uint64, out []uint64) int {
+
+	if bucket == 0 {
+		for i, idx := range indices {
+			out[i] = uint64(int64(arr[idx]))
+		}
+		return len(indices)
+	}
+
+	b := int64(bucket)
+
+	for i, idx := range indices {
+		v := int64(arr[idx])
+
+		rem := v % b
+		if rem < 0 {
+			rem += b
+		}
+
+		out[i] = uint64(v - rem)
+	}
+
+	return len(indices)
+}
+
+// float keys are stored as bits of float64
+func GatherFloatKeys[T Floats](arr []T, indices []uint16, out []uint64) int {
+	for i, idx := range indices {
+		out[i] = math.Float64bits(float64(arr[idx]))
+	}
+	return len(indices)
+}
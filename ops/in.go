@@ -0,0 +1,71 @@
+package ops
+
+import "slices"
+
+// small sets are scanned linearly, bigger ones use binary search
+const linearSetScanSize = 8
+
+// set must be sorted and have no duplicates
+func setContains[T NumericTypes](set []T, v T) bool {
+
+	if v < set[0] || v > set[len(set)-1] {
+		return false
+	}
+
+	if len(set) <= linearSetScanSize {
+		for _, it := range set {
+			if it == v {
+				return true
+			}
+		}
+		return false
+	}
+
+	_, found := slices.BinarySearch(set, v)
+	return found
+}
+
+// set must be sorted and have no duplicates
+func CompareValuesAreInSet[T NumericTypes](arr []T, set []T, out []uint16) int {
+	n := len(arr)
+	filled := 0
+
+	if len(set) == 0 {
+		return 0
+	}
+
+	if len(set) == 1 {
+		return CompareNumericValuesAreEqual(arr, set[0], out)
+	}
+
+	for i := 0; i < n; i++ {
+		out[filled] = uint16(i)
+		filled += b2i(setContains(set, arr[i]))
+	}
+
+	return filled
+}
+
+// set must be sorted and have no duplicates
+func CompareValuesAreNotInSet[T NumericTypes](arr []T, set []T, out []uint16) int {
+	n := len(arr)
+	filled := 0
+
+	if len(set) == 0 {
+		for i := 0; i < n; i++ {
+			out[i] = uint16(i)
+		}
+		return n
+	}
+
+	if len(set) == 1 {
+		return CompareNumericValuesAreNotEqual(arr, set[0], out)
+	}
+
+	for i := 0; i < n; i++ {
+		out[filled] = uint16(i)
+		filled += b2i(!setContains(set, arr[i]))
+	}
+
+	return filled
+}
@@ -23,16 +23,16 @@ func b2i(b bool) int {
 	return 0
 }
 
+// both bounds are inclusive
 func CompareValuesAreInRangeSignedInts[T SignedInts](
 	arr []T, from, to T, out []uint16,
 ) int {
-	if to <= from {
+	if to < from {
 		return 0
 	}
 
 	n := len(arr)
 	filled := 0
-	rng := to - from
 	i := 0
 
 	for ; i+7 < n; i += 8 {
@@ -45,14 +45,14 @@ func CompareValuesAreInRangeSignedInts[T SignedInts](
 		a6 := arr[i+6]
 		a7 := arr[i+7]
 
-		m0 := (a0 - from) < rng
-		m1 := (a1 - from) < rng
-		m2 := (a2 - from) < rng
-		m3 := (a3 - from) < rng
-		m4 := (a4 - from) < rng
-		m5 := (a5 - from) < rng
-		m6 := (a6 - from) < rng
-		m7 := (a7 - from) < rng
+		m0 := a0 >= from && a0 <= to
+		m1 := a1 >= from && a1 <= to
+		m2 := a2 >= from && a2 <= to
+		m3 := a3 >= from && a3 <= to
+		m4 := a4 >= from && a4 <= to
+		m5 := a5 >= from && a5 <= to
+		m6 := a6 >= from && a6 <= to
+		m7 := a7 >= from && a7 <= to
 
 		if m0 {
 			out[filled] = uint16(i + 0)
@@ -90,7 +90,7 @@ func CompareValuesAreInRangeSignedInts[T SignedInts](
 
 	for ; i < n; i++ {
 		a := arr[i]
-		if (a - from) < rng {
+		if a >= from && a <= to {
 			out[filled] = uint16(i)
 			filled++
 		}
@@ -99,10 +99,12 @@ func CompareValuesAreInRangeSignedInts[T SignedInts](
 	return filled
 }
 
+// both bounds are inclusive,
+// single unsigned compare due to wraparound of values below `from`
 func CompareValuesAreInRangeUnsignedInts[T UnsignedInts](
 	arr []T, from, to T, out []uint16,
 ) int {
-	if to <= from {
+	if to < from {
 		return 0
 	}
 
@@ -121,42 +123,42 @@ func CompareValuesAreInRangeUnsignedInts[T UnsignedInts](
 		a6 := arr[i+6]
 		a7 := arr[i+7]
 
-		if (a0 - from) < rng {
+		if (a0 - from) <= rng {
 			out[filled] = uint16(i + 0)
 			filled++
 		}
-		if (a1 - from) < rng {
+		if (a1 - from) <= rng {
 			out[filled] = uint16(i + 1)
 			filled++
 		}
-		if (a2 - from) < rng {
+		if (a2 - from) <= rng {
 			out[filled] = uint16(i + 2)
 			filled++
 		}
-		if (a3 - from) < rng {
+		if (a3 - from) <= rng {
 			out[filled] = uint16(i + 3)
 			filled++
 		}
-		if (a4 - from) < rng {
+		if (a4 - from) <= rng {
 			out[filled] = uint16(i + 4)
 			filled++
 		}
-		if (a5 - from) < rng {
+		if (a5 - from) <= rng {
 			out[filled] = uint16(i + 5)
 			filled++
 		}
-		if (a6 - from) < rng {
+		if (a6 - from) <= rng {
 			out[filled] = uint16(i + 6)
 			filled++
 		}
-		if (a7 - from) < rng {
+		if (a7 - from) <= rng {
 			out[filled] = uint16(i + 7)
 			filled++
 		}
 	}
 
 	for ; i < n; i++ {
-		if (arr[i] - from) < rng {
+		if (arr[i] - from) <= rng {
 			out[filled] = uint16(i)
 			filled++
 		}
@@ -165,10 +167,11 @@ func CompareValuesAreInRangeUnsignedInts[T UnsignedInts](
 	return filled
 }
 
+// both bounds are inclusive
 func CompareValuesAreInRangeFloats[T Floats](
 	arr []T, from, to T, out []uint16,
 ) int {
-	if to <= from {
+	if to < from {
 		return 0
 	}
 
@@ -186,35 +189,35 @@ func CompareValuesAreInRangeFloats[T Floats](
 		a6 := arr[i+6]
 		a7 := arr[i+7]
 
-		if a0 >= from && a0 < to {
+		if a0 >= from && a0 <= to {
 			out[filled] = uint16(i + 0)
 			filled++
 		}
-		if a1 >= from && a1 < to {
+		if a1 >= from && a1 <= to {
 			out[filled] = uint16(i + 1)
 			filled++
 		}
-		if a2 >= from && a2 < to {
+		if a2 >= from && a2 <= to {
 			out[filled] = uint16(i + 2)
 			filled++
 		}
-		if a3 >= from && a3 < to {
+		if a3 >= from && a3 <= to {
 			out[filled] = uint16(i + 3)
 			filled++
 		}
-		if a4 >= from && a4 < to {
+		if a4 >= from && a4 <= to {
 			out[filled] = uint16(i + 4)
 			filled++
 		}
-		if a5 >= from && a5 < to {
+		if a5 >= from && a5 <= to {
 			out[filled] = uint16(i + 5)
 			filled++
 		}
-		if a6 >= from && a6 < to {
+		if a6 >= from && a6 <= to {
 			out[filled] = uint16(i + 6)
 			filled++
 		}
-		if a7 >= from && a7 < to {
+		if a7 >= from && a7 <= to {
 			out[filled] = uint16(i + 7)
 			filled++
 		}
@@ -222,7 +225,7 @@ func CompareValuesAreInRangeFloats[T Floats](
 
 	for ; i < n; i++ {
 		a := arr[i]
-		if a >= from && a < to {
+		if a >= from && a <= to {
 			out[filled] = uint16(i)
 			filled++
 		}
@@ -164,3 +164,50 @@ func BenchmarkRangeFloats(b *testing.B) {
 	}
 
 }
+
+func TestAggregateByIndices(t *testing.T) {
+
+	input := []float32{5, 1, 7, 3, 9, 2}
+	indices := []uint16{1, 2, 3, 5}
+
+	sum, minVal, maxVal := ops.AggregateByIndices(input, indices)
+
+	if sum != 13 {
+		t.Errorf("Expected sum %.2f but got %.2f", 13.0, sum)
+	}
+
+	if minVal != 1 || maxVal != 7 {
+		t.Errorf("Expected bounds [1, 7] but got [%.2f, %.2f]", minVal, maxVal)
+	}
+
+}
+
+func TestRangeInclusiveSigned(t *testing.T) {
+
+	input := []int64{-5, -3, 0, 2, 3, 4, -4, 7, 3}
+	out := make([]uint16, len(input))
+
+	resultSize := ops.CompareValuesAreInRangeSignedInts(input, -3, 3, out)
+
+	if resultSize != 5 {
+		t.Errorf("Expected %d but got %d : %v", 5, resultSize, out[:resultSize])
+	}
+}
+
+func TestInSet(t *testing.T) {
+
+	input := []uint64{1, 5, 9, 12, 5, 7, 3, 100, 42, 9}
+	set := []uint64{3, 5, 9, 10, 11, 12, 13, 14, 15, 42}
+
+	out := make([]uint16, len(input))
+
+	resultSize := ops.CompareValuesAreInSet(input, set, out)
+	if resultSize != 7 {
+		t.Errorf("Expected %d but got %d : %v", 7, resultSize, out[:resultSize])
+	}
+
+	resultSize = ops.CompareValuesAreNotInSet(input, set, out)
+	if resultSize != 3 || out[0] != 0 || out[1] != 5 || out[2] != 7 {
+		t.Errorf("Expected [0 5 7] but got %v", out[:resultSize])
+	}
+}
@@ -13,7 +13,7 @@ const BlockRowsSize = 32 * 1024 // 32k rows per block
 
 const TotalHeaderSize = 128
 
-const HeaderSizeUsed uint64 = 16 + 2 + 8 + 8 + 1 + 16 // guid + start offset + compressed size + datatype + [max value + min value] bounds : 16
+const HeaderSizeUsed uint64 = 16 + 2 + 8 + 8 + 1 + 16 + 1 + 2 + 4 + 4 // guid + start offset + compressed size + datatype + [max value + min value] bounds : 16 + codec + null count + data checksum + header checksum
 const ReservedSize uint64 = TotalHeaderSize - HeaderSizeUsed
 
 type DiskHeader struct {
@@ -31,6 +31,17 @@ type DiskHeader struct {
 
 	DataType FieldType
 
+	// encoding of block data, CompressedSize holds encoded size
+	Codec ColumnCodec
+
+	// rows without value, bounds cover only rows with values
+	NullCount uint16
+
+	// crc32c of stored block data, see BlockPayload
+	DataChecksum uint32
+	// crc32c of header fields above, set by WriteTo
+	HeaderChecksum uint32
+
 	Reserved [ReservedSize]uint8
 }
 
@@ -74,7 +85,32 @@ func (header *DiskHeader) FromBytes(input io.ReadSeeker) (topErr error) {
 	header.DataType = columnType
 
 	// read max/min values
-	header.Bounds.FromBytes(reader)
+	topErr = header.Bounds.FromBytes(reader)
+	if topErr != nil {
+		return fmt.Errorf("unable to decode block header bounds: %s", topErr.Error())
+	}
+
+	codecRaw, topErr := reader.ReadU8()
+	if topErr != nil {
+		return fmt.Errorf("unable to decode block header codec: %s", topErr.Error())
+	}
+
+	header.Codec = ColumnCodec(codecRaw)
+
+	header.NullCount, topErr = reader.ReadU16()
+	if topErr != nil {
+		return fmt.Errorf("unable to decode block header null count: %s", topErr.Error())
+	}
+
+	header.DataChecksum, topErr = reader.ReadU32()
+	if topErr != nil {
+		return fmt.Errorf("unable to decode block header data checksum: %s", topErr.Error())
+	}
+
+	header.HeaderChecksum, topErr = reader.ReadU32()
+	if topErr != nil {
+		return fmt.Errorf("unable to decode block header checksum: %s", topErr.Error())
+	}
 
 	// log.Printf(" -- block %s bounds loaded : %e : %e", header.Uid.String(), header.Bounds.Min, header.Bounds.Max)
 
@@ -84,10 +120,86 @@ func (header *DiskHeader) FromBytes(input io.ReadSeeker) (topErr error) {
 
 func (header *DiskHeader) WriteTo(bw *bits.BitWriter) (int, error) {
 
+	start := bw.Position()
+
+	writeErr := header.writeFields(bw)
+	if writeErr != nil {
+		return 0, writeErr
+	}
+
+	header.HeaderChecksum = Checksum(bw.Bytes()[start:])
+	bw.PutUint32(header.HeaderChecksum)
+
+	bw.EmptyBytes(int(ReservedSize))
+
+	return bw.Position(), nil
+}
+
+// checksum of header fields as they are written to disk
+func (header *DiskHeader) Checksum() uint32 {
+
+	var buf [TotalHeaderSize]byte
+	bw := bits.NewEncodeBuffer(buf[:], binary.LittleEndian)
+
+	header.writeFields(&bw)
+
+	return Checksum(bw.Bytes())
+}
+
+// checks header against checksum stored with it
+func (header *DiskHeader) Verify(slab uuid.UUID) error {
+
+	actual := header.Checksum()
+	if actual != header.HeaderChecksum {
+		return &CorruptionError{Part: CorruptedBlockHeader, Slab: slab, Block: header.Uid, Expected: header.HeaderChecksum, Actual: actual}
+	}
+
+	return nil
+}
+
+// checks stored block data against checksum of header
+func (header *DiskHeader) VerifyData(slab uuid.UUID, blockData []byte) error {
+
+	payload, payloadErr := header.BlockPayload(blockData)
+	if payloadErr != nil {
+		return payloadErr
+	}
+
+	actual := Checksum(payload)
+	if actual != header.DataChecksum {
+		return &CorruptionError{Part: CorruptedBlockData, Slab: slab, Block: header.Uid, Expected: header.DataChecksum, Actual: actual}
+	}
+
+	return nil
+}
+
+// stored part of block data : values of written rows of a raw block
+// or encoded values of a block with codec
+func (header *DiskHeader) BlockPayload(blockData []byte) ([]byte, error) {
+
+	_, typeErr := header.DataType.Ops()
+	if typeErr != nil {
+		return nil, fmt.Errorf("unknown block data type : %s", typeErr.Error())
+	}
+
+	size := uint64(header.Items) * uint64(header.DataType.Size())
+	if header.Codec != CodecNone {
+		size = header.CompressedSize
+	}
+
+	if uint64(len(blockData)) < size {
+		return nil, fmt.Errorf("block %s data size %d is out of slab data", header.Uid.String(), size)
+	}
+
+	return blockData[:size], nil
+}
+
+func (header *DiskHeader) writeFields(bw *bits.BitWriter) error {
+
 	// UUID
 	n, _ := bw.Write(header.Uid[:])
 	if n != 16 {
-		return 0, fmt.Errorf("failed to write GroupUid")
+		return fmt.Errorf("failed to write GroupUid")
 	}
 
 	// Items
@@ -103,7 +215,9 @@ func (header *DiskHeader) WriteTo(bw *bits.BitWriter) (int, error) {
 	// bounds
 	header.Bounds.WriteTo(bw)
 
-	bw.EmptyBytes(int(ReservedSize))
+	bw.WriteByte(uint8(header.Codec))
+	bw.PutUint16(header.NullCount)
+	bw.PutUint32(header.DataChecksum)
 
-	return bw.Position(), nil
+	return nil
 }
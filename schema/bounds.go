@@ -134,8 +134,16 @@ func GetMaxMinBoundsFloat[T NumericTypes](arr []T) BoundsFloat {
 
 func (header *BoundsFloat) FromBytes(reader *bits.BitsReader) (topErr error) {
 
-	header.Max = reader.MustReadF64()
-	header.Min = reader.MustReadF64()
+	header.Max, topErr = reader.ReadF64()
+	if topErr != nil {
+		return topErr
+	}
+
+	header.Min, topErr = reader.ReadF64()
+	if topErr != nil {
+		return topErr
+	}
+
 	header.initialized = true
 
 	return nil
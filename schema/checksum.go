@@ -0,0 +1,55 @@
+package schema
+
+import (
+	"fmt"
+	"hash/crc32"
+
+	"github.com/google/uuid"
+)
+
+var castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+
+// crc32c, used for slab header, block headers and block data
+func Checksum(data []byte) uint32 {
+	return crc32.Checksum(data, castagnoliTable)
+}
+
+// part of slab file that failed checksum verification
+type CorruptedPart uint8
+
+const (
+	CorruptedSlabHeader CorruptedPart = iota
+	CorruptedBlockHeader
+	CorruptedBlockData
+)
+
+func (p CorruptedPart) String() string {
+	switch p {
+	case CorruptedSlabHeader:
+		return "slab header"
+	case CorruptedBlockHeader:
+		return "block header"
+	case CorruptedBlockData:
+		return "block data"
+	default:
+		return fmt.Sprintf("unknown part %d", uint8(p))
+	}
+}
+
+// returned when data read from disk doesn't match its checksum,
+// Block is uuid.Nil for slab header
+type CorruptionError struct {
+	Part  CorruptedPart
+	Slab  uuid.UUID
+	Block uuid.UUID
+
+	Expected uint32
+	Actual   uint32
+}
+
+func (e *CorruptionError) Error() string {
+	if e.Block == uuid.Nil {
+		return fmt.Sprintf("%s of slab %s is corrupted : checksum %08x, expected %08x", e.Part.String(), e.Slab.String(), e.Actual, e.Expected)
+	}
+	return fmt.Sprintf("%s of block %s in slab %s is corrupted : checksum %08x, expected %08x", e.Part.String(), e.Block.String(), e.Slab.String(), e.Actual, e.Expected)
+}
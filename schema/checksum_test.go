@@ -0,0 +1,108 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/dot5enko/simple-column-db/bits"
+	"github.com/google/uuid"
+)
+
+func expectCorruption(t *testing.T, err error, part CorruptedPart, slab, block uuid.UUID) {
+
+	t.Helper()
+
+	var corruption *CorruptionError
+	if !errors.As(err, &corruption) {
+		t.Fatalf("expected %s corruption, got %v", part.String(), err)
+	}
+
+	if corruption.Part != part || corruption.Slab != slab || corruption.Block != block || corruption.Expected == corruption.Actual {
+		t.Errorf("expected %s corruption of block %s in slab %s, got %s", part.String(), block.String(), slab.String(), corruption.Error())
+	}
+}
+
+func TestBlockChecksums(t *testing.T) {
+
+	slab := uuid.New()
+
+	data := []byte{1, 0, 0, 0, 2, 0, 0, 0, 3, 0, 0, 0, 0xff, 0xff}
+
+	header := NewBlockHeader(Uint32FieldType)
+	header.Items = 3
+	header.DataChecksum = Checksum(data[:12])
+
+	var buf [TotalHeaderSize]byte
+	bw := bits.NewEncodeBuffer(buf[:], binary.LittleEndian)
+
+	if _, writeErr := header.WriteTo(&bw); writeErr != nil {
+		t.Fatal(writeErr)
+	}
+
+	read := DiskHeader{}
+	if readErr := read.FromBytes(bytes.NewReader(buf[:])); readErr != nil {
+		t.Fatal(readErr)
+	}
+
+	if verifyErr := read.Verify(slab); verifyErr != nil {
+		t.Fatalf("unexpected error of written header : %s", verifyErr.Error())
+	}
+
+	if verifyErr := read.VerifyData(slab, data); verifyErr != nil {
+		t.Fatalf("unexpected error of written data : %s", verifyErr.Error())
+	}
+
+	// bytes past written rows aren't covered
+	data[13] = 0
+	if verifyErr := read.VerifyData(slab, data); verifyErr != nil {
+		t.Fatalf("unexpected error of data past written rows : %s", verifyErr.Error())
+	}
+
+	data[5] ^= 0x10
+	expectCorruption(t, read.VerifyData(slab, data), CorruptedBlockData, slab, header.Uid)
+
+	// start offset
+	buf[20] ^= 0x01
+
+	read = DiskHeader{}
+	if readErr := read.FromBytes(bytes.NewReader(buf[:])); readErr != nil {
+		t.Fatal(readErr)
+	}
+
+	expectCorruption(t, read.Verify(slab), CorruptedBlockHeader, slab, header.Uid)
+}
+
+func TestSlabHeaderChecksum(t *testing.T) {
+
+	header, headerErr := NewDiskSlab(Schema{Name: "s", Columns: []SchemaColumn{{Name: "x", Type: Uint64FieldType}}}, "x", 40)
+	if headerErr != nil {
+		t.Fatal(headerErr)
+	}
+
+	buf := make([]byte, SlabHeaderFixedSize)
+
+	if _, writeErr := header.WriteTo(buf); writeErr != nil {
+		t.Fatal(writeErr)
+	}
+
+	read := &DiskSlabHeader{}
+	if readErr := read.FromBytes(bytes.NewReader(buf)); readErr != nil {
+		t.Fatal(readErr)
+	}
+
+	if verifyErr := read.Verify(header.Uid); verifyErr != nil {
+		t.Fatalf("unexpected error of written header : %s", verifyErr.Error())
+	}
+
+	// blocks finalized
+	buf[28] ^= 0x01
+
+	read = &DiskSlabHeader{}
+	if readErr := read.FromBytes(bytes.NewReader(buf)); readErr != nil {
+		t.Fatal(readErr)
+	}
+
+	expectCorruption(t, read.Verify(header.Uid), CorruptedSlabHeader, header.Uid, uuid.Nil)
+}
@@ -0,0 +1,53 @@
+package schema
+
+import "fmt"
+
+// encoding of block values inside finalized slabs,
+// applied before slab level compression
+type ColumnCodec uint8
+
+const (
+	CodecNone ColumnCodec = iota
+
+	// difference between consecutive values, for slowly changing integers
+	CodecDelta
+
+	// difference between consecutive deltas, for monotonic timestamps
+	CodecDeltaOfDelta
+
+	// gorilla style xor of consecutive values, for floats
+	CodecXor
+
+	// offset from block minimum packed with the smallest bit width, for small range integers
+	CodecFrameOfReference
+)
+
+func (c ColumnCodec) String() string {
+	switch c {
+	case CodecNone:
+		return "none"
+	case CodecDelta:
+		return "delta"
+	case CodecDeltaOfDelta:
+		return "delta-of-delta"
+	case CodecXor:
+		return "xor"
+	case CodecFrameOfReference:
+		return "frame-of-reference"
+	default:
+		return fmt.Sprintf("unknown codec %d", uint8(c))
+	}
+}
+
+func (c ColumnCodec) SupportsType(typ FieldType) bool {
+	switch c {
+	case CodecNone:
+		return true
+	case CodecXor:
+		return typ == Float32FieldType || typ == Float64FieldType
+	case CodecDelta, CodecDeltaOfDelta, CodecFrameOfReference:
+		return typ != Float32FieldType && typ != Float64FieldType
+	default:
+		return false
+	}
+}
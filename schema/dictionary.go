@@ -0,0 +1,114 @@
+package schema
+
+import (
+	"strings"
+	"sync"
+)
+
+// per slab dictionary of string column values,
+// blocks store codes which are indices of values in the dictionary
+type StringDictionary struct {
+	lock sync.RWMutex
+
+	values []string
+	codes  map[string]uint32
+}
+
+func NewStringDictionary(values []string) *StringDictionary {
+
+	d := &StringDictionary{
+		values: values,
+		codes:  make(map[string]uint32, len(values)),
+	}
+
+	for code, value := range values {
+		d.codes[value] = uint32(code)
+	}
+
+	return d
+}
+
+// fills codes of values, unknown values are added to the dictionary.
+// returns values added by this call in order of their codes
+func (d *StringDictionary) Encode(values []string, out []uint32) (added []string) {
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for idx, value := range values {
+
+		code, ok := d.codes[value]
+		if !ok {
+			code = uint32(len(d.values))
+
+			d.values = append(d.values, value)
+			d.codes[value] = code
+
+			added = append(added, value)
+		}
+
+		out[idx] = code
+	}
+
+	return added
+}
+
+// removes values added by the last Encode call, used when they can't be persisted
+func (d *StringDictionary) Rollback(added []string) {
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for _, value := range added {
+		delete(d.codes, value)
+	}
+
+	d.values = d.values[:len(d.values)-len(added)]
+}
+
+func (d *StringDictionary) Code(value string) (uint32, bool) {
+
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	code, ok := d.codes[value]
+	return code, ok
+}
+
+// codes of all values starting with prefix
+func (d *StringDictionary) CodesWithPrefix(prefix string) []uint32 {
+
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	result := []uint32{}
+
+	for code, value := range d.values {
+		if strings.HasPrefix(value, prefix) {
+			result = append(result, uint32(code))
+		}
+	}
+
+	return result
+}
+
+// appends decoded values of codes at indices to out
+func (d *StringDictionary) DecodeAt(codes []uint32, indices []uint16, out []any) []any {
+
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	for _, idx := range indices {
+		out = append(out, d.values[codes[idx]])
+	}
+
+	return out
+}
+
+func (d *StringDictionary) Size() int {
+
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+
+	return len(d.values)
+}
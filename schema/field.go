@@ -6,6 +6,14 @@ type SchemaColumn struct {
 	Name string    `json:"name"`
 	Type FieldType `json:"type"`
 
+	Codec ColumnCodec `json:"codec,omitempty"`
+
+	// rows may have no value, validity of rows is kept per block
+	Nullable bool `json:"nullable,omitempty"`
+
+	// unit of timestamp column values, seconds by default
+	Precision TimePrecision `json:"precision,omitempty"`
+
 	// runtime
 	ActiveSlab uuid.UUID   `json:"active_slab"`
 	Slabs      []uuid.UUID `json:"slabs"`
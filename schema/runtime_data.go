@@ -2,6 +2,8 @@ package schema
 
 import (
 	"fmt"
+
+	"github.com/dot5enko/simple-column-db/bits"
 	"reflect"
 	"sync"
 )
@@ -13,50 +15,96 @@ type RuntimeBlockData struct {
 
 	DataTypedArray any
 
+	// set for string columns, decodes codes stored in DataTypedArray
+	Dictionary *StringDictionary
+
+	// set for nullable columns, bit is set for rows with value
+	Validity *bits.Bitfield
+
 	// why dup ?
 	Cap   int
 	Items int
 }
 
-func writeTypedArray[T NumericTypes](b *RuntimeBlockData, dataArray any, startOffset int) (int, error, BoundsFloat) {
+// returned when values written into a block aren't of the block type
+type ArrayTypeError struct {
+	Input    reflect.Type
+	Expected reflect.Type
+}
+
+func (e *ArrayTypeError) Error() string {
+	return fmt.Sprintf("wrong type in runtime block: input type: %s, expected type : %s", e.Input, e.Expected)
+}
+
+func writeTypedArray[T NumericTypes](b *RuntimeBlockData, dataArray any, startOffset int, nulls []bool) (int, error, BoundsFloat) {
 	typedArray, typedOk := b.DataTypedArray.([]T)
 	inputArray, inputOk := dataArray.([]T)
 
-	inputArray = inputArray[startOffset:]
-
 	if !typedOk || !inputOk {
-		return 0, fmt.Errorf("wrong type in runtime block: input type: %s, expected type : %s", reflect.TypeOf(inputArray), reflect.TypeOf(typedArray)), BoundsFloat{}
+		return 0, &ArrayTypeError{Input: reflect.TypeOf(dataArray), Expected: reflect.TypeOf(typedArray)}, BoundsFloat{}
 	}
+
+	inputArray = inputArray[startOffset:]
 	// log.Printf(" >>>> about to copy %d items from array of size. dest len : %d. items : %d. cap : %d", len(inputArray), len(typedArray[b.Items:]), b.Items, b.Cap)
 	copied := copy(typedArray[b.Items:b.Cap], inputArray)
 
-	bounds := GetMaxMinBoundsFloat(inputArray[:copied])
+	// full block, left by ingest interrupted before slab header update
+	if copied == 0 {
+		return 0, nil, NewBounds()
+	}
+
+	if nulls == nil {
+		return copied, nil, GetMaxMinBoundsFloat(inputArray[:copied])
+	}
+
+	// values of null rows are placeholders, they don't get into bounds
+	bounds := NewBounds()
+	for idx, v := range inputArray[:copied] {
+		if !nulls[startOffset+idx] {
+			bounds.Min = min(bounds.Min, float64(v))
+			bounds.Max = max(bounds.Max, float64(v))
+		}
+	}
 
 	return copied, nil, bounds
 }
 
-func (b *RuntimeBlockData) Write(dataArray any, dataArrayStartOffset int, typ FieldType) (written int, topErr error, bounds BoundsFloat) {
+// nulls are set for rows without value, aligned with dataArray.
+// nil when the column is not nullable
+func (b *RuntimeBlockData) Write(dataArray any, dataArrayStartOffset int, typ FieldType, nulls []bool) (written int, topErr error, bounds BoundsFloat) {
 	b.lock.Lock()
 	defer b.lock.Unlock()
 
-	switch typ {
-	case Uint64FieldType:
-		written, topErr, bounds = writeTypedArray[uint64](b, dataArray, dataArrayStartOffset)
-	case Uint8FieldType:
-		written, topErr, bounds = writeTypedArray[uint8](b, dataArray, dataArrayStartOffset)
-	case Float32FieldType:
-		written, topErr, bounds = writeTypedArray[float32](b, dataArray, dataArrayStartOffset)
-	case Uint16FieldType:
-		written, topErr, bounds = writeTypedArray[uint16](b, dataArray, dataArrayStartOffset)
-	case Float64FieldType:
-		written, topErr, bounds = writeTypedArray[float64](b, dataArray, dataArrayStartOffset)
-	case Uint32FieldType:
-		written, topErr, bounds = writeTypedArray[uint32](b, dataArray, dataArrayStartOffset)
-	default:
-		panic(fmt.Sprintf("unsupported type when writing to RuntimeBlockData: %s", typ.String()))
+	if nulls != nil && b.Validity == nil {
+		return 0, fmt.Errorf("null values can't be written into not nullable column"), BoundsFloat{}
+	}
+
+	typeOps, typeErr := typ.Ops()
+	if typeErr != nil {
+		return 0, fmt.Errorf("unable to write into RuntimeBlockData : %s", typeErr.Error()), BoundsFloat{}
 	}
 
+	written, topErr, bounds = typeOps.write(b, dataArray, dataArrayStartOffset, nulls)
+
 	if topErr == nil {
+
+		if b.Validity != nil {
+			nullCount := 0
+
+			for idx := range written {
+				row := b.Items + idx
+
+				if nulls != nil && nulls[dataArrayStartOffset+idx] {
+					b.Validity.Clear(row)
+					nullCount++
+				} else {
+					b.Validity.Set(row)
+				}
+			}
+
+			b.Header.NullCount += uint16(nullCount)
+		}
+
 		// change block runtimr
 		b.Items += written
 
@@ -69,6 +117,22 @@ func (b *RuntimeBlockData) Write(dataArray any, dataArrayStartOffset int, typ Fi
 	return
 }
 
+// true if block has rows without value
+func (b *RuntimeBlockData) HasNulls() bool {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	return b.Validity != nil && b.Header.NullCount > 0
+}
+
+// bounds of rows written so far, header of the active block is changed by Write
+func (b *RuntimeBlockData) HeaderBounds() BoundsFloat {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	return b.Header.Bounds
+}
+
 // func (b *RuntimeBlockData) ExportData(out []T) int {
 // 	b.lock.RLock()
 // 	defer b.lock.RUnlock()
@@ -77,6 +141,9 @@ func (b *RuntimeBlockData) Write(dataArray any, dataArrayStartOffset int, typ Fi
 // }
 
 func (b *RuntimeBlockData) DirectAccess() (typedDataArray any, endOffset int) {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
 	return b.DataTypedArray, b.Items
 }
 
@@ -88,3 +155,17 @@ func NewRuntimeBlockDataFromSlice(dataArray any, itemCount int) *RuntimeBlockDat
 		DataTypedArray: dataArray,
 	}
 }
+
+// validity of the rows, nil for not nullable columns.
+// bitmap of a block that's still written is copied, ingest sets bits in the same words
+func (b *RuntimeBlockData) ValidityView() *bits.Bitfield {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	if b.Validity == nil || b.Items >= b.Cap {
+		return b.Validity
+	}
+
+	view := *b.Validity
+	return &view
+}
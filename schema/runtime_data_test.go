@@ -0,0 +1,38 @@
+package schema
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// values of another go type are reported, also when written from an offset
+func TestWriteOfWrongArrayType(t *testing.T) {
+
+	header := NewBlockHeader(Uint64FieldType)
+	block := NewRuntimeBlockDataFromSlice(make([]uint64, BlockRowsSize), 0)
+	block.Header = &header
+
+	for _, offset := range []int{0, 5} {
+
+		written, writeErr, _ := block.Write([]uint32{1, 2, 3, 4, 5, 6, 7}, offset, Uint64FieldType, nil)
+
+		var typeErr *ArrayTypeError
+		if !errors.As(writeErr, &typeErr) {
+			t.Fatalf("offset %d : expected array type error, got %v", offset, writeErr)
+		}
+
+		if typeErr.Input != reflect.TypeOf([]uint32{}) || typeErr.Expected != reflect.TypeOf([]uint64{}) {
+			t.Errorf("offset %d : unexpected error %s", offset, typeErr.Error())
+		}
+
+		if written != 0 || block.Items != 0 || header.Items != 0 {
+			t.Errorf("offset %d : expected nothing written, got %d rows", offset, written)
+		}
+	}
+
+	written, writeErr, _ := block.Write([]uint64{1, 2, 3}, 1, Uint64FieldType, nil)
+	if writeErr != nil || written != 2 || header.Bounds.Min != 2 || header.Bounds.Max != 3 {
+		t.Errorf("expected 2 rows with bounds [2, 3], got %d rows with bounds %+v, error %v", written, header.Bounds, writeErr)
+	}
+}
@@ -4,16 +4,25 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"slices"
+	"sync"
 
 	"github.com/dot5enko/simple-column-db/bits"
 	"github.com/fatih/color"
 	"github.com/google/uuid"
 )
 
-const CurrentSlabVersion = 1
-const SlabHeaderFixedSize = 2 + 8 + 16 + 2 + 2 + 2 + 1 + 1 + 1 + 8 + BoundsSize
+// version 2 : checksums of slab header, block headers and block data
+const CurrentSlabVersion = 2
+const SlabHeaderFixedSize = 2 + 8 + 16 + 2 + 2 + 2 + 1 + 1 + 1 + 8 + BoundsSize + 4
 const SlabDiskContentsUncompressed = 10 * 1024 * 1024
 
+// DiskSlabHeader.CompressionType values
+const (
+	SlabCompressionNone uint8 = 0
+	SlabCompressionLz4  uint8 = 1
+)
+
 type DiskSlabHeader struct {
 	Bounds BoundsFloat
 	Uid    uuid.UUID
@@ -30,8 +39,53 @@ type DiskSlabHeader struct {
 	CompressionType uint8
 	Type            FieldType
 
+	// crc32c of header fields above, set by WriteTo
+	Checksum uint32
+
 	// up to this point we have a predictable layout
 	BlockHeaders []DiskHeader
+
+	// guards fields queries read while ingest writes the active slab
+	// or compression rewrites a finalized one : bounds, finalized blocks,
+	// block headers, compression type and size
+	lock sync.RWMutex
+}
+
+func (header *DiskSlabHeader) Lock() {
+	header.lock.Lock()
+}
+
+func (header *DiskSlabHeader) Unlock() {
+	header.lock.Unlock()
+}
+
+// read lock isn't reentrant once a writer waits for it,
+// so readers never take it twice
+func (header *DiskSlabHeader) RLock() {
+	header.lock.RLock()
+}
+
+func (header *DiskSlabHeader) RUnlock() {
+	header.lock.RUnlock()
+}
+
+// copy of the header with its own block headers
+func (header *DiskSlabHeader) Clone() *DiskSlabHeader {
+	return &DiskSlabHeader{
+		Bounds:                    header.Bounds,
+		Uid:                       header.Uid,
+		CompressedSlabContentSize: header.CompressedSlabContentSize,
+		SlabOffsetBlocks:          header.SlabOffsetBlocks,
+		BlocksTotal:               header.BlocksTotal,
+		BlocksFinalized:           header.BlocksFinalized,
+		SingleBlockRowsSize:       header.SingleBlockRowsSize,
+		Version:                   header.Version,
+		SchemaFieldId:             header.SchemaFieldId,
+		CompressionType:           header.CompressionType,
+		Type:                      header.Type,
+		Checksum:                  header.Checksum,
+		BlockHeaders:              slices.Clone(header.BlockHeaders),
+	}
 }
 
 func NewDiskSlab(
@@ -83,31 +137,63 @@ func (header *DiskSlabHeader) FromBytes(input io.ReadSeeker) (topErr error) {
 
 	reader := bits.NewReader(input, binary.LittleEndian)
 
-	header.Version = reader.MustReadU16()
-	header.SlabOffsetBlocks = reader.MustReadU64()
+	header.Version, topErr = reader.ReadU16()
+	if topErr != nil {
+		return fmt.Errorf("unable to decode slab header version : %s", topErr.Error())
+	}
 
 	if header.Version != CurrentSlabVersion {
 		return fmt.Errorf("invalid version (%d). Supported versions: %d ", header.Version, CurrentSlabVersion)
 	}
 
-	var uuidErr error
-	header.Uid, uuidErr = reader.ReadUUID()
-	if uuidErr != nil {
-		return uuidErr
+	header.SlabOffsetBlocks, topErr = reader.ReadU64()
+	if topErr != nil {
+		return fmt.Errorf("unable to decode slab header offset : %s", topErr.Error())
+	}
+
+	header.Uid, topErr = reader.ReadUUID()
+	if topErr != nil {
+		return fmt.Errorf("unable to decode slab header guid : %s", topErr.Error())
+	}
+
+	if header.BlocksTotal, topErr = reader.ReadU16(); topErr != nil {
+		return fmt.Errorf("unable to decode slab header blocks total : %s", topErr.Error())
+	}
+
+	if header.BlocksFinalized, topErr = reader.ReadU16(); topErr != nil {
+		return fmt.Errorf("unable to decode slab header blocks finalized : %s", topErr.Error())
+	}
+
+	if header.SingleBlockRowsSize, topErr = reader.ReadU16(); topErr != nil {
+		return fmt.Errorf("unable to decode slab header block rows : %s", topErr.Error())
+	}
+
+	if header.SchemaFieldId, topErr = reader.ReadU8(); topErr != nil {
+		return fmt.Errorf("unable to decode slab header field id : %s", topErr.Error())
 	}
 
-	header.BlocksTotal = reader.MustReadU16()
-	header.BlocksFinalized = reader.MustReadU16()
-	header.SingleBlockRowsSize = reader.MustReadU16()
+	columnTypeRaw, topErr := reader.ReadU8()
+	if topErr != nil {
+		return fmt.Errorf("unable to decode slab header column type : %s", topErr.Error())
+	}
+	header.Type = FieldType(columnTypeRaw)
 
-	header.SchemaFieldId = reader.MustReadU8()
-	header.Type = FieldType(reader.MustReadU8())
+	if header.CompressionType, topErr = reader.ReadU8(); topErr != nil {
+		return fmt.Errorf("unable to decode slab header compression type : %s", topErr.Error())
+	}
 
-	header.CompressionType = reader.MustReadU8()
 	// header.UncompressedSlabContentSize = reader.MustReadU64()
-	header.CompressedSlabContentSize = reader.MustReadU64()
+	if header.CompressedSlabContentSize, topErr = reader.ReadU64(); topErr != nil {
+		return fmt.Errorf("unable to decode slab header content size : %s", topErr.Error())
+	}
 
-	header.Bounds.FromBytes(reader)
+	if topErr = header.Bounds.FromBytes(reader); topErr != nil {
+		return fmt.Errorf("unable to decode slab header bounds : %s", topErr.Error())
+	}
+
+	if header.Checksum, topErr = reader.ReadU32(); topErr != nil {
+		return fmt.Errorf("unable to decode slab header checksum : %s", topErr.Error())
+	}
 
 	return nil
 
@@ -119,6 +205,36 @@ func (header *DiskSlabHeader) WriteTo(buffer []byte) (int, error) {
 
 	// defer fmt.Printf(" >> wsh writing slab header %s : \n >> wsh %v\n", header.Uid.String(), buffer[:SlabHeaderFixedSize])
 
+	writeErr := header.writeFields(&bw)
+	if writeErr != nil {
+		return 0, writeErr
+	}
+
+	header.Checksum = Checksum(bw.Bytes())
+	bw.PutUint32(header.Checksum)
+
+	return bw.Position(), nil
+
+}
+
+// checks header against checksum stored with it
+func (header *DiskSlabHeader) Verify(slab uuid.UUID) error {
+
+	var buf [SlabHeaderFixedSize]byte
+	bw := bits.NewEncodeBuffer(buf[:], binary.LittleEndian)
+
+	header.writeFields(&bw)
+
+	actual := Checksum(bw.Bytes())
+	if actual != header.Checksum {
+		return &CorruptionError{Part: CorruptedSlabHeader, Slab: slab, Expected: header.Checksum, Actual: actual}
+	}
+
+	return nil
+}
+
+func (header *DiskSlabHeader) writeFields(bw *bits.BitWriter) error {
+
 	// Write basic fields
 	bw.PutUint16(header.Version)
 	bw.PutUint64(header.SlabOffsetBlocks)
@@ -126,7 +242,7 @@ func (header *DiskSlabHeader) WriteTo(buffer []byte) (int, error) {
 	uuidLength := 16
 	n, _ := bw.Write(header.Uid[:])
 	if n != uuidLength {
-		return 0, fmt.Errorf("failed to write UUID")
+		return fmt.Errorf("failed to write UUID")
 	}
 
 	bw.PutUint16(header.BlocksTotal)
@@ -141,8 +257,7 @@ func (header *DiskSlabHeader) WriteTo(buffer []byte) (int, error) {
 	// bw.PutUint64(header.UncompressedSlabContentSize)
 	bw.PutUint64(header.CompressedSlabContentSize)
 
-	header.Bounds.WriteTo(&bw)
-
-	return bw.Position(), nil
+	header.Bounds.WriteTo(bw)
 
+	return nil
 }
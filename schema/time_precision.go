@@ -0,0 +1,79 @@
+package schema
+
+import (
+	"fmt"
+	"time"
+)
+
+// unit of timestamp column values
+type TimePrecision uint8
+
+const (
+	PrecisionSeconds TimePrecision = iota
+	PrecisionMillis
+	PrecisionMicros
+	PrecisionNanos
+)
+
+func (p TimePrecision) String() string {
+	switch p {
+	case PrecisionSeconds:
+		return "s"
+	case PrecisionMillis:
+		return "ms"
+	case PrecisionMicros:
+		return "us"
+	case PrecisionNanos:
+		return "ns"
+	default:
+		return fmt.Sprintf("unknown precision %d", uint8(p))
+	}
+}
+
+func (p TimePrecision) Validate() error {
+	if p > PrecisionNanos {
+		return fmt.Errorf("unknown time precision %d", uint8(p))
+	}
+	return nil
+}
+
+// duration of a single unit
+func (p TimePrecision) Unit() time.Duration {
+	switch p {
+	case PrecisionMillis:
+		return time.Millisecond
+	case PrecisionMicros:
+		return time.Microsecond
+	case PrecisionNanos:
+		return time.Nanosecond
+	default:
+		return time.Second
+	}
+}
+
+// units since unix epoch, truncated towards the past
+func (p TimePrecision) FromTime(t time.Time) int64 {
+	switch p {
+	case PrecisionMillis:
+		return t.UnixMilli()
+	case PrecisionMicros:
+		return t.UnixMicro()
+	case PrecisionNanos:
+		return t.UnixNano()
+	default:
+		return t.Unix()
+	}
+}
+
+func (p TimePrecision) ToTime(v int64) time.Time {
+	switch p {
+	case PrecisionMillis:
+		return time.UnixMilli(v).UTC()
+	case PrecisionMicros:
+		return time.UnixMicro(v).UTC()
+	case PrecisionNanos:
+		return time.Unix(0, v).UTC()
+	default:
+		return time.Unix(v, 0).UTC()
+	}
+}
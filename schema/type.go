@@ -15,6 +15,12 @@ const (
 	Uint8FieldType
 	Uint32FieldType
 	Uint16FieldType
+
+	// dictionary encoded strings, blocks hold uint32 codes of per slab dictionary
+	StringFieldType
+
+	// int64 count of time units since unix epoch, unit is set by column precision
+	TimestampFieldType
 )
 
 func (f FieldType) String() string {
@@ -39,6 +45,10 @@ func (f FieldType) String() string {
 		return "Uint32"
 	case Uint16FieldType:
 		return "Uint16"
+	case StringFieldType:
+		return "String"
+	case TimestampFieldType:
+		return "Timestamp"
 	default:
 		return ""
 
@@ -52,9 +62,9 @@ func (f FieldType) Size() int {
 		return 1
 	case Int16FieldType, Uint16FieldType:
 		return 2
-	case Int32FieldType, Float32FieldType, Uint32FieldType:
+	case Int32FieldType, Float32FieldType, Uint32FieldType, StringFieldType:
 		return 4
-	case Int64FieldType, Float64FieldType, Uint64FieldType:
+	case Int64FieldType, Float64FieldType, Uint64FieldType, TimestampFieldType:
 		return 8
 
 	default:
@@ -0,0 +1,73 @@
+package schema
+
+import (
+	"fmt"
+
+	"github.com/dot5enko/simple-column-db/bits"
+)
+
+// storage operations of a field type, instantiated with its Go type.
+// string columns are stored as uint32 codes of slab dictionary
+type TypeOps struct {
+	// []T of given size
+	MakeSlice func(size int) any
+
+	// []T mapped onto raw bytes, no copy is made
+	MapBytes func(data []byte, count int) any
+
+	// raw bytes of []T, no copy is made
+	Bytes func(array any) []byte
+
+	write func(b *RuntimeBlockData, dataArray any, startOffset int, nulls []bool) (int, error, BoundsFloat)
+}
+
+var fieldTypeOps = [...]*TypeOps{
+	Int8FieldType:    newTypeOps[int8](),
+	Int16FieldType:   newTypeOps[int16](),
+	Int32FieldType:   newTypeOps[int32](),
+	Int64FieldType:   newTypeOps[int64](),
+	Float64FieldType: newTypeOps[float64](),
+	Float32FieldType: newTypeOps[float32](),
+	Uint64FieldType:  newTypeOps[uint64](),
+	Uint8FieldType:   newTypeOps[uint8](),
+	Uint32FieldType:  newTypeOps[uint32](),
+	Uint16FieldType:  newTypeOps[uint16](),
+	StringFieldType:  newTypeOps[uint32](),
+
+	TimestampFieldType: newTypeOps[int64](),
+}
+
+func newTypeOps[T NumericTypes]() *TypeOps {
+	return &TypeOps{
+		MakeSlice: func(size int) any {
+			return make([]T, size)
+		},
+		MapBytes: func(data []byte, count int) any {
+			return bits.MapBytesToArray[T](data, count)
+		},
+		Bytes: func(array any) []byte {
+			return bits.ArrayToBytes(array.([]T))
+		},
+		write: writeTypedArray[T],
+	}
+}
+
+func (f FieldType) Ops() (*TypeOps, error) {
+	if int(f) >= len(fieldTypeOps) || fieldTypeOps[f] == nil {
+		return nil, fmt.Errorf("unsupported field type %d", uint8(f))
+	}
+	return fieldTypeOps[f], nil
+}
+
+func (f FieldType) IsFloat() bool {
+	return f == Float32FieldType || f == Float64FieldType
+}
+
+func (f FieldType) IsSigned() bool {
+	switch f {
+	case Int8FieldType, Int16FieldType, Int32FieldType, Int64FieldType, TimestampFieldType:
+		return true
+	default:
+		return false
+	}
+}